@@ -0,0 +1,68 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/noman/todo-application/models"
+)
+
+// Register creates an account and stores its token
+func (c *Client) Register(ctx context.Context, username, email, password string) error {
+	var resp models.TokenResponse
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/api/auth/register",
+		body:   models.RegisterRequest{Username: username, Email: email, Password: password},
+		public: true,
+	}, &resp)
+	if err != nil {
+		return err
+	}
+	return c.tokens.SetToken(resp.Token)
+}
+
+// Login logs in and stores the token. The credentials are remembered for the
+// life of the client, so it can log in again when the token expires.
+func (c *Client) Login(ctx context.Context, email, password string) error {
+	if err := c.login(ctx, email, password); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.email, c.password = email, password
+	c.mu.Unlock()
+	return nil
+}
+
+// login logs in and stores the token
+func (c *Client) login(ctx context.Context, email, password string) error {
+	var resp models.TokenResponse
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/api/auth/login",
+		body:   models.LoginRequest{Email: email, Password: password},
+		public: true,
+	}, &resp)
+	if err != nil {
+		return err
+	}
+	return c.tokens.SetToken(resp.Token)
+}
+
+// Logout revokes the token and removes it from the token store
+func (c *Client) Logout(ctx context.Context) error {
+	c.mu.Lock()
+	c.email, c.password = "", ""
+	c.mu.Unlock()
+
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/api/auth/logout"}, nil); err != nil {
+		return err
+	}
+	return c.tokens.SetToken("")
+}
+
+// Token returns the stored bearer token, or "" if the client isn't logged in
+func (c *Client) Token() (string, error) {
+	return c.tokens.Token()
+}
@@ -0,0 +1,309 @@
+// Package client is a typed Go client for the todo application API.
+//
+// Requests take a context, retry transient failures with exponential backoff
+// and return *Error for API errors, which can be matched against the sentinel
+// errors of this package with errors.Is:
+//
+//	c, err := client.New("http://localhost:8080")
+//	if err := c.Login(ctx, "me@example.com", "secret"); err != nil { ... }
+//	todo, err := c.CreateTodo(ctx, models.CreateTodoRequest{Title: "Write docs"})
+//	if errors.Is(err, client.ErrValidation) { ... }
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/noman/todo-application/problem"
+)
+
+// Client talks to the API. It is safe for concurrent use.
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+	tokens     TokenStore
+	maxRetries int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+
+	// Credentials remembered by Login, used to log in again when the token expires
+	mu       sync.Mutex
+	email    string
+	password string
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client used to send requests
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithTokenStore sets where the bearer token is kept; the default keeps it in memory
+func WithTokenStore(tokens TokenStore) Option {
+	return func(c *Client) {
+		c.tokens = tokens
+	}
+}
+
+// WithRetries sets how many times a failed request is retried; 0 disables retries
+func WithRetries(maxRetries int) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+	}
+}
+
+// WithBackoff sets the delay before the first retry and the longest delay
+// between retries
+func WithBackoff(min, max time.Duration) Option {
+	return func(c *Client) {
+		c.minBackoff = min
+		c.maxBackoff = max
+	}
+}
+
+// New creates a client for the API at baseURL, e.g. "http://localhost:8080"
+func New(baseURL string, opts ...Option) (*Client, error) {
+	parsed, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL: %w", err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return nil, fmt.Errorf("invalid base URL %q", baseURL)
+	}
+
+	c := &Client{
+		baseURL:    parsed,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		tokens:     &MemoryTokenStore{},
+		maxRetries: 3,
+		minBackoff: 200 * time.Millisecond,
+		maxBackoff: 5 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// request describes an API request
+type request struct {
+	method      string
+	path        string
+	query       url.Values
+	header      http.Header
+	body        interface{}  // Encoded as JSON unless it's []byte
+	contentType string       // Defaults to application/json when there is a body
+	public      bool         // Sent without a bearer token
+	accept      []int        // Error statuses whose body is decoded into out like a success
+	respHeader  *http.Header // Set to the headers of the final response
+}
+
+// do sends a request, retrying transient failures, and decodes the JSON
+// response into out if it isn't nil
+func (c *Client) do(ctx context.Context, req request, out interface{}) error {
+	var body []byte
+	if req.body != nil {
+		if raw, ok := req.body.([]byte); ok {
+			body = raw
+		} else {
+			encoded, err := json.Marshal(req.body)
+			if err != nil {
+				return err
+			}
+			body = encoded
+		}
+		if req.contentType == "" {
+			req.contentType = "application/json"
+		}
+	}
+
+	header := http.Header{}
+	for name, values := range req.header {
+		header[name] = values
+	}
+
+	// Authenticated changes carry an idempotency key, so they can be retried
+	// without being applied twice
+	retryable := req.method == http.MethodGet || req.method == http.MethodPut || req.method == http.MethodDelete
+	if !req.public && req.method != http.MethodGet && header.Get("Idempotency-Key") == "" {
+		header.Set("Idempotency-Key", uuid.NewString())
+		retryable = true
+	}
+
+	reloggedIn := false
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, req, header, body)
+		if err != nil {
+			if ctx.Err() != nil || !retryable || attempt >= c.maxRetries {
+				return err
+			}
+			if err := c.wait(ctx, attempt, nil); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Log in again once if the token has expired
+		if resp.StatusCode == http.StatusUnauthorized && !req.public && !reloggedIn {
+			relogged, err := c.relogin(ctx)
+			if err != nil {
+				resp.Body.Close()
+				return err
+			}
+			if relogged {
+				resp.Body.Close()
+				reloggedIn = true
+				attempt--
+				continue
+			}
+		}
+
+		if retryable && attempt < c.maxRetries && isTransient(resp.StatusCode) {
+			resp.Body.Close()
+			if err := c.wait(ctx, attempt, resp); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if req.respHeader != nil {
+			*req.respHeader = resp.Header
+		}
+		return decodeResponse(resp, out, req.accept)
+	}
+}
+
+// send sends a single HTTP request
+func (c *Client) send(ctx context.Context, req request, header http.Header, body []byte) (*http.Response, error) {
+	target := *c.baseURL
+	target.Path += req.path
+	target.RawQuery = req.query.Encode()
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, target.String(), reader)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, values := range header {
+		httpReq.Header[name] = values
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	if body != nil {
+		httpReq.Header.Set("Content-Type", req.contentType)
+	}
+	if !req.public {
+		token, err := c.tokens.Token()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read token: %w", err)
+		}
+		if token != "" {
+			httpReq.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+
+	return c.httpClient.Do(httpReq)
+}
+
+// isTransient checks if a status code is worth retrying
+func isTransient(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// wait sleeps before a retry, for as long as the response's Retry-After header
+// asks or with exponential backoff and full jitter otherwise
+func (c *Client) wait(ctx context.Context, attempt int, resp *http.Response) error {
+	delay := c.maxBackoff
+	if shift := uint(attempt); shift < 30 && c.minBackoff<<shift < c.maxBackoff {
+		delay = c.minBackoff << shift
+	}
+	delay = time.Duration(rand.Int64N(int64(delay) + 1))
+
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			delay = time.Duration(seconds) * time.Second
+		}
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// decodeResponse closes a response after decoding its JSON body into out, or
+// into an *Error for error statuses other than the accepted ones
+func decodeResponse(resp *http.Response, out interface{}, accept []int) error {
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest && !slices.Contains(accept, resp.StatusCode) {
+		apiErr := &Error{}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+		if mediaType != problem.MediaType || json.Unmarshal(data, &apiErr.Problem) != nil {
+			apiErr.Detail = strings.TrimSpace(string(data))
+		}
+		apiErr.Status = resp.StatusCode
+		if apiErr.RequestID == "" {
+			apiErr.RequestID = resp.Header.Get(problem.RequestIDHeader)
+		}
+		return apiErr
+	}
+
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// relogin logs in again with the credentials remembered by Login, and reports
+// false if there are none
+func (c *Client) relogin(ctx context.Context) (bool, error) {
+	c.mu.Lock()
+	email, password := c.email, c.password
+	c.mu.Unlock()
+
+	if email == "" {
+		return false, nil
+	}
+	if err := c.login(ctx, email, password); err != nil {
+		if errors.Is(err, ErrUnauthorized) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,54 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/noman/todo-application/problem"
+)
+
+// Sentinel errors that an *Error matches with errors.Is, based on its code
+var (
+	ErrUnauthorized    = errors.New("unauthorized")
+	ErrForbidden       = errors.New("forbidden")
+	ErrNotFound        = errors.New("not found")
+	ErrVersionConflict = errors.New("version conflict")
+	ErrValidation      = errors.New("validation failed")
+)
+
+// Error is an error response from the API
+type Error struct {
+	problem.Problem
+}
+
+// Error implements the error interface
+func (e *Error) Error() string {
+	message := fmt.Sprintf("todo api: %d %s", e.Status, e.Code)
+	if e.Detail != "" {
+		message += ": " + e.Detail
+	}
+	for _, fieldErr := range e.Errors {
+		message += "; " + fieldErr.Error()
+	}
+	if e.RequestID != "" {
+		message += " (request " + e.RequestID + ")"
+	}
+	return message
+}
+
+// Is matches the sentinel errors of this package
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.Code == problem.CodeUnauthorized
+	case ErrForbidden:
+		return e.Code == problem.CodeForbidden
+	case ErrNotFound:
+		return e.Code == problem.CodeNotFound
+	case ErrVersionConflict:
+		return e.Code == problem.CodeVersionConflict
+	case ErrValidation:
+		return e.Code == problem.CodeValidationFailed
+	}
+	return false
+}
@@ -0,0 +1,207 @@
+package client
+
+import (
+	"context"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/noman/todo-application/models"
+	"github.com/noman/todo-application/patch"
+)
+
+// ListTodosOptions narrows which todos are listed
+type ListTodosOptions struct {
+	WorkspaceID *uuid.UUID // List a workspace's todos instead of personal todos
+	View        string     // One of the models.TodoView constants
+	Filter      string     // A filter expression, like completed:false AND created<7d
+	PageSize    int        // Todos fetched per request by Todos, at most 100; defaults to 100
+}
+
+// query encodes the options as query parameters
+func (o ListTodosOptions) query() url.Values {
+	query := url.Values{}
+	if o.WorkspaceID != nil {
+		query.Set("workspace_id", o.WorkspaceID.String())
+	}
+	if o.View != "" {
+		query.Set("view", o.View)
+	}
+	if o.Filter != "" {
+		query.Set("filter", o.Filter)
+	}
+	return query
+}
+
+// todoPath returns the path of a todo, or of one of its sub-resources
+func todoPath(id uuid.UUID, sub string) string {
+	return "/api/todos/" + id.String() + sub
+}
+
+// ifMatch returns an If-Match header for a todo's ETag, or no header for ""
+func ifMatch(etag string) http.Header {
+	if etag == "" {
+		return nil
+	}
+	return http.Header{"If-Match": {etag}}
+}
+
+// ListTodos lists every matching todo, newest first, in a single request
+func (c *Client) ListTodos(ctx context.Context, opts ListTodosOptions) ([]models.TodoResponse, error) {
+	var todos []models.TodoResponse
+	err := c.do(ctx, request{method: http.MethodGet, path: "/api/todos", query: opts.query()}, &todos)
+	return todos, err
+}
+
+// Todos iterates over the matching todos, newest first, fetching them a page
+// at a time as the loop needs them. Iteration stops after the first error.
+//
+//	for todo, err := range c.Todos(ctx, client.ListTodosOptions{}) {
+//		if err != nil { ... }
+//	}
+func (c *Client) Todos(ctx context.Context, opts ListTodosOptions) iter.Seq2[models.TodoResponse, error] {
+	return func(yield func(models.TodoResponse, error) bool) {
+		pageSize := opts.PageSize
+		if pageSize <= 0 {
+			pageSize = 100
+		}
+		query := opts.query()
+		query.Set("limit", strconv.Itoa(pageSize))
+
+		for {
+			var todos []models.TodoResponse
+			var header http.Header
+			err := c.do(ctx, request{method: http.MethodGet, path: "/api/todos", query: query, respHeader: &header}, &todos)
+			if err != nil {
+				yield(models.TodoResponse{}, err)
+				return
+			}
+			for _, todo := range todos {
+				if !yield(todo, nil) {
+					return
+				}
+			}
+
+			cursor := nextCursor(header)
+			if cursor == "" {
+				return
+			}
+			query.Set("cursor", cursor)
+		}
+	}
+}
+
+// nextCursor returns the cursor of the page a Link header's next link points
+// to, or "" on the last page
+func nextCursor(header http.Header) string {
+	for _, value := range header.Values("Link") {
+		for _, link := range strings.Split(value, ",") {
+			target, params, _ := strings.Cut(strings.TrimSpace(link), ";")
+			if !strings.Contains(strings.ReplaceAll(params, " ", ""), `rel="next"`) {
+				continue
+			}
+			next, err := url.Parse(strings.Trim(target, "<>"))
+			if err != nil {
+				return ""
+			}
+			return next.Query().Get("cursor")
+		}
+	}
+	return ""
+}
+
+// GetTodo gets a todo
+func (c *Client) GetTodo(ctx context.Context, id uuid.UUID) (*models.TodoResponse, error) {
+	var todo models.TodoResponse
+	if err := c.do(ctx, request{method: http.MethodGet, path: todoPath(id, "")}, &todo); err != nil {
+		return nil, err
+	}
+	return &todo, nil
+}
+
+// CreateTodo creates a todo
+func (c *Client) CreateTodo(ctx context.Context, req models.CreateTodoRequest) (*models.TodoResponse, error) {
+	var todo models.TodoResponse
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/api/todos", body: req}, &todo); err != nil {
+		return nil, err
+	}
+	return &todo, nil
+}
+
+// ReplaceTodo replaces a todo's editable fields. If etag isn't empty the change
+// is only applied if the todo still has that ETag, as returned by
+// TodoResponse.ETag; otherwise the error matches ErrVersionConflict.
+func (c *Client) ReplaceTodo(ctx context.Context, id uuid.UUID, doc models.UpdateTodoRequest, etag string) (*models.TodoResponse, error) {
+	var todo models.TodoResponse
+	err := c.do(ctx, request{
+		method: http.MethodPut,
+		path:   todoPath(id, ""),
+		header: ifMatch(etag),
+		body:   doc,
+	}, &todo)
+	if err != nil {
+		return nil, err
+	}
+	return &todo, nil
+}
+
+// PatchTodo applies a JSON Merge Patch, such as map[string]interface{}{"completed": true},
+// to a todo. The etag works as in ReplaceTodo.
+func (c *Client) PatchTodo(ctx context.Context, id uuid.UUID, mergePatch interface{}, etag string) (*models.TodoResponse, error) {
+	var todo models.TodoResponse
+	err := c.do(ctx, request{
+		method:      http.MethodPatch,
+		path:        todoPath(id, ""),
+		header:      ifMatch(etag),
+		body:        mergePatch,
+		contentType: patch.MergePatchMediaType,
+	}, &todo)
+	if err != nil {
+		return nil, err
+	}
+	return &todo, nil
+}
+
+// CompleteTodo marks a todo as completed or not. The etag works as in ReplaceTodo.
+func (c *Client) CompleteTodo(ctx context.Context, id uuid.UUID, completed bool, etag string) (*models.TodoResponse, error) {
+	return c.PatchTodo(ctx, id, map[string]bool{"completed": completed}, etag)
+}
+
+// AssignTodo replaces the assignees of a todo. The etag works as in ReplaceTodo.
+func (c *Client) AssignTodo(ctx context.Context, id uuid.UUID, assigneeIDs []uuid.UUID, etag string) (*models.TodoResponse, error) {
+	var todo models.TodoResponse
+	err := c.do(ctx, request{
+		method: http.MethodPut,
+		path:   todoPath(id, "/assignees"),
+		header: ifMatch(etag),
+		body:   models.AssignTodoRequest{AssigneeIDs: assigneeIDs},
+	}, &todo)
+	if err != nil {
+		return nil, err
+	}
+	return &todo, nil
+}
+
+// DeleteTodo moves a todo to the trash. The etag works as in ReplaceTodo.
+func (c *Client) DeleteTodo(ctx context.Context, id uuid.UUID, etag string) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: todoPath(id, ""), header: ifMatch(etag)}, nil)
+}
+
+// Batch runs several todo operations in one transaction. A failed atomic batch
+// isn't an error: check the response's Committed field and each result's Status.
+func (c *Client) Batch(ctx context.Context, req models.BatchRequest) (*models.BatchResponse, error) {
+	var resp models.BatchResponse
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/api/todos/batch",
+		body:   req,
+		accept: []int{http.StatusUnprocessableEntity},
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
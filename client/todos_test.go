@@ -0,0 +1,25 @@
+package client
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestNextCursor(t *testing.T) {
+	tests := []struct {
+		links []string
+		want  string
+	}{
+		{nil, ""},
+		{[]string{`</api/todos?limit=2&cursor=1715900000000000.abc>; rel="next"`}, "1715900000000000.abc"},
+		{[]string{`</api/todos?cursor=a>; rel="prev", </api/todos?cursor=b%2Bc>; rel = "next"`}, "b+c"},
+		{[]string{`</api/todos?cursor=a>; rel="prev"`, `</api/todos?cursor=b>; rel="next"`}, "b"},
+		{[]string{`</api/todos?cursor=a>; rel="prev"`}, ""},
+	}
+	for _, tt := range tests {
+		header := http.Header{"Link": tt.links}
+		if got := nextCursor(header); got != tt.want {
+			t.Errorf("nextCursor(%q) = %q, want %q", tt.links, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package client
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// TokenStore keeps the bearer token between requests, and between runs for
+// stores that persist it
+type TokenStore interface {
+	// Token returns the stored token, or "" if there is none
+	Token() (string, error)
+	// SetToken stores a token; "" removes it
+	SetToken(token string) error
+}
+
+// MemoryTokenStore keeps the token in memory
+type MemoryTokenStore struct {
+	mu    sync.Mutex
+	token string
+}
+
+// Token returns the stored token
+func (s *MemoryTokenStore) Token() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.token, nil
+}
+
+// SetToken stores a token
+func (s *MemoryTokenStore) SetToken(token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.token = token
+	return nil
+}
+
+// FileTokenStore keeps the token in a file that only the user can read
+type FileTokenStore struct {
+	Path string
+}
+
+// Token reads the token from the file
+func (s *FileTokenStore) Token() (string, error) {
+	data, err := os.ReadFile(s.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// SetToken writes the token to the file, or removes the file for ""
+func (s *FileTokenStore) SetToken(token string) error {
+	if token == "" {
+		err := os.Remove(s.Path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(s.Path, []byte(token+"\n"), 0o600)
+}
@@ -0,0 +1,520 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/noman/todo-application/client"
+	"github.com/noman/todo-application/database/databasetest"
+	"github.com/noman/todo-application/events"
+	"github.com/noman/todo-application/middleware"
+	"github.com/noman/todo-application/models"
+	"github.com/noman/todo-application/problem"
+)
+
+var initBroker sync.Once
+
+// newAPIClient serves the API's routes, behind wrap if it isn't nil, and
+// creates a client for them that retries quickly. Tests that need a
+// database call databasetest.Open first.
+func newAPIClient(t *testing.T, wrap func(http.Handler) http.Handler, opts ...client.Option) *client.Client {
+	t.Helper()
+	t.Setenv("JWT_SECRET", "client-test-secret")
+	initBroker.Do(events.InitBroker)
+
+	var handler http.Handler = middleware.RequestIDMiddleware(newRouter())
+	if wrap != nil {
+		handler = wrap(handler)
+	}
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	opts = append([]client.Option{client.WithBackoff(time.Millisecond, 2*time.Millisecond)}, opts...)
+	c, err := client.New(server.URL, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// failFirst answers the first n requests with status, as a proxy in front of
+// the API would, and passes the rest to the API
+func failFirst(n int32, status int, requests *atomic.Int32) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if requests.Add(1) <= n {
+				problem.Error(w, status, problem.CodeInternal, "Try again")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// countPaths counts the requests for each path
+func countPaths(counts *sync.Map) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n, _ := counts.LoadOrStore(r.URL.Path, new(atomic.Int32))
+			n.(*atomic.Int32).Add(1)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// pathCount returns how many requests countPaths saw for a path
+func pathCount(counts *sync.Map, path string) int32 {
+	n, ok := counts.Load(path)
+	if !ok {
+		return 0
+	}
+	return n.(*atomic.Int32).Load()
+}
+
+func TestClientTodos(t *testing.T) {
+	databasetest.Open(t)
+	user := databasetest.NewUser(t)
+	counts := &sync.Map{}
+	c := newAPIClient(t, countPaths(counts))
+	ctx := context.Background()
+
+	if err := c.Login(ctx, user.Email, databasetest.Password); err != nil {
+		t.Fatal(err)
+	}
+
+	// Create, then change a todo
+	todo, err := c.CreateTodo(ctx, models.CreateTodoRequest{Title: "Write docs", Tags: []string{"work"}, Priority: models.PriorityHigh})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if todo.Title != "Write docs" || todo.Priority != models.PriorityHigh || len(todo.Tags) != 1 {
+		t.Errorf("created %+v", todo)
+	}
+	completed, err := c.CompleteTodo(ctx, todo.ID, true, todo.ETag())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !completed.Completed {
+		t.Errorf("CompleteTodo returned %+v", completed)
+	}
+
+	// The first ETag is stale now
+	stale := "Stale"
+	_, err = c.ReplaceTodo(ctx, todo.ID, models.UpdateTodoRequest{Title: &stale}, todo.ETag())
+	if !errors.Is(err, client.ErrVersionConflict) {
+		t.Errorf("replacing with a stale ETag: got %v, want ErrVersionConflict", err)
+	}
+
+	got, err := c.GetTodo(ctx, todo.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != "Write docs" || !got.Completed {
+		t.Errorf("GetTodo = %+v", got)
+	}
+
+	if err := c.DeleteTodo(ctx, todo.ID, got.ETag()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetTodo(ctx, todo.ID); !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("getting a deleted todo: got %v, want ErrNotFound", err)
+	}
+
+	// A batch that fails is rolled back, but isn't an error
+	batch, err := c.Batch(ctx, models.BatchRequest{
+		Mode: models.BatchModeAtomic,
+		Operations: []models.BatchOperation{
+			{Op: models.BatchOpCreate, Todo: json.RawMessage(`{"title": "In the batch"}`)},
+			{Op: models.BatchOpDelete, ID: uuidPtr(uuid.New())},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if batch.Committed {
+		t.Errorf("batch with a missing todo committed: %+v", batch)
+	}
+	if todos, err := c.ListTodos(ctx, client.ListTodosOptions{}); err != nil || len(todos) != 0 {
+		t.Errorf("ListTodos after a failed batch = %v, %v; want none", todos, err)
+	}
+
+	if n := pathCount(counts, "/api/auth/login"); n != 1 {
+		t.Errorf("logged in %d times, want 1", n)
+	}
+}
+
+func TestClientTodosIteratorPages(t *testing.T) {
+	databasetest.Open(t)
+	user := databasetest.NewUser(t)
+	counts := &sync.Map{}
+	c := newAPIClient(t, countPaths(counts))
+	ctx := context.Background()
+
+	if err := c.Login(ctx, user.Email, databasetest.Password); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if _, err := c.CreateTodo(ctx, models.CreateTodoRequest{Title: "Todo " + string(rune('A'+i))}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	all, err := c.ListTodos(ctx, client.ListTodosOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 5 {
+		t.Fatalf("ListTodos returned %d todos, want 5", len(all))
+	}
+	before := pathCount(counts, "/api/todos")
+
+	// Pages of two take three requests and list the todos in the same order
+	var paged []uuid.UUID
+	for todo, err := range c.Todos(ctx, client.ListTodosOptions{PageSize: 2}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		paged = append(paged, todo.ID)
+	}
+	if len(paged) != len(all) {
+		t.Fatalf("Todos returned %d todos, want %d", len(paged), len(all))
+	}
+	for i := range all {
+		if paged[i] != all[i].ID {
+			t.Errorf("todo %d is %s, want %s", i, paged[i], all[i].ID)
+		}
+	}
+	if n := pathCount(counts, "/api/todos") - before; n != 3 {
+		t.Errorf("Todos sent %d requests, want 3", n)
+	}
+
+	// Pages are only fetched as the loop needs them
+	before = pathCount(counts, "/api/todos")
+	seen := 0
+	for _, err := range c.Todos(ctx, client.ListTodosOptions{PageSize: 2}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seen++; seen == 2 {
+			break
+		}
+	}
+	if n := pathCount(counts, "/api/todos") - before; n != 1 {
+		t.Errorf("stopping after the first page sent %d requests, want 1", n)
+	}
+
+	// Filters apply to every page
+	if _, err := c.CompleteTodo(ctx, all[0].ID, true, all[0].ETag()); err != nil {
+		t.Fatal(err)
+	}
+	open := 0
+	for todo, err := range c.Todos(ctx, client.ListTodosOptions{PageSize: 2, Filter: "completed:false"}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if todo.Completed {
+			t.Errorf("filtered list has completed todo %s", todo.ID)
+		}
+		open++
+	}
+	if open != 4 {
+		t.Errorf("listed %d open todos, want 4", open)
+	}
+
+	// Page sizes past the API's limit are rejected
+	for _, err := range c.Todos(ctx, client.ListTodosOptions{PageSize: 101}) {
+		var apiErr *client.Error
+		if !errors.As(err, &apiErr) || apiErr.Status != http.StatusBadRequest {
+			t.Errorf("page size 101: got %v, want a 400 error", err)
+		}
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	databasetest.Open(t)
+	user := databasetest.NewUser(t)
+	c := newAPIClient(t, nil)
+	ctx := context.Background()
+
+	if err := c.Login(ctx, user.Email, "wrong password"); !errors.Is(err, client.ErrUnauthorized) {
+		t.Fatalf("login with a wrong password: got %v, want ErrUnauthorized", err)
+	}
+	if err := c.Login(ctx, user.Email, databasetest.Password); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := c.CreateTodo(ctx, models.CreateTodoRequest{Title: ""})
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || !errors.Is(err, client.ErrValidation) {
+		t.Fatalf("creating a todo without a title: got %v, want ErrValidation", err)
+	}
+	if len(apiErr.Errors) == 0 || apiErr.Errors[0].Field != "title" || apiErr.RequestID == "" {
+		t.Errorf("got %+v", apiErr.Problem)
+	}
+
+	workspaceID := uuid.New()
+	_, err = c.ListTodos(ctx, client.ListTodosOptions{WorkspaceID: &workspaceID})
+	if !errors.Is(err, client.ErrForbidden) || errors.Is(err, client.ErrNotFound) {
+		t.Errorf("listing another workspace's todos: got %v, want ErrForbidden", err)
+	}
+}
+
+func TestClientLogsInAgainWhenTheTokenExpires(t *testing.T) {
+	databasetest.Open(t)
+	user := databasetest.NewUser(t)
+	counts := &sync.Map{}
+	tokens := &client.FileTokenStore{Path: filepath.Join(t.TempDir(), "config", "token")}
+	c := newAPIClient(t, countPaths(counts), client.WithTokenStore(tokens))
+	ctx := context.Background()
+
+	if err := c.Login(ctx, user.Email, databasetest.Password); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(tokens.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("token file has mode %v, want 0600", info.Mode().Perm())
+	}
+
+	// A token the API no longer accepts
+	if err := tokens.SetToken("expired"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ListTodos(ctx, client.ListTodosOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if n := pathCount(counts, "/api/auth/login"); n != 2 {
+		t.Errorf("logged in %d times, want 2", n)
+	}
+
+	// The new token is read back from the file by a new store
+	if token, _ := (&client.FileTokenStore{Path: tokens.Path}).Token(); token == "" || token == "expired" {
+		t.Errorf("got stored token %q, want the new token", token)
+	}
+
+	// Without remembered credentials a rejected token is an error
+	if err := c.Logout(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ListTodos(ctx, client.ListTodosOptions{}); !errors.Is(err, client.ErrUnauthorized) {
+		t.Errorf("after logging out: got %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestClientUnauthorizedIsNotRetried(t *testing.T) {
+	var requests atomic.Int32
+	c := newAPIClient(t, failFirst(0, 0, &requests))
+
+	_, err := c.GetTodo(context.Background(), uuid.New())
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || !errors.Is(err, client.ErrUnauthorized) {
+		t.Fatalf("got %v, want ErrUnauthorized", err)
+	}
+	if apiErr.RequestID == "" {
+		t.Errorf("error has no request ID: %+v", apiErr.Problem)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("sent %d requests, want 1", n)
+	}
+}
+
+func TestClientRetriesTransientFailures(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int32
+		retries  int
+		requests int32
+		status   int // The status the client gives up with
+	}{
+		{"recovers", 3, 3, 4, http.StatusUnauthorized},
+		{"gives up", 5, 2, 3, http.StatusServiceUnavailable},
+		{"no retries", 1, 0, 1, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Without a token, the API itself answers 401 once the failures stop
+			var requests atomic.Int32
+			c := newAPIClient(t, failFirst(tt.failures, http.StatusServiceUnavailable, &requests), client.WithRetries(tt.retries))
+
+			_, err := c.ListTodos(context.Background(), client.ListTodosOptions{})
+			var apiErr *client.Error
+			if !errors.As(err, &apiErr) || apiErr.Status != tt.status {
+				t.Fatalf("got %v, want a %d error", err, tt.status)
+			}
+			if n := requests.Load(); n != tt.requests {
+				t.Errorf("sent %d requests, want %d", n, tt.requests)
+			}
+		})
+	}
+}
+
+func TestClientRetryHonoursRetryAfter(t *testing.T) {
+	var requests atomic.Int32
+	c := newAPIClient(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if requests.Add(1) == 1 {
+				w.Header().Set("Retry-After", "1")
+				problem.Error(w, http.StatusTooManyRequests, problem.CodeInternal, "Slow down")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}, client.WithBackoff(time.Hour, time.Hour)) // Backing off would take far longer than asked
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := c.ListTodos(ctx, client.ListTodosOptions{}); !errors.Is(err, client.ErrUnauthorized) {
+		t.Fatalf("got %v, want the API's ErrUnauthorized", err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %v, before Retry-After", elapsed)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("sent %d requests, want 2", n)
+	}
+}
+
+func TestClientRetriesReuseIdempotencyKey(t *testing.T) {
+	var mu sync.Mutex
+	keys := []string{}
+	c := newAPIClient(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			keys = append(keys, r.Header.Get("Idempotency-Key"))
+			attempt := len(keys)
+			mu.Unlock()
+
+			// The first two requests of each call fail
+			if attempt%3 != 0 {
+				problem.Error(w, http.StatusBadGateway, problem.CodeInternal, "Bad gateway")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.CreateTodo(ctx, models.CreateTodoRequest{Title: "Write docs"}); !errors.Is(err, client.ErrUnauthorized) {
+			t.Fatalf("got %v, want the API's ErrUnauthorized", err)
+		}
+	}
+
+	if len(keys) != 6 {
+		t.Fatalf("sent %d requests, want 6", len(keys))
+	}
+	if keys[0] == "" {
+		t.Fatal("sent no Idempotency-Key")
+	}
+	if keys[0] != keys[1] || keys[1] != keys[2] {
+		t.Errorf("retries used different keys %q", keys[:3])
+	}
+	if keys[3] != keys[4] || keys[4] != keys[5] {
+		t.Errorf("retries used different keys %q", keys[3:])
+	}
+	if keys[0] == keys[3] {
+		t.Errorf("separate requests used the same key %q", keys[0])
+	}
+}
+
+func TestClientPublicPostIsNotRetried(t *testing.T) {
+	var requests atomic.Int32
+	c := newAPIClient(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			if r.Header.Get("Idempotency-Key") != "" {
+				t.Errorf("public request sent an Idempotency-Key")
+			}
+			problem.Error(w, http.StatusServiceUnavailable, problem.CodeInternal, "Try again")
+		})
+	})
+
+	if err := c.Register(context.Background(), "me", "me@example.com", "secret"); err == nil {
+		t.Fatal("got no error")
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("sent %d requests, want 1", n)
+	}
+}
+
+func TestClientKeepsNonProblemErrorBodies(t *testing.T) {
+	// A proxy in front of the API fails without a problem document
+	c := newAPIClient(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(problem.RequestIDHeader, "req-2")
+			http.Error(w, "upstream exploded", http.StatusInternalServerError)
+		})
+	})
+
+	_, err := c.GetTodo(context.Background(), uuid.New())
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("got %T %v, want *client.Error", err, err)
+	}
+	if apiErr.Status != http.StatusInternalServerError || apiErr.Detail != "upstream exploded" || apiErr.RequestID != "req-2" {
+		t.Errorf("got %+v", apiErr.Problem)
+	}
+	if !strings.Contains(err.Error(), "upstream exploded") {
+		t.Errorf("Error() = %q", err.Error())
+	}
+}
+
+func TestClientContextCancellation(t *testing.T) {
+	t.Run("during a request", func(t *testing.T) {
+		c := newAPIClient(t, func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				<-r.Context().Done()
+			})
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+
+		_, err := c.ListTodos(ctx, client.ListTodosOptions{})
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("got %v, want context.Canceled", err)
+		}
+	})
+
+	t.Run("while waiting to retry", func(t *testing.T) {
+		var requests atomic.Int32
+		c := newAPIClient(t, func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+				w.Header().Set("Retry-After", "60")
+				problem.Error(w, http.StatusServiceUnavailable, problem.CodeInternal, "Try again")
+			})
+		})
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		_, err := c.ListTodos(ctx, client.ListTodosOptions{})
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("got %v, want context.DeadlineExceeded", err)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("returned after %v", elapsed)
+		}
+		if n := requests.Load(); n != 1 {
+			t.Errorf("sent %d requests, want 1", n)
+		}
+	})
+}
+
+func uuidPtr(id uuid.UUID) *uuid.UUID {
+	return &id
+}
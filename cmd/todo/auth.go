@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// runLogin handles "todo login"
+func runLogin(ctx context.Context, args []string) error {
+	fs := newFlagSet("login")
+	server := fs.String("server", "", "API URL, remembered for later commands")
+	passwordStdin := fs.Bool("password-stdin", false, "read the password from stdin")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := argCount(fs, args, 1, 1); err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if *server != "" {
+		cfg.Server = *server
+	}
+	c, err := newClient(cfg)
+	if err != nil {
+		return err
+	}
+
+	password, err := readPassword("Password: ", *passwordStdin)
+	if err != nil {
+		return err
+	}
+	if err := c.Login(ctx, args[0], password); err != nil {
+		return err
+	}
+
+	cfg.Email = args[0]
+	if err := cfg.save(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Logged in to %s as %s\n", cfg.server(), args[0])
+	return nil
+}
+
+// runRegister handles "todo register"
+func runRegister(ctx context.Context, args []string) error {
+	fs := newFlagSet("register")
+	server := fs.String("server", "", "API URL, remembered for later commands")
+	passwordStdin := fs.Bool("password-stdin", false, "read the password from stdin")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := argCount(fs, args, 2, 2); err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if *server != "" {
+		cfg.Server = *server
+	}
+	c, err := newClient(cfg)
+	if err != nil {
+		return err
+	}
+
+	password, err := readPassword("Password: ", *passwordStdin)
+	if err != nil {
+		return err
+	}
+	if err := c.Register(ctx, args[0], args[1], password); err != nil {
+		return err
+	}
+
+	cfg.Email = args[1]
+	if err := cfg.save(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Registered and logged in to %s as %s\n", cfg.server(), args[1])
+	return nil
+}
+
+// runLogout handles "todo logout"
+func runLogout(ctx context.Context, args []string) error {
+	fs := newFlagSet("logout")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := argCount(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	c, err := loggedInClient()
+	if err != nil {
+		return err
+	}
+	if err := c.Logout(ctx); err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "Logged out")
+	return nil
+}
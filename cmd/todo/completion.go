@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// runCompletion handles "todo completion"
+func runCompletion(ctx context.Context, args []string) error {
+	fs := newFlagSet("completion")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := argCount(fs, args, 1, 1); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	words := strings.Join(names, " ")
+
+	switch args[0] {
+	case "bash":
+		fmt.Printf(bashCompletion, words)
+	case "zsh":
+		fmt.Printf(zshCompletion, words)
+	case "fish":
+		fmt.Printf(fishCompletion, words)
+	default:
+		return fmt.Errorf("unsupported shell %q; use bash, zsh or fish", args[0])
+	}
+	return nil
+}
+
+// Completion scripts complete command names, and the flags of a command by
+// scraping its --help output
+const bashCompletion = `# bash completion for todo; add to ~/.bashrc:
+#   source <(todo completion bash)
+_todo() {
+    local cur=${COMP_WORDS[COMP_CWORD]}
+    if [ "$COMP_CWORD" -eq 1 ]; then
+        COMPREPLY=($(compgen -W "%s" -- "$cur"))
+    elif [[ $cur == -* ]]; then
+        local flags=$(todo "${COMP_WORDS[1]}" --help 2>&1 | grep -oE '^  -[a-z-]+' | sed -E 's/^  -([a-z])$/-\1/; s/^  -/--/')
+        COMPREPLY=($(compgen -W "$flags" -- "$cur"))
+    fi
+}
+complete -o default -F _todo todo
+`
+
+const zshCompletion = `#compdef todo
+# zsh completion for todo; add to ~/.zshrc:
+#   source <(todo completion zsh)
+_todo() {
+    if (( CURRENT == 2 )); then
+        compadd -- %s
+    elif [[ $PREFIX == -* ]]; then
+        compadd -- $(todo $words[2] --help 2>&1 | grep -oE '^  -[a-z-]+' | sed -E 's/^  -([a-z])$/-\1/; s/^  -/--/')
+    else
+        _files
+    fi
+}
+compdef _todo todo
+`
+
+const fishCompletion = `# fish completion for todo; add to ~/.config/fish/completions/todo.fish:
+#   todo completion fish > ~/.config/fish/completions/todo.fish
+complete -c todo -f -n '__fish_use_subcommand' -a '%s'
+complete -c todo -f -n 'not __fish_use_subcommand; and string match -q -- "-*" (commandline -ct)' -a '(todo (commandline -opc)[2] --help 2>&1 | string match -r "^  -[a-z-]+" | string replace -r "^  -(?=..)" "--" | string replace -r "^  -" "-")'
+`
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/noman/todo-application/client"
+)
+
+// defaultServer is the API used until another one is given to login
+const defaultServer = "http://localhost:8080"
+
+// errNotLoggedIn is returned by commands that need a token when there is none
+var errNotLoggedIn = errors.New("not logged in")
+
+// config is what the CLI remembers between runs, besides the token
+type config struct {
+	Server string `json:"server"`
+	Email  string `json:"email,omitempty"`
+}
+
+// configDir returns the directory the CLI keeps its files in, which is
+// $TODO_CONFIG_DIR or "todo" in the user config directory
+func configDir() (string, error) {
+	if dir := os.Getenv("TODO_CONFIG_DIR"); dir != "" {
+		return dir, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "todo"), nil
+}
+
+// loadConfig reads the config file, returning the defaults if there is none
+func loadConfig() (*config, error) {
+	cfg := &config{Server: defaultServer}
+
+	dir, err := configDir()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "config.json"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("invalid config file: %w", err)
+	}
+	return cfg, nil
+}
+
+// save writes the config file
+func (cfg *config) save() error {
+	dir, err := configDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "config.json"), append(data, '\n'), 0600)
+}
+
+// server returns the API URL, which $TODO_SERVER overrides
+func (cfg *config) server() string {
+	if server := os.Getenv("TODO_SERVER"); server != "" {
+		return server
+	}
+	return cfg.Server
+}
+
+// newClient creates an API client that keeps its token in the config directory
+func newClient(cfg *config) (*client.Client, error) {
+	dir, err := configDir()
+	if err != nil {
+		return nil, err
+	}
+	return client.New(cfg.server(), client.WithTokenStore(&client.FileTokenStore{Path: filepath.Join(dir, "token")}))
+}
+
+// loggedInClient creates an API client, failing if there is no stored token
+func loggedInClient() (*client.Client, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	c, err := newClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	token, err := c.Token()
+	if err != nil {
+		return nil, err
+	}
+	if token == "" {
+		return nil, errNotLoggedIn
+	}
+	return c, nil
+}
+
+// readPassword reads a password from stdin, prompting for it without echo
+// when stdin is a terminal
+func readPassword(prompt string, fromStdin bool) (string, error) {
+	if fromStdin || !isTerminal(os.Stdin) {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+			return "", fmt.Errorf("reading password: %w", err)
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	fmt.Fprint(os.Stderr, prompt)
+	echo := setEcho(false) == nil
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if echo {
+		setEcho(true)
+		fmt.Fprintln(os.Stderr)
+	}
+	if err != nil {
+		return "", fmt.Errorf("reading password: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// isTerminal reports whether a file is a character device, such as a terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// setEcho turns terminal echo on or off with stty
+func setEcho(on bool) error {
+	mode := "-echo"
+	if on {
+		mode = "echo"
+	}
+	cmd := exec.Command("stty", mode)
+	cmd.Stdin = os.Stdin
+	return cmd.Run()
+}
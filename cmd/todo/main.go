@@ -0,0 +1,148 @@
+// Command todo is a command-line client for the todo application API.
+//
+//	todo login --server http://localhost:8080 me@example.com
+//	todo add "Write docs" --description "Cover the CLI"
+//	todo ls --pending
+//	todo done 3f2a
+//
+// Todos can be referred to by any unique prefix of their ID.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+
+	"github.com/noman/todo-application/client"
+)
+
+// command is a todo subcommand
+type command struct {
+	usage   string // Arguments, shown after the command name
+	summary string
+	run     func(ctx context.Context, args []string) error
+}
+
+// commands are the subcommands by name
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"login":      {"[--server URL] [--password-stdin] EMAIL", "Log in and remember the token", runLogin},
+		"logout":     {"", "Log out and forget the token", runLogout},
+		"register":   {"[--server URL] [--password-stdin] USERNAME EMAIL", "Create an account and log in", runRegister},
+		"add":        {"[--description TEXT] [--workspace ID] [--project ID] [--assign ID,...] [--tags TAG,...] [--priority PRIORITY] [--due DATE] TITLE...", "Add a todo", runAdd},
+		"ls":         {"[--done | --pending] [--view all|assigned|created] [--workspace ID] [--project ID] [--search TEXT] [--filter EXPR] [-o table|json]", "List todos", runList},
+		"show":       {"[-o table|json] ID", "Show a todo", runShow},
+		"done":       {"[--undo] ID...", "Mark todos as completed, or as pending with --undo", runDone},
+		"edit":       {"[--title TEXT] [--description TEXT] [--project ID] [--tags TAG,...] [--priority PRIORITY] [--due DATE] ID", "Edit a todo, in $EDITOR unless fields are given", runEdit},
+		"rm":         {"ID...", "Move todos to the trash", runRemove},
+		"tui":        {"[--refresh DURATION] [--view all|assigned|created] [--workspace ID]", "Manage todos in a full-screen terminal UI", runTUI},
+		"completion": {"bash|zsh|fish", "Print a shell completion script", runCompletion},
+	}
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	name := flag.Arg(0)
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "todo: unknown command %q\n\n", name)
+		usage()
+		os.Exit(2)
+	}
+
+	// Cancel requests on Ctrl-C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := cmd.run(ctx, flag.Args()[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		fmt.Fprintln(os.Stderr, "todo:", err)
+		if errors.Is(err, client.ErrUnauthorized) || errors.Is(err, errNotLoggedIn) {
+			fmt.Fprintln(os.Stderr, "Run 'todo login EMAIL' to log in.")
+		}
+		os.Exit(1)
+	}
+}
+
+// usage prints the list of commands
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: todo COMMAND [ARGS]\n\nCommands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-11s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprintln(os.Stderr, "\nRun 'todo COMMAND --help' for the options of a command.")
+}
+
+// newFlagSet creates the flag set of a command
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: todo %s %s\n\n%s\n", name, commands[name].usage, commands[name].summary)
+		if hasFlags(fs) {
+			fmt.Fprintln(fs.Output(), "\nOptions:")
+			fs.PrintDefaults()
+		}
+	}
+	return fs
+}
+
+// hasFlags reports whether a flag set defines any flags
+func hasFlags(fs *flag.FlagSet) bool {
+	found := false
+	fs.VisitAll(func(*flag.Flag) { found = true })
+	return found
+}
+
+// parseArgs parses flags that may appear before, after or between the
+// positional arguments, which it returns. Everything after "--" is positional.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := fs.Args()
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+// argCount checks the number of positional arguments
+func argCount(fs *flag.FlagSet, args []string, min, max int) error {
+	if len(args) < min || (max >= 0 && len(args) > max) {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	return nil
+}
+
+// joinArgs joins positional arguments into one string, so titles don't need quoting
+func joinArgs(args []string) string {
+	return strings.TrimSpace(strings.Join(args, " "))
+}
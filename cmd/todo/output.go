@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/noman/todo-application/models"
+)
+
+// Output formats
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// outputFlag adds the -o flag that picks the output format
+func outputFlag(fs *flag.FlagSet) *string {
+	output := fs.String("o", outputTable, "output format: table or json")
+	return output
+}
+
+// printTodos prints a list of todos
+func printTodos(output string, todos []models.TodoResponse) error {
+	switch output {
+	case outputJSON:
+		return printJSON(todos)
+	case outputTable:
+		if len(todos) == 0 {
+			fmt.Fprintln(os.Stderr, "No todos")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tDONE\tTITLE\tCREATED")
+		for _, todo := range todos {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", shortID(todo), checkbox(todo.Completed), oneLine(todo.Title), todo.CreatedAt.Local().Format("2006-01-02"))
+		}
+		return w.Flush()
+	}
+	return fmt.Errorf("unknown output format %q", output)
+}
+
+// printTodo prints a single todo
+func printTodo(output string, todo *models.TodoResponse) error {
+	switch output {
+	case outputJSON:
+		return printJSON(todo)
+	case outputTable:
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "ID:\t%s\n", todo.ID)
+		fmt.Fprintf(w, "Title:\t%s\n", oneLine(todo.Title))
+		fmt.Fprintf(w, "Done:\t%s\n", checkbox(todo.Completed))
+		if todo.WorkspaceID != nil {
+			fmt.Fprintf(w, "Workspace:\t%s\n", todo.WorkspaceID)
+		}
+		if todo.ProjectID != nil {
+			fmt.Fprintf(w, "Project:\t%s\n", todo.ProjectID)
+		}
+		for _, id := range todo.AssigneeIDs {
+			fmt.Fprintf(w, "Assignee:\t%s\n", id)
+		}
+		if len(todo.Tags) > 0 {
+			fmt.Fprintf(w, "Tags:\t%s\n", strings.Join(todo.Tags, ", "))
+		}
+		if todo.Priority != "" && todo.Priority != models.PriorityNone {
+			fmt.Fprintf(w, "Priority:\t%s\n", todo.Priority)
+		}
+		if todo.DueAt != nil {
+			fmt.Fprintf(w, "Due:\t%s\n", todo.DueAt.Local().Format("2006-01-02 15:04"))
+		}
+		fmt.Fprintf(w, "Comments:\t%d\n", todo.CommentCount)
+		fmt.Fprintf(w, "Version:\t%d\n", todo.Version)
+		fmt.Fprintf(w, "Created:\t%s\n", todo.CreatedAt.Local().Format("2006-01-02 15:04"))
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if todo.Description != "" {
+			fmt.Printf("\n%s\n", strings.TrimRight(todo.Description, "\n"))
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown output format %q", output)
+}
+
+// printJSON prints a value as indented JSON
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// shortID returns the first part of a todo's ID, which is enough to refer to it
+func shortID(todo models.TodoResponse) string {
+	return todo.ID.String()[:8]
+}
+
+// checkbox shows whether a todo is completed
+func checkbox(completed bool) string {
+	if completed {
+		return "[x]"
+	}
+	return "[ ]"
+}
+
+// oneLine keeps a title on one table row
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
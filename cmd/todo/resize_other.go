@@ -0,0 +1,9 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// notifyResize does nothing where there is no resize signal; the size is
+// read again on every refresh instead
+func notifyResize(c chan<- os.Signal) {}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ANSI escape sequences used to draw the terminal UI
+const (
+	escAltScreen  = "\x1b[?1049h"
+	escMainScreen = "\x1b[?1049l"
+	escHideCursor = "\x1b[?25l"
+	escShowCursor = "\x1b[?25h"
+	escHome       = "\x1b[H"
+	escClearLine  = "\x1b[K"
+	escClearToEnd = "\x1b[J"
+	escReset      = "\x1b[0m"
+	escBold       = "\x1b[1m"
+	escDim        = "\x1b[2m"
+	escReverse    = "\x1b[7m"
+	escRed        = "\x1b[31m"
+	escGreen      = "\x1b[32m"
+)
+
+// stty runs stty on the terminal attached to stdin
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+// makeRaw puts the terminal in raw mode and returns a function that restores it
+func makeRaw() (func(), error) {
+	if !isTerminal(os.Stdin) {
+		return nil, fmt.Errorf("stdin is not a terminal")
+	}
+	state, err := stty("-g")
+	if err != nil {
+		return nil, fmt.Errorf("saving terminal state: %w", err)
+	}
+	if _, err := stty("raw", "-echo"); err != nil {
+		return nil, fmt.Errorf("setting raw mode: %w", err)
+	}
+	return func() { stty(state) }, nil
+}
+
+// terminalSize returns the width and height of the terminal
+func terminalSize() (int, int) {
+	out, err := stty("size")
+	if err == nil {
+		var rows, cols int
+		if _, err := fmt.Sscan(out, &rows, &cols); err == nil && rows > 0 && cols > 0 {
+			return cols, rows
+		}
+	}
+	return 80, 24
+}
+
+// key is a key press; printable keys have a rune, the others a name
+type key struct {
+	r    rune
+	name string
+}
+
+// Names of the non-printable keys
+const (
+	keyUp        = "up"
+	keyDown      = "down"
+	keyLeft      = "left"
+	keyRight     = "right"
+	keyHome      = "home"
+	keyEnd       = "end"
+	keyPageUp    = "pgup"
+	keyPageDown  = "pgdn"
+	keyDelete    = "delete"
+	keyEnter     = "enter"
+	keyEscape    = "esc"
+	keyBackspace = "backspace"
+	keyTab       = "tab"
+	keyCtrlC     = "ctrl-c"
+	keyCtrlD     = "ctrl-d"
+	keyCtrlJ     = "ctrl-j"
+	keyCtrlU     = "ctrl-u"
+	keyCtrlW     = "ctrl-w"
+)
+
+// controlKeys names the control characters the UI uses
+var controlKeys = map[byte]string{
+	0x01: keyHome, // Ctrl-A
+	0x03: keyCtrlC,
+	0x04: keyCtrlD,
+	0x05: keyEnd, // Ctrl-E
+	0x08: keyBackspace,
+	0x09: keyTab,
+	0x0a: keyCtrlJ, // Enter sends CR in raw mode, leaving LF for a line break
+	0x0d: keyEnter,
+	0x15: keyCtrlU,
+	0x17: keyCtrlW,
+	0x7f: keyBackspace,
+}
+
+// escapeKeys names the escape sequences sent by special keys, without the
+// leading ESC
+var escapeKeys = map[string]string{
+	"[A": keyUp, "[B": keyDown, "[C": keyRight, "[D": keyLeft,
+	"OA": keyUp, "OB": keyDown, "OC": keyRight, "OD": keyLeft,
+	"[H": keyHome, "[F": keyEnd, "OH": keyHome, "OF": keyEnd,
+	"[1~": keyHome, "[7~": keyHome, "[4~": keyEnd, "[8~": keyEnd,
+	"[3~": keyDelete, "[5~": keyPageUp, "[6~": keyPageDown,
+}
+
+// parseKeys splits what one read from the terminal returned into key presses.
+// A lone ESC is the escape key; an unknown escape sequence is dropped.
+func parseKeys(buf []byte) []key {
+	var keys []key
+	for len(buf) > 0 {
+		b := buf[0]
+		switch {
+		case b == 0x1b:
+			// Escape sequences end with a letter or "~"
+			end := 1
+			if len(buf) > 1 && (buf[1] == '[' || buf[1] == 'O') {
+				end = 2
+				for end < len(buf) && !isSequenceEnd(buf[end]) {
+					end++
+				}
+				if end < len(buf) {
+					end++
+				}
+			}
+			if end == 1 {
+				keys = append(keys, key{name: keyEscape})
+			} else if name, ok := escapeKeys[string(buf[1:end])]; ok {
+				keys = append(keys, key{name: name})
+			}
+			buf = buf[end:]
+		case b < 0x20 || b == 0x7f:
+			if name, ok := controlKeys[b]; ok {
+				keys = append(keys, key{name: name})
+			}
+			buf = buf[1:]
+		default:
+			r, size := utf8.DecodeRune(buf)
+			if r != utf8.RuneError {
+				keys = append(keys, key{r: r})
+			}
+			buf = buf[size:]
+		}
+	}
+	return keys
+}
+
+// isSequenceEnd reports whether a byte ends an escape sequence
+func isSequenceEnd(b byte) bool {
+	return b == '~' || (b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z')
+}
+
+// readKeys sends the keys pressed to a channel until reading stdin fails
+func readKeys(keys chan<- key, errs chan<- error) {
+	buf := make([]byte, 256)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			errs <- err
+			return
+		}
+		for _, k := range parseKeys(buf[:n]) {
+			keys <- k
+		}
+	}
+}
+
+// lineEditor is a line of editable text; Ctrl-J inserts a line break
+type lineEditor struct {
+	prompt string
+	text   []rune
+	pos    int
+}
+
+// newLineEditor creates an editor with the cursor after its initial text
+func newLineEditor(prompt, text string) *lineEditor {
+	runes := []rune(text)
+	return &lineEditor{prompt: prompt, text: runes, pos: len(runes)}
+}
+
+// handle applies a key press to the text
+func (e *lineEditor) handle(k key) {
+	switch k.name {
+	case "", keyCtrlJ:
+		r := k.r
+		if k.name == keyCtrlJ {
+			r = '\n'
+		}
+		e.text = append(e.text[:e.pos], append([]rune{r}, e.text[e.pos:]...)...)
+		e.pos++
+	case keyBackspace:
+		if e.pos > 0 {
+			e.text = append(e.text[:e.pos-1], e.text[e.pos:]...)
+			e.pos--
+		}
+	case keyDelete:
+		if e.pos < len(e.text) {
+			e.text = append(e.text[:e.pos], e.text[e.pos+1:]...)
+		}
+	case keyLeft:
+		if e.pos > 0 {
+			e.pos--
+		}
+	case keyRight:
+		if e.pos < len(e.text) {
+			e.pos++
+		}
+	case keyHome:
+		e.pos = 0
+	case keyEnd:
+		e.pos = len(e.text)
+	case keyCtrlU:
+		e.text = append([]rune{}, e.text[e.pos:]...)
+		e.pos = 0
+	case keyCtrlW:
+		// Delete the word before the cursor and the spaces after it
+		start := e.pos
+		for start > 0 && e.text[start-1] == ' ' {
+			start--
+		}
+		for start > 0 && e.text[start-1] != ' ' {
+			start--
+		}
+		e.text = append(e.text[:start], e.text[e.pos:]...)
+		e.pos = start
+	}
+}
+
+// String returns the text
+func (e *lineEditor) String() string {
+	return string(e.text)
+}
+
+// truncate shortens a string to at most width runes, marking the cut with "…"
+func truncate(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	runes := []rune(s)
+	if len(runes) <= width {
+		return s
+	}
+	return string(runes[:width-1]) + "…"
+}
+
+// padRight pads a string with spaces to width runes
+func padRight(s string, width int) string {
+	n := utf8.RuneCountInString(s)
+	if n >= width {
+		return s
+	}
+	return s + strings.Repeat(" ", width-n)
+}
+
+// moveCursorTo returns the sequence that moves the cursor to a 1-based row and column
+func moveCursorTo(row, col int) string {
+	return "\x1b[" + strconv.Itoa(row) + ";" + strconv.Itoa(col) + "H"
+}
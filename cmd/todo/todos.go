@@ -0,0 +1,403 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/noman/todo-application/client"
+	"github.com/noman/todo-application/models"
+)
+
+// runAdd handles "todo add"
+func runAdd(ctx context.Context, args []string) error {
+	fs := newFlagSet("add")
+	description := fs.String("description", "", "description of the todo")
+	workspace := fs.String("workspace", "", "ID of the workspace to add the todo to")
+	project := fs.String("project", "", "ID of the project to add the todo to")
+	assign := fs.String("assign", "", "comma-separated IDs of the users to assign")
+	tags := fs.String("tags", "", "comma-separated tags")
+	priority := fs.String("priority", "", "priority: none, low, medium or high")
+	due := fs.String("due", "", "due date, like 2024-05-17 or 2024-05-17T17:00:00Z")
+	output := outputFlag(fs)
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := argCount(fs, args, 1, -1); err != nil {
+		return err
+	}
+
+	req := models.CreateTodoRequest{Title: joinArgs(args), Description: *description, Tags: splitTags(*tags), Priority: *priority}
+	if req.DueAt, err = optionalTime("due", *due); err != nil {
+		return err
+	}
+	if req.WorkspaceID, err = optionalID("workspace", *workspace); err != nil {
+		return err
+	}
+	if req.ProjectID, err = optionalID("project", *project); err != nil {
+		return err
+	}
+	if *assign != "" {
+		for _, s := range strings.Split(*assign, ",") {
+			id, err := uuid.Parse(strings.TrimSpace(s))
+			if err != nil {
+				return fmt.Errorf("invalid assignee ID %q", s)
+			}
+			req.AssigneeIDs = append(req.AssigneeIDs, id)
+		}
+	}
+
+	c, err := loggedInClient()
+	if err != nil {
+		return err
+	}
+	todo, err := c.CreateTodo(ctx, req)
+	if err != nil {
+		return err
+	}
+	return printTodo(*output, todo)
+}
+
+// runList handles "todo ls"
+func runList(ctx context.Context, args []string) error {
+	fs := newFlagSet("ls")
+	done := fs.Bool("done", false, "only list completed todos")
+	pending := fs.Bool("pending", false, "only list todos that aren't completed")
+	view := fs.String("view", models.TodoViewAll, "all, assigned (to me) or created (by me)")
+	workspace := fs.String("workspace", "", "list the todos of a workspace instead of personal todos")
+	project := fs.String("project", "", "only list todos in a project")
+	search := fs.String("search", "", "only list todos whose title or description contains this text")
+	filter := fs.String("filter", "", "only list todos matching a filter expression, like 'completed:false AND created<7d'")
+	output := outputFlag(fs)
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := argCount(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if *done && *pending {
+		return errors.New("--done and --pending can't be used together")
+	}
+
+	opts := client.ListTodosOptions{View: *view, Filter: *filter}
+	if opts.WorkspaceID, err = optionalID("workspace", *workspace); err != nil {
+		return err
+	}
+	projectID, err := optionalID("project", *project)
+	if err != nil {
+		return err
+	}
+
+	c, err := loggedInClient()
+	if err != nil {
+		return err
+	}
+
+	// The API filters by workspace, view and filter expression; the rest is
+	// filtered here
+	listed, err := c.ListTodos(ctx, opts)
+	if err != nil {
+		return err
+	}
+	todos := []models.TodoResponse{}
+	needle := strings.ToLower(*search)
+	for _, todo := range listed {
+		if (*done && !todo.Completed) || (*pending && todo.Completed) {
+			continue
+		}
+		if projectID != nil && (todo.ProjectID == nil || *todo.ProjectID != *projectID) {
+			continue
+		}
+		if needle != "" && !strings.Contains(strings.ToLower(todo.Title+"\n"+todo.Description), needle) {
+			continue
+		}
+		todos = append(todos, todo)
+	}
+	return printTodos(*output, todos)
+}
+
+// runShow handles "todo show"
+func runShow(ctx context.Context, args []string) error {
+	fs := newFlagSet("show")
+	output := outputFlag(fs)
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := argCount(fs, args, 1, 1); err != nil {
+		return err
+	}
+
+	c, err := loggedInClient()
+	if err != nil {
+		return err
+	}
+	todo, err := resolveTodo(ctx, c, args[0])
+	if err != nil {
+		return err
+	}
+	return printTodo(*output, todo)
+}
+
+// runDone handles "todo done"
+func runDone(ctx context.Context, args []string) error {
+	fs := newFlagSet("done")
+	undo := fs.Bool("undo", false, "mark the todos as pending instead")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := argCount(fs, args, 1, -1); err != nil {
+		return err
+	}
+
+	c, err := loggedInClient()
+	if err != nil {
+		return err
+	}
+	for _, arg := range args {
+		todo, err := resolveTodo(ctx, c, arg)
+		if err != nil {
+			return err
+		}
+		todo, err = c.CompleteTodo(ctx, todo.ID, !*undo, todo.ETag())
+		if err != nil {
+			return changeError(arg, err)
+		}
+		fmt.Printf("%s %s\n", checkbox(todo.Completed), todo.Title)
+	}
+	return nil
+}
+
+// runEdit handles "todo edit"
+func runEdit(ctx context.Context, args []string) error {
+	fs := newFlagSet("edit")
+	title := fs.String("title", "", "new title")
+	description := fs.String("description", "", "new description")
+	project := fs.String("project", "", `ID of the project to move the todo to, or "" for none`)
+	tags := fs.String("tags", "", `comma-separated tags, or "" for none`)
+	priority := fs.String("priority", "", "priority: none, low, medium or high")
+	due := fs.String("due", "", `due date, like 2024-05-17 or 2024-05-17T17:00:00Z, or "" for none`)
+	output := outputFlag(fs)
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := argCount(fs, args, 1, 1); err != nil {
+		return err
+	}
+
+	c, err := loggedInClient()
+	if err != nil {
+		return err
+	}
+	todo, err := resolveTodo(ctx, c, args[0])
+	if err != nil {
+		return err
+	}
+
+	// Build a merge patch from the flags that were given
+	changes := map[string]interface{}{}
+	var flagErr error
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "title":
+			changes["title"] = *title
+		case "description":
+			changes["description"] = *description
+		case "project":
+			projectID, err := optionalID("project", *project)
+			if err != nil {
+				flagErr = err
+			}
+			changes["project_id"] = projectID
+		case "tags":
+			changes["tags"] = splitTags(*tags)
+		case "priority":
+			changes["priority"] = *priority
+		case "due":
+			dueAt, err := optionalTime("due", *due)
+			if err != nil {
+				flagErr = err
+			}
+			changes["due_at"] = dueAt
+		}
+	})
+	if flagErr != nil {
+		return flagErr
+	}
+
+	// Without flags, edit the title and description in $EDITOR
+	if len(changes) == 0 {
+		newTitle, newDescription, err := editInEditor(todo.Title, todo.Description)
+		if err != nil {
+			return err
+		}
+		if newTitle != todo.Title {
+			changes["title"] = newTitle
+		}
+		if newDescription != strings.TrimSpace(todo.Description) {
+			changes["description"] = newDescription
+		}
+		if len(changes) == 0 {
+			fmt.Fprintln(os.Stderr, "Nothing changed")
+			return nil
+		}
+	}
+
+	todo, err = c.PatchTodo(ctx, todo.ID, changes, todo.ETag())
+	if err != nil {
+		return changeError(args[0], err)
+	}
+	return printTodo(*output, todo)
+}
+
+// runRemove handles "todo rm"
+func runRemove(ctx context.Context, args []string) error {
+	fs := newFlagSet("rm")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := argCount(fs, args, 1, -1); err != nil {
+		return err
+	}
+
+	c, err := loggedInClient()
+	if err != nil {
+		return err
+	}
+	for _, arg := range args {
+		todo, err := resolveTodo(ctx, c, arg)
+		if err != nil {
+			return err
+		}
+		if err := c.DeleteTodo(ctx, todo.ID, todo.ETag()); err != nil {
+			return changeError(arg, err)
+		}
+		fmt.Fprintf(os.Stderr, "Moved %q to the trash\n", todo.Title)
+	}
+	return nil
+}
+
+// resolveTodo gets a todo by its ID or a unique prefix of it
+func resolveTodo(ctx context.Context, c *client.Client, arg string) (*models.TodoResponse, error) {
+	if id, err := uuid.Parse(arg); err == nil {
+		return c.GetTodo(ctx, id)
+	}
+
+	todos, err := c.ListTodos(ctx, client.ListTodosOptions{View: models.TodoViewAll})
+	if err != nil {
+		return nil, err
+	}
+	prefix := strings.ToLower(arg)
+	var match *models.TodoResponse
+	for _, todo := range todos {
+		if !strings.HasPrefix(todo.ID.String(), prefix) {
+			continue
+		}
+		if match != nil {
+			return nil, fmt.Errorf("%q matches more than one todo", arg)
+		}
+		match = &todo
+	}
+	if match == nil {
+		return nil, fmt.Errorf("no todo matches %q", arg)
+	}
+	return match, nil
+}
+
+// changeError explains errors from changing a todo
+func changeError(arg string, err error) error {
+	if errors.Is(err, client.ErrVersionConflict) {
+		return fmt.Errorf("todo %s was changed by someone else; run the command again", arg)
+	}
+	return err
+}
+
+// optionalID parses an ID flag, which may be empty
+func optionalID(name, value string) (*uuid.UUID, error) {
+	if value == "" {
+		return nil, nil
+	}
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s ID %q", name, value)
+	}
+	return &id, nil
+}
+
+// optionalTime parses a date or time flag, which may be empty. A date is
+// midnight of that day in the local time zone.
+func optionalTime(name, value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s date %q; use a date like 2024-05-17 or a time like 2024-05-17T17:00:00Z", name, value)
+	}
+	return &t, nil
+}
+
+// splitTags splits a comma-separated list of tags
+func splitTags(value string) []string {
+	tags := []string{}
+	for _, tag := range strings.Split(value, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// editInEditor lets the user edit a title and description in $EDITOR. The
+// first line of the file is the title and the description follows a blank line.
+func editInEditor(title, description string) (string, string, error) {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	f, err := os.CreateTemp("", "todo-*.txt")
+	if err != nil {
+		return "", "", err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(title + "\n\n" + description + "\n")
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	// The editor command may include arguments, e.g. "code --wait"
+	fields := strings.Fields(editor)
+	cmd := exec.Command(fields[0], append(fields[1:], f.Name())...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", "", fmt.Errorf("running %s: %w", editor, err)
+	}
+
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		return "", "", err
+	}
+	newTitle, newDescription, _ := strings.Cut(string(data), "\n")
+	return strings.TrimSpace(newTitle), strings.TrimSpace(newDescription), nil
+}
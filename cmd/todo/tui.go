@@ -0,0 +1,679 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/noman/todo-application/client"
+	"github.com/noman/todo-application/models"
+)
+
+// tuiMode is what the keyboard is driving
+type tuiMode int
+
+const (
+	modeNormal tuiMode = iota
+	modeFilter
+	modeAdd
+	modeEditTitle
+	modeEditDescription
+)
+
+// tuiViews are the list views "v" cycles through
+var tuiViews = []string{models.TodoViewAll, models.TodoViewAssignedToMe, models.TodoViewCreatedByMe}
+
+// tuiHelp lists the key bindings
+var tuiHelp = [][2]string{
+	{"j / ↓", "Move down"},
+	{"k / ↑", "Move up"},
+	{"gg / G", "Go to the first / last todo"},
+	{"Ctrl-D / Ctrl-U", "Move half a page down / up"},
+	{"space / x", "Toggle completed"},
+	{"a / o", "Add a todo"},
+	{"e / i / enter", "Edit the title"},
+	{"E", "Edit the description (Ctrl-J for a line break)"},
+	{"dd", "Move the todo to the trash"},
+	{"/", "Filter by text; esc clears the filter"},
+	{"c", "Show or hide completed todos"},
+	{"v", "Switch between all, assigned to me and created by me"},
+	{"r", "Reload"},
+	{"?", "Show or hide this help"},
+	{"q / Ctrl-C", "Quit"},
+}
+
+// tui is the state of the terminal UI. It is only touched by the goroutine
+// running the event loop; API calls report back through updates.
+type tui struct {
+	ctx    context.Context
+	client *client.Client
+	server string
+	opts   client.ListTodosOptions
+
+	todos    []models.TodoResponse
+	loaded   bool
+	hideDone bool
+	filter   string
+
+	cursor   int       // Index of the selected todo in the visible list
+	selected uuid.UUID // ID of the selected todo, followed across reloads
+	offset   int       // First list line on screen
+	width    int
+	height   int
+
+	mode     tuiMode
+	input    *lineEditor
+	editing  uuid.UUID // Todo being edited
+	prefix   rune      // First key of a two-key command such as "dd"
+	showHelp bool
+
+	status       string
+	statusErr    bool
+	busy         int // API calls in flight
+	refreshing   bool
+	refreshAgain bool
+
+	updates chan func(*tui)
+}
+
+// runTUI handles "todo tui"
+func runTUI(ctx context.Context, args []string) error {
+	fs := newFlagSet("tui")
+	refresh := fs.Duration("refresh", 5*time.Second, "how often to reload todos")
+	view := fs.String("view", models.TodoViewAll, "all, assigned (to me) or created (by me)")
+	workspace := fs.String("workspace", "", "show the todos of a workspace instead of personal todos")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := argCount(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if *refresh <= 0 {
+		return errors.New("--refresh must be positive")
+	}
+
+	t := &tui{ctx: ctx, opts: client.ListTodosOptions{View: *view}, updates: make(chan func(*tui), 16)}
+	if t.opts.WorkspaceID, err = optionalID("workspace", *workspace); err != nil {
+		return err
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	t.server = cfg.server()
+	if t.client, err = loggedInClient(); err != nil {
+		return err
+	}
+
+	restore, err := makeRaw()
+	if err != nil {
+		return err
+	}
+	defer restore()
+	fmt.Print(escAltScreen + escHideCursor)
+	defer fmt.Print(escShowCursor + escMainScreen)
+
+	return t.run(*refresh)
+}
+
+// run is the event loop: it redraws the screen after every key press, API
+// response and reload until the user quits
+func (t *tui) run(interval time.Duration) error {
+	keys := make(chan key, 16)
+	readErrs := make(chan error, 1)
+	go readKeys(keys, readErrs)
+
+	resize := make(chan os.Signal, 1)
+	notifyResize(resize)
+	defer signal.Stop(resize)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	t.width, t.height = terminalSize()
+	t.refresh()
+	for {
+		t.render()
+		select {
+		case k := <-keys:
+			if t.handleKey(k) {
+				return nil
+			}
+		case update := <-t.updates:
+			update(t)
+		case <-ticker.C:
+			t.width, t.height = terminalSize()
+			t.refresh()
+		case <-resize:
+			t.width, t.height = terminalSize()
+		case err := <-readErrs:
+			return err
+		case <-t.ctx.Done():
+			return nil
+		}
+	}
+}
+
+// refresh reloads the todos in the background
+func (t *tui) refresh() {
+	if t.refreshing {
+		t.refreshAgain = true
+		return
+	}
+	t.refreshing = true
+	opts := t.opts
+	go func() {
+		todos, err := t.client.ListTodos(t.ctx, opts)
+		t.updates <- func(t *tui) {
+			t.refreshing = false
+			if t.refreshAgain {
+				// The view changed while loading, so this list is stale
+				t.refreshAgain = false
+				t.refresh()
+				return
+			}
+			if err != nil {
+				t.fail(err)
+				return
+			}
+			t.todos, t.loaded = todos, true
+			t.clampCursor()
+		}
+	}()
+}
+
+// async runs an API call in the background. The function it returns is
+// applied on the event loop once the call succeeds.
+func (t *tui) async(status string, call func() (func(*tui), error)) {
+	t.busy++
+	t.setStatus(status, false)
+	go func() {
+		apply, err := call()
+		t.updates <- func(t *tui) {
+			t.busy--
+			if err != nil {
+				t.fail(err)
+				return
+			}
+			apply(t)
+		}
+	}()
+}
+
+// setStatus shows a message in the status line
+func (t *tui) setStatus(message string, isErr bool) {
+	t.status, t.statusErr = message, isErr
+}
+
+// fail shows an API error in the status line
+func (t *tui) fail(err error) {
+	switch {
+	case errors.Is(err, context.Canceled):
+	case errors.Is(err, client.ErrVersionConflict):
+		t.setStatus("The todo was changed elsewhere; reloaded it", true)
+		t.refresh()
+	case errors.Is(err, client.ErrNotFound):
+		t.setStatus("The todo no longer exists; reloaded", true)
+		t.refresh()
+	default:
+		t.setStatus(err.Error(), true)
+	}
+}
+
+// visible returns the todos to show, pending ones first like the web app
+func (t *tui) visible() []models.TodoResponse {
+	needle := strings.ToLower(t.filter)
+	var pending, done []models.TodoResponse
+	for _, todo := range t.todos {
+		if needle != "" && !strings.Contains(strings.ToLower(todo.Title+"\n"+todo.Description), needle) {
+			continue
+		}
+		if !todo.Completed {
+			pending = append(pending, todo)
+		} else if !t.hideDone {
+			done = append(done, todo)
+		}
+	}
+	return append(pending, done...)
+}
+
+// current returns the selected todo, or nil if the list is empty
+func (t *tui) current() *models.TodoResponse {
+	visible := t.visible()
+	if t.cursor < 0 || t.cursor >= len(visible) {
+		return nil
+	}
+	return &visible[t.cursor]
+}
+
+// clampCursor keeps the selection on the same todo after the list changes,
+// or on the same row if the todo is gone
+func (t *tui) clampCursor() {
+	visible := t.visible()
+	for i, todo := range visible {
+		if todo.ID == t.selected {
+			t.cursor = i
+			return
+		}
+	}
+	t.cursor = max(min(t.cursor, len(visible)-1), 0)
+	t.selected = uuid.Nil
+	if len(visible) > 0 {
+		t.selected = visible[t.cursor].ID
+	}
+}
+
+// moveCursor moves the selection by delta rows
+func (t *tui) moveCursor(delta int) {
+	visible := t.visible()
+	if len(visible) == 0 {
+		return
+	}
+	t.cursor = max(min(t.cursor+delta, len(visible)-1), 0)
+	t.selected = visible[t.cursor].ID
+}
+
+// replace updates a todo in the list, adding it if it's new
+func (t *tui) replace(todo models.TodoResponse) {
+	for i := range t.todos {
+		if t.todos[i].ID == todo.ID {
+			t.todos[i] = todo
+			return
+		}
+	}
+	// The API lists the newest todos first
+	t.todos = append([]models.TodoResponse{todo}, t.todos...)
+}
+
+// handleKey handles a key press and reports whether to quit
+func (t *tui) handleKey(k key) bool {
+	if t.input != nil {
+		t.handleInput(k)
+		return false
+	}
+	if t.showHelp {
+		t.showHelp = false
+		return k.name == keyCtrlC
+	}
+
+	prefix := t.prefix
+	t.prefix = 0
+	if t.busy == 0 {
+		t.status = ""
+	}
+
+	switch {
+	case k.name == keyCtrlC, k.r == 'q':
+		return true
+	case k.name == keyDown, k.r == 'j':
+		t.moveCursor(1)
+	case k.name == keyUp, k.r == 'k':
+		t.moveCursor(-1)
+	case k.name == keyPageDown, k.name == keyCtrlD:
+		t.moveCursor(t.listHeight() / 2)
+	case k.name == keyPageUp, k.name == keyCtrlU:
+		t.moveCursor(-t.listHeight() / 2)
+	case k.name == keyHome, k.r == 'g' && prefix == 'g':
+		t.moveCursor(-len(t.todos))
+	case k.name == keyEnd, k.r == 'G':
+		t.moveCursor(len(t.todos))
+	case k.r == 'g', k.r == 'd' && prefix != 'd':
+		t.prefix = k.r
+	case k.r == 'd', k.name == keyDelete:
+		t.remove()
+	case k.r == ' ', k.r == 'x':
+		t.toggle()
+	case k.r == 'a', k.r == 'o':
+		t.startInput(modeAdd, "New todo: ", "")
+	case k.r == 'e', k.r == 'i', k.name == keyEnter:
+		if todo := t.current(); todo != nil {
+			t.editing = todo.ID
+			t.startInput(modeEditTitle, "Title: ", todo.Title)
+		}
+	case k.r == 'E':
+		if todo := t.current(); todo != nil {
+			t.editing = todo.ID
+			t.startInput(modeEditDescription, "Description: ", todo.Description)
+		}
+	case k.r == '/':
+		t.startInput(modeFilter, "/", t.filter)
+	case k.name == keyEscape:
+		t.filter = ""
+		t.clampCursor()
+	case k.r == 'c':
+		t.hideDone = !t.hideDone
+		t.clampCursor()
+	case k.r == 'v':
+		for i, view := range tuiViews {
+			if view == t.opts.View {
+				t.opts.View = tuiViews[(i+1)%len(tuiViews)]
+				break
+			}
+		}
+		t.loaded = false
+		t.refresh()
+	case k.r == 'r':
+		t.refresh()
+	case k.r == '?':
+		t.showHelp = true
+	}
+	return false
+}
+
+// startInput shows a line editor in the status line
+func (t *tui) startInput(mode tuiMode, prompt, text string) {
+	t.mode = mode
+	t.input = newLineEditor(prompt, text)
+}
+
+// handleInput handles a key press while a line editor is open
+func (t *tui) handleInput(k key) {
+	switch k.name {
+	case keyEscape, keyCtrlC:
+		if t.mode == modeFilter {
+			t.filter = ""
+			t.clampCursor()
+		}
+		t.mode, t.input = modeNormal, nil
+		return
+	case keyEnter:
+		mode, text := t.mode, strings.TrimSpace(t.input.String())
+		t.mode, t.input = modeNormal, nil
+		t.submit(mode, text)
+		return
+	}
+
+	t.input.handle(k)
+	// The filter applies as it's typed
+	if t.mode == modeFilter {
+		t.filter = t.input.String()
+		t.clampCursor()
+	}
+}
+
+// submit applies the text entered in a line editor
+func (t *tui) submit(mode tuiMode, text string) {
+	switch mode {
+	case modeFilter:
+		t.filter = text
+		t.clampCursor()
+
+	case modeAdd:
+		if text == "" {
+			return
+		}
+		req := models.CreateTodoRequest{Title: text, WorkspaceID: t.opts.WorkspaceID}
+		t.async("Adding…", func() (func(*tui), error) {
+			todo, err := t.client.CreateTodo(t.ctx, req)
+			if err != nil {
+				return nil, err
+			}
+			return func(t *tui) {
+				t.replace(*todo)
+				t.selected = todo.ID
+				t.clampCursor()
+				t.setStatus("Added", false)
+			}, nil
+		})
+
+	case modeEditTitle, modeEditDescription:
+		var todo *models.TodoResponse
+		for i := range t.todos {
+			if t.todos[i].ID == t.editing {
+				todo = &t.todos[i]
+			}
+		}
+		if todo == nil {
+			return
+		}
+		field, current := "title", todo.Title
+		if mode == modeEditDescription {
+			field, current = "description", strings.TrimSpace(todo.Description)
+		}
+		if text == current {
+			return
+		}
+		if field == "title" && text == "" {
+			t.setStatus("The title can't be empty", true)
+			return
+		}
+		t.patch(*todo, map[string]interface{}{field: text}, "Saved", false)
+	}
+}
+
+// toggle marks the selected todo as completed or pending
+func (t *tui) toggle() {
+	todo := t.current()
+	if todo == nil {
+		return
+	}
+	status := "Marked as completed"
+	if todo.Completed {
+		status = "Marked as pending"
+	}
+	t.patch(*todo, map[string]interface{}{"completed": !todo.Completed}, status, true)
+}
+
+// patch applies a merge patch to a todo, unless someone else changed it
+// first. With keepRow the selection stays on the same row when the todo moves
+// to the other section, so a run of todos can be toggled in turn.
+func (t *tui) patch(todo models.TodoResponse, changes map[string]interface{}, status string, keepRow bool) {
+	t.async("Saving…", func() (func(*tui), error) {
+		updated, err := t.client.PatchTodo(t.ctx, todo.ID, changes, todo.ETag())
+		if err != nil {
+			return nil, err
+		}
+		return func(t *tui) {
+			t.replace(*updated)
+			if keepRow && t.selected == updated.ID {
+				t.selected = uuid.Nil
+			}
+			t.clampCursor()
+			t.setStatus(status, false)
+		}, nil
+	})
+}
+
+// remove moves the selected todo to the trash
+func (t *tui) remove() {
+	todo := t.current()
+	if todo == nil {
+		return
+	}
+	id, etag, title := todo.ID, todo.ETag(), todo.Title
+	t.async("Deleting…", func() (func(*tui), error) {
+		if err := t.client.DeleteTodo(t.ctx, id, etag); err != nil {
+			return nil, err
+		}
+		return func(t *tui) {
+			for i := range t.todos {
+				if t.todos[i].ID == id {
+					t.todos = append(t.todos[:i], t.todos[i+1:]...)
+					break
+				}
+			}
+			if t.selected == id {
+				t.selected = uuid.Nil
+			}
+			t.clampCursor()
+			t.setStatus(fmt.Sprintf("Moved %q to the trash", title), false)
+		}, nil
+	})
+}
+
+// listHeight is the number of rows available to the list
+func (t *tui) listHeight() int {
+	// The header, status line and key hints take three rows
+	return max(t.height-3, 1)
+}
+
+// listLine is a row of the list: a section heading or a todo
+type listLine struct {
+	text string
+	todo int // Index into the visible todos, or -1 for a heading
+}
+
+// render draws the whole screen
+func (t *tui) render() {
+	var b strings.Builder
+	b.WriteString(escHome)
+
+	// Header
+	visible := t.visible()
+	pending, done := 0, 0
+	for _, todo := range t.todos {
+		if todo.Completed {
+			done++
+		} else {
+			pending++
+		}
+	}
+	header := fmt.Sprintf(" Todos · %s · %d pending, %d done", t.opts.View, pending, done)
+	if t.filter != "" {
+		header += " · /" + t.filter
+	}
+	if t.hideDone {
+		header += " · completed hidden"
+	}
+	right := t.server + " "
+	if t.busy > 0 || t.refreshing {
+		right = "⟳ " + right
+	}
+	header = padRight(truncate(header, t.width-len([]rune(right))), t.width-len([]rune(right))) + right
+	b.WriteString(escReverse + escBold + truncate(header, t.width) + escReset + escClearLine + "\r\n")
+
+	// List, or help
+	rows := t.listHeight()
+	var lines []string
+	if t.showHelp {
+		lines = t.helpLines()
+	} else {
+		lines = t.listLines(visible, rows)
+	}
+	for i := 0; i < rows; i++ {
+		if i < len(lines) {
+			b.WriteString(lines[i])
+		}
+		b.WriteString(escReset + escClearLine + "\r\n")
+	}
+
+	// Status line, or the line editor
+	cursorRow, cursorCol := 0, 0
+	if t.input != nil {
+		prompt := t.input.prompt
+		room := max(t.width-len([]rune(prompt))-1, 1)
+		start := max(t.input.pos-room+1, 0)
+		shown := strings.ReplaceAll(string(t.input.text[start:]), "\n", "↵")
+		b.WriteString(escBold + prompt + escReset + truncate(shown, room))
+		cursorRow, cursorCol = t.height-1, len([]rune(prompt))+t.input.pos-start+1
+	} else if t.status != "" {
+		color := escGreen
+		if t.statusErr {
+			color = escRed
+		}
+		b.WriteString(color + " " + truncate(t.status, t.width-2) + escReset)
+	}
+	b.WriteString(escClearLine + "\r\n")
+
+	// Key hints
+	hints := " j/k move · space done · a add · e edit · dd delete · / filter · ? help · q quit"
+	if t.input != nil {
+		hints = " enter save · esc cancel"
+	}
+	b.WriteString(escDim + truncate(hints, t.width) + escReset + escClearLine + escClearToEnd)
+
+	if t.input != nil {
+		b.WriteString(moveCursorTo(cursorRow, cursorCol) + escShowCursor)
+	} else {
+		b.WriteString(escHideCursor)
+	}
+	os.Stdout.WriteString(b.String())
+}
+
+// listLines returns the rows of the list that fit on screen, scrolled to
+// keep the selected todo visible
+func (t *tui) listLines(visible []models.TodoResponse, rows int) []string {
+	if !t.loaded {
+		return []string{escDim + " Loading…"}
+	}
+	if len(visible) == 0 {
+		if t.filter != "" {
+			return []string{escDim + " No todos match /" + t.filter}
+		}
+		return []string{escDim + " No todos yet. Press a to add one."}
+	}
+
+	// Pending todos come first, under headings like the web app's
+	var lines []listLine
+	for i, todo := range visible {
+		if i == 0 && !todo.Completed {
+			lines = append(lines, listLine{escBold + " Pending", -1})
+		}
+		if todo.Completed && (i == 0 || !visible[i-1].Completed) {
+			if i > 0 {
+				lines = append(lines, listLine{"", -1})
+			}
+			lines = append(lines, listLine{escBold + " Completed", -1})
+		}
+		lines = append(lines, listLine{t.todoLine(todo, i == t.cursor), i})
+	}
+
+	// Scroll so the selected todo, and the heading just above it, are on screen
+	selected := 0
+	for i, line := range lines {
+		if line.todo == t.cursor {
+			selected = i
+		}
+	}
+	if selected >= t.offset+rows {
+		t.offset = selected - rows + 1
+	}
+	if selected < t.offset {
+		t.offset = selected
+	}
+	if selected > 0 && lines[selected-1].todo == -1 && selected-1 < t.offset {
+		t.offset = selected - 1
+	}
+	t.offset = max(min(t.offset, len(lines)-rows), 0)
+
+	shown := make([]string, 0, rows)
+	for _, line := range lines[t.offset:min(t.offset+rows, len(lines))] {
+		shown = append(shown, line.text)
+	}
+	return shown
+}
+
+// todoLine renders a todo as a list row
+func (t *tui) todoLine(todo models.TodoResponse, selected bool) string {
+	text := "   " + checkbox(todo.Completed) + " " + oneLine(todo.Title)
+	if todo.Description != "" {
+		text += "  — " + oneLine(todo.Description)
+	}
+	if todo.CommentCount > 0 {
+		text += fmt.Sprintf("  (%d comments)", todo.CommentCount)
+	}
+	text = truncate(text, t.width)
+
+	switch {
+	case selected:
+		return escReverse + padRight(text, t.width)
+	case todo.Completed:
+		return escDim + text
+	}
+	return text
+}
+
+// helpLines renders the key bindings
+func (t *tui) helpLines() []string {
+	lines := []string{escBold + " Keys", ""}
+	for _, binding := range tuiHelp {
+		lines = append(lines, truncate("   "+padRight(binding[0], 18)+binding[1], t.width))
+	}
+	return append(lines, "", escDim+" Press any key to close this help")
+}
@@ -0,0 +1,248 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/noman/todo-application/middleware"
+	"github.com/noman/todo-application/models"
+	"github.com/noman/todo-application/problem"
+	"github.com/noman/todo-application/repository"
+	"github.com/noman/todo-application/storage"
+)
+
+// AccountController handles requests about the signed-in user's account:
+// exporting all of its data, and deleting it
+type AccountController struct {
+	accountRepo *repository.AccountRepository
+	exportRepo  *repository.AccountExportRepository
+	userRepo    *repository.UserRepository
+	store       storage.BlobStore
+	gracePeriod time.Duration
+}
+
+// NewAccountController creates a new AccountController
+func NewAccountController() *AccountController {
+	gracePeriod, err := time.ParseDuration(os.Getenv("ACCOUNT_DELETION_GRACE_PERIOD"))
+	if err != nil || gracePeriod < 0 {
+		gracePeriod = 14 * 24 * time.Hour // Default to 14 days if not specified
+	}
+
+	log.Printf("Deleting accounts %s after they ask", gracePeriod)
+
+	return &AccountController{
+		accountRepo: repository.NewAccountRepository(),
+		exportRepo:  repository.NewAccountExportRepository(),
+		userRepo:    repository.NewUserRepository(),
+		store:       storage.Store,
+		gracePeriod: gracePeriod,
+	}
+}
+
+// Get handles getting the user's account
+func (c *AccountController) Get(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	account, err := c.accountRepo.GetByID(userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			problem.Error(w, http.StatusNotFound, problem.CodeNotFound, "User not found")
+			return
+		}
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to get account")
+		return
+	}
+
+	// Return the account
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(account)
+}
+
+// Delete handles scheduling the user's account for deletion once the grace
+// period ends. The user must enter their password again.
+func (c *AccountController) Delete(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Parse the request body
+	var req models.DeleteAccountRequest
+	if !decodeRequest(w, r.Body, &req) {
+		return
+	}
+
+	// Check the password
+	user, err := c.userRepo.GetByID(userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			problem.Error(w, http.StatusNotFound, problem.CodeNotFound, "User not found")
+			return
+		}
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to delete account")
+		return
+	}
+
+	if _, err := c.userRepo.VerifyPassword(user.Email, req.Password); err != nil {
+		if errors.Is(err, repository.ErrInvalidPassword) {
+			problem.Error(w, http.StatusForbidden, problem.CodeForbidden, "Incorrect password")
+			return
+		}
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to delete account")
+		return
+	}
+
+	// Schedule the deletion
+	if _, err := c.accountRepo.ScheduleDeletion(userID, time.Now().Add(c.gracePeriod)); err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to delete account")
+		return
+	}
+
+	account, err := c.accountRepo.GetByID(userID)
+	if err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to delete account")
+		return
+	}
+
+	// Return the account, with when it will be deleted
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(account)
+}
+
+// Restore handles cancelling the user's scheduled account deletion
+func (c *AccountController) Restore(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	if err := c.accountRepo.CancelDeletion(userID); err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to restore account")
+		return
+	}
+
+	account, err := c.accountRepo.GetByID(userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			problem.Error(w, http.StatusNotFound, problem.CodeNotFound, "User not found")
+			return
+		}
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to restore account")
+		return
+	}
+
+	// Return the account
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(account)
+}
+
+// CreateExport handles starting an export of all of the user's data. The
+// archive is built in the background; if one is already being built, that
+// export is returned instead.
+func (c *AccountController) CreateExport(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	export, err := c.exportRepo.GetLatest(userID)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to create export")
+		return
+	}
+
+	// Queue a new export unless one is pending
+	if export == nil || (export.Status != models.AccountExportQueued && export.Status != models.AccountExportRunning) {
+		export = &models.AccountExport{UserID: userID}
+		if err := c.exportRepo.Create(export); err != nil {
+			problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to create export")
+			return
+		}
+	}
+
+	// Return the export
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/users/me/export")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(export)
+}
+
+// GetExport handles getting the user's latest export, to follow its progress
+func (c *AccountController) GetExport(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	export, err := c.exportRepo.GetLatest(userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			problem.Error(w, http.StatusNotFound, problem.CodeNotFound, "Export not found")
+			return
+		}
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to get export")
+		return
+	}
+
+	// Return the export
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(export)
+}
+
+// DownloadExport handles downloading the archive of the user's latest export
+func (c *AccountController) DownloadExport(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	export, err := c.exportRepo.GetLatest(userID)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to download export")
+		return
+	}
+	if export == nil || export.Status != models.AccountExportCompleted {
+		problem.Error(w, http.StatusNotFound, problem.CodeNotFound, "No export is ready to download")
+		return
+	}
+
+	blob, err := c.store.Open(r.Context(), export.StorageKey, export.Size)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			problem.Error(w, http.StatusNotFound, problem.CodeNotFound, "No export is ready to download")
+			return
+		}
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to download export")
+		return
+	}
+	defer blob.Close()
+
+	// Archives never change, so their ID makes a strong ETag for If-Range
+	filename := "account-export-" + export.CreatedAt.Format("2006-01-02") + ".zip"
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	w.Header().Set("ETag", `"`+export.ID.String()+`"`)
+
+	http.ServeContent(w, r, filename, *export.FinishedAt, blob)
+}
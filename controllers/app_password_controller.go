@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/noman/todo-application/middleware"
+	"github.com/noman/todo-application/models"
+	"github.com/noman/todo-application/problem"
+	"github.com/noman/todo-application/repository"
+)
+
+// appPasswordAlphabet is what app passwords are made of: lowercase letters and
+// digits, without the ones that are easily confused
+const appPasswordAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+// AppPasswordController handles requests for a user's app passwords
+type AppPasswordController struct {
+	appPasswordRepo *repository.AppPasswordRepository
+}
+
+// NewAppPasswordController creates a new AppPasswordController
+func NewAppPasswordController() *AppPasswordController {
+	return &AppPasswordController{
+		appPasswordRepo: repository.NewAppPasswordRepository(),
+	}
+}
+
+// newAppPassword generates an app password: five groups of four characters,
+// about 99 bits of randomness
+func newAppPassword() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	var password strings.Builder
+	for i, c := range b {
+		if i > 0 && i%4 == 0 {
+			password.WriteByte('-')
+		}
+		// The alphabet has 31 characters, so the remainder is very slightly
+		// biased; that costs well under a bit of the password's strength
+		password.WriteByte(appPasswordAlphabet[int(c)%len(appPasswordAlphabet)])
+	}
+	return password.String(), nil
+}
+
+// Create handles creating a new app password. The response is the only one
+// that includes the password.
+func (c *AppPasswordController) Create(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Parse the request body
+	var req models.CreateAppPasswordRequest
+	if !decodeRequest(w, r.Body, &req) {
+		return
+	}
+
+	password, err := newAppPassword()
+	if err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to create app password")
+		return
+	}
+
+	// Create the app password
+	appPassword := &models.AppPassword{
+		UserID:   userID,
+		Name:     req.Name,
+		Password: password,
+	}
+
+	if err := c.appPasswordRepo.Create(appPassword); err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to create app password")
+		return
+	}
+
+	// Return the created app password
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(appPassword)
+}
+
+// GetAll handles listing the user's app passwords
+func (c *AppPasswordController) GetAll(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	appPasswords, err := c.appPasswordRepo.GetAllByUserID(userID)
+	if err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to get app passwords")
+		return
+	}
+
+	// Return the app passwords
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(appPasswords)
+}
+
+// Delete handles revoking an app password
+func (c *AppPasswordController) Delete(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	vars := mux.Vars(r)
+	appPasswordID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		problem.Error(w, http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid app password ID")
+		return
+	}
+
+	if err := c.appPasswordRepo.Delete(appPasswordID, userID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			problem.Error(w, http.StatusNotFound, problem.CodeNotFound, "App password not found")
+			return
+		}
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to delete app password")
+		return
+	}
+
+	// Return success
+	w.WriteHeader(http.StatusNoContent)
+}
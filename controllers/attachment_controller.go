@@ -0,0 +1,420 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/noman/todo-application/middleware"
+	"github.com/noman/todo-application/models"
+	"github.com/noman/todo-application/problem"
+	"github.com/noman/todo-application/repository"
+	"github.com/noman/todo-application/storage"
+)
+
+// sniffLen is the number of bytes http.DetectContentType looks at
+const sniffLen = 512
+
+// AttachmentController handles attachment requests
+type AttachmentController struct {
+	attachmentRepo *repository.AttachmentRepository
+	todoRepo       *repository.TodoRepository
+	workspaceRepo  *repository.WorkspaceRepository
+	store          storage.BlobStore
+	maxUploadSize  int64
+	quota          int64
+}
+
+// NewAttachmentController creates a new AttachmentController
+func NewAttachmentController() *AttachmentController {
+	maxUploadSize, err := strconv.ParseInt(os.Getenv("ATTACHMENT_MAX_SIZE"), 10, 64)
+	if err != nil || maxUploadSize <= 0 {
+		maxUploadSize = 25 << 20 // Default to 25 MiB if not specified
+	}
+
+	quota, err := strconv.ParseInt(os.Getenv("STORAGE_QUOTA_BYTES"), 10, 64)
+	if err != nil || quota <= 0 {
+		quota = 100 << 20 // Default to 100 MiB if not specified
+	}
+
+	return &AttachmentController{
+		attachmentRepo: repository.NewAttachmentRepository(),
+		todoRepo:       repository.NewTodoRepository(),
+		workspaceRepo:  repository.NewWorkspaceRepository(),
+		store:          storage.Store,
+		maxUploadSize:  maxUploadSize,
+		quota:          quota,
+	}
+}
+
+// getAttachment parses the attachment ID from the URL and loads the attachment,
+// writing an error response and returning nil if it isn't attached to the todo
+func (c *AttachmentController) getAttachment(w http.ResponseWriter, r *http.Request, todo *models.Todo) *models.Attachment {
+	vars := mux.Vars(r)
+	attachmentID, err := uuid.Parse(vars["attachmentId"])
+	if err != nil {
+		problem.Error(w, http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid attachment ID")
+		return nil
+	}
+
+	attachment, err := c.attachmentRepo.GetByID(attachmentID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			problem.Error(w, http.StatusNotFound, problem.CodeNotFound, "Attachment not found")
+			return nil
+		}
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to get attachment")
+		return nil
+	}
+	if attachment.TodoID != todo.ID {
+		problem.Error(w, http.StatusNotFound, problem.CodeNotFound, "Attachment not found")
+		return nil
+	}
+
+	return attachment
+}
+
+// errTooLarge is returned by spool when a file exceeds the allowed size
+var errTooLarge = errors.New("file too large")
+
+// spool copies an uploaded file into a temporary file so its size is known
+// before it is stored, failing with errTooLarge past limit bytes
+func spool(src io.Reader, limit int64) (*os.File, int64, error) {
+	tmp, err := os.CreateTemp("", "attachment-*")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	size, err := io.Copy(tmp, io.LimitReader(src, limit+1))
+	if err == nil && size > limit {
+		err = errTooLarge
+	}
+	if err == nil {
+		_, err = tmp.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, 0, err
+	}
+
+	return tmp, size, nil
+}
+
+// uploadLimit returns the most bytes the next file of an upload may have,
+// given how much of the user's quota remains, along with the error code and
+// detail to respond with if the file is larger
+func uploadLimit(maxUploadSize, remaining int64) (int64, string, string) {
+	if remaining < maxUploadSize {
+		return max(remaining, 0), problem.CodeQuotaExceeded, "Storage quota exceeded"
+	}
+	return maxUploadSize, problem.CodePayloadTooLarge, "File exceeds the maximum upload size"
+}
+
+// sniffContentType detects a file's content type from its first bytes,
+// ignoring whatever type the client claimed
+func sniffContentType(file io.ReadSeeker) (string, error) {
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return http.DetectContentType(head[:n]), nil
+}
+
+// Upload handles uploading one or more files to a todo as multipart/form-data.
+// The upload succeeds or fails as a whole: no file is kept unless all are.
+func (c *AttachmentController) Upload(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	todo := loadAccessibleTodo(w, r, c.todoRepo, c.workspaceRepo, userID)
+	if todo == nil {
+		return
+	}
+
+	// Work out how much the user may still upload, to stop reading a file
+	// as soon as it can't fit. The quota is enforced when the files are
+	// recorded, since other uploads may run at the same time.
+	used, err := c.attachmentRepo.GetUsageByUserID(userID)
+	if err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to upload attachment")
+		return
+	}
+	remaining := c.quota - used
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		problem.Error(w, http.StatusBadRequest, problem.CodeInvalidRequest, "Expected a multipart/form-data request")
+		return
+	}
+
+	// Every file is stored before any is recorded; if the upload fails,
+	// the files stored so far are deleted
+	attachments := []*models.Attachment{}
+	recorded := false
+	defer func() {
+		if !recorded {
+			c.deleteBlobs(context.WithoutCancel(r.Context()), attachments)
+		}
+	}()
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			problem.Error(w, http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid multipart payload")
+			return
+		}
+
+		// Skip non-file fields
+		if part.FileName() == "" {
+			part.Close()
+			continue
+		}
+
+		limit, code, detail := uploadLimit(c.maxUploadSize, remaining)
+		file, size, err := spool(part, limit)
+		part.Close()
+		if err == errTooLarge {
+			problem.Error(w, http.StatusRequestEntityTooLarge, code, detail)
+			return
+		}
+		if err != nil {
+			problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to upload attachment")
+			return
+		}
+
+		attachment, err := c.put(r.Context(), todo, userID, part.FileName(), file, size)
+		file.Close()
+		os.Remove(file.Name())
+		if err != nil {
+			problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to upload attachment")
+			return
+		}
+
+		remaining -= size
+		attachments = append(attachments, attachment)
+	}
+
+	if len(attachments) == 0 {
+		problem.Error(w, http.StatusBadRequest, problem.CodeInvalidRequest, "No files were uploaded")
+		return
+	}
+
+	if err := c.attachmentRepo.CreateAll(userID, attachments, c.quota); err != nil {
+		if errors.Is(err, repository.ErrQuotaExceeded) {
+			problem.Error(w, http.StatusRequestEntityTooLarge, problem.CodeQuotaExceeded, "Storage quota exceeded")
+			return
+		}
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to upload attachment")
+		return
+	}
+	recorded = true
+
+	// Return the created attachments
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(attachments)
+}
+
+// maxFilenameLength is the longest filename kept, in characters
+const maxFilenameLength = 255
+
+// attachmentFilename returns the name to keep for an uploaded file: the last
+// element of the path the client sent, with either kind of separator, cut to
+// maxFilenameLength characters while keeping its extension
+func attachmentFilename(name string) string {
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == ".." {
+		return "file"
+	}
+
+	runes := []rune(name)
+	if len(runes) <= maxFilenameLength {
+		return name
+	}
+	ext := []rune(filepath.Ext(name))
+	if len(ext) > maxFilenameLength/2 {
+		ext = nil
+	}
+	return string(runes[:maxFilenameLength-len(ext)]) + string(ext)
+}
+
+// put stores a spooled file in the blob store, returning the attachment to
+// record for it
+func (c *AttachmentController) put(ctx context.Context, todo *models.Todo, userID uuid.UUID, filename string, file *os.File, size int64) (*models.Attachment, error) {
+	contentType, err := sniffContentType(file)
+	if err != nil {
+		return nil, err
+	}
+
+	attachment := &models.Attachment{
+		ID:          uuid.New(),
+		TodoID:      todo.ID,
+		UserID:      userID,
+		Filename:    attachmentFilename(filename),
+		ContentType: contentType,
+		Size:        size,
+	}
+	attachment.StorageKey = "attachments/" + userID.String() + "/" + attachment.ID.String()
+
+	if err := c.store.Put(ctx, attachment.StorageKey, file, size, contentType); err != nil {
+		return nil, err
+	}
+
+	return attachment, nil
+}
+
+// deleteBlobs deletes the stored files of attachments that weren't recorded,
+// so they aren't left orphaned
+func (c *AttachmentController) deleteBlobs(ctx context.Context, attachments []*models.Attachment) {
+	for _, attachment := range attachments {
+		if err := c.store.Delete(ctx, attachment.StorageKey); err != nil {
+			log.Printf("Failed to delete orphaned blob %s: %v", attachment.StorageKey, err)
+		}
+	}
+}
+
+// GetAll handles listing the attachments of a todo
+func (c *AttachmentController) GetAll(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	todo := loadAccessibleTodo(w, r, c.todoRepo, c.workspaceRepo, userID)
+	if todo == nil {
+		return
+	}
+
+	attachments, err := c.attachmentRepo.GetAllByTodoID(todo.ID)
+	if err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to get attachments")
+		return
+	}
+
+	// Return the attachments
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(attachments)
+}
+
+// Download handles downloading an attachment, including range requests
+func (c *AttachmentController) Download(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	todo := loadAccessibleTodo(w, r, c.todoRepo, c.workspaceRepo, userID)
+	if todo == nil {
+		return
+	}
+
+	attachment := c.getAttachment(w, r, todo)
+	if attachment == nil {
+		return
+	}
+
+	blob, err := c.store.Open(r.Context(), attachment.StorageKey, attachment.Size)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			problem.Error(w, http.StatusNotFound, problem.CodeNotFound, "Attachment not found")
+			return
+		}
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to download attachment")
+		return
+	}
+	defer blob.Close()
+
+	// Attachments never change, so their ID makes a strong ETag for If-Range
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("ETag", `"`+attachment.ID.String()+`"`)
+
+	http.ServeContent(w, r, attachment.Filename, attachment.CreatedAt, blob)
+}
+
+// Delete handles deleting an attachment (uploader or todo creator only)
+func (c *AttachmentController) Delete(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	todo := loadAccessibleTodo(w, r, c.todoRepo, c.workspaceRepo, userID)
+	if todo == nil {
+		return
+	}
+
+	attachment := c.getAttachment(w, r, todo)
+	if attachment == nil {
+		return
+	}
+
+	if attachment.UserID != userID && todo.UserID != userID {
+		problem.Error(w, http.StatusForbidden, problem.CodeForbidden, "Only the uploader can delete this attachment")
+		return
+	}
+
+	if err := c.attachmentRepo.Delete(attachment.ID); err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to delete attachment")
+		return
+	}
+
+	if err := c.store.Delete(r.Context(), attachment.StorageKey); err != nil {
+		log.Printf("Failed to delete blob %s: %v", attachment.StorageKey, err)
+	}
+
+	// Return success
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Usage handles reporting the user's storage usage and quota
+func (c *AttachmentController) Usage(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	used, err := c.attachmentRepo.GetUsageByUserID(userID)
+	if err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to get storage usage")
+		return
+	}
+
+	// Return the usage
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.StorageUsageResponse{UsedBytes: used, QuotaBytes: c.quota})
+}
@@ -0,0 +1,97 @@
+package controllers
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/noman/todo-application/problem"
+)
+
+func TestUploadLimit(t *testing.T) {
+	tests := []struct {
+		name          string
+		maxUploadSize int64
+		remaining     int64
+		limit         int64
+		code          string
+	}{
+		{"plenty of quota", 25, 100, 25, problem.CodePayloadTooLarge},
+		{"quota equals the upload size", 25, 25, 25, problem.CodePayloadTooLarge},
+		{"quota below the upload size", 25, 10, 10, problem.CodeQuotaExceeded},
+		{"quota used up", 25, 0, 0, problem.CodeQuotaExceeded},
+		{"over quota", 25, -5, 0, problem.CodeQuotaExceeded},
+	}
+	for _, tt := range tests {
+		limit, code, _ := uploadLimit(tt.maxUploadSize, tt.remaining)
+		if limit != tt.limit || code != tt.code {
+			t.Errorf("%s: got %d %s, want %d %s", tt.name, limit, code, tt.limit, tt.code)
+		}
+	}
+}
+
+func TestSpool(t *testing.T) {
+	tests := []struct {
+		content string
+		limit   int64
+		err     error
+	}{
+		{"", 0, nil},
+		{"", 10, nil},
+		{"abc", 3, nil},
+		{"abc", 10, nil},
+		{"abcd", 3, errTooLarge},
+		{"a", 0, errTooLarge},
+	}
+	for _, tt := range tests {
+		// Spooled files go to the temporary directory
+		dir := t.TempDir()
+		t.Setenv("TMPDIR", dir)
+
+		file, size, err := spool(strings.NewReader(tt.content), tt.limit)
+		if err != tt.err {
+			t.Errorf("%q with limit %d: got error %v, want %v", tt.content, tt.limit, err, tt.err)
+		}
+		if err != nil {
+			// Nothing is left behind on failure
+			if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+				t.Errorf("%q with limit %d: left %d files behind", tt.content, tt.limit, len(entries))
+			}
+			continue
+		}
+
+		data, readErr := io.ReadAll(file)
+		file.Close()
+		os.Remove(file.Name())
+		if readErr != nil {
+			t.Fatal(readErr)
+		}
+		if size != int64(len(tt.content)) || string(data) != tt.content {
+			t.Errorf("%q with limit %d: got %d bytes %q", tt.content, tt.limit, size, data)
+		}
+	}
+}
+
+func TestAttachmentFilename(t *testing.T) {
+	long := strings.Repeat("a", 300)
+	tests := []struct {
+		name, want string
+	}{
+		{"report.pdf", "report.pdf"},
+		{"/home/user/report.pdf", "report.pdf"},
+		{`C:\Users\me\report.pdf`, "report.pdf"},
+		{`..\..\etc/passwd`, "passwd"},
+		{"dir/", "file"},
+		{"..", "file"},
+		{"  ", "file"},
+		{long + ".pdf", strings.Repeat("a", 251) + ".pdf"},
+		{long, strings.Repeat("a", 255)},
+		{strings.Repeat("é", 300), strings.Repeat("é", 255)},
+	}
+	for _, tt := range tests {
+		if got := attachmentFilename(tt.name); got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -2,11 +2,14 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strings"
 
+	"github.com/noman/todo-application/events"
 	"github.com/noman/todo-application/middleware"
 	"github.com/noman/todo-application/models"
+	"github.com/noman/todo-application/problem"
 	"github.com/noman/todo-application/repository"
 )
 
@@ -26,21 +29,18 @@ func NewAuthController() *AuthController {
 func (c *AuthController) Register(w http.ResponseWriter, r *http.Request) {
 	// Parse the request body
 	var req models.RegisterRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
-		return
-	}
-
-	// Validate the request
-	if req.Username == "" || req.Email == "" || req.Password == "" {
-		http.Error(w, "Username, email, and password are required", http.StatusBadRequest)
+	if !decodeRequest(w, r.Body, &req) {
 		return
 	}
 
 	// Check if the user already exists
 	_, err := c.userRepo.GetByEmail(req.Email)
 	if err == nil {
-		http.Error(w, "User with this email already exists", http.StatusConflict)
+		problem.Error(w, http.StatusConflict, problem.CodeConflict, "User with this email already exists")
+		return
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to create user")
 		return
 	}
 
@@ -52,14 +52,14 @@ func (c *AuthController) Register(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := c.userRepo.Create(user); err != nil {
-		http.Error(w, "Failed to create user", http.StatusInternalServerError)
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to create user")
 		return
 	}
 
 	// Generate a token for the user
 	token, err := middleware.GenerateToken(user.ID)
 	if err != nil {
-		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to generate token")
 		return
 	}
 
@@ -72,28 +72,25 @@ func (c *AuthController) Register(w http.ResponseWriter, r *http.Request) {
 func (c *AuthController) Login(w http.ResponseWriter, r *http.Request) {
 	// Parse the request body
 	var req models.LoginRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
-		return
-	}
-
-	// Validate the request
-	if req.Email == "" || req.Password == "" {
-		http.Error(w, "Email and password are required", http.StatusBadRequest)
+	if !decodeRequest(w, r.Body, &req) {
 		return
 	}
 
 	// Verify the user's credentials
 	user, err := c.userRepo.VerifyPassword(req.Email, req.Password)
 	if err != nil {
-		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+		if errors.Is(err, repository.ErrNotFound) || errors.Is(err, repository.ErrInvalidPassword) {
+			problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Invalid credentials")
+			return
+		}
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to log in")
 		return
 	}
 
 	// Generate a token for the user
 	token, err := middleware.GenerateToken(user.ID)
 	if err != nil {
-		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to generate token")
 		return
 	}
 
@@ -107,14 +104,14 @@ func (c *AuthController) Logout(w http.ResponseWriter, r *http.Request) {
 	// Get the user ID from the context
 	userID, err := middleware.GetUserIDFromContext(r)
 	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
 		return
 	}
 
 	// Get the token from the Authorization header
 	authHeader := r.Header.Get("Authorization")
 	if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-		http.Error(w, "Invalid authorization format", http.StatusUnauthorized)
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Invalid authorization format")
 		return
 	}
 
@@ -124,7 +121,11 @@ func (c *AuthController) Logout(w http.ResponseWriter, r *http.Request) {
 	// Parse the token to get the expiration time
 	claims, err := middleware.ValidateToken(tokenString)
 	if err != nil {
-		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		if errors.Is(err, middleware.ErrInvalidToken) {
+			problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Invalid token")
+			return
+		}
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to logout")
 		return
 	}
 
@@ -133,10 +134,13 @@ func (c *AuthController) Logout(w http.ResponseWriter, r *http.Request) {
 
 	// Add the token to the blacklist
 	if err := tokenRepo.BlacklistToken(tokenString, userID, claims.ExpiresAt.Time); err != nil {
-		http.Error(w, "Failed to logout", http.StatusInternalServerError)
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to logout")
 		return
 	}
 
+	// End the event streams opened with the token
+	events.RevokeAccess(events.Default, userID)
+
 	// Return success response
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"message": "Successfully logged out"})
@@ -0,0 +1,294 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/noman/todo-application/events"
+	"github.com/noman/todo-application/middleware"
+	"github.com/noman/todo-application/models"
+	"github.com/noman/todo-application/patch"
+	"github.com/noman/todo-application/problem"
+	"github.com/noman/todo-application/repository"
+	"github.com/noman/todo-application/validation"
+)
+
+// batchError is a failed batch operation and the status and error code it's
+// reported with
+type batchError struct {
+	status  int
+	code    string
+	message string
+}
+
+func (e *batchError) Error() string {
+	return e.message
+}
+
+// Batch handles running several todo operations in a single transaction. In
+// atomic mode (the default) the first failure rolls back the whole batch; in
+// independent mode each operation is applied or rolled back on its own.
+func (c *TodoController) Batch(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Parse the request body
+	var req models.BatchRequest
+	if !decodeRequest(w, r.Body, &req) {
+		return
+	}
+	if req.Mode == "" {
+		req.Mode = models.BatchModeAtomic
+	}
+
+	batch, err := repository.BeginBatch()
+	if err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to run batch")
+		return
+	}
+	defer batch.Rollback()
+	todoRepo := batch.Todos()
+
+	results := make([]models.BatchResult, len(req.Operations))
+	applied := make(map[int]*models.Todo) // Todos changed by operations that succeeded
+	failed := -1
+	for i, op := range req.Operations {
+		results[i] = models.BatchResult{Index: i, Op: op.Op, ID: op.ID}
+
+		// In independent mode each operation gets a savepoint to roll back to
+		var savepoint string
+		if req.Mode == models.BatchModeIndependent {
+			savepoint, err = batch.Savepoint()
+			if err != nil {
+				problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to run batch")
+				return
+			}
+		}
+
+		status, todo, err := c.runBatchOperation(todoRepo, userID, op)
+		if err != nil {
+			var opErr *batchError
+			if !errors.As(err, &opErr) {
+				opErr = &batchError{http.StatusInternalServerError, problem.CodeInternal, "Failed to " + op.Op + " todo"}
+			}
+			results[i].Status = opErr.status
+			results[i].Code = opErr.code
+			results[i].Error = opErr.message
+
+			if req.Mode == models.BatchModeAtomic {
+				failed = i
+				break
+			}
+			if err := batch.RollbackTo(savepoint); err != nil {
+				problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to run batch")
+				return
+			}
+			continue
+		}
+
+		results[i].Status = status
+		results[i].ID = &todo.ID
+		if op.Op != models.BatchOpDelete {
+			response := todo.ToResponse()
+			results[i].Todo = &response
+		}
+		applied[i] = todo
+
+		if savepoint != "" {
+			if err := batch.Release(savepoint); err != nil {
+				problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to run batch")
+				return
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	// A failed atomic batch is rolled back; every other operation is reported
+	// as failed because of the one that broke it
+	if failed >= 0 {
+		for i := range results {
+			if i == failed {
+				continue
+			}
+			results[i] = models.BatchResult{
+				Index:  i,
+				Op:     req.Operations[i].Op,
+				Status: http.StatusFailedDependency,
+				ID:     req.Operations[i].ID,
+				Code:   problem.CodeBatchRolledBack,
+				Error:  "Batch was rolled back",
+			}
+		}
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(models.BatchResponse{Committed: false, Results: results})
+		return
+	}
+
+	if err := batch.Commit(); err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to run batch")
+		return
+	}
+
+	// Publish the changes now that they're committed
+	for i, op := range req.Operations {
+		todo, ok := applied[i]
+		if !ok {
+			continue
+		}
+		eventType := events.TypeTodoUpdated
+		switch op.Op {
+		case models.BatchOpCreate:
+			eventType = events.TypeTodoCreated
+		case models.BatchOpDelete:
+			eventType = events.TypeTodoDeleted
+		}
+		c.publish(eventType, todo, userID)
+	}
+
+	// Return the results
+	json.NewEncoder(w).Encode(models.BatchResponse{Committed: true, Results: results})
+}
+
+// runBatchOperation runs one batch operation and returns the status to report
+// for it along with the affected todo
+func (c *TodoController) runBatchOperation(todoRepo *repository.TodoRepository, userID uuid.UUID, op models.BatchOperation) (int, *models.Todo, error) {
+	if op.Op == models.BatchOpCreate {
+		return c.batchCreate(todoRepo, userID, op)
+	}
+
+	// Every other operation works on an existing todo
+	if op.ID == nil {
+		return 0, nil, &batchError{http.StatusBadRequest, problem.CodeInvalidRequest, "Todo ID is required"}
+	}
+
+	todo, err := todoRepo.GetByID(*op.ID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return 0, nil, &batchError{http.StatusNotFound, problem.CodeNotFound, "Todo not found"}
+		}
+		return 0, nil, err
+	}
+
+	allowed, err := canAccessTodo(c.workspaceRepo, todo, userID)
+	if err != nil {
+		return 0, nil, err
+	}
+	if !allowed {
+		return 0, nil, &batchError{http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized"}
+	}
+
+	if op.Version != nil && *op.Version != todo.Version {
+		return 0, nil, &batchError{http.StatusPreconditionFailed, problem.CodeVersionConflict, "Todo has been modified"}
+	}
+
+	switch op.Op {
+	case models.BatchOpUpdate:
+		current, err := json.Marshal(todo.Document())
+		if err != nil {
+			return 0, nil, err
+		}
+		patched, err := patch.MergePatch(current, op.Todo)
+		if err != nil {
+			return 0, nil, &batchError{http.StatusUnprocessableEntity, problem.CodeValidationFailed, err.Error()}
+		}
+		var doc models.UpdateTodoRequest
+		if err := validation.Decode(bytes.NewReader(patched), &doc); err != nil {
+			return 0, nil, &batchError{http.StatusUnprocessableEntity, problem.CodeValidationFailed, err.Error()}
+		}
+		if !c.validateProject(todo.WorkspaceID, doc.ProjectID) {
+			return 0, nil, &batchError{http.StatusUnprocessableEntity, problem.CodeValidationFailed, "Project does not belong to this workspace"}
+		}
+
+		todo.Apply(doc)
+
+	case models.BatchOpComplete:
+		todo.Completed = true
+
+	case models.BatchOpDelete:
+		if err := todoRepo.Delete(todo, userID); err != nil {
+			return 0, nil, batchRepositoryError(err)
+		}
+		return http.StatusNoContent, todo, nil
+	}
+
+	if err := todoRepo.Update(todo, userID); err != nil {
+		return 0, nil, batchRepositoryError(err)
+	}
+	return http.StatusOK, todo, nil
+}
+
+// batchCreate runs a batch "create" operation
+func (c *TodoController) batchCreate(todoRepo *repository.TodoRepository, userID uuid.UUID, op models.BatchOperation) (int, *models.Todo, error) {
+	todo, err := c.createTodo(todoRepo, userID, op.Todo, uuid.Nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusCreated, todo, nil
+}
+
+// createTodo validates a CreateTodoRequest and creates the todo with the
+// given ID, or a new one if it's uuid.Nil. Invalid requests fail with a *batchError.
+func (c *TodoController) createTodo(todoRepo *repository.TodoRepository, userID uuid.UUID, body json.RawMessage, id uuid.UUID) (*models.Todo, error) {
+	var req models.CreateTodoRequest
+	if err := validation.Decode(bytes.NewReader(body), &req); err != nil {
+		if errors.Is(err, validation.ErrMalformed) {
+			return nil, &batchError{http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid todo"}
+		}
+		return nil, &batchError{http.StatusUnprocessableEntity, problem.CodeValidationFailed, err.Error()}
+	}
+
+	if req.WorkspaceID != nil {
+		isMember, err := c.workspaceRepo.IsMember(*req.WorkspaceID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if !isMember {
+			return nil, &batchError{http.StatusForbidden, problem.CodeForbidden, "Not a member of this workspace"}
+		}
+	}
+
+	if !c.validateProject(req.WorkspaceID, req.ProjectID) {
+		return nil, &batchError{http.StatusBadRequest, problem.CodeInvalidRequest, "Project does not belong to this workspace"}
+	}
+
+	valid, err := c.validateAssignees(req.WorkspaceID, userID, req.AssigneeIDs)
+	if err != nil {
+		return nil, err
+	}
+	if !valid {
+		return nil, &batchError{http.StatusBadRequest, problem.CodeInvalidRequest, "Assignees must be members of the workspace"}
+	}
+
+	todo := &models.Todo{
+		ID:          id,
+		Title:       req.Title,
+		Description: req.Description,
+		UserID:      userID,
+		WorkspaceID: req.WorkspaceID,
+		ProjectID:   req.ProjectID,
+		AssigneeIDs: req.AssigneeIDs,
+		Tags:        req.Tags,
+		Priority:    req.Priority,
+		DueAt:       req.DueAt,
+	}
+	if err := todoRepo.Create(todo); err != nil {
+		return nil, err
+	}
+	return todo, nil
+}
+
+// batchRepositoryError maps a repository error to a batch operation failure
+func batchRepositoryError(err error) error {
+	if errors.Is(err, repository.ErrVersionConflict) {
+		return &batchError{http.StatusPreconditionFailed, problem.CodeVersionConflict, "Todo has been modified"}
+	}
+	return err
+}
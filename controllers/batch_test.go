@@ -0,0 +1,184 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/noman/todo-application/database/databasetest"
+	"github.com/noman/todo-application/events"
+	"github.com/noman/todo-application/models"
+	"github.com/noman/todo-application/problem"
+	"github.com/noman/todo-application/repository"
+)
+
+// runBatch sends a batch request as the user, or without one if userID is
+// uuid.Nil, and decodes the batch response if there is one
+func runBatch(t *testing.T, c *TodoController, userID uuid.UUID, body string) (*httptest.ResponseRecorder, models.BatchResponse) {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodPost, "/api/todos/batch", strings.NewReader(body))
+	if userID != uuid.Nil {
+		r = r.WithContext(context.WithValue(r.Context(), "userID", userID))
+	}
+	rec := httptest.NewRecorder()
+	c.Batch(rec, r)
+
+	var resp models.BatchResponse
+	if rec.Code == http.StatusOK || rec.Code == http.StatusUnprocessableEntity {
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+	}
+	return rec, resp
+}
+
+// newTodo creates a todo for the user
+func newTodo(t *testing.T, userID uuid.UUID, title string) *models.Todo {
+	t.Helper()
+	todo := &models.Todo{Title: title, UserID: userID}
+	if err := repository.NewTodoRepository().Create(todo); err != nil {
+		t.Fatal(err)
+	}
+	return todo
+}
+
+// userTodos returns the user's todos by title
+func userTodos(t *testing.T, userID uuid.UUID) map[string]*models.Todo {
+	t.Helper()
+	todos, err := repository.NewTodoRepository().List(models.TodoFilter{UserID: userID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	byTitle := map[string]*models.Todo{}
+	for _, todo := range todos {
+		byTitle[todo.Title] = todo
+	}
+	return byTitle
+}
+
+// statuses returns the status of each batch result
+func statuses(resp models.BatchResponse) []int {
+	var statuses []int
+	for _, result := range resp.Results {
+		statuses = append(statuses, result.Status)
+	}
+	return statuses
+}
+
+func TestBatchRejectsInvalidRequests(t *testing.T) {
+	c := NewTodoController()
+	userID := uuid.New()
+
+	tests := []struct {
+		name   string
+		userID uuid.UUID
+		body   string
+		want   int
+	}{
+		{"no user", uuid.Nil, `{"operations":[]}`, http.StatusUnauthorized},
+		{"malformed", userID, `{"operations":`, http.StatusBadRequest},
+		{"unknown mode", userID, `{"mode":"sometimes","operations":[{"op":"create"}]}`, http.StatusUnprocessableEntity},
+		{"unknown operation", userID, `{"operations":[{"op":"archive"}]}`, http.StatusUnprocessableEntity},
+		{"no operations", userID, `{}`, http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		rec, _ := runBatch(t, c, tt.userID, tt.body)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, rec.Code, tt.want, rec.Body.String())
+		}
+	}
+}
+
+func TestBatchAtomicRollsBack(t *testing.T) {
+	databasetest.Open(t)
+	user := databasetest.NewUser(t)
+	c := NewTodoController()
+	c.broker = events.NewMemoryBroker()
+	existing := newTodo(t, user.ID, "Existing")
+
+	// The delete of a missing todo fails the batch after two operations
+	// already changed the database
+	rec, resp := runBatch(t, c, user.ID, `{"operations":[
+		{"op":"create","todo":{"title":"Created"}},
+		{"op":"update","id":"`+existing.ID.String()+`","todo":{"title":"Renamed"}},
+		{"op":"delete","id":"`+uuid.NewString()+`"},
+		{"op":"complete","id":"`+existing.ID.String()+`"}
+	]}`)
+	if rec.Code != http.StatusUnprocessableEntity || resp.Committed {
+		t.Fatalf("status %d, committed %v: %s", rec.Code, resp.Committed, rec.Body.String())
+	}
+	want := []int{http.StatusFailedDependency, http.StatusFailedDependency, http.StatusNotFound, http.StatusFailedDependency}
+	if got := statuses(resp); len(got) != len(want) {
+		t.Fatalf("statuses %v, want %v", got, want)
+	}
+	for i, result := range resp.Results {
+		if result.Index != i || result.Status != want[i] {
+			t.Errorf("result %d = %+v, want status %d", i, result, want[i])
+		}
+		wantCode := problem.CodeBatchRolledBack
+		if i == 2 {
+			wantCode = problem.CodeNotFound
+		}
+		if result.Code != wantCode || result.Todo != nil {
+			t.Errorf("result %d = %+v, want code %s and no todo", i, result, wantCode)
+		}
+	}
+
+	// Nothing the batch did was kept
+	todos := userTodos(t, user.ID)
+	if len(todos) != 1 || todos["Existing"] == nil {
+		t.Fatalf("todos after the batch: %v", todos)
+	}
+	if got := todos["Existing"]; got.Completed || got.Version != existing.Version {
+		t.Errorf("existing todo changed to %+v", got)
+	}
+}
+
+func TestBatchIndependentSavepoints(t *testing.T) {
+	databasetest.Open(t)
+	user := databasetest.NewUser(t)
+	c := NewTodoController()
+	c.broker = events.NewMemoryBroker()
+	existing := newTodo(t, user.ID, "Existing")
+	removed := newTodo(t, user.ID, "Removed")
+
+	// Each failure is rolled back to its savepoint and the rest is committed
+	rec, resp := runBatch(t, c, user.ID, `{"mode":"independent","operations":[
+		{"op":"create","todo":{"title":"Created"}},
+		{"op":"update","id":"`+existing.ID.String()+`","version":7,"todo":{"title":"Stale"}},
+		{"op":"create","todo":{"description":"No title"}},
+		{"op":"complete","id":"`+existing.ID.String()+`","version":1},
+		{"op":"delete","id":"`+uuid.NewString()+`"},
+		{"op":"delete","id":"`+removed.ID.String()+`"}
+	]}`)
+	if rec.Code != http.StatusOK || !resp.Committed {
+		t.Fatalf("status %d, committed %v: %s", rec.Code, resp.Committed, rec.Body.String())
+	}
+	want := []int{http.StatusCreated, http.StatusPreconditionFailed, http.StatusUnprocessableEntity, http.StatusOK, http.StatusNotFound, http.StatusNoContent}
+	got := statuses(resp)
+	if len(got) != len(want) {
+		t.Fatalf("statuses %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("statuses %v, want %v", got, want)
+			break
+		}
+	}
+	if code := resp.Results[1].Code; code != problem.CodeVersionConflict {
+		t.Errorf("stale update has code %s, want %s", code, problem.CodeVersionConflict)
+	}
+	if result := resp.Results[0]; result.Todo == nil || result.ID == nil || result.Todo.Title != "Created" {
+		t.Errorf("create result = %+v", result)
+	}
+
+	todos := userTodos(t, user.ID)
+	if len(todos) != 2 || todos["Created"] == nil || todos["Existing"] == nil {
+		t.Fatalf("todos after the batch: %v", todos)
+	}
+	if got := todos["Existing"]; !got.Completed || got.Version != existing.Version+1 {
+		t.Errorf("existing todo = %+v, want it completed once", got)
+	}
+}
@@ -0,0 +1,929 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/noman/todo-application/dav"
+	"github.com/noman/todo-application/events"
+	"github.com/noman/todo-application/ical"
+	"github.com/noman/todo-application/middleware"
+	"github.com/noman/todo-application/models"
+	"github.com/noman/todo-application/problem"
+	"github.com/noman/todo-application/repository"
+	"github.com/noman/todo-application/validation"
+)
+
+// CalDAVRoot is the path CalDAV is served under. Each user's calendar home
+// is "<root><user ID>/", holding a "personal" calendar and one per workspace,
+// whose todos are "<name>.ics" resources.
+const CalDAVRoot = "/caldav/"
+
+// caldavPersonal is the name of the calendar holding a user's personal todos
+const caldavPersonal = "personal"
+
+// caldavSyncTokenPrefix turns sync cursors into the URIs RFC 6578 requires
+// sync tokens to be
+const caldavSyncTokenPrefix = "http://todo-application/ns/sync/"
+
+// maxCalDAVBody limits the size of CalDAV request bodies
+const maxCalDAVBody = 1 << 20
+
+// caldavObjectType is the content type of the todos' calendar objects
+const caldavObjectType = "text/calendar; charset=utf-8; component=VTODO"
+
+// CalDAVMethods are the methods CalDAV resources support
+var CalDAVMethods = []string{"OPTIONS", "GET", "HEAD", "PUT", "DELETE", "PROPFIND", "PROPPATCH", "REPORT"}
+
+// caldavCollection is a calendar: a user's personal todos, or a workspace's
+type caldavCollection struct {
+	name      string
+	workspace *models.Workspace // nil for personal todos
+}
+
+// workspaceID returns the ID of the collection's workspace, or nil
+func (col *caldavCollection) workspaceID() *uuid.UUID {
+	if col.workspace == nil {
+		return nil
+	}
+	return &col.workspace.ID
+}
+
+// contains checks if a todo belongs in the collection
+func (col *caldavCollection) contains(todo *models.Todo, userID uuid.UUID) bool {
+	if col.workspace == nil {
+		return todo.WorkspaceID == nil && todo.UserID == userID
+	}
+	return todo.WorkspaceID != nil && *todo.WorkspaceID == col.workspace.ID
+}
+
+// caldavObject is a todo served as a calendar object resource
+type caldavObject struct {
+	todo *models.Todo
+	name string
+	uid  string
+}
+
+// calendar returns the calendar object the todo is served as
+func (o *caldavObject) calendar() []byte {
+	calendar := ical.NewCalendar(calendarProdID)
+	calendar.AddComponent(todoComponent(o.todo, o.uid))
+	var body bytes.Buffer
+	calendar.Encode(&body)
+	return body.Bytes()
+}
+
+// propRequest is the set of properties a PROPFIND or REPORT asks for
+type propRequest struct {
+	all   bool // allprop: every property except calendar-data
+	names bool // propname: the names of the properties only
+	props []xml.Name
+}
+
+// newPropRequest builds a propRequest from a request's allprop, propname
+// and prop elements
+func newPropRequest(allProp, propName *struct{}, prop *dav.Prop) propRequest {
+	switch {
+	case propName != nil:
+		return propRequest{names: true}
+	case prop != nil:
+		return propRequest{props: prop.Names()}
+	default:
+		return propRequest{all: true}
+	}
+}
+
+// respond builds the multistatus response for the resource at path with the
+// given properties
+func (p propRequest) respond(path string, available []dav.Property) dav.Response {
+	response := dav.Response{Href: escapePath(path)}
+	if p.all || p.names {
+		for _, property := range available {
+			if property.Name == dav.CalDAVName("calendar-data") {
+				continue
+			}
+			if p.names {
+				property = dav.Property{Name: property.Name}
+			}
+			response.Found = append(response.Found, property)
+		}
+		return response
+	}
+
+	for _, name := range p.props {
+		found := false
+		for _, property := range available {
+			if property.Name == name {
+				response.Found = append(response.Found, property)
+				found = true
+				break
+			}
+		}
+		if !found {
+			response.NotFound = append(response.NotFound, name)
+		}
+	}
+	return response
+}
+
+// escapePath escapes a path for use in an href or Location header
+func escapePath(path string) string {
+	return (&url.URL{Path: path}).EscapedPath()
+}
+
+// caldavHome returns the path of a user's calendar home, which is also their
+// principal
+func caldavHome(userID uuid.UUID) string {
+	return CalDAVRoot + userID.String() + "/"
+}
+
+// CalDAV handles every CalDAV request. Todos map onto VTODO resources; only
+// their title, description, completion, due date, priority and categories
+// are kept, so other properties such as alarms are dropped when a client
+// saves a todo.
+func (c *TodoController) CalDAV(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	w.Header().Set("DAV", "1, 3, calendar-access")
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Allow", strings.Join(CalDAVMethods, ", "))
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxCalDAVBody)
+
+	// Split the path into the user, collection and resource
+	rest := strings.TrimPrefix(r.URL.Path, strings.TrimSuffix(CalDAVRoot, "/"))
+	segments := strings.Split(strings.Trim(rest, "/"), "/")
+	if segments[0] == "" {
+		segments = nil
+	}
+
+	// The root only points clients at their own calendar home
+	if len(segments) == 0 {
+		c.caldavRoot(w, r, userID)
+		return
+	}
+
+	// Users can only see their own calendar home
+	if segments[0] != userID.String() || len(segments) > 3 {
+		problem.Error(w, http.StatusNotFound, problem.CodeNotFound, "Not found")
+		return
+	}
+
+	if len(segments) == 1 {
+		c.caldavHome(w, r, userID)
+		return
+	}
+
+	collection, err := c.caldavCollection(userID, segments[1])
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			problem.Error(w, http.StatusNotFound, problem.CodeNotFound, "Calendar not found")
+			return
+		}
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to get calendar")
+		return
+	}
+
+	if len(segments) == 2 {
+		c.caldavCalendar(w, r, userID, collection)
+		return
+	}
+
+	c.caldavResource(w, r, userID, collection, segments[2])
+}
+
+// caldavRoot handles requests for the CalDAV root
+func (c *TodoController) caldavRoot(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+	if r.Method != "PROPFIND" {
+		caldavMethodNotAllowed(w)
+		return
+	}
+
+	propfind, ok := readPropfind(w, r)
+	if !ok {
+		return
+	}
+
+	home := dav.Href(escapePath(caldavHome(userID)))
+	properties := []dav.Property{
+		{Name: dav.Name("resourcetype"), InnerXML: "<D:collection/>"},
+		{Name: dav.Name("current-user-principal"), InnerXML: home},
+	}
+	multistatus := &dav.Multistatus{Responses: []dav.Response{propfind.respond(CalDAVRoot, properties)}}
+	multistatus.Write(w)
+}
+
+// caldavHome handles requests for a user's calendar home and principal
+func (c *TodoController) caldavHome(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+	if r.Method != "PROPFIND" {
+		if r.Method == "PROPPATCH" {
+			caldavPropPatch(w, r, caldavHome(userID))
+			return
+		}
+		caldavMethodNotAllowed(w)
+		return
+	}
+
+	propfind, ok := readPropfind(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := c.userRepo.GetByID(userID)
+	if err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to get user")
+		return
+	}
+
+	homePath := caldavHome(userID)
+	home := dav.Href(escapePath(homePath))
+	properties := []dav.Property{
+		{Name: dav.Name("resourcetype"), InnerXML: "<D:collection/><D:principal/>"},
+		{Name: dav.Name("displayname"), Value: user.Username},
+		{Name: dav.Name("current-user-principal"), InnerXML: home},
+		{Name: dav.Name("principal-URL"), InnerXML: home},
+		{Name: dav.Name("owner"), InnerXML: home},
+		{Name: dav.CalDAVName("calendar-home-set"), InnerXML: home},
+		{Name: dav.CalDAVName("calendar-user-address-set"), InnerXML: dav.Href("mailto:" + user.Email)},
+		{Name: dav.Name("current-user-privilege-set"), InnerXML: "<D:privilege><D:read/></D:privilege>"},
+	}
+	responses := []dav.Response{propfind.respond(homePath, properties)}
+
+	// List the calendars
+	if r.Header.Get("Depth") != "0" {
+		collections := []*caldavCollection{{name: caldavPersonal}}
+		workspaces, err := c.workspaceRepo.GetAllByUserID(userID)
+		if err != nil {
+			problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to get calendars")
+			return
+		}
+		for _, workspace := range workspaces {
+			collections = append(collections, &caldavCollection{name: workspace.ID.String(), workspace: workspace})
+		}
+
+		for _, collection := range collections {
+			properties, err := c.caldavCollectionProps(userID, collection)
+			if err != nil {
+				problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to get calendars")
+				return
+			}
+			responses = append(responses, propfind.respond(homePath+collection.name+"/", properties))
+		}
+	}
+
+	multistatus := &dav.Multistatus{Responses: responses}
+	multistatus.Write(w)
+}
+
+// caldavCollection looks up one of a user's calendars by name
+func (c *TodoController) caldavCollection(userID uuid.UUID, name string) (*caldavCollection, error) {
+	if name == caldavPersonal {
+		return &caldavCollection{name: name}, nil
+	}
+
+	workspaceID, err := uuid.Parse(name)
+	if err != nil || workspaceID.String() != name {
+		return nil, repository.ErrWorkspaceNotFound
+	}
+
+	isMember, err := c.workspaceRepo.IsMember(workspaceID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !isMember {
+		return nil, repository.ErrWorkspaceNotFound
+	}
+
+	workspace, err := c.workspaceRepo.GetByID(workspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &caldavCollection{name: name, workspace: workspace}, nil
+}
+
+// caldavCollectionProps returns the properties of a calendar
+func (c *TodoController) caldavCollectionProps(userID uuid.UUID, collection *caldavCollection) ([]dav.Property, error) {
+	// Every change to a todo the user can see moves the head of their change
+	// log, so it serves as the sync token and ctag of all their calendars
+	head, err := c.syncRepo.Head(userID)
+	if err != nil {
+		return nil, err
+	}
+	syncToken := caldavSyncTokenPrefix + head.String()
+
+	displayName := "Personal"
+	if collection.workspace != nil {
+		displayName = collection.workspace.Name
+	}
+
+	home := dav.Href(escapePath(caldavHome(userID)))
+	return []dav.Property{
+		{Name: dav.Name("resourcetype"), InnerXML: "<D:collection/><C:calendar/>"},
+		{Name: dav.Name("displayname"), Value: displayName},
+		{Name: dav.Name("current-user-principal"), InnerXML: home},
+		{Name: dav.Name("owner"), InnerXML: home},
+		{Name: dav.Name("current-user-privilege-set"), InnerXML: "<D:privilege><D:read/></D:privilege>" +
+			"<D:privilege><D:write/></D:privilege><D:privilege><D:write-content/></D:privilege>" +
+			"<D:privilege><D:bind/></D:privilege><D:privilege><D:unbind/></D:privilege>"},
+		{Name: dav.Name("supported-report-set"), InnerXML: "<D:supported-report><D:report><C:calendar-query/></D:report></D:supported-report>" +
+			"<D:supported-report><D:report><C:calendar-multiget/></D:report></D:supported-report>" +
+			"<D:supported-report><D:report><D:sync-collection/></D:report></D:supported-report>"},
+		{Name: dav.CalDAVName("supported-calendar-component-set"), InnerXML: `<C:comp name="VTODO"/>`},
+		{Name: dav.CalDAVName("supported-calendar-data"), InnerXML: `<C:calendar-data content-type="text/calendar" version="2.0"/>`},
+		{Name: dav.CalDAVName("max-resource-size"), Value: strconv.Itoa(maxCalDAVBody)},
+		{Name: dav.Name("sync-token"), Value: syncToken},
+		{Name: xml.Name{Space: dav.NamespaceCalendarServer, Local: "getctag"}, Value: syncToken},
+	}, nil
+}
+
+// caldavObjectProps returns the properties of a calendar object resource
+func caldavObjectProps(object *caldavObject) []dav.Property {
+	calendar := object.calendar()
+	return []dav.Property{
+		{Name: dav.Name("resourcetype")},
+		{Name: dav.Name("getetag"), Value: versionETag(object.todo)},
+		{Name: dav.Name("getcontenttype"), Value: caldavObjectType},
+		{Name: dav.Name("getcontentlength"), Value: strconv.Itoa(len(calendar))},
+		{Name: dav.Name("getlastmodified"), Value: object.todo.UpdatedAt.UTC().Format(http.TimeFormat)},
+		{Name: dav.CalDAVName("calendar-data"), Value: string(calendar)},
+	}
+}
+
+// caldavObjects pairs todos with their resource names and UIDs
+func (c *TodoController) caldavObjects(todos []*models.Todo) ([]*caldavObject, error) {
+	ids := make([]uuid.UUID, len(todos))
+	for i, todo := range todos {
+		ids[i] = todo.ID
+	}
+	resources, err := c.caldavRepo.GetByTodoIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	objects := make([]*caldavObject, len(todos))
+	for i, todo := range todos {
+		objects[i] = &caldavObject{todo: todo, name: todo.ID.String() + ".ics", uid: todo.ID.String()}
+		if resource, ok := resources[todo.ID]; ok {
+			objects[i].name, objects[i].uid = resource.Name, resource.UID
+		}
+	}
+	return objects, nil
+}
+
+// caldavCollectionObjects gets every todo in a calendar as a calendar object
+func (c *TodoController) caldavCollectionObjects(userID uuid.UUID, collection *caldavCollection) ([]*caldavObject, error) {
+	todos, err := c.todoRepo.List(models.TodoFilter{UserID: userID, WorkspaceID: collection.workspaceID()})
+	if err != nil {
+		return nil, err
+	}
+	return c.caldavObjects(todos)
+}
+
+// findCalDAVObject looks up a calendar object by resource name. Todos
+// created over CalDAV are found by the name the client gave them, and every
+// other todo by "<id>.ics".
+func (c *TodoController) findCalDAVObject(userID uuid.UUID, collection *caldavCollection, name string) (*caldavObject, error) {
+	todoID, err := c.caldavRepo.FindTodoID(name, userID, collection.workspaceID())
+	if errors.Is(err, repository.ErrNotFound) {
+		todoID, err = uuid.Parse(strings.TrimSuffix(name, ".ics"))
+		if err != nil || !strings.HasSuffix(name, ".ics") {
+			return nil, repository.ErrTodoNotFound
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	todo, err := c.todoRepo.GetByID(todoID)
+	if err != nil {
+		return nil, err
+	}
+	if !collection.contains(todo, userID) {
+		return nil, repository.ErrTodoNotFound
+	}
+
+	objects, err := c.caldavObjects([]*models.Todo{todo})
+	if err != nil {
+		return nil, err
+	}
+	// A todo whose client-chosen name is different can't be reached by its ID
+	if objects[0].name != name {
+		return nil, repository.ErrTodoNotFound
+	}
+	return objects[0], nil
+}
+
+// caldavCalendar handles requests for a calendar collection
+func (c *TodoController) caldavCalendar(w http.ResponseWriter, r *http.Request, userID uuid.UUID, collection *caldavCollection) {
+	collectionPath := caldavHome(userID) + collection.name + "/"
+
+	switch r.Method {
+	case "PROPFIND":
+		propfind, ok := readPropfind(w, r)
+		if !ok {
+			return
+		}
+
+		properties, err := c.caldavCollectionProps(userID, collection)
+		if err != nil {
+			problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to get calendar")
+			return
+		}
+		responses := []dav.Response{propfind.respond(collectionPath, properties)}
+
+		// List the todos
+		if r.Header.Get("Depth") != "0" {
+			objects, err := c.caldavCollectionObjects(userID, collection)
+			if err != nil {
+				problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to get todos")
+				return
+			}
+			for _, object := range objects {
+				responses = append(responses, propfind.respond(collectionPath+object.name, caldavObjectProps(object)))
+			}
+		}
+
+		multistatus := &dav.Multistatus{Responses: responses}
+		multistatus.Write(w)
+	case "PROPPATCH":
+		caldavPropPatch(w, r, collectionPath)
+	case "REPORT":
+		c.caldavReport(w, r, userID, collection, collectionPath)
+	default:
+		caldavMethodNotAllowed(w)
+	}
+}
+
+// caldavReport handles the calendar-query, calendar-multiget and
+// sync-collection reports on a calendar
+func (c *TodoController) caldavReport(w http.ResponseWriter, r *http.Request, userID uuid.UUID, collection *caldavCollection, collectionPath string) {
+	report, _, err := dav.ReadReport(r.Body)
+	if err != nil {
+		if errors.Is(err, dav.ErrUnsupportedReport) {
+			dav.Error(w, http.StatusForbidden, dav.Name("supported-report"))
+			return
+		}
+		problem.Error(w, http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid report")
+		return
+	}
+
+	multistatus := &dav.Multistatus{Responses: []dav.Response{}}
+	switch report := report.(type) {
+	case *dav.CalendarQuery:
+		props := newPropRequest(report.AllProp, nil, report.Prop)
+		objects, err := c.caldavCollectionObjects(userID, collection)
+		if err != nil {
+			problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to get todos")
+			return
+		}
+		for _, object := range objects {
+			calendar := ical.NewCalendar(calendarProdID)
+			calendar.AddComponent(todoComponent(object.todo, object.uid))
+			if report.Filter.CompFilter.Name != "" && !matchesCompFilter(calendar, report.Filter.CompFilter) {
+				continue
+			}
+			multistatus.Responses = append(multistatus.Responses, props.respond(collectionPath+object.name, caldavObjectProps(object)))
+		}
+
+	case *dav.CalendarMultiget:
+		props := newPropRequest(report.AllProp, nil, report.Prop)
+		for _, href := range report.Hrefs {
+			// Hrefs may be full URLs and are escaped
+			parsed, err := url.Parse(strings.TrimSpace(href))
+			name := ""
+			if err == nil && strings.HasPrefix(parsed.Path, collectionPath) {
+				name = strings.TrimPrefix(parsed.Path, collectionPath)
+			}
+
+			var object *caldavObject
+			if name != "" && !strings.Contains(name, "/") {
+				object, err = c.findCalDAVObject(userID, collection, name)
+				if err != nil && !errors.Is(err, repository.ErrNotFound) {
+					problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to get todos")
+					return
+				}
+			}
+			if object == nil {
+				multistatus.Responses = append(multistatus.Responses, dav.Response{Href: href, Status: http.StatusNotFound})
+				continue
+			}
+			multistatus.Responses = append(multistatus.Responses, props.respond(collectionPath+object.name, caldavObjectProps(object)))
+		}
+
+	case *dav.SyncCollection:
+		if !c.caldavSync(w, userID, collection, collectionPath, report, multistatus) {
+			return
+		}
+	}
+
+	multistatus.Write(w)
+}
+
+// caldavSync fills in a sync-collection report with the todos in a calendar
+// that changed since the client's sync token, and the todos that were deleted,
+// moved away or that the user lost access to. It writes an error response and
+// returns false if the token isn't valid.
+func (c *TodoController) caldavSync(w http.ResponseWriter, userID uuid.UUID, collection *caldavCollection, collectionPath string, report *dav.SyncCollection, multistatus *dav.Multistatus) bool {
+	if report.SyncLevel != "" && report.SyncLevel != "1" {
+		dav.Error(w, http.StatusForbidden, dav.Name("sync-traversal-supported"))
+		return false
+	}
+
+	// An empty token asks for every todo
+	since := models.SyncCursor{}
+	if report.SyncToken != "" {
+		token := strings.TrimSpace(report.SyncToken)
+		cursor, err := models.ParseSyncCursor(strings.TrimPrefix(token, caldavSyncTokenPrefix))
+		if err != nil || !strings.HasPrefix(token, caldavSyncTokenPrefix) {
+			dav.Error(w, http.StatusForbidden, dav.Name("valid-sync-token"))
+			return false
+		}
+
+		// Deletions older than the token may have been forgotten
+		prunedSeq, err := c.syncRepo.PrunedSeq(userID)
+		if err != nil {
+			problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to get changes")
+			return false
+		}
+		if cursor.Seq > 0 && cursor.Seq < prunedSeq {
+			dav.Error(w, http.StatusForbidden, dav.Name("valid-sync-token"))
+			return false
+		}
+		since = cursor
+	}
+
+	limit := 0
+	if report.Limit != nil {
+		limit = report.Limit.NResults
+	}
+	props := newPropRequest(nil, nil, report.Prop)
+
+	// Read the change log to the end, keeping the changes to this calendar
+	cursor := since
+	truncated := false
+	for !truncated {
+		entries, err := c.syncRepo.Changes(userID, cursor, maxSyncLimit)
+		if err != nil {
+			problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to get changes")
+			return false
+		}
+
+		ids := make([]uuid.UUID, 0, len(entries))
+		visibleIDs := []uuid.UUID{}
+		for _, entry := range entries {
+			ids = append(ids, entry.TodoID)
+			if entry.Visible {
+				visibleIDs = append(visibleIDs, entry.TodoID)
+			}
+		}
+		todos, err := c.todoRepo.ListByIDs(visibleIDs)
+		if err != nil {
+			problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to get changes")
+			return false
+		}
+		objects, err := c.caldavObjects(todos)
+		if err != nil {
+			problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to get changes")
+			return false
+		}
+		byID := make(map[uuid.UUID]*caldavObject, len(objects))
+		for _, object := range objects {
+			byID[object.todo.ID] = object
+		}
+		// Removed todos keep the name their client gave them until purged
+		resources, err := c.caldavRepo.GetByTodoIDs(ids)
+		if err != nil {
+			problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to get changes")
+			return false
+		}
+
+		for _, entry := range entries {
+			var response *dav.Response
+			object, visible := byID[entry.TodoID]
+			switch {
+			case visible && collection.contains(object.todo, userID):
+				found := props.respond(collectionPath+object.name, caldavObjectProps(object))
+				response = &found
+			case entry.FirstSeq <= since.Seq:
+				// The client may have had the todo, but which calendar it was
+				// in isn't known once it's gone, so every other calendar
+				// reports it; clients ignore removals of hrefs they don't know
+				name := entry.TodoID.String() + ".ics"
+				if resource, ok := resources[entry.TodoID]; ok {
+					name = resource.Name
+				}
+				response = &dav.Response{Href: escapePath(collectionPath + name), Status: http.StatusNotFound}
+			}
+
+			if response != nil {
+				if limit > 0 && len(multistatus.Responses) == limit {
+					truncated = true
+					break
+				}
+				multistatus.Responses = append(multistatus.Responses, *response)
+			}
+			cursor = entry.Cursor()
+		}
+
+		if len(entries) < maxSyncLimit {
+			break
+		}
+	}
+
+	if truncated {
+		multistatus.Responses = append(multistatus.Responses, dav.Response{Href: escapePath(collectionPath), Status: http.StatusInsufficientStorage})
+	}
+	multistatus.SyncToken = caldavSyncTokenPrefix + cursor.String()
+	return true
+}
+
+// matchesCompFilter checks if a component matches a calendar-query
+// comp-filter with the same name
+func matchesCompFilter(component *ical.Component, filter dav.CompFilter) bool {
+	if !strings.EqualFold(component.Name, filter.Name) {
+		return false
+	}
+
+	for _, sub := range filter.CompFilters {
+		found := false
+		for _, child := range component.Components {
+			if strings.EqualFold(child.Name, sub.Name) && (sub.IsNotDefined != nil || matchesCompFilter(child, sub)) {
+				found = true
+				break
+			}
+		}
+		if found == (sub.IsNotDefined != nil) {
+			return false
+		}
+	}
+
+	for _, sub := range filter.PropFilters {
+		property := component.Get(sub.Name)
+		switch {
+		case sub.IsNotDefined != nil:
+			if property != nil {
+				return false
+			}
+		case property == nil:
+			return false
+		case sub.TextMatch != nil:
+			if !sub.TextMatch.Matches(ical.UnescapeText(property.Value)) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// caldavResource handles requests for a calendar object resource
+func (c *TodoController) caldavResource(w http.ResponseWriter, r *http.Request, userID uuid.UUID, collection *caldavCollection, name string) {
+	if r.Method == http.MethodPut {
+		c.caldavPut(w, r, userID, collection, name)
+		return
+	}
+
+	object, err := c.findCalDAVObject(userID, collection, name)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			problem.Error(w, http.StatusNotFound, problem.CodeNotFound, "Todo not found")
+			return
+		}
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to get todo")
+		return
+	}
+	resourcePath := caldavHome(userID) + collection.name + "/" + object.name
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		if notModified(w, r, versionETag(object.todo)) {
+			return
+		}
+		calendar := object.calendar()
+		w.Header().Set("Content-Type", caldavObjectType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(calendar)))
+		w.Header().Set("ETag", versionETag(object.todo))
+		w.Header().Set("Last-Modified", object.todo.UpdatedAt.UTC().Format(http.TimeFormat))
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			w.Write(calendar)
+		}
+
+	case http.MethodDelete:
+		if !checkIfMatch(w, r, versionETag(object.todo)) {
+			return
+		}
+		if err := c.todoRepo.Delete(object.todo, userID); err != nil {
+			if errors.Is(err, repository.ErrVersionConflict) {
+				problem.Error(w, http.StatusPreconditionFailed, problem.CodeVersionConflict, "Todo has been modified")
+				return
+			}
+			problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to delete todo")
+			return
+		}
+		c.publish(events.TypeTodoDeleted, object.todo, userID)
+		w.WriteHeader(http.StatusNoContent)
+
+	case "PROPFIND":
+		propfind, ok := readPropfind(w, r)
+		if !ok {
+			return
+		}
+		multistatus := &dav.Multistatus{Responses: []dav.Response{propfind.respond(resourcePath, caldavObjectProps(object))}}
+		multistatus.Write(w)
+
+	case "PROPPATCH":
+		caldavPropPatch(w, r, resourcePath)
+
+	default:
+		caldavMethodNotAllowed(w)
+	}
+}
+
+// caldavPut handles creating or replacing a todo from a calendar object. The
+// stored todo is not exactly what the client sent, so no ETag is returned
+// and the client fetches the todo again.
+func (c *TodoController) caldavPut(w http.ResponseWriter, r *http.Request, userID uuid.UUID, collection *caldavCollection, name string) {
+	if !strings.HasSuffix(name, ".ics") || len(name) > 255 {
+		problem.Error(w, http.StatusForbidden, problem.CodeForbidden, "Resource names must end in .ics")
+		return
+	}
+
+	// Read the calendar object, which must hold a single todo
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			dav.Error(w, http.StatusRequestEntityTooLarge, dav.CalDAVName("max-resource-size"))
+			return
+		}
+		problem.Error(w, http.StatusBadRequest, problem.CodeInvalidRequest, "Failed to read request body")
+		return
+	}
+	calendar, err := ical.Decode(bytes.NewReader(body))
+	if err != nil || calendar.Name != "VCALENDAR" {
+		dav.Error(w, http.StatusForbidden, dav.CalDAVName("valid-calendar-data"))
+		return
+	}
+	var vtodo *ical.Component
+	for _, component := range calendar.Components {
+		switch component.Name {
+		case "VTODO":
+			if vtodo != nil {
+				dav.Error(w, http.StatusForbidden, dav.CalDAVName("valid-calendar-object-resource"))
+				return
+			}
+			vtodo = component
+		case "VTIMEZONE":
+		default:
+			dav.Error(w, http.StatusForbidden, dav.CalDAVName("supported-calendar-component"))
+			return
+		}
+	}
+	if vtodo == nil || vtodo.Text("UID") == "" {
+		dav.Error(w, http.StatusForbidden, dav.CalDAVName("valid-calendar-object-resource"))
+		return
+	}
+
+	title := strings.TrimSpace(vtodo.Text("SUMMARY"))
+	description := vtodo.Text("DESCRIPTION")
+	status := strings.ToUpper(vtodo.Text("STATUS"))
+	completed := status == "COMPLETED" || vtodo.Get("COMPLETED") != nil || vtodo.Text("PERCENT-COMPLETE") == "100"
+	if status == "NEEDS-ACTION" {
+		completed = false
+	}
+	tags := models.NormalizeTags(vtodo.TextList("CATEGORIES"))
+	priority := priorityFromICal(vtodo.Text("PRIORITY"))
+
+	// Dates without a time zone are in the server's, like the filter's days
+	dueAt, err := vtodo.Time("DUE", time.Local)
+	if err != nil {
+		dav.Error(w, http.StatusForbidden, dav.CalDAVName("valid-calendar-object-resource"))
+		return
+	}
+
+	// The todo's fields have the same limits as in the API
+	if errs := validation.Struct(models.UpdateTodoRequest{Title: &title, Description: description, Tags: tags}); len(errs) > 0 {
+		dav.Error(w, http.StatusForbidden, dav.CalDAVName("valid-calendar-object-resource"))
+		return
+	}
+
+	object, err := c.findCalDAVObject(userID, collection, name)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to get todo")
+		return
+	}
+
+	// If-None-Match: * only creates, and If-Match only replaces
+	if object != nil && r.Header.Get("If-None-Match") == "*" {
+		problem.Error(w, http.StatusPreconditionFailed, problem.CodeConflict, "Todo already exists")
+		return
+	}
+	if object == nil && r.Header.Get("If-Match") != "" {
+		problem.Error(w, http.StatusPreconditionFailed, problem.CodeNotFound, "Todo not found")
+		return
+	}
+
+	if object != nil {
+		if !checkIfMatch(w, r, versionETag(object.todo)) {
+			return
+		}
+
+		todo := object.todo
+		todo.Title, todo.Description, todo.Completed = title, description, completed
+		todo.Tags, todo.Priority, todo.DueAt = tags, priority, dueAt
+		if err := c.todoRepo.Update(todo, userID); err != nil {
+			if errors.Is(err, repository.ErrVersionConflict) {
+				problem.Error(w, http.StatusPreconditionFailed, problem.CodeVersionConflict, "Todo has been modified")
+				return
+			}
+			problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to update todo")
+			return
+		}
+		c.publish(events.TypeTodoUpdated, todo, userID)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	// Create the todo, remembering the name and UID the client gave it
+	batch, err := repository.BeginBatch()
+	if err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to create todo")
+		return
+	}
+	defer batch.Rollback()
+
+	todo := &models.Todo{
+		Title:       title,
+		Description: description,
+		Completed:   completed,
+		Tags:        tags,
+		Priority:    priority,
+		DueAt:       dueAt,
+		UserID:      userID,
+		WorkspaceID: collection.workspaceID(),
+	}
+	if err := batch.Todos().Create(todo); err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to create todo")
+		return
+	}
+	resource := &models.CalDAVResource{TodoID: todo.ID, Name: name, UID: vtodo.Text("UID")}
+	if err := batch.CalDAV().Set(resource); err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to create todo")
+		return
+	}
+	if err := batch.Commit(); err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to create todo")
+		return
+	}
+
+	c.publish(events.TypeTodoCreated, todo, userID)
+	w.Header().Set("Location", escapePath(caldavHome(userID)+collection.name+"/"+name))
+	w.WriteHeader(http.StatusCreated)
+}
+
+// readPropfind reads a PROPFIND request, writing an error response and
+// returning false if its body is invalid
+func readPropfind(w http.ResponseWriter, r *http.Request) (propRequest, bool) {
+	propfind, err := dav.ReadPropfind(r.Body)
+	if err != nil {
+		problem.Error(w, http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid PROPFIND request")
+		return propRequest{}, false
+	}
+	return newPropRequest(propfind.AllProp, propfind.PropName, propfind.Prop), true
+}
+
+// caldavPropPatch handles PROPPATCH requests, which clients send to set
+// things like a calendar's color. Properties can't be changed, so every one
+// is refused.
+func caldavPropPatch(w http.ResponseWriter, r *http.Request, path string) {
+	update, err := dav.ReadPropertyUpdate(r.Body)
+	if err != nil {
+		problem.Error(w, http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid PROPPATCH request")
+		return
+	}
+
+	multistatus := &dav.Multistatus{Responses: []dav.Response{{Href: escapePath(path), Forbidden: update.Names()}}}
+	multistatus.Write(w)
+}
+
+// caldavMethodNotAllowed writes a 405 response listing the CalDAV methods
+func caldavMethodNotAllowed(w http.ResponseWriter) {
+	w.Header().Set("Allow", strings.Join(CalDAVMethods, ", "))
+	problem.Error(w, http.StatusMethodNotAllowed, problem.CodeMethodNotAllowed, "Method not allowed")
+}
@@ -0,0 +1,264 @@
+package controllers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/noman/todo-application/ical"
+	"github.com/noman/todo-application/middleware"
+	"github.com/noman/todo-application/models"
+	"github.com/noman/todo-application/problem"
+	"github.com/noman/todo-application/repository"
+)
+
+// calendarProdID identifies this application in the calendars it produces
+const calendarProdID = "-//todo-application//Todos//EN"
+
+// CalendarController handles iCalendar exports and calendar feeds
+type CalendarController struct {
+	todoRepo     *repository.TodoRepository
+	calendarRepo *repository.CalendarRepository
+}
+
+// NewCalendarController creates a new CalendarController
+func NewCalendarController() *CalendarController {
+	return &CalendarController{
+		todoRepo:     repository.NewTodoRepository(),
+		calendarRepo: repository.NewCalendarRepository(),
+	}
+}
+
+// todoComponent converts a todo to a VTODO component with a UID
+func todoComponent(todo *models.Todo, uid string) *ical.Component {
+	vtodo := &ical.Component{Name: "VTODO"}
+	vtodo.AddText("UID", uid)
+	// Without a METHOD, DTSTAMP is the time the todo was last changed
+	vtodo.AddTime("DTSTAMP", todo.UpdatedAt)
+	vtodo.AddTime("CREATED", todo.CreatedAt)
+	vtodo.AddTime("LAST-MODIFIED", todo.UpdatedAt)
+	vtodo.Add("SEQUENCE", strconv.Itoa(todo.Version-1))
+	vtodo.AddText("SUMMARY", todo.Title)
+	if todo.Description != "" {
+		vtodo.AddText("DESCRIPTION", todo.Description)
+	}
+	if todo.DueAt != nil {
+		vtodo.AddTime("DUE", *todo.DueAt)
+	}
+	if priority := icalPriority(todo.Priority); priority != 0 {
+		vtodo.Add("PRIORITY", strconv.Itoa(priority))
+	}
+	if len(todo.Tags) > 0 {
+		vtodo.AddTextList("CATEGORIES", todo.Tags)
+	}
+	if todo.Completed {
+		vtodo.Add("STATUS", "COMPLETED")
+		vtodo.Add("PERCENT-COMPLETE", "100")
+	} else {
+		vtodo.Add("STATUS", "NEEDS-ACTION")
+	}
+	return vtodo
+}
+
+// icalPriority returns the iCalendar PRIORITY of a priority: 1 is the
+// highest, 9 the lowest, and 0 is undefined
+func icalPriority(priority string) int {
+	switch priority {
+	case models.PriorityHigh:
+		return 1
+	case models.PriorityMedium:
+		return 5
+	case models.PriorityLow:
+		return 9
+	default:
+		return 0
+	}
+}
+
+// priorityFromICal returns the priority of an iCalendar PRIORITY, the reverse
+// of icalPriority: 1 to 4 are high, 5 medium, 6 to 9 low, and anything else none
+func priorityFromICal(value string) string {
+	priority, err := strconv.Atoi(strings.TrimSpace(value))
+	switch {
+	case err != nil || priority < 1 || priority > 9:
+		return models.PriorityNone
+	case priority < 5:
+		return models.PriorityHigh
+	case priority == 5:
+		return models.PriorityMedium
+	default:
+		return models.PriorityLow
+	}
+}
+
+// writeCalendar writes every todo a user can see as an iCalendar object. The
+// ETag is a hash of the calendar, so clients polling a feed only download it
+// again when it changed.
+func (c *CalendarController) writeCalendar(w http.ResponseWriter, r *http.Request, userID uuid.UUID, filename string) {
+	todos, err := c.todoRepo.ListVisible(userID)
+	if err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to get todos")
+		return
+	}
+
+	calendar := ical.NewCalendar(calendarProdID)
+	calendar.AddText("X-WR-CALNAME", "Todos")
+	calendar.Add("X-PUBLISHED-TTL", "PT1H")
+	for _, todo := range todos {
+		calendar.AddComponent(todoComponent(todo, todo.ID.String()))
+	}
+
+	var body bytes.Buffer
+	if err := calendar.Encode(&body); err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to export todos")
+		return
+	}
+
+	sum := sha256.Sum256(body.Bytes())
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	if header := r.Header.Get("If-None-Match"); header != "" && etagMatches(header, etag, true) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", ical.MediaType)
+	if filename != "" {
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(body.Bytes())
+}
+
+// Export handles downloading the user's todos as an iCalendar file
+func (c *CalendarController) Export(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	c.writeCalendar(w, r, userID, "todos.ics")
+}
+
+// Feed handles calendar apps fetching a user's calendar feed. The secret
+// token in the URL takes the place of a bearer token.
+func (c *CalendarController) Feed(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID, err := c.calendarRepo.GetUserIDByFeedToken(vars["token"])
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			problem.Error(w, http.StatusNotFound, problem.CodeNotFound, "Calendar feed not found")
+			return
+		}
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to get calendar feed")
+		return
+	}
+
+	c.writeCalendar(w, r, userID, "")
+}
+
+// feedURL returns the absolute URL of a calendar feed. PUBLIC_URL sets the
+// scheme and host when the server is behind a proxy that changes them.
+func feedURL(r *http.Request, token string) string {
+	base := strings.TrimSuffix(os.Getenv("PUBLIC_URL"), "/")
+	if base == "" {
+		scheme := "http"
+		if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+			scheme = "https"
+		}
+		base = scheme + "://" + r.Host
+	}
+	return base + "/api/calendar/" + token + ".ics"
+}
+
+// GetFeed handles checking whether the user has a calendar feed. The feed's
+// URL can't be shown again; creating a new feed replaces it.
+func (c *CalendarController) GetFeed(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	feed, err := c.calendarRepo.GetFeed(userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			problem.Error(w, http.StatusNotFound, problem.CodeNotFound, "Calendar feed not found")
+			return
+		}
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to get calendar feed")
+		return
+	}
+
+	// Return the feed
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(feed)
+}
+
+// CreateFeed handles creating the user's calendar feed, or replacing it with
+// a new URL. The response is the only one that includes the URL.
+func (c *CalendarController) CreateFeed(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Generate the feed's secret token
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to create calendar feed")
+		return
+	}
+	token := hex.EncodeToString(b)
+
+	feed := &models.CalendarFeed{UserID: userID}
+	if err := c.calendarRepo.SetFeed(feed, token); err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to create calendar feed")
+		return
+	}
+
+	// Return the feed's URL
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(models.CalendarFeedResponse{
+		URL:       feedURL(r, token),
+		CreatedAt: feed.CreatedAt,
+	})
+}
+
+// DeleteFeed handles revoking the user's calendar feed
+func (c *CalendarController) DeleteFeed(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	if err := c.calendarRepo.DeleteFeed(userID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			problem.Error(w, http.StatusNotFound, problem.CodeNotFound, "Calendar feed not found")
+			return
+		}
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to revoke calendar feed")
+		return
+	}
+
+	// Return success
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/noman/todo-application/models"
+)
+
+func TestTodoComponent(t *testing.T) {
+	due := time.Date(2024, 5, 17, 19, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+	todo := &models.Todo{
+		ID:          uuid.New(),
+		Title:       "Send invoice, today",
+		Description: "Line one\nLine two",
+		Tags:        []string{"work", "billing"},
+		Priority:    models.PriorityHigh,
+		DueAt:       &due,
+		Version:     3,
+		CreatedAt:   due.Add(-48 * time.Hour),
+		UpdatedAt:   due.Add(-24 * time.Hour),
+	}
+
+	vtodo := todoComponent(todo, "uid-1")
+	want := map[string]string{
+		"UID":         "uid-1",
+		"SUMMARY":     `Send invoice\, today`,
+		"DESCRIPTION": `Line one\nLine two`,
+		"DUE":         "20240517T170000Z",
+		"PRIORITY":    "1",
+		"CATEGORIES":  "work,billing",
+		"STATUS":      "NEEDS-ACTION",
+		"SEQUENCE":    "2",
+	}
+	for name, value := range want {
+		property := vtodo.Get(name)
+		if property == nil {
+			t.Errorf("%s is missing", name)
+			continue
+		}
+		if property.Value != value {
+			t.Errorf("%s = %q, want %q", name, property.Value, value)
+		}
+	}
+
+	// Todos without them have no DUE, PRIORITY or CATEGORIES
+	todo.DueAt, todo.Priority, todo.Tags, todo.Completed = nil, models.PriorityNone, []string{}, true
+	vtodo = todoComponent(todo, "uid-1")
+	for _, name := range []string{"DUE", "PRIORITY", "CATEGORIES"} {
+		if vtodo.Get(name) != nil {
+			t.Errorf("%s = %q, want none", name, vtodo.Get(name).Value)
+		}
+	}
+	if got := vtodo.Get("STATUS").Value; got != "COMPLETED" {
+		t.Errorf("STATUS = %q, want COMPLETED", got)
+	}
+}
+
+func TestPriorityFromICal(t *testing.T) {
+	tests := map[string]string{
+		"":   models.PriorityNone,
+		"0":  models.PriorityNone,
+		"1":  models.PriorityHigh,
+		"4":  models.PriorityHigh,
+		"5":  models.PriorityMedium,
+		"6":  models.PriorityLow,
+		"9":  models.PriorityLow,
+		"10": models.PriorityNone,
+		"x":  models.PriorityNone,
+	}
+	for value, want := range tests {
+		if got := priorityFromICal(value); got != want {
+			t.Errorf("priorityFromICal(%q) = %q, want %q", value, got, want)
+		}
+	}
+
+	// Priorities survive a round trip
+	for _, priority := range models.Priorities {
+		if got := priorityFromICal(strconv.Itoa(icalPriority(priority))); got != priority {
+			t.Errorf("priorityFromICal(icalPriority(%q)) = %q", priority, got)
+		}
+	}
+}
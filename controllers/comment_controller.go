@@ -0,0 +1,309 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/noman/todo-application/middleware"
+	"github.com/noman/todo-application/models"
+	"github.com/noman/todo-application/problem"
+	"github.com/noman/todo-application/repository"
+)
+
+// mentionPattern matches @username mentions that are not part of an email address
+var mentionPattern = regexp.MustCompile(`(?:^|[^A-Za-z0-9_@])@([A-Za-z0-9_][A-Za-z0-9_.-]*)`)
+
+// codePattern matches Markdown fenced code blocks and inline code spans
+var codePattern = regexp.MustCompile("(?s)```.*?```|`[^`\n]*`")
+
+// extractMentions returns the distinct usernames mentioned in a Markdown body,
+// ignoring anything inside code
+func extractMentions(body string) []string {
+	body = codePattern.ReplaceAllString(body, " ")
+
+	seen := map[string]bool{}
+	var usernames []string
+	for _, match := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		username := strings.TrimRight(match[1], ".-")
+		key := strings.ToLower(username)
+		if username == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		usernames = append(usernames, username)
+	}
+
+	return usernames
+}
+
+// CommentController handles comment requests
+type CommentController struct {
+	commentRepo      *repository.CommentRepository
+	todoRepo         *repository.TodoRepository
+	workspaceRepo    *repository.WorkspaceRepository
+	userRepo         *repository.UserRepository
+	notificationRepo *repository.NotificationRepository
+}
+
+// NewCommentController creates a new CommentController
+func NewCommentController() *CommentController {
+	return &CommentController{
+		commentRepo:      repository.NewCommentRepository(),
+		todoRepo:         repository.NewTodoRepository(),
+		workspaceRepo:    repository.NewWorkspaceRepository(),
+		userRepo:         repository.NewUserRepository(),
+		notificationRepo: repository.NewNotificationRepository(),
+	}
+}
+
+// getTodo loads the todo named in the URL if the user can access it
+func (c *CommentController) getTodo(w http.ResponseWriter, r *http.Request, userID uuid.UUID) *models.Todo {
+	return loadAccessibleTodo(w, r, c.todoRepo, c.workspaceRepo, userID)
+}
+
+// getOwnComment parses the comment ID from the URL and loads the comment,
+// writing an error response and returning nil unless the user wrote it
+func (c *CommentController) getOwnComment(w http.ResponseWriter, r *http.Request, todo *models.Todo, userID uuid.UUID) *models.Comment {
+	vars := mux.Vars(r)
+	commentID, err := uuid.Parse(vars["commentId"])
+	if err != nil {
+		problem.Error(w, http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid comment ID")
+		return nil
+	}
+
+	comment, err := c.commentRepo.GetByID(commentID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			problem.Error(w, http.StatusNotFound, problem.CodeNotFound, "Comment not found")
+			return nil
+		}
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to get comment")
+		return nil
+	}
+	if comment.TodoID != todo.ID {
+		problem.Error(w, http.StatusNotFound, problem.CodeNotFound, "Comment not found")
+		return nil
+	}
+
+	if comment.UserID != userID {
+		problem.Error(w, http.StatusForbidden, problem.CodeForbidden, "Only the author can change this comment")
+		return nil
+	}
+
+	return comment
+}
+
+// notifyMentions notifies the users mentioned in a comment. Only users who can
+// see the todo are notified, which also resolves usernames (which are not
+// unique) within the todo's audience. Usernames in skip are not notified again.
+func (c *CommentController) notifyMentions(todo *models.Todo, comment *models.Comment, skip []string) {
+	usernames := extractMentions(comment.Body)
+	if len(usernames) == 0 {
+		return
+	}
+
+	skipped := map[string]bool{}
+	for _, username := range skip {
+		skipped[strings.ToLower(username)] = true
+	}
+
+	mentioned := map[string]bool{}
+	for _, username := range usernames {
+		if !skipped[strings.ToLower(username)] {
+			mentioned[strings.ToLower(username)] = true
+		}
+	}
+
+	// Build the todo's audience
+	var audience []*models.WorkspaceMember
+	if todo.WorkspaceID != nil {
+		members, err := c.workspaceRepo.GetMembers(*todo.WorkspaceID)
+		if err != nil {
+			log.Printf("Failed to get workspace members for mentions: %v", err)
+			return
+		}
+		audience = members
+	} else {
+		owner, err := c.userRepo.GetByID(todo.UserID)
+		if err != nil {
+			log.Printf("Failed to get todo owner for mentions: %v", err)
+			return
+		}
+		audience = []*models.WorkspaceMember{{UserID: owner.ID, Username: owner.Username}}
+	}
+
+	for _, member := range audience {
+		if member.UserID == comment.UserID || !mentioned[strings.ToLower(member.Username)] {
+			continue
+		}
+
+		notification := &models.Notification{
+			UserID:    member.UserID,
+			Type:      models.NotificationTypeMention,
+			ActorID:   comment.UserID,
+			TodoID:    &todo.ID,
+			CommentID: &comment.ID,
+			Message:   fmt.Sprintf("%s mentioned you on %q", comment.Username, todo.Title),
+		}
+		if err := c.notificationRepo.Create(notification); err != nil {
+			log.Printf("Failed to create mention notification: %v", err)
+		}
+	}
+}
+
+// GetAll handles listing the comment threads of a todo
+func (c *CommentController) GetAll(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	todo := c.getTodo(w, r, userID)
+	if todo == nil {
+		return
+	}
+
+	comments, err := c.commentRepo.GetThreadByTodoID(todo.ID)
+	if err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to get comments")
+		return
+	}
+
+	// Return the comments
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(comments)
+}
+
+// Create handles adding a comment (or a reply) to a todo
+func (c *CommentController) Create(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	todo := c.getTodo(w, r, userID)
+	if todo == nil {
+		return
+	}
+
+	// Parse the request body
+	var req models.CreateCommentRequest
+	if !decodeRequest(w, r.Body, &req) {
+		return
+	}
+
+	if req.ParentID != nil {
+		parent, err := c.commentRepo.GetByID(*req.ParentID)
+		if err != nil || parent.TodoID != todo.ID {
+			problem.Error(w, http.StatusBadRequest, problem.CodeInvalidRequest, "Parent comment not found")
+			return
+		}
+	}
+
+	author, err := c.userRepo.GetByID(userID)
+	if err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to create comment")
+		return
+	}
+
+	// Create the comment
+	comment := &models.Comment{
+		TodoID:   todo.ID,
+		ParentID: req.ParentID,
+		UserID:   userID,
+		Username: author.Username,
+		Body:     req.Body,
+	}
+
+	if err := c.commentRepo.Create(comment); err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to create comment")
+		return
+	}
+
+	c.notifyMentions(todo, comment, nil)
+
+	// Return the created comment
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(comment)
+}
+
+// Update handles editing a comment (author only)
+func (c *CommentController) Update(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	todo := c.getTodo(w, r, userID)
+	if todo == nil {
+		return
+	}
+
+	comment := c.getOwnComment(w, r, todo, userID)
+	if comment == nil {
+		return
+	}
+
+	// Parse the request body
+	var req models.UpdateCommentRequest
+	if !decodeRequest(w, r.Body, &req) {
+		return
+	}
+
+	previousMentions := extractMentions(comment.Body)
+	comment.Body = req.Body
+
+	if err := c.commentRepo.Update(comment); err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to update comment")
+		return
+	}
+
+	// Only notify users who weren't already mentioned before the edit
+	c.notifyMentions(todo, comment, previousMentions)
+
+	// Return the updated comment
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(comment)
+}
+
+// Delete handles deleting a comment and its replies (author only)
+func (c *CommentController) Delete(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	todo := c.getTodo(w, r, userID)
+	if todo == nil {
+		return
+	}
+
+	comment := c.getOwnComment(w, r, todo, userID)
+	if comment == nil {
+		return
+	}
+
+	if err := c.commentRepo.Delete(comment.ID); err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to delete comment")
+		return
+	}
+
+	// Return success
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/noman/todo-application/models"
+	"github.com/noman/todo-application/problem"
+)
+
+// todoETag returns the entity tag of a todo's API representation, derived
+// from its version and its comment count, which changes without a new version
+func todoETag(todo *models.Todo) string {
+	return models.TodoETag(todo.Version, todo.CommentCount)
+}
+
+// versionETag returns the entity tag of a representation of a todo without
+// its comments, like its iCalendar object, derived from its version alone
+func versionETag(todo *models.Todo) string {
+	return `"` + strconv.Itoa(todo.Version) + `"`
+}
+
+// etagMatches checks if an If-Match or If-None-Match header lists the given
+// entity tag. Weak tags (W/"...") only match when weak comparison is allowed.
+func etagMatches(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if strings.HasPrefix(candidate, "W/") {
+			if !weak {
+				continue
+			}
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch enforces the If-Match precondition for a representation with
+// the given etag, writing a 412 Precondition Failed response with the current
+// etag and returning false if it doesn't hold. If-Match uses the strong
+// comparison, so weak tags never match.
+func checkIfMatch(w http.ResponseWriter, r *http.Request, etag string) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" || etagMatches(header, etag, false) {
+		return true
+	}
+
+	w.Header().Set("ETag", etag)
+	problem.Error(w, http.StatusPreconditionFailed, problem.CodeVersionConflict, "Todo has been modified")
+	return false
+}
+
+// notModified handles the If-None-Match precondition for a representation
+// with the given etag, writing a 304 Not Modified response and returning
+// true if the client's copy is current
+func notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" || !etagMatches(header, etag, true) {
+		return false
+	}
+
+	w.Header().Set("ETag", etag)
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/noman/todo-application/models"
+)
+
+func TestCheckIfMatch(t *testing.T) {
+	todo := &models.Todo{Version: 3, CommentCount: 2}
+
+	tests := []struct {
+		header string
+		ok     bool
+	}{
+		{"", true},
+		{"*", true},
+		{`"3.2"`, true},
+		{`"1.0", "3.2"`, true},
+		{`"3"`, false},   // The version alone isn't the todo's tag
+		{`"3.1"`, false}, // A comment was added since
+		{`"4.2"`, false},
+		{`W/"3.2"`, false}, // If-Match only uses the strong comparison
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPut, "/api/todos/x", nil)
+		if tt.header != "" {
+			r.Header.Set("If-Match", tt.header)
+		}
+		w := httptest.NewRecorder()
+
+		if ok := checkIfMatch(w, r, todoETag(todo)); ok != tt.ok {
+			t.Errorf("If-Match %s: got %v, want %v", tt.header, ok, tt.ok)
+		}
+		if !tt.ok {
+			if w.Code != http.StatusPreconditionFailed || w.Header().Get("ETag") != `"3.2"` {
+				t.Errorf("If-Match %s: got %d with ETag %s", tt.header, w.Code, w.Header().Get("ETag"))
+			}
+		}
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	tests := []struct {
+		header      string
+		notModified bool
+	}{
+		{"", false},
+		{`"3.2"`, true},
+		{`W/"3.2"`, true},
+		{`"3.1"`, false},
+		{"*", true},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/api/todos/x", nil)
+		if tt.header != "" {
+			r.Header.Set("If-None-Match", tt.header)
+		}
+		w := httptest.NewRecorder()
+
+		if got := notModified(w, r, `"3.2"`); got != tt.notModified {
+			t.Errorf("If-None-Match %s: got %v, want %v", tt.header, got, tt.notModified)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/noman/todo-application/events"
+	"github.com/noman/todo-application/middleware"
+	"github.com/noman/todo-application/models"
+	"github.com/noman/todo-application/problem"
+	"github.com/noman/todo-application/repository"
+)
+
+// heartbeatInterval is how often an idle event stream sends a comment, which
+// stops proxies from closing it
+const heartbeatInterval = 25 * time.Second
+
+// reconnectDelay is how long browsers wait before reconnecting a dropped stream
+const reconnectDelay = 3 * time.Second
+
+// streamTicketTTL is how long a stream ticket can be used for
+const streamTicketTTL = 30 * time.Second
+
+// tokenCheckInterval is how often a stream checks that its token still works,
+// in case it missed the event about a logout or account deletion
+const tokenCheckInterval = time.Minute
+
+// EventController handles the real-time event stream
+type EventController struct {
+	streamTicketRepo *repository.StreamTicketRepository
+	broker           events.Broker
+}
+
+// NewEventController creates a new EventController
+func NewEventController() *EventController {
+	return &EventController{
+		streamTicketRepo: repository.NewStreamTicketRepository(),
+		broker:           events.Default,
+	}
+}
+
+// CreateTicket handles creating a single-use ticket that opens an event
+// stream on behalf of the request's bearer token, for clients that can't
+// send the token in a header
+func (c *EventController) CreateTicket(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID and token from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+	token, err := middleware.GetTokenFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Generate the ticket
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to create stream ticket")
+		return
+	}
+	ticket := models.StreamTicket{
+		Ticket:    hex.EncodeToString(b),
+		ExpiresAt: time.Now().Add(streamTicketTTL),
+	}
+
+	if err := c.streamTicketRepo.Create(ticket.Ticket, userID, token, ticket.ExpiresAt); err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to create stream ticket")
+		return
+	}
+
+	// Return the ticket
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(ticket)
+}
+
+// Stream handles streaming changes to the todos the user can see as
+// Server-Sent Events. Events missed while disconnected aren't replayed, so
+// clients should reload their todos whenever the stream (re)connects. The
+// stream ends when the token it was opened with expires, is logged out of,
+// or its user is deleted.
+func (c *EventController) Stream(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID and token from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+	token, err := middleware.GetTokenFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+	claims, err := middleware.ValidateToken(token)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Invalid or expired token")
+		return
+	}
+
+	sub := c.broker.Subscribe(userID)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // Stop nginx from buffering the stream
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", reconnectDelay.Milliseconds())
+
+	rc := http.NewResponseController(w)
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	tokenCheck := time.NewTicker(tokenCheckInterval)
+	defer tokenCheck.Stop()
+	var expired <-chan time.Time
+	if claims.ExpiresAt != nil {
+		expiry := time.NewTimer(time.Until(claims.ExpiresAt.Time))
+		defer expiry.Stop()
+		expired = expiry.C
+	}
+
+	for {
+		select {
+		case event, ok := <-sub.C:
+			// The subscription was dropped; the client reconnects and reloads
+			if !ok {
+				return
+			}
+			if event.Type == events.TypeAccessRevoked {
+				if !tokenStillValid(token) {
+					return
+				}
+				continue
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				log.Printf("Failed to encode event: %v", err)
+				continue
+			}
+			fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+
+		case <-tokenCheck.C:
+			if !tokenStillValid(token) {
+				return
+			}
+			continue
+
+		case <-expired:
+			return
+
+		case <-r.Context().Done():
+			return
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// tokenStillValid checks if the token a stream was opened with still works.
+// A failure to check it is logged and doesn't end the stream.
+func tokenStillValid(token string) bool {
+	_, err := middleware.ValidateToken(token)
+	if err != nil && !errors.Is(err, middleware.ErrInvalidToken) {
+		log.Printf("Failed to check event stream token: %v", err)
+		return true
+	}
+	return err == nil
+}
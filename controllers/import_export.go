@@ -0,0 +1,587 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/noman/todo-application/events"
+	"github.com/noman/todo-application/middleware"
+	"github.com/noman/todo-application/models"
+	"github.com/noman/todo-application/problem"
+	"github.com/noman/todo-application/repository"
+	"github.com/noman/todo-application/todotxt"
+	"github.com/noman/todo-application/validation"
+)
+
+// maxImportSize limits the size of import files
+const maxImportSize = 5 << 20
+
+// maxImportRows limits the number of rows in an import file
+const maxImportRows = 5000
+
+// exportCSVHeader is the header row of CSV exports. Imports read the same
+// columns, in any order.
+var exportCSVHeader = []string{"id", "title", "description", "completed", "workspace_id", "project_id", "tags", "priority", "due_at", "created_at", "updated_at"}
+
+// todoTxtPriorities maps priorities to todo.txt priorities and back. Imported
+// priorities after C are low.
+var todoTxtPriorities = map[string]string{
+	models.PriorityHigh:   "A",
+	models.PriorityMedium: "B",
+	models.PriorityLow:    "C",
+}
+
+// todoTxtDueKey is the key:value pair todo.txt apps keep due dates in
+const todoTxtDueKey = "due"
+
+// exportWriter sends an export's headers with its first byte, so that a
+// failure before anything was written can still be reported as an error
+type exportWriter struct {
+	w           http.ResponseWriter
+	contentType string
+	filename    string
+	started     bool
+}
+
+// start sends the headers, if they haven't been sent yet
+func (e *exportWriter) start() {
+	if e.started {
+		return
+	}
+	e.started = true
+	e.w.Header().Set("Content-Type", e.contentType)
+	e.w.Header().Set("Content-Disposition", `attachment; filename="`+e.filename+`"`)
+	e.w.WriteHeader(http.StatusOK)
+}
+
+// Write implements io.Writer
+func (e *exportWriter) Write(p []byte) (int, error) {
+	e.start()
+	return e.w.Write(p)
+}
+
+// exportCSVRecord converts a todo to a row of a CSV export. Tags are
+// separated by spaces, since a tag is a single word.
+func exportCSVRecord(todo *models.Todo) []string {
+	optionalID := func(id *uuid.UUID) string {
+		if id == nil {
+			return ""
+		}
+		return id.String()
+	}
+	dueAt := ""
+	if todo.DueAt != nil {
+		dueAt = todo.DueAt.UTC().Format(time.RFC3339)
+	}
+	return []string{
+		todo.ID.String(),
+		todo.Title,
+		todo.Description,
+		strconv.FormatBool(todo.Completed),
+		optionalID(todo.WorkspaceID),
+		optionalID(todo.ProjectID),
+		strings.Join(todo.Tags, " "),
+		models.NormalizePriority(todo.Priority),
+		dueAt,
+		todo.CreatedAt.UTC().Format(time.RFC3339),
+		todo.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// exportTodoTxtTask converts a todo to a todo.txt task. Todos don't record
+// when they were completed, so their last change stands in for it. Tags are
+// written as +project tags and the due date, in the server's time zone, as a
+// due: pair; todo.txt has no times, so a due date's time is lost.
+func exportTodoTxtTask(todo *models.Todo) todotxt.Task {
+	text := []string{todo.Title}
+	for _, tag := range todo.Tags {
+		text = append(text, "+"+tag)
+	}
+	if todo.DueAt != nil {
+		text = append(text, todoTxtDueKey+":"+todo.DueAt.Local().Format("2006-01-02"))
+	}
+
+	task := todotxt.Task{
+		Completed:    todo.Completed,
+		Priority:     todoTxtPriorities[todo.Priority],
+		CreationDate: todo.CreatedAt.UTC(),
+		Text:         strings.Join(text, " "),
+	}
+	if todo.Completed {
+		task.CompletionDate = todo.UpdatedAt.UTC()
+	}
+	return task
+}
+
+// Export handles downloading every todo the user can see, personal and in
+// their workspaces. The todos are written as they're read from the database.
+func (c *TodoController) Export(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = models.TransferFormatJSON
+	}
+
+	// Pick the encoding of each todo and of the end of the file
+	out := &exportWriter{w: w}
+	var write func(*models.Todo) error
+	finish := func() error { return nil }
+	switch format {
+	case models.TransferFormatJSON:
+		out.contentType, out.filename = "application/json", "todos.json"
+		separator := "[\n"
+		write = func(todo *models.Todo) error {
+			data, err := json.Marshal(todo.Export())
+			if err != nil {
+				return err
+			}
+			_, err = io.WriteString(out, separator+string(data))
+			separator = ",\n"
+			return err
+		}
+		finish = func() error {
+			end := "\n]\n"
+			if separator == "[\n" {
+				end = "[]\n"
+			}
+			_, err := io.WriteString(out, end)
+			return err
+		}
+
+	case models.TransferFormatCSV:
+		out.contentType, out.filename = "text/csv; charset=utf-8", "todos.csv"
+		writer := csv.NewWriter(out)
+		writer.Write(exportCSVHeader)
+		write = func(todo *models.Todo) error {
+			return writer.Write(exportCSVRecord(todo))
+		}
+		finish = func() error {
+			writer.Flush()
+			return writer.Error()
+		}
+
+	case models.TransferFormatTodoTxt:
+		out.contentType, out.filename = todotxt.MediaType, "todo.txt"
+		write = func(todo *models.Todo) error {
+			_, err := io.WriteString(out, exportTodoTxtTask(todo).String()+"\n")
+			return err
+		}
+
+	default:
+		problem.Error(w, http.StatusBadRequest, problem.CodeInvalidRequest, "Format must be json, csv or todotxt")
+		return
+	}
+
+	err = c.todoRepo.EachVisible(userID, write)
+	if err == nil {
+		err = finish()
+	}
+	if err != nil {
+		// Once the file has started the status can't change, so the failure
+		// can only cut it short
+		if !out.started {
+			problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to export todos")
+			return
+		}
+		log.Printf("Failed to export todos for user %s: %v", userID, err)
+		return
+	}
+	out.start()
+}
+
+// importRecord is a todo read from an import file, with the errors found in
+// its row
+type importRecord struct {
+	row    int
+	todo   models.ExportedTodo
+	errors validation.Errors
+}
+
+// importKey identifies duplicate todos: those in the same list with the
+// same title and description, ignoring case and surrounding whitespace in
+// the title. The list is a workspace, or uuid.Nil for personal todos.
+type importKey struct {
+	list        uuid.UUID
+	title       string
+	description string
+}
+
+// newImportKey returns the key of a todo in a list
+func newImportKey(workspaceID *uuid.UUID, title, description string) importKey {
+	key := importKey{title: strings.ToLower(strings.TrimSpace(title)), description: strings.TrimSpace(description)}
+	if workspaceID != nil {
+		key.list = *workspaceID
+	}
+	return key
+}
+
+// importFormat returns the format named by an import's format parameter, or
+// else implied by its Content-Type
+func importFormat(r *http.Request) string {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/json":
+		return models.TransferFormatJSON
+	case "text/csv":
+		return models.TransferFormatCSV
+	case "text/plain":
+		return models.TransferFormatTodoTxt
+	}
+	return ""
+}
+
+// readJSONImport reads an array of todos in the form they're exported in
+func readJSONImport(body []byte) ([]*importRecord, error) {
+	var elements []json.RawMessage
+	if err := json.Unmarshal(body, &elements); err != nil {
+		return nil, errors.New("JSON imports must be an array of todos")
+	}
+
+	records := make([]*importRecord, len(elements))
+	for i, element := range elements {
+		records[i] = &importRecord{row: i + 1}
+		if err := validation.Decode(bytes.NewReader(element), &records[i].todo); err != nil {
+			if !errors.As(err, &records[i].errors) {
+				records[i].errors = validation.Errors{{Code: validation.CodeInvalidType, Message: "must be an object"}}
+			}
+		}
+	}
+	return records, nil
+}
+
+// readCSVImport reads a CSV file with a header row naming its columns. Only
+// the title column is required; columns that aren't imported are ignored.
+func readCSVImport(body []byte) ([]*importRecord, error) {
+	// Spreadsheet apps often start UTF-8 files with a byte order mark
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(body, []byte("\xef\xbb\xbf"))))
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return []*importRecord{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Invalid CSV: %v", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["title"]; !ok {
+		return nil, errors.New("CSV imports must have a title column")
+	}
+
+	records := []*importRecord{}
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Invalid CSV: %v", err)
+		}
+
+		line, _ := reader.FieldPos(0)
+		record := &importRecord{row: line}
+		get := func(column string) string {
+			if i, ok := columns[column]; ok {
+				return fields[i]
+			}
+			return ""
+		}
+		getID := func(column string) *uuid.UUID {
+			value := strings.TrimSpace(get(column))
+			if value == "" {
+				return nil
+			}
+			id, err := uuid.Parse(value)
+			if err != nil {
+				record.errors = append(record.errors, problem.FieldError{Field: column, Code: validation.CodeInvalidType, Message: "must be a UUID"})
+				return nil
+			}
+			return &id
+		}
+
+		record.todo.Title = get("title")
+		record.todo.Description = get("description")
+		if value := strings.TrimSpace(get("completed")); value != "" {
+			record.todo.Completed, err = strconv.ParseBool(value)
+			if err != nil {
+				record.errors = append(record.errors, problem.FieldError{Field: "completed", Code: validation.CodeInvalidType, Message: "must be true or false"})
+			}
+		}
+		record.todo.WorkspaceID = getID("workspace_id")
+		record.todo.ProjectID = getID("project_id")
+		record.todo.Tags = strings.Fields(get("tags"))
+		record.todo.Priority = strings.ToLower(strings.TrimSpace(get("priority")))
+		if value := strings.TrimSpace(get("due_at")); value != "" {
+			dueAt, err := parseImportTime(value)
+			if err != nil {
+				record.errors = append(record.errors, problem.FieldError{Field: "due_at", Code: validation.CodeInvalidType, Message: "must be a date like 2024-05-17 or a time like 2024-05-17T17:00:00Z"})
+			} else {
+				record.todo.DueAt = &dueAt
+			}
+		}
+		record.errors = append(record.errors, validation.Struct(record.todo)...)
+
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// parseImportTime reads a time like 2024-05-17T17:00:00Z, or a date like
+// 2024-05-17, which is midnight in the server's time zone
+func parseImportTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", value, time.Local)
+}
+
+// readTodoTxtImport reads a todo.txt file. Each line's text becomes a
+// todo's title, its +project and @context tags its tags, its due: pair its
+// due date and its priority (A) high, (B) medium and (C) or later low;
+// creation and completion dates are dropped. A line of nothing but tags
+// keeps them in the title.
+func readTodoTxtImport(body []byte) []*importRecord {
+	records := []*importRecord{}
+	for i, line := range strings.Split(string(body), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		task := todotxt.Parse(line)
+		todo := models.ExportedTodo{Title: task.PlainText(todoTxtDueKey), Completed: task.Completed, Tags: task.Tags()}
+		if todo.Title == "" {
+			todo.Title = task.Text
+		}
+		if task.Priority != "" {
+			todo.Priority = models.PriorityLow
+			for priority, letter := range todoTxtPriorities {
+				if letter == task.Priority {
+					todo.Priority = priority
+				}
+			}
+		}
+
+		record := &importRecord{row: i + 1, todo: todo}
+		if value := task.Value(todoTxtDueKey); value != "" {
+			dueAt, err := time.ParseInLocation("2006-01-02", value, time.Local)
+			if err != nil {
+				record.errors = append(record.errors, problem.FieldError{Field: "due_at", Code: validation.CodeInvalidType, Message: "due: must be a date like 2024-05-17"})
+			} else {
+				record.todo.DueAt = &dueAt
+			}
+		}
+		record.errors = append(record.errors, validation.Struct(record.todo)...)
+		records = append(records, record)
+	}
+	return records
+}
+
+// Import handles creating todos from a file in one of the export formats.
+// Rows that duplicate an existing todo, or an earlier row, are skipped. If
+// any row is invalid nothing is imported, and a dry run only reports what
+// would be.
+func (c *TodoController) Import(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	format := importFormat(r)
+	switch format {
+	case models.TransferFormatJSON, models.TransferFormatCSV, models.TransferFormatTodoTxt:
+	default:
+		problem.Error(w, http.StatusBadRequest, problem.CodeInvalidRequest, "Format must be json, csv or todotxt")
+		return
+	}
+
+	dryRun := false
+	if value := r.URL.Query().Get("dry_run"); value != "" {
+		dryRun, err = strconv.ParseBool(value)
+		if err != nil {
+			problem.Error(w, http.StatusBadRequest, problem.CodeInvalidRequest, "dry_run must be true or false")
+			return
+		}
+	}
+
+	// Read the file
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImportSize))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			problem.Error(w, http.StatusRequestEntityTooLarge, problem.CodePayloadTooLarge, "Import files are limited to 5 MB")
+			return
+		}
+		problem.Error(w, http.StatusBadRequest, problem.CodeInvalidRequest, "Failed to read request body")
+		return
+	}
+	if !utf8.Valid(body) {
+		problem.Error(w, http.StatusBadRequest, problem.CodeInvalidRequest, "Import files must be UTF-8")
+		return
+	}
+
+	var records []*importRecord
+	switch format {
+	case models.TransferFormatJSON:
+		records, err = readJSONImport(body)
+	case models.TransferFormatCSV:
+		records, err = readCSVImport(body)
+	case models.TransferFormatTodoTxt:
+		records = readTodoTxtImport(body)
+	}
+	if err != nil {
+		problem.Error(w, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
+		return
+	}
+	if len(records) > maxImportRows {
+		problem.Error(w, http.StatusRequestEntityTooLarge, problem.CodePayloadTooLarge, "Import files are limited to 5000 todos")
+		return
+	}
+
+	// Check the rows and find the duplicates in the same transaction the
+	// todos are created in
+	batch, err := repository.BeginBatch()
+	if err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to import todos")
+		return
+	}
+	defer batch.Rollback()
+	todoRepo := batch.Todos()
+
+	response := models.ImportResponse{DryRun: dryRun, Rows: make([]models.ImportRow, len(records))}
+	isMember := make(map[uuid.UUID]bool)
+	seen := make(map[importKey]bool)
+	loaded := make(map[uuid.UUID]bool) // Lists whose todos are in seen
+	for i, record := range records {
+		response.Rows[i] = models.ImportRow{Row: record.row, Title: record.todo.Title}
+		todo := &record.todo
+
+		// Imported todos can go in any workspace the user belongs to
+		if todo.WorkspaceID != nil {
+			member, ok := isMember[*todo.WorkspaceID]
+			if !ok {
+				member, err = c.workspaceRepo.IsMember(*todo.WorkspaceID, userID)
+				if err != nil {
+					problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to import todos")
+					return
+				}
+				isMember[*todo.WorkspaceID] = member
+			}
+			if !member {
+				record.errors = append(record.errors, problem.FieldError{Field: "workspace_id", Code: validation.CodeInvalidReference, Message: "is not a workspace you belong to"})
+			}
+		}
+		if !c.validateProject(todo.WorkspaceID, todo.ProjectID) {
+			record.errors = append(record.errors, problem.FieldError{Field: "project_id", Code: validation.CodeInvalidReference, Message: "is not a project of the todo's workspace"})
+		}
+
+		if len(record.errors) > 0 {
+			response.Rows[i].Status = models.ImportRowInvalid
+			response.Rows[i].Errors = record.errors
+			response.Invalid++
+			continue
+		}
+
+		// Load the todos already in the list the first time it comes up
+		key := newImportKey(todo.WorkspaceID, todo.Title, todo.Description)
+		if !loaded[key.list] {
+			existing, err := todoRepo.List(models.TodoFilter{UserID: userID, WorkspaceID: todo.WorkspaceID})
+			if err != nil {
+				problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to import todos")
+				return
+			}
+			for _, t := range existing {
+				seen[newImportKey(t.WorkspaceID, t.Title, t.Description)] = true
+			}
+			loaded[key.list] = true
+		}
+
+		if seen[key] {
+			response.Rows[i].Status = models.ImportRowDuplicate
+			response.Duplicates++
+			continue
+		}
+		seen[key] = true
+		response.Rows[i].Status = models.ImportRowOK
+		response.Imported++
+	}
+
+	// Invalid rows fail the whole import, so the file can be fixed and
+	// imported again without creating duplicates
+	if response.Invalid > 0 || dryRun {
+		status := http.StatusOK
+		if response.Invalid > 0 {
+			status = http.StatusUnprocessableEntity
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// Create the todos
+	created := []*models.Todo{}
+	for i, record := range records {
+		if response.Rows[i].Status != models.ImportRowOK {
+			continue
+		}
+
+		todo := &models.Todo{
+			Title:       record.todo.Title,
+			Description: record.todo.Description,
+			Completed:   record.todo.Completed,
+			UserID:      userID,
+			WorkspaceID: record.todo.WorkspaceID,
+			ProjectID:   record.todo.ProjectID,
+			Tags:        record.todo.Tags,
+			Priority:    record.todo.Priority,
+			DueAt:       record.todo.DueAt,
+		}
+		if err := todoRepo.Create(todo); err != nil {
+			problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to import todos")
+			return
+		}
+		response.Rows[i].ID = &todo.ID
+		created = append(created, todo)
+	}
+
+	if err := batch.Commit(); err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to import todos")
+		return
+	}
+	response.Committed = true
+
+	// Publish the new todos now that they're committed
+	for _, todo := range created {
+		c.publish(events.TypeTodoCreated, todo, userID)
+	}
+
+	// Return the report
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,204 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/noman/todo-application/models"
+)
+
+// exportedTodos are todos with every field an export can carry
+func exportedTodos() []*models.Todo {
+	workspaceID, projectID := uuid.New(), uuid.New()
+	due := time.Date(2024, 5, 17, 0, 0, 0, 0, time.Local)
+	created := time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC)
+	return []*models.Todo{
+		{
+			ID:          uuid.New(),
+			Title:       "Pay invoice, \"urgent\"",
+			Description: "Line one\nLine two",
+			Completed:   false,
+			WorkspaceID: &workspaceID,
+			ProjectID:   &projectID,
+			Tags:        []string{"work", "billing"},
+			Priority:    models.PriorityHigh,
+			DueAt:       &due,
+			CreatedAt:   created,
+			UpdatedAt:   created.Add(time.Hour),
+		},
+		{
+			ID:        uuid.New(),
+			Title:     "Call mom",
+			Completed: true,
+			Tags:      []string{},
+			Priority:  models.PriorityNone,
+			CreatedAt: created,
+			UpdatedAt: created.Add(24 * time.Hour),
+		},
+	}
+}
+
+// sameTime compares optional times
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+func TestJSONExportRoundTrip(t *testing.T) {
+	todos := exportedTodos()
+	exported := []models.ExportedTodo{}
+	for _, todo := range todos {
+		exported = append(exported, todo.Export())
+	}
+	data, err := json.Marshal(exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := readJSONImport(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, record := range records {
+		if len(record.errors) > 0 {
+			t.Errorf("row %d: %v", record.row, record.errors)
+		}
+		want := todos[i]
+		got := record.todo
+		if got.Title != want.Title || got.Description != want.Description || got.Completed != want.Completed ||
+			!sameUUID(got.WorkspaceID, want.WorkspaceID) || !sameUUID(got.ProjectID, want.ProjectID) ||
+			!reflect.DeepEqual(models.NormalizeTags(got.Tags), want.Tags) || models.NormalizePriority(got.Priority) != want.Priority ||
+			!sameTime(got.DueAt, want.DueAt) {
+			t.Errorf("row %d = %+v, want %+v", record.row, got, want)
+		}
+	}
+}
+
+func TestCSVExportRoundTrip(t *testing.T) {
+	todos := exportedTodos()
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	writer.Write(exportCSVHeader)
+	for _, todo := range todos {
+		writer.Write(exportCSVRecord(todo))
+	}
+	writer.Flush()
+
+	records, err := readCSVImport(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != len(todos) {
+		t.Fatalf("got %d rows, want %d", len(records), len(todos))
+	}
+	for i, record := range records {
+		if len(record.errors) > 0 {
+			t.Errorf("row %d: %v", record.row, record.errors)
+		}
+		want := todos[i]
+		got := record.todo
+		if got.Title != want.Title || got.Description != want.Description || got.Completed != want.Completed ||
+			!sameUUID(got.WorkspaceID, want.WorkspaceID) || !sameUUID(got.ProjectID, want.ProjectID) ||
+			!reflect.DeepEqual(models.NormalizeTags(got.Tags), want.Tags) || models.NormalizePriority(got.Priority) != want.Priority ||
+			!sameTime(got.DueAt, want.DueAt) {
+			t.Errorf("row %d = %+v, want %+v", record.row, got, want)
+		}
+	}
+}
+
+func TestCSVImport(t *testing.T) {
+	// Columns can be in any order and only title is required
+	body := "\xef\xbb\xbfPriority,Title,due_at,tags\n" +
+		"LOW,First,2024-05-17,a b\n" +
+		"urgent,Second,tomorrow,\n"
+	records, err := readCSVImport([]byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := records[0].todo
+	due := time.Date(2024, 5, 17, 0, 0, 0, 0, time.Local)
+	if len(records[0].errors) > 0 || first.Priority != models.PriorityLow || !sameTime(first.DueAt, &due) || !reflect.DeepEqual(first.Tags, []string{"a", "b"}) {
+		t.Errorf("row 2 = %+v, errors %v", first, records[0].errors)
+	}
+
+	fields := map[string]bool{}
+	for _, fieldErr := range records[1].errors {
+		fields[fieldErr.Field] = true
+	}
+	if !fields["priority"] || !fields["due_at"] {
+		t.Errorf("row 3 errors = %v, want priority and due_at", records[1].errors)
+	}
+
+	if _, err := readCSVImport([]byte("name\nFirst\n")); err == nil {
+		t.Error("CSV without a title column: got no error")
+	}
+}
+
+func TestTodoTxtExportRoundTrip(t *testing.T) {
+	todos := exportedTodos()
+	var lines []string
+	for _, todo := range todos {
+		lines = append(lines, exportTodoTxtTask(todo).String())
+	}
+	want := []string{
+		`(A) 2024-05-01 Pay invoice, "urgent" +work +billing due:2024-05-17`,
+		`x 2024-05-02 2024-05-01 Call mom`,
+	}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("exported\n%s\nwant\n%s", strings.Join(lines, "\n"), strings.Join(want, "\n"))
+	}
+
+	records := readTodoTxtImport([]byte(strings.Join(lines, "\n") + "\n"))
+	if len(records) != len(todos) {
+		t.Fatalf("got %d rows, want %d", len(records), len(todos))
+	}
+	for i, record := range records {
+		if len(record.errors) > 0 {
+			t.Errorf("line %d: %v", record.row, record.errors)
+		}
+		want := todos[i]
+		got := record.todo
+		if got.Title != want.Title || got.Completed != want.Completed ||
+			!reflect.DeepEqual(models.NormalizeTags(got.Tags), want.Tags) || models.NormalizePriority(got.Priority) != want.Priority ||
+			!sameTime(got.DueAt, want.DueAt) {
+			t.Errorf("line %d = %+v, want %+v", record.row, got, want)
+		}
+	}
+}
+
+func TestTodoTxtImport(t *testing.T) {
+	body := "(D) Later thing @home\n" +
+		"\n" +
+		"+only +tags\n" +
+		"Bad due due:someday\n"
+	records := readTodoTxtImport([]byte(body))
+	if len(records) != 3 {
+		t.Fatalf("got %d rows, want 3", len(records))
+	}
+
+	if got := records[0].todo; got.Title != "Later thing" || got.Priority != models.PriorityLow || !reflect.DeepEqual(got.Tags, []string{"home"}) {
+		t.Errorf("line 1 = %+v", got)
+	}
+	if got := records[1]; got.row != 3 || got.todo.Title != "+only +tags" || !reflect.DeepEqual(got.todo.Tags, []string{"only", "tags"}) {
+		t.Errorf("line 3 = %d %+v", got.row, got.todo)
+	}
+	if got := records[2]; len(got.errors) != 1 || got.errors[0].Field != "due_at" {
+		t.Errorf("line 4 errors = %v, want due_at", got.errors)
+	}
+}
+
+func sameUUID(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
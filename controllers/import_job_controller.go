@@ -0,0 +1,185 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"path"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/noman/todo-application/importers"
+	"github.com/noman/todo-application/middleware"
+	"github.com/noman/todo-application/models"
+	"github.com/noman/todo-application/problem"
+	"github.com/noman/todo-application/repository"
+)
+
+// maxImportJobSize limits the size of files imported from other apps
+const maxImportJobSize = 20 << 20
+
+// maxImportFilenameLength is the longest file name stored with an import job
+const maxImportFilenameLength = 255
+
+// ImportJobController handles importing other apps' export files
+type ImportJobController struct {
+	importJobRepo *repository.ImportJobRepository
+	workspaceRepo *repository.WorkspaceRepository
+}
+
+// NewImportJobController creates a new ImportJobController
+func NewImportJobController() *ImportJobController {
+	return &ImportJobController{
+		importJobRepo: repository.NewImportJobRepository(),
+		workspaceRepo: repository.NewWorkspaceRepository(),
+	}
+}
+
+// Create handles uploading another app's export file as multipart/form-data.
+// The file is imported in the background; the response is the queued job.
+func (c *ImportJobController) Create(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	job := &models.ImportJob{
+		UserID: userID,
+		Source: r.URL.Query().Get("source"),
+	}
+	switch job.Source {
+	case importers.SourceTodoist, importers.SourceTrello, importers.SourceMicrosoftToDo:
+	default:
+		problem.Error(w, http.StatusBadRequest, problem.CodeInvalidRequest, "Source must be todoist, trello or microsoft_todo")
+		return
+	}
+
+	// Import into a workspace if one is given
+	if value := r.URL.Query().Get("workspace_id"); value != "" {
+		workspaceID, err := uuid.Parse(value)
+		if err != nil {
+			problem.Error(w, http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid workspace ID")
+			return
+		}
+
+		isMember, err := c.workspaceRepo.IsMember(workspaceID, userID)
+		if err != nil {
+			problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to create import job")
+			return
+		}
+		if !isMember {
+			problem.Error(w, http.StatusForbidden, problem.CodeForbidden, "You are not a member of this workspace")
+			return
+		}
+		job.WorkspaceID = &workspaceID
+	}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		problem.Error(w, http.StatusBadRequest, problem.CodeInvalidRequest, "Expected a multipart/form-data request")
+		return
+	}
+
+	// Read the first file in the request
+	for job.Data == nil {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			problem.Error(w, http.StatusBadRequest, problem.CodeInvalidRequest, "No file was uploaded")
+			return
+		}
+		if err != nil {
+			problem.Error(w, http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid multipart payload")
+			return
+		}
+
+		// Skip non-file fields
+		if part.FileName() == "" {
+			part.Close()
+			continue
+		}
+
+		// Keep the end of long names, which has the extension importers look at
+		filename := []rune(path.Base(part.FileName()))
+		if len(filename) > maxImportFilenameLength {
+			filename = filename[len(filename)-maxImportFilenameLength:]
+		}
+		job.Filename = string(filename)
+
+		job.Data, err = io.ReadAll(io.LimitReader(part, maxImportJobSize+1))
+		part.Close()
+		if err != nil {
+			problem.Error(w, http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid multipart payload")
+			return
+		}
+		if len(job.Data) > maxImportJobSize {
+			problem.Error(w, http.StatusRequestEntityTooLarge, problem.CodePayloadTooLarge, "Import files are limited to 20 MB")
+			return
+		}
+	}
+
+	// Queue the job
+	if err := c.importJobRepo.Create(job); err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to create import job")
+		return
+	}
+
+	// Return the queued job
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/import/jobs/"+job.ID.String())
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
+
+// GetAll handles listing the user's recent import jobs
+func (c *ImportJobController) GetAll(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	jobs, err := c.importJobRepo.GetAllByUserID(userID)
+	if err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to get import jobs")
+		return
+	}
+
+	// Return the import jobs
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(jobs)
+}
+
+// Get handles getting an import job, to follow its progress
+func (c *ImportJobController) Get(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	vars := mux.Vars(r)
+	jobID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		problem.Error(w, http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid import job ID")
+		return
+	}
+
+	job, err := c.importJobRepo.GetByID(jobID, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			problem.Error(w, http.StatusNotFound, problem.CodeNotFound, "Import job not found")
+			return
+		}
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to get import job")
+		return
+	}
+
+	// Return the import job
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
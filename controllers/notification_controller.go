@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/noman/todo-application/middleware"
+	"github.com/noman/todo-application/problem"
+	"github.com/noman/todo-application/repository"
+)
+
+// NotificationController handles notification requests
+type NotificationController struct {
+	notificationRepo *repository.NotificationRepository
+}
+
+// NewNotificationController creates a new NotificationController
+func NewNotificationController() *NotificationController {
+	return &NotificationController{
+		notificationRepo: repository.NewNotificationRepository(),
+	}
+}
+
+// GetAll handles listing the user's notifications (?unread=true for unread only)
+func (c *NotificationController) GetAll(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	unreadOnly := r.URL.Query().Get("unread") == "true"
+
+	notifications, err := c.notificationRepo.GetAllByUserID(userID, unreadOnly)
+	if err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to get notifications")
+		return
+	}
+
+	// Return the notifications
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(notifications)
+}
+
+// MarkRead handles marking a notification as read
+func (c *NotificationController) MarkRead(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Get the notification ID from the URL
+	vars := mux.Vars(r)
+	notificationID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		problem.Error(w, http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid notification ID")
+		return
+	}
+
+	if err := c.notificationRepo.MarkRead(notificationID, userID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			problem.Error(w, http.StatusNotFound, problem.CodeNotFound, "Notification not found")
+			return
+		}
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to mark notification as read")
+		return
+	}
+
+	// Return success
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MarkAllRead handles marking all of the user's notifications as read
+func (c *NotificationController) MarkAllRead(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	if err := c.notificationRepo.MarkAllRead(userID); err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to mark notifications as read")
+		return
+	}
+
+	// Return success
+	w.WriteHeader(http.StatusNoContent)
+}
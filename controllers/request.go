@@ -0,0 +1,29 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/noman/todo-application/problem"
+	"github.com/noman/todo-application/validation"
+)
+
+// decodeRequest parses a JSON request body into req and validates it against
+// its `validate` tags, writing a problem response and returning false if the
+// body is malformed or invalid
+func decodeRequest(w http.ResponseWriter, body io.Reader, req interface{}) bool {
+	err := validation.Decode(body, req)
+	if err == nil {
+		return true
+	}
+
+	var fieldErrs validation.Errors
+	if errors.As(err, &fieldErrs) {
+		problem.Invalid(w, http.StatusUnprocessableEntity, "Invalid request", fieldErrs...)
+		return false
+	}
+
+	problem.Error(w, http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid request payload")
+	return false
+}
@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/noman/todo-application/database"
+	"github.com/noman/todo-application/middleware"
+	"github.com/noman/todo-application/problem"
+	"github.com/noman/todo-application/search"
+)
+
+// Search page sizes
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// Search handles finding the todos a user can see by the words in their
+// titles and descriptions, best matches first
+func (c *TodoController) Search(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Parse the query
+	query, err := search.Parse(r.URL.Query().Get("q"))
+	if err != nil {
+		switch err {
+		case search.ErrQueryTooLong:
+			problem.Error(w, http.StatusBadRequest, problem.CodeInvalidRequest, "Query must be at most 256 characters")
+		default:
+			problem.Error(w, http.StatusBadRequest, problem.CodeInvalidRequest, "Query must contain a word to search for")
+		}
+		return
+	}
+
+	limit := defaultSearchLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxSearchLimit {
+			problem.Error(w, http.StatusBadRequest, problem.CodeInvalidRequest, "Limit must be between 1 and 100")
+			return
+		}
+	}
+
+	// Use the full-text index when the database has one
+	find := c.todoRepo.SearchScan
+	if database.FullTextSearch {
+		find = c.todoRepo.Search
+	}
+
+	results, err := find(userID, query, limit)
+	if err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to search todos")
+		return
+	}
+
+	// Return the results
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
@@ -0,0 +1,376 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/noman/todo-application/events"
+	"github.com/noman/todo-application/middleware"
+	"github.com/noman/todo-application/models"
+	"github.com/noman/todo-application/patch"
+	"github.com/noman/todo-application/problem"
+	"github.com/noman/todo-application/repository"
+	"github.com/noman/todo-application/validation"
+)
+
+// Sync page sizes
+const (
+	defaultSyncLimit = 500
+	maxSyncLimit     = 1000
+)
+
+// Changes handles pulling the todos created, updated and deleted since a sync
+// token. Without a token every todo the user can see is returned as created.
+func (c *TodoController) Changes(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	since, err := models.ParseSyncCursor(r.URL.Query().Get("since"))
+	if err != nil {
+		problem.Error(w, http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid sync token")
+		return
+	}
+
+	limit := defaultSyncLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxSyncLimit {
+			problem.Error(w, http.StatusBadRequest, problem.CodeInvalidRequest, "Limit must be between 1 and 1000")
+			return
+		}
+	}
+
+	// Deletions older than the token may have been forgotten, so the client
+	// has to start over
+	prunedSeq, err := c.syncRepo.PrunedSeq(userID)
+	if err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to get changes")
+		return
+	}
+	if since.Seq > 0 && since.Seq < prunedSeq {
+		problem.Error(w, http.StatusGone, problem.CodeSyncTokenExpired, "Sync token has expired; sync again without a token")
+		return
+	}
+
+	entries, err := c.syncRepo.Changes(userID, since, limit)
+	if err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to get changes")
+		return
+	}
+
+	// Load the todos that are still visible
+	ids := []uuid.UUID{}
+	for _, entry := range entries {
+		if entry.Visible {
+			ids = append(ids, entry.TodoID)
+		}
+	}
+	todos, err := c.todoRepo.ListByIDs(ids)
+	if err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to get changes")
+		return
+	}
+	byID := make(map[uuid.UUID]*models.Todo, len(todos))
+	for _, todo := range todos {
+		byID[todo.ID] = todo
+	}
+
+	response := models.SyncResponse{
+		Created:   []models.TodoResponse{},
+		Updated:   []models.TodoResponse{},
+		Deleted:   []uuid.UUID{},
+		SyncToken: since.String(),
+		HasMore:   len(entries) == limit,
+	}
+	for _, entry := range entries {
+		// Todos the client hasn't seen yet are new to it, even if they
+		// changed again since
+		isNew := entry.FirstSeq > since.Seq
+		todo, ok := byID[entry.TodoID]
+		switch {
+		case ok && isNew:
+			response.Created = append(response.Created, todo.ToResponse())
+		case ok:
+			response.Updated = append(response.Updated, todo.ToResponse())
+		case !isNew:
+			response.Deleted = append(response.Deleted, entry.TodoID)
+		}
+		response.SyncToken = entry.Cursor().String()
+	}
+
+	// Return the changes
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// PushChanges handles applying edits a client made while offline. Each change
+// is applied on its own, in order. Conflicts are resolved the same way every
+// time: fields changed on the server since the client's base version keep the
+// server's value, deletions on the server win over edits, and edits on the
+// server win over deletions.
+func (c *TodoController) PushChanges(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Parse the request body
+	var req models.SyncPushRequest
+	if !decodeRequest(w, r.Body, &req) {
+		return
+	}
+
+	results := make([]models.SyncChangeResult, len(req.Changes))
+	for i, change := range req.Changes {
+		result := models.SyncChangeResult{Index: i, Op: change.Op, ID: change.ID}
+
+		var eventType string
+		var todo *models.Todo
+		switch change.Op {
+		case models.SyncOpCreate:
+			eventType = events.TypeTodoCreated
+			todo, err = c.syncCreate(userID, change, &result)
+		case models.SyncOpUpdate:
+			eventType = events.TypeTodoUpdated
+			todo, err = c.syncUpdate(userID, change, &result)
+		case models.SyncOpDelete:
+			eventType = events.TypeTodoDeleted
+			todo, err = c.syncDelete(userID, change, &result)
+		}
+
+		if err != nil {
+			var changeErr *batchError
+			if !errors.As(err, &changeErr) {
+				changeErr = &batchError{http.StatusInternalServerError, problem.CodeInternal, "Failed to " + change.Op + " todo"}
+			}
+			result.Status = models.SyncStatusRejected
+			result.Code = changeErr.code
+			result.Error = changeErr.message
+		}
+
+		// Publish the change if anything was written
+		if todo != nil {
+			c.publish(eventType, todo, userID)
+		}
+
+		results[i] = result
+	}
+
+	// Return the results
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.SyncPushResponse{Results: results})
+}
+
+// syncCreate applies an offline "create". Creating a todo that already exists
+// succeeds, so a client can safely push the same change again. It returns the
+// todo if one was created.
+func (c *TodoController) syncCreate(userID uuid.UUID, change models.SyncChange, result *models.SyncChangeResult) (*models.Todo, error) {
+	existing, err := c.todoRepo.GetByID(change.ID)
+	if err == nil {
+		if existing.UserID != userID {
+			return nil, &batchError{http.StatusConflict, problem.CodeConflict, "Todo ID is already in use"}
+		}
+		response := existing.ToResponse()
+		result.Status = models.SyncStatusApplied
+		result.Todo = &response
+		return nil, nil
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		return nil, err
+	}
+
+	// A todo in the trash can't be created again
+	if _, err := c.todoRepo.GetDeletedByID(change.ID); err == nil {
+		return nil, &batchError{http.StatusConflict, problem.CodeConflict, "Todo ID is already in use"}
+	} else if !errors.Is(err, repository.ErrNotFound) {
+		return nil, err
+	}
+
+	todo, err := c.createTodo(c.todoRepo, userID, change.Todo, change.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := todo.ToResponse()
+	result.Status = models.SyncStatusApplied
+	result.Todo = &response
+	return todo, nil
+}
+
+// syncUpdate applies an offline "update" on top of the server's changes. It
+// returns the todo if it was updated.
+func (c *TodoController) syncUpdate(userID uuid.UUID, change models.SyncChange, result *models.SyncChangeResult) (*models.Todo, error) {
+	todo, err := c.loadSyncTodo(userID, change)
+	if err != nil {
+		return nil, err
+	}
+	if todo == nil {
+		return nil, &batchError{http.StatusNotFound, problem.CodeNotFound, "Todo was deleted"}
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(change.Todo, &fields); err != nil || fields == nil {
+		return nil, &batchError{http.StatusBadRequest, problem.CodeInvalidRequest, "Todo must be a JSON object"}
+	}
+
+	current, err := json.Marshal(todo.Document())
+	if err != nil {
+		return nil, err
+	}
+
+	// Fields the server changed since the client's version keep the server's value
+	if change.BaseVersion < todo.Version {
+		changed, err := c.eventRepo.ChangedFieldsSince(todo.ID, change.BaseVersion)
+		if err != nil {
+			return nil, err
+		}
+		for field, value := range fields {
+			if !changed[field] {
+				continue
+			}
+			differs, err := fieldDiffers(current, field, value)
+			if err != nil {
+				return nil, err
+			}
+			if differs {
+				result.Conflicts = append(result.Conflicts, field)
+			}
+			delete(fields, field)
+		}
+		sort.Strings(result.Conflicts)
+	}
+
+	response := todo.ToResponse()
+	result.Todo = &response
+	if len(fields) == 0 {
+		if len(result.Conflicts) > 0 {
+			return nil, &batchError{http.StatusConflict, problem.CodeVersionConflict, "Every field was changed on the server"}
+		}
+		result.Status = models.SyncStatusApplied
+		return nil, nil
+	}
+
+	// Apply the rest of the change
+	remaining, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	patched, err := patch.MergePatch(current, remaining)
+	if err != nil {
+		return nil, &batchError{http.StatusUnprocessableEntity, problem.CodeValidationFailed, err.Error()}
+	}
+	var doc models.UpdateTodoRequest
+	if err := validation.Decode(bytes.NewReader(patched), &doc); err != nil {
+		return nil, &batchError{http.StatusUnprocessableEntity, problem.CodeValidationFailed, err.Error()}
+	}
+	if !c.validateProject(todo.WorkspaceID, doc.ProjectID) {
+		return nil, &batchError{http.StatusUnprocessableEntity, problem.CodeValidationFailed, "Project does not belong to this workspace"}
+	}
+
+	todo.Apply(doc)
+	if err := c.todoRepo.Update(todo, userID); err != nil {
+		return nil, batchRepositoryError(err)
+	}
+
+	response = todo.ToResponse()
+	result.Todo = &response
+	result.Status = models.SyncStatusApplied
+	if len(result.Conflicts) > 0 {
+		result.Status = models.SyncStatusMerged
+	}
+	return todo, nil
+}
+
+// syncDelete applies an offline "delete", unless the todo was edited on the
+// server since the client's version. It returns the todo if it was deleted.
+func (c *TodoController) syncDelete(userID uuid.UUID, change models.SyncChange, result *models.SyncChangeResult) (*models.Todo, error) {
+	todo, err := c.loadSyncTodo(userID, change)
+	if err != nil {
+		return nil, err
+	}
+
+	// Already deleted
+	if todo == nil {
+		result.Status = models.SyncStatusApplied
+		return nil, nil
+	}
+
+	if change.BaseVersion < todo.Version {
+		changed, err := c.eventRepo.ChangedFieldsSince(todo.ID, change.BaseVersion)
+		if err != nil {
+			return nil, err
+		}
+		if len(changed) > 0 {
+			response := todo.ToResponse()
+			result.Todo = &response
+			return nil, &batchError{http.StatusConflict, problem.CodeVersionConflict, "Todo was changed on the server"}
+		}
+	}
+
+	if err := c.todoRepo.Delete(todo, userID); err != nil {
+		return nil, batchRepositoryError(err)
+	}
+
+	result.Status = models.SyncStatusApplied
+	return todo, nil
+}
+
+// loadSyncTodo loads the live todo an offline change applies to, returning
+// nil if it no longer exists, and checks the change's base version
+func (c *TodoController) loadSyncTodo(userID uuid.UUID, change models.SyncChange) (*models.Todo, error) {
+	todo, err := c.todoRepo.GetByID(change.ID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	allowed, err := canAccessTodo(c.workspaceRepo, todo, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, &batchError{http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized"}
+	}
+
+	if change.BaseVersion < 1 || change.BaseVersion > todo.Version {
+		return nil, &batchError{http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid base version"}
+	}
+
+	return todo, nil
+}
+
+// fieldDiffers reports whether setting a single field of a todo document to
+// a value would change the document
+func fieldDiffers(current []byte, field string, value json.RawMessage) (bool, error) {
+	single, err := json.Marshal(map[string]json.RawMessage{field: value})
+	if err != nil {
+		return false, err
+	}
+	patched, err := patch.MergePatch(current, single)
+	if err != nil {
+		return true, nil
+	}
+
+	var before, after models.UpdateTodoRequest
+	if err := json.Unmarshal(current, &before); err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(patched, &after); err != nil {
+		return true, nil
+	}
+	return !reflect.DeepEqual(before, after), nil
+}
@@ -0,0 +1,167 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/noman/todo-application/database/databasetest"
+	"github.com/noman/todo-application/events"
+	"github.com/noman/todo-application/models"
+	"github.com/noman/todo-application/problem"
+	"github.com/noman/todo-application/repository"
+)
+
+// pushChanges pushes offline changes as the user
+func pushChanges(t *testing.T, c *TodoController, userID uuid.UUID, body string) []models.SyncChangeResult {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodPost, "/api/sync", strings.NewReader(body))
+	r = r.WithContext(context.WithValue(r.Context(), "userID", userID))
+	rec := httptest.NewRecorder()
+	c.PushChanges(rec, r)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp models.SyncPushResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp.Results
+}
+
+func TestFieldDiffers(t *testing.T) {
+	projectID := uuid.New()
+	due := time.Date(2024, 5, 17, 9, 0, 0, 0, time.UTC)
+	todo := &models.Todo{
+		Title:     "Pay invoice",
+		ProjectID: &projectID,
+		Tags:      []string{"work", "billing"},
+		Priority:  models.PriorityHigh,
+		DueAt:     &due,
+	}
+	current, err := json.Marshal(todo.Document())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		field, value string
+		want         bool
+	}{
+		{"title", `"Pay invoice"`, false},
+		{"title", `"Pay the invoice"`, true},
+		{"description", `""`, false},
+		{"description", `null`, false},
+		{"completed", `false`, false},
+		{"completed", `true`, true},
+		{"project_id", `"` + projectID.String() + `"`, false},
+		{"project_id", `null`, true},
+		{"tags", `["work","billing"]`, false},
+		{"tags", `["billing","work"]`, true},
+		{"priority", `"high"`, false},
+		{"priority", `"low"`, true},
+		{"due_at", `"2024-05-17T09:00:00Z"`, false},
+		{"due_at", `"2024-05-18T09:00:00Z"`, true},
+		{"due_at", `null`, true},
+		{"completed", `"yes"`, true}, // Can't be applied, so the server's value is kept
+	}
+	for _, tt := range tests {
+		got, err := fieldDiffers(current, tt.field, json.RawMessage(tt.value))
+		if err != nil {
+			t.Errorf("%s = %s: %v", tt.field, tt.value, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s = %s: differs %v, want %v", tt.field, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestPushChangesConflictPolicy(t *testing.T) {
+	databasetest.Open(t)
+	user := databasetest.NewUser(t)
+	c := NewTodoController()
+	c.broker = events.NewMemoryBroker()
+	todoRepo := repository.NewTodoRepository()
+
+	// The client last saw both todos at version 1; the server renamed one since
+	edited := newTodo(t, user.ID, "Original")
+	edited.Title = "Server"
+	if err := todoRepo.Update(edited, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	untouched := newTodo(t, user.ID, "Untouched")
+	createdID := uuid.New()
+
+	results := pushChanges(t, c, user.ID, `{"changes":[
+		{"op":"update","id":"`+edited.ID.String()+`","base_version":1,"todo":{"title":"Client","description":"Client notes"}},
+		{"op":"update","id":"`+edited.ID.String()+`","base_version":1,"todo":{"title":"Server"}},
+		{"op":"update","id":"`+edited.ID.String()+`","base_version":1,"todo":{"title":"Client again"}},
+		{"op":"delete","id":"`+edited.ID.String()+`","base_version":1},
+		{"op":"update","id":"`+edited.ID.String()+`","base_version":9,"todo":{"title":"Future"}},
+		{"op":"create","id":"`+createdID.String()+`","todo":{"title":"Offline"}},
+		{"op":"create","id":"`+createdID.String()+`","todo":{"title":"Offline"}},
+		{"op":"create","id":"`+untouched.ID.String()+`","todo":{"title":"Taken"}},
+		{"op":"delete","id":"`+untouched.ID.String()+`","base_version":1},
+		{"op":"update","id":"`+untouched.ID.String()+`","base_version":1,"todo":{"title":"Too late"}},
+		{"op":"delete","id":"`+untouched.ID.String()+`","base_version":1}
+	]}`)
+
+	tests := []struct {
+		name      string
+		status    string
+		code      string
+		conflicts []string
+	}{
+		{"edit of a changed field is merged", models.SyncStatusMerged, "", []string{"title"}},
+		{"edit to the server's value isn't a conflict", models.SyncStatusApplied, "", nil},
+		{"edit of only changed fields is rejected", models.SyncStatusRejected, problem.CodeVersionConflict, []string{"title"}},
+		{"server edit wins over a delete", models.SyncStatusRejected, problem.CodeVersionConflict, nil},
+		{"unknown base version", models.SyncStatusRejected, problem.CodeInvalidRequest, nil},
+		{"create", models.SyncStatusApplied, "", nil},
+		{"create pushed again", models.SyncStatusApplied, "", nil},
+		{"create of an existing todo", models.SyncStatusApplied, "", nil},
+		{"delete", models.SyncStatusApplied, "", nil},
+		{"server delete wins over an edit", models.SyncStatusRejected, problem.CodeNotFound, nil},
+		{"delete pushed again", models.SyncStatusApplied, "", nil},
+	}
+	if len(results) != len(tests) {
+		t.Fatalf("got %d results, want %d", len(results), len(tests))
+	}
+	for i, tt := range tests {
+		got := results[i]
+		if got.Index != i || got.Status != tt.status || got.Code != tt.code || !reflect.DeepEqual(got.Conflicts, tt.conflicts) {
+			t.Errorf("%s: got %+v, want status %s, code %q and conflicts %v", tt.name, got, tt.status, tt.code, tt.conflicts)
+		}
+	}
+
+	// The merged edit kept the server's title and took the client's description
+	if todo := results[0].Todo; todo == nil || todo.Title != "Server" || todo.Description != "Client notes" {
+		t.Errorf("merged todo = %+v", results[0].Todo)
+	}
+	if todo := results[6].Todo; todo == nil || todo.ID != createdID || todo.Title != "Offline" {
+		t.Errorf("created todo = %+v", results[6].Todo)
+	}
+
+	todo, err := todoRepo.GetByID(edited.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if todo.Title != "Server" || todo.Description != "Client notes" || todo.Version != 3 {
+		t.Errorf("edited todo = %+v, want it merged once", todo)
+	}
+	if _, err := todoRepo.GetByID(untouched.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("deleted todo: got %v, want ErrNotFound", err)
+	}
+	if titles := userTodos(t, user.ID); len(titles) != 2 || titles["Offline"] == nil {
+		t.Errorf("todos after the push: %v", titles)
+	}
+}
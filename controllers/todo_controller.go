@@ -1,47 +1,175 @@
 package controllers
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"io"
+	"mime"
 	"net/http"
+	"strconv"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	"github.com/noman/todo-application/events"
+	"github.com/noman/todo-application/filterexpr"
 	"github.com/noman/todo-application/middleware"
 	"github.com/noman/todo-application/models"
+	"github.com/noman/todo-application/patch"
+	"github.com/noman/todo-application/problem"
 	"github.com/noman/todo-application/repository"
+	"github.com/noman/todo-application/validation"
 )
 
 // TodoController handles todo requests
 type TodoController struct {
-	todoRepo *repository.TodoRepository
+	todoRepo      *repository.TodoRepository
+	workspaceRepo *repository.WorkspaceRepository
+	eventRepo     *repository.TodoEventRepository
+	syncRepo      *repository.SyncRepository
+	webhookRepo   *repository.WebhookRepository
+	caldavRepo    *repository.CalDAVRepository
+	userRepo      *repository.UserRepository
+	broker        events.Broker
 }
 
 // NewTodoController creates a new TodoController
 func NewTodoController() *TodoController {
 	return &TodoController{
-		todoRepo: repository.NewTodoRepository(),
+		todoRepo:      repository.NewTodoRepository(),
+		workspaceRepo: repository.NewWorkspaceRepository(),
+		eventRepo:     repository.NewTodoEventRepository(),
+		syncRepo:      repository.NewSyncRepository(),
+		webhookRepo:   repository.NewWebhookRepository(),
+		caldavRepo:    repository.NewCalDAVRepository(),
+		userRepo:      repository.NewUserRepository(),
+		broker:        events.Default,
 	}
 }
 
+// publish tells clients that a todo changed. Its webhooks were already queued
+// by the repository, in the transaction that changed it.
+func (c *TodoController) publish(eventType string, todo *models.Todo, actorID uuid.UUID) {
+	events.PublishTodo(c.broker, c.workspaceRepo, eventType, todo, actorID)
+}
+
+// canAccessTodo checks if a user may read and modify a todo: its creator
+// always can, and so can every member of the workspace the todo belongs to
+func canAccessTodo(workspaceRepo *repository.WorkspaceRepository, todo *models.Todo, userID uuid.UUID) (bool, error) {
+	if todo.UserID == userID {
+		return true, nil
+	}
+	if todo.WorkspaceID == nil {
+		return false, nil
+	}
+	return workspaceRepo.IsMember(*todo.WorkspaceID, userID)
+}
+
+// loadAccessibleTodo parses the todo ID from the URL and loads the todo,
+// writing an error response and returning nil if it doesn't exist or the user
+// can't access it
+func loadAccessibleTodo(w http.ResponseWriter, r *http.Request, todoRepo *repository.TodoRepository, workspaceRepo *repository.WorkspaceRepository, userID uuid.UUID) *models.Todo {
+	vars := mux.Vars(r)
+	todoID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		problem.Error(w, http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid todo ID")
+		return nil
+	}
+
+	todo, err := todoRepo.GetByID(todoID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			problem.Error(w, http.StatusNotFound, problem.CodeNotFound, "Todo not found")
+			return nil
+		}
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to get todo")
+		return nil
+	}
+
+	allowed, err := canAccessTodo(workspaceRepo, todo, userID)
+	if err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to get todo")
+		return nil
+	}
+	if !allowed {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return nil
+	}
+
+	return todo
+}
+
+// validateAssignees checks that every assignee may be assigned a todo in the
+// given workspace; personal todos can only be assigned to their creator
+func (c *TodoController) validateAssignees(workspaceID *uuid.UUID, userID uuid.UUID, assigneeIDs []uuid.UUID) (bool, error) {
+	for _, assigneeID := range assigneeIDs {
+		if workspaceID == nil {
+			if assigneeID != userID {
+				return false, nil
+			}
+			continue
+		}
+		isMember, err := c.workspaceRepo.IsMember(*workspaceID, assigneeID)
+		if err != nil || !isMember {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// validateProject checks that a project belongs to the given workspace
+func (c *TodoController) validateProject(workspaceID, projectID *uuid.UUID) bool {
+	if projectID == nil {
+		return true
+	}
+	if workspaceID == nil {
+		return false
+	}
+	project, err := c.workspaceRepo.GetProjectByID(*projectID)
+	return err == nil && project.WorkspaceID == *workspaceID
+}
+
 // Create handles creating a new todo
 func (c *TodoController) Create(w http.ResponseWriter, r *http.Request) {
 	// Get the user ID from the context
 	userID, err := middleware.GetUserIDFromContext(r)
 	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
 		return
 	}
 
 	// Parse the request body
 	var req models.CreateTodoRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+	if !decodeRequest(w, r.Body, &req) {
 		return
 	}
 
-	// Validate the request
-	if req.Title == "" {
-		http.Error(w, "Title is required", http.StatusBadRequest)
+	// Check that the user belongs to the workspace
+	if req.WorkspaceID != nil {
+		isMember, err := c.workspaceRepo.IsMember(*req.WorkspaceID, userID)
+		if err != nil {
+			problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to create todo")
+			return
+		}
+		if !isMember {
+			problem.Error(w, http.StatusForbidden, problem.CodeForbidden, "Not a member of this workspace")
+			return
+		}
+	}
+
+	// Validate the project and assignees
+	if !c.validateProject(req.WorkspaceID, req.ProjectID) {
+		problem.Error(w, http.StatusBadRequest, problem.CodeInvalidRequest, "Project does not belong to this workspace")
+		return
+	}
+
+	valid, err := c.validateAssignees(req.WorkspaceID, userID, req.AssigneeIDs)
+	if err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to create todo")
+		return
+	}
+	if !valid {
+		problem.Error(w, http.StatusBadRequest, problem.CodeInvalidRequest, "Assignees must be members of the workspace")
 		return
 	}
 
@@ -51,35 +179,123 @@ func (c *TodoController) Create(w http.ResponseWriter, r *http.Request) {
 		Description: req.Description,
 		Completed:   false,
 		UserID:      userID,
+		WorkspaceID: req.WorkspaceID,
+		ProjectID:   req.ProjectID,
+		AssigneeIDs: req.AssigneeIDs,
+		Tags:        req.Tags,
+		Priority:    req.Priority,
+		DueAt:       req.DueAt,
 	}
 
 	if err := c.todoRepo.Create(todo); err != nil {
-		http.Error(w, "Failed to create todo", http.StatusInternalServerError)
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to create todo")
 		return
 	}
+	c.publish(events.TypeTodoCreated, todo, userID)
 
 	// Return the created todo
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", todoETag(todo))
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(todo.ToResponse())
 }
 
-// GetAll handles getting all todos for a user
+// maxTodoPageLimit is the most todos a page of the todo list can have
+const maxTodoPageLimit = 100
+
+// GetAll handles getting all todos for a user, optionally scoped to a
+// workspace with ?workspace_id=, narrowed with ?view=assigned|created and
+// filtered with a ?filter= expression. With ?limit= the list is paged: a
+// Link header points to the next page, which starts after ?cursor=.
 func (c *TodoController) GetAll(w http.ResponseWriter, r *http.Request) {
 	// Get the user ID from the context
 	userID, err := middleware.GetUserIDFromContext(r)
 	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
 		return
 	}
 
-	// Get all todos for the user
-	todos, err := c.todoRepo.GetAllByUserID(userID)
+	filter := models.TodoFilter{
+		UserID: userID,
+		View:   r.URL.Query().Get("view"),
+	}
+
+	// Validate the view
+	switch filter.View {
+	case "", models.TodoViewAll, models.TodoViewAssignedToMe, models.TodoViewCreatedByMe:
+	default:
+		problem.Error(w, http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid view")
+		return
+	}
+
+	// Scope the query to the workspace if one is given
+	if value := r.URL.Query().Get("workspace_id"); value != "" {
+		workspaceID, err := uuid.Parse(value)
+		if err != nil {
+			problem.Error(w, http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid workspace ID")
+			return
+		}
+
+		isMember, err := c.workspaceRepo.IsMember(workspaceID, userID)
+		if err != nil {
+			problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to get todos")
+			return
+		}
+		if !isMember {
+			problem.Error(w, http.StatusForbidden, problem.CodeForbidden, "Not a member of this workspace")
+			return
+		}
+
+		filter.WorkspaceID = &workspaceID
+	}
+
+	// Parse the filter expression if one is given
+	if value := r.URL.Query().Get("filter"); value != "" {
+		expr, err := filterexpr.Parse(value)
+		if err != nil {
+			problem.Error(w, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
+			return
+		}
+		filter.Expr = expr
+	}
+
+	// Page the list if a limit is given, getting one more todo than the page
+	// holds to see if there's a next page
+	limit := 0
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxTodoPageLimit {
+			problem.Error(w, http.StatusBadRequest, problem.CodeInvalidRequest, "Limit must be between 1 and 100")
+			return
+		}
+		filter.Limit = limit + 1
+	}
+	if value := r.URL.Query().Get("cursor"); value != "" {
+		cursor, err := models.ParseTodoPageCursor(value)
+		if err != nil {
+			problem.Error(w, http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid cursor")
+			return
+		}
+		filter.After = &cursor
+	}
+
+	// Get the todos matching the filter
+	todos, err := c.todoRepo.List(filter)
 	if err != nil {
-		http.Error(w, "Failed to get todos", http.StatusInternalServerError)
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to get todos")
 		return
 	}
 
+	// Link to the next page if there is one
+	if limit > 0 && len(todos) > limit {
+		todos = todos[:limit]
+		next := *r.URL
+		query := next.Query()
+		query.Set("cursor", todos[limit-1].PageCursor().String())
+		next.RawQuery = query.Encode()
+		w.Header().Set("Link", "<"+next.RequestURI()+`>; rel="next"`)
+	}
+
 	// Convert todos to responses
 	responses := make([]models.TodoResponse, len(todos))
 	for i, todo := range todos {
@@ -96,92 +312,149 @@ func (c *TodoController) GetByID(w http.ResponseWriter, r *http.Request) {
 	// Get the user ID from the context
 	userID, err := middleware.GetUserIDFromContext(r)
 	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-
-	// Get the todo ID from the URL
-	vars := mux.Vars(r)
-	todoID, err := uuid.Parse(vars["id"])
-	if err != nil {
-		http.Error(w, "Invalid todo ID", http.StatusBadRequest)
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
 		return
 	}
 
-	// Get the todo
-	todo, err := c.todoRepo.GetByID(todoID)
-	if err != nil {
-		http.Error(w, "Todo not found", http.StatusNotFound)
+	todo := loadAccessibleTodo(w, r, c.todoRepo, c.workspaceRepo, userID)
+	if todo == nil {
 		return
 	}
 
-	// Check if the todo belongs to the user
-	if todo.UserID != userID {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	// Let the client reuse its copy if it is current
+	if notModified(w, r, todoETag(todo)) {
 		return
 	}
 
 	// Return the todo
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", todoETag(todo))
 	json.NewEncoder(w).Encode(todo.ToResponse())
 }
 
-// Update handles updating a todo
+// Update handles replacing a todo's editable fields (PUT)
 func (c *TodoController) Update(w http.ResponseWriter, r *http.Request) {
 	// Get the user ID from the context
 	userID, err := middleware.GetUserIDFromContext(r)
 	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
 		return
 	}
 
-	// Get the todo ID from the URL
-	vars := mux.Vars(r)
-	todoID, err := uuid.Parse(vars["id"])
+	todo := loadAccessibleTodo(w, r, c.todoRepo, c.workspaceRepo, userID)
+	if todo == nil {
+		return
+	}
+
+	// Check the client's version of the todo is current
+	if !checkIfMatch(w, r, todoETag(todo)) {
+		return
+	}
+
+	// Parse the request body, which replaces the todo entirely
+	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		http.Error(w, "Invalid todo ID", http.StatusBadRequest)
+		problem.Error(w, http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid request payload")
 		return
 	}
 
-	// Get the existing todo
-	todo, err := c.todoRepo.GetByID(todoID)
+	c.replace(w, todo, userID, body)
+}
+
+// Patch handles partially updating a todo with a JSON Merge Patch (RFC 7396)
+// or a JSON Patch (RFC 6902), selected by the request's Content-Type
+func (c *TodoController) Patch(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
 	if err != nil {
-		http.Error(w, "Todo not found", http.StatusNotFound)
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
 		return
 	}
 
-	// Check if the todo belongs to the user
-	if todo.UserID != userID {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	// Pick the patch format
+	var applyPatch func(target, patch []byte) ([]byte, error)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case patch.MergePatchMediaType:
+		applyPatch = patch.MergePatch
+	case patch.JSONPatchMediaType:
+		applyPatch = patch.JSONPatch
+	default:
+		w.Header().Set("Accept-Patch", patch.MergePatchMediaType+", "+patch.JSONPatchMediaType)
+		problem.Error(w, http.StatusUnsupportedMediaType, problem.CodeUnsupportedMediaType, "Unsupported patch format")
 		return
 	}
 
-	// Parse the request body
-	var req models.UpdateTodoRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+	todo := loadAccessibleTodo(w, r, c.todoRepo, c.workspaceRepo, userID)
+	if todo == nil {
+		return
+	}
+
+	// Check the client's version of the todo is current
+	if !checkIfMatch(w, r, todoETag(todo)) {
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		problem.Error(w, http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid request payload")
 		return
 	}
 
-	// Update the todo fields if provided
-	if req.Title != "" {
-		todo.Title = req.Title
+	// Apply the patch to the todo's editable fields
+	current, err := json.Marshal(todo.Document())
+	if err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to update todo")
+		return
 	}
-	if req.Description != "" {
-		todo.Description = req.Description
+
+	patched, err := applyPatch(current, body)
+	if err != nil {
+		if errors.Is(err, patch.ErrTestFailed) {
+			problem.Error(w, http.StatusConflict, problem.CodePatchTestFailed, err.Error())
+			return
+		}
+		problem.Error(w, http.StatusUnprocessableEntity, problem.CodeValidationFailed, err.Error())
+		return
 	}
-	if req.Completed != nil {
-		todo.Completed = *req.Completed
+
+	c.replace(w, todo, userID, patched)
+}
+
+// replace validates a full todo document, writes it over the todo and
+// responds with the updated todo
+func (c *TodoController) replace(w http.ResponseWriter, todo *models.Todo, userID uuid.UUID, data []byte) {
+	var doc models.UpdateTodoRequest
+	if !decodeRequest(w, bytes.NewReader(data), &doc) {
+		return
 	}
 
+	if !c.validateProject(todo.WorkspaceID, doc.ProjectID) {
+		problem.Invalid(w, http.StatusUnprocessableEntity, "Invalid request", problem.FieldError{
+			Field:   "project_id",
+			Code:    validation.CodeInvalidReference,
+			Message: "must be a project in the todo's workspace",
+		})
+		return
+	}
+
+	// Replace the todo's editable fields
+	todo.Apply(doc)
+
 	// Update the todo in the database
-	if err := c.todoRepo.Update(todo); err != nil {
-		http.Error(w, "Failed to update todo", http.StatusInternalServerError)
+	if err := c.todoRepo.Update(todo, userID); err != nil {
+		if errors.Is(err, repository.ErrVersionConflict) {
+			problem.Error(w, http.StatusPreconditionFailed, problem.CodeVersionConflict, "Todo has been modified")
+			return
+		}
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to update todo")
 		return
 	}
+	c.publish(events.TypeTodoUpdated, todo, userID)
 
 	// Return the updated todo
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", todoETag(todo))
 	json.NewEncoder(w).Encode(todo.ToResponse())
 }
 
@@ -190,24 +463,188 @@ func (c *TodoController) Delete(w http.ResponseWriter, r *http.Request) {
 	// Get the user ID from the context
 	userID, err := middleware.GetUserIDFromContext(r)
 	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
 		return
 	}
 
-	// Get the todo ID from the URL
-	vars := mux.Vars(r)
-	todoID, err := uuid.Parse(vars["id"])
-	if err != nil {
-		http.Error(w, "Invalid todo ID", http.StatusBadRequest)
+	todo := loadAccessibleTodo(w, r, c.todoRepo, c.workspaceRepo, userID)
+	if todo == nil {
 		return
 	}
 
-	// Delete the todo
-	if err := c.todoRepo.Delete(todoID, userID); err != nil {
-		http.Error(w, "Failed to delete todo", http.StatusInternalServerError)
+	// Check the client's version of the todo is current
+	if !checkIfMatch(w, r, todoETag(todo)) {
 		return
 	}
 
+	// Move the todo to the trash
+	if err := c.todoRepo.Delete(todo, userID); err != nil {
+		if errors.Is(err, repository.ErrVersionConflict) {
+			problem.Error(w, http.StatusPreconditionFailed, problem.CodeVersionConflict, "Todo has been modified")
+			return
+		}
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to delete todo")
+		return
+	}
+	c.publish(events.TypeTodoDeleted, todo, userID)
+
 	// Return success
 	w.WriteHeader(http.StatusNoContent)
-}
\ No newline at end of file
+}
+
+// Assign handles replacing the assignees of a todo
+func (c *TodoController) Assign(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	todo := loadAccessibleTodo(w, r, c.todoRepo, c.workspaceRepo, userID)
+	if todo == nil {
+		return
+	}
+
+	// Check the client's version of the todo is current
+	if !checkIfMatch(w, r, todoETag(todo)) {
+		return
+	}
+
+	// Parse the request body
+	var req models.AssignTodoRequest
+	if !decodeRequest(w, r.Body, &req) {
+		return
+	}
+
+	// Validate the assignees
+	valid, err := c.validateAssignees(todo.WorkspaceID, todo.UserID, req.AssigneeIDs)
+	if err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to assign todo")
+		return
+	}
+	if !valid {
+		problem.Error(w, http.StatusBadRequest, problem.CodeInvalidRequest, "Assignees must be members of the workspace")
+		return
+	}
+
+	// Replace the assignees
+	if err := c.todoRepo.SetAssignees(todo, req.AssigneeIDs, userID); err != nil {
+		if errors.Is(err, repository.ErrVersionConflict) {
+			problem.Error(w, http.StatusPreconditionFailed, problem.CodeVersionConflict, "Todo has been modified")
+			return
+		}
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to assign todo")
+		return
+	}
+	c.publish(events.TypeTodoUpdated, todo, userID)
+
+	// Return the updated todo
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", todoETag(todo))
+	json.NewEncoder(w).Encode(todo.ToResponse())
+}
+
+// History handles getting the change history of a todo, oldest revision first
+func (c *TodoController) History(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	todo := loadAccessibleTodo(w, r, c.todoRepo, c.workspaceRepo, userID)
+	if todo == nil {
+		return
+	}
+
+	history, err := c.eventRepo.GetByTodoID(todo.ID)
+	if err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to get history")
+		return
+	}
+
+	// Return the history
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
+
+// RestoreRevision handles restoring a todo to the state it had at a revision.
+// A project or assignees that are no longer valid in the workspace are dropped.
+func (c *TodoController) RestoreRevision(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	todo := loadAccessibleTodo(w, r, c.todoRepo, c.workspaceRepo, userID)
+	if todo == nil {
+		return
+	}
+
+	// Get the revision from the URL
+	vars := mux.Vars(r)
+	revision, err := strconv.Atoi(vars["revision"])
+	if err != nil || revision < 1 {
+		problem.Error(w, http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid revision")
+		return
+	}
+
+	event, err := c.eventRepo.GetRevision(todo.ID, revision)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			problem.Error(w, http.StatusNotFound, problem.CodeNotFound, "Revision not found")
+			return
+		}
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to get revision")
+		return
+	}
+
+	// Copy the revision's state onto the todo
+	snapshot := event.Snapshot
+	todo.Title = snapshot.Title
+	todo.Description = snapshot.Description
+	todo.Completed = snapshot.Completed
+	todo.ProjectID = snapshot.ProjectID
+	if !c.validateProject(todo.WorkspaceID, todo.ProjectID) {
+		todo.ProjectID = nil
+	}
+
+	// Revisions from before todos had tags, a priority and a due date leave
+	// them as they are
+	if snapshot.Tags != nil {
+		todo.Tags = snapshot.Tags
+		todo.Priority = snapshot.Priority
+		todo.DueAt = snapshot.DueAt
+	}
+
+	todo.AssigneeIDs = []uuid.UUID{}
+	for _, assigneeID := range snapshot.AssigneeIDs {
+		valid, err := c.validateAssignees(todo.WorkspaceID, todo.UserID, []uuid.UUID{assigneeID})
+		if err != nil {
+			problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to restore todo")
+			return
+		}
+		if valid {
+			todo.AssigneeIDs = append(todo.AssigneeIDs, assigneeID)
+		}
+	}
+
+	if err := c.todoRepo.Restore(todo, userID); err != nil {
+		if errors.Is(err, repository.ErrVersionConflict) {
+			problem.Error(w, http.StatusPreconditionFailed, problem.CodeVersionConflict, "Todo has been modified")
+			return
+		}
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to restore todo")
+		return
+	}
+	c.publish(events.TypeTodoUpdated, todo, userID)
+
+	// Return the restored todo
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", todoETag(todo))
+	json.NewEncoder(w).Encode(todo.ToResponse())
+}
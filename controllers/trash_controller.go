@@ -0,0 +1,185 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/noman/todo-application/events"
+	"github.com/noman/todo-application/middleware"
+	"github.com/noman/todo-application/models"
+	"github.com/noman/todo-application/problem"
+	"github.com/noman/todo-application/repository"
+	"github.com/noman/todo-application/storage"
+)
+
+// TrashController handles requests for deleted todos
+type TrashController struct {
+	todoRepo      *repository.TodoRepository
+	workspaceRepo *repository.WorkspaceRepository
+	store         storage.BlobStore
+	broker        events.Broker
+}
+
+// NewTrashController creates a new TrashController
+func NewTrashController() *TrashController {
+	return &TrashController{
+		todoRepo:      repository.NewTodoRepository(),
+		workspaceRepo: repository.NewWorkspaceRepository(),
+		store:         storage.Store,
+		broker:        events.Default,
+	}
+}
+
+// publish tells clients that a todo changed, like TodoController.publish
+func (c *TrashController) publish(eventType string, todo *models.Todo, actorID uuid.UUID) {
+	events.PublishTodo(c.broker, c.workspaceRepo, eventType, todo, actorID)
+}
+
+// getDeletedTodo parses the todo ID from the URL and loads the todo from the
+// trash, writing an error response and returning nil if it isn't there or the
+// user can't access it
+func (c *TrashController) getDeletedTodo(w http.ResponseWriter, r *http.Request, userID uuid.UUID) *models.Todo {
+	vars := mux.Vars(r)
+	todoID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		problem.Error(w, http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid todo ID")
+		return nil
+	}
+
+	todo, err := c.todoRepo.GetDeletedByID(todoID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			problem.Error(w, http.StatusNotFound, problem.CodeNotFound, "Todo not found in trash")
+			return nil
+		}
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to get todo")
+		return nil
+	}
+
+	allowed, err := canAccessTodo(c.workspaceRepo, todo, userID)
+	if err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to get todo")
+		return nil
+	}
+	if !allowed {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return nil
+	}
+
+	return todo
+}
+
+// GetAll handles listing the todos in the user's trash
+func (c *TrashController) GetAll(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	todos, err := c.todoRepo.ListTrash(userID)
+	if err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to get trash")
+		return
+	}
+
+	// Convert todos to responses
+	responses := make([]models.TodoResponse, len(todos))
+	for i, todo := range todos {
+		responses[i] = todo.ToResponse()
+	}
+
+	// Return the todos
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(responses)
+}
+
+// Restore handles moving a todo out of the trash
+func (c *TrashController) Restore(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	todo := c.getDeletedTodo(w, r, userID)
+	if todo == nil {
+		return
+	}
+
+	if err := c.todoRepo.Undelete(todo.ID, userID); err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to restore todo")
+		return
+	}
+
+	// Return the restored todo
+	restored, err := c.todoRepo.GetByID(todo.ID)
+	if err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to get todo")
+		return
+	}
+
+	// The todo reappears in lists, so it's new to clients
+	c.publish(events.TypeTodoCreated, restored, userID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(restored.ToResponse())
+}
+
+// Purge handles permanently deleting a todo from the trash
+func (c *TrashController) Purge(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	todo := c.getDeletedTodo(w, r, userID)
+	if todo == nil {
+		return
+	}
+
+	keys, err := c.todoRepo.Purge(todo.ID, userID)
+	if err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to delete todo")
+		return
+	}
+	storage.DeleteAll(r.Context(), c.store, keys)
+
+	// Return success
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Empty handles permanently deleting every todo in the user's trash
+func (c *TrashController) Empty(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	todos, err := c.todoRepo.ListTrash(userID)
+	if err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to empty trash")
+		return
+	}
+
+	for _, todo := range todos {
+		keys, err := c.todoRepo.Purge(todo.ID, userID)
+		if err != nil {
+			problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to empty trash")
+			return
+		}
+		storage.DeleteAll(r.Context(), c.store, keys)
+	}
+
+	// Return success
+	w.WriteHeader(http.StatusNoContent)
+}
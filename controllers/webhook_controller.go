@@ -0,0 +1,237 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/noman/todo-application/middleware"
+	"github.com/noman/todo-application/models"
+	"github.com/noman/todo-application/problem"
+	"github.com/noman/todo-application/repository"
+	"github.com/noman/todo-application/webhooks"
+)
+
+// deliveryLogLimit is how many recent deliveries are listed for a webhook
+const deliveryLogLimit = 100
+
+// WebhookController handles requests for a user's webhooks
+type WebhookController struct {
+	webhookRepo *repository.WebhookRepository
+}
+
+// NewWebhookController creates a new WebhookController
+func NewWebhookController() *WebhookController {
+	return &WebhookController{
+		webhookRepo: repository.NewWebhookRepository(),
+	}
+}
+
+// getOwnWebhook parses the webhook ID from the URL and loads the webhook,
+// writing an error response and returning nil unless it belongs to the user
+func (c *WebhookController) getOwnWebhook(w http.ResponseWriter, r *http.Request, userID uuid.UUID) *models.Webhook {
+	vars := mux.Vars(r)
+	webhookID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		problem.Error(w, http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid webhook ID")
+		return nil
+	}
+
+	webhook, err := c.webhookRepo.GetByID(webhookID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			problem.Error(w, http.StatusNotFound, problem.CodeNotFound, "Webhook not found")
+			return nil
+		}
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to get webhook")
+		return nil
+	}
+
+	// Other users' webhooks are reported as missing
+	if webhook.UserID != userID {
+		problem.Error(w, http.StatusNotFound, problem.CodeNotFound, "Webhook not found")
+		return nil
+	}
+
+	return webhook
+}
+
+// uniqueEvents drops repeated event types, keeping the first of each
+func uniqueEvents(eventTypes []string) []string {
+	unique := []string{}
+	seen := map[string]bool{}
+	for _, eventType := range eventTypes {
+		if !seen[eventType] {
+			seen[eventType] = true
+			unique = append(unique, eventType)
+		}
+	}
+	return unique
+}
+
+// Create handles registering a new webhook. The response is the only one that
+// includes the webhook's signing secret.
+func (c *WebhookController) Create(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Parse the request body
+	var req models.CreateWebhookRequest
+	if !decodeRequest(w, r.Body, &req) {
+		return
+	}
+
+	secret, err := webhooks.NewSecret()
+	if err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to create webhook")
+		return
+	}
+
+	// Create the webhook
+	webhook := &models.Webhook{
+		UserID:      userID,
+		URL:         req.URL,
+		Description: req.Description,
+		Events:      uniqueEvents(req.Events),
+		Secret:      secret,
+	}
+
+	if err := c.webhookRepo.Create(webhook); err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to create webhook")
+		return
+	}
+
+	// Return the created webhook
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(webhook)
+}
+
+// GetAll handles listing the user's webhooks
+func (c *WebhookController) GetAll(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	hooks, err := c.webhookRepo.GetAllByUserID(userID)
+	if err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to get webhooks")
+		return
+	}
+
+	// Return the webhooks
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(hooks)
+}
+
+// Get handles getting a single webhook
+func (c *WebhookController) Get(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	webhook := c.getOwnWebhook(w, r, userID)
+	if webhook == nil {
+		return
+	}
+
+	// Return the webhook
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(webhook)
+}
+
+// Update handles changing a webhook's endpoint and events, and pausing or
+// re-enabling it
+func (c *WebhookController) Update(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	webhook := c.getOwnWebhook(w, r, userID)
+	if webhook == nil {
+		return
+	}
+
+	// Parse the request body
+	var req models.UpdateWebhookRequest
+	if !decodeRequest(w, r.Body, &req) {
+		return
+	}
+
+	webhook.URL = req.URL
+	webhook.Description = req.Description
+	webhook.Events = uniqueEvents(req.Events)
+	webhook.Active = *req.Active
+
+	if err := c.webhookRepo.Update(webhook); err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to update webhook")
+		return
+	}
+
+	// Return the updated webhook
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(webhook)
+}
+
+// Delete handles deleting a webhook and its delivery log
+func (c *WebhookController) Delete(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	webhook := c.getOwnWebhook(w, r, userID)
+	if webhook == nil {
+		return
+	}
+
+	if err := c.webhookRepo.Delete(webhook.ID); err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to delete webhook")
+		return
+	}
+
+	// Return success
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetDeliveries handles listing a webhook's most recent deliveries
+func (c *WebhookController) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	webhook := c.getOwnWebhook(w, r, userID)
+	if webhook == nil {
+		return
+	}
+
+	deliveries, err := c.webhookRepo.GetDeliveries(webhook.ID, deliveryLogLimit)
+	if err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to get deliveries")
+		return
+	}
+
+	// Return the deliveries
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deliveries)
+}
@@ -0,0 +1,299 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/noman/todo-application/middleware"
+	"github.com/noman/todo-application/models"
+	"github.com/noman/todo-application/problem"
+	"github.com/noman/todo-application/repository"
+)
+
+// WorkspaceController handles workspace, member and project requests
+type WorkspaceController struct {
+	workspaceRepo *repository.WorkspaceRepository
+	userRepo      *repository.UserRepository
+}
+
+// NewWorkspaceController creates a new WorkspaceController
+func NewWorkspaceController() *WorkspaceController {
+	return &WorkspaceController{
+		workspaceRepo: repository.NewWorkspaceRepository(),
+		userRepo:      repository.NewUserRepository(),
+	}
+}
+
+// memberRole parses the workspace ID from the URL and returns the user's role
+// in it, writing an error response and returning false if the user is not a member
+func (c *WorkspaceController) memberRole(w http.ResponseWriter, r *http.Request, userID uuid.UUID) (uuid.UUID, string, bool) {
+	vars := mux.Vars(r)
+	workspaceID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		problem.Error(w, http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid workspace ID")
+		return uuid.Nil, "", false
+	}
+
+	role, err := c.workspaceRepo.GetMemberRole(workspaceID, userID)
+	if err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to get workspace")
+		return uuid.Nil, "", false
+	}
+	if role == "" {
+		problem.Error(w, http.StatusNotFound, problem.CodeNotFound, "Workspace not found")
+		return uuid.Nil, "", false
+	}
+
+	return workspaceID, role, true
+}
+
+// Create handles creating a new workspace
+func (c *WorkspaceController) Create(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Parse the request body
+	var req models.CreateWorkspaceRequest
+	if !decodeRequest(w, r.Body, &req) {
+		return
+	}
+
+	// Create the workspace
+	workspace := &models.Workspace{
+		Name:    req.Name,
+		OwnerID: userID,
+	}
+
+	if err := c.workspaceRepo.Create(workspace); err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to create workspace")
+		return
+	}
+
+	// Return the created workspace
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(workspace)
+}
+
+// GetAll handles getting all workspaces the user is a member of
+func (c *WorkspaceController) GetAll(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	workspaces, err := c.workspaceRepo.GetAllByUserID(userID)
+	if err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to get workspaces")
+		return
+	}
+
+	// Return the workspaces
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(workspaces)
+}
+
+// GetMembers handles listing the members of a workspace
+func (c *WorkspaceController) GetMembers(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	workspaceID, _, ok := c.memberRole(w, r, userID)
+	if !ok {
+		return
+	}
+
+	members, err := c.workspaceRepo.GetMembers(workspaceID)
+	if err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to get members")
+		return
+	}
+
+	// Return the members
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(members)
+}
+
+// AddMember handles adding a user to a workspace by email (owners only)
+func (c *WorkspaceController) AddMember(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	workspaceID, role, ok := c.memberRole(w, r, userID)
+	if !ok {
+		return
+	}
+	if role != models.WorkspaceRoleOwner {
+		problem.Error(w, http.StatusForbidden, problem.CodeForbidden, "Only workspace owners can add members")
+		return
+	}
+
+	// Parse the request body
+	var req models.AddMemberRequest
+	if !decodeRequest(w, r.Body, &req) {
+		return
+	}
+
+	// Default to adding a regular member
+	if req.Role == "" {
+		req.Role = models.WorkspaceRoleMember
+	}
+
+	// Look up the user being added
+	user, err := c.userRepo.GetByEmail(req.Email)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			problem.Error(w, http.StatusNotFound, problem.CodeNotFound, "User not found")
+			return
+		}
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to add member")
+		return
+	}
+
+	if err := c.workspaceRepo.AddMember(workspaceID, user.ID, req.Role); err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to add member")
+		return
+	}
+
+	// Return the members
+	members, err := c.workspaceRepo.GetMembers(workspaceID)
+	if err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to get members")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(members)
+}
+
+// RemoveMember handles removing a user from a workspace; owners can remove
+// anyone except the workspace creator, and members can remove themselves
+func (c *WorkspaceController) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	workspaceID, role, ok := c.memberRole(w, r, userID)
+	if !ok {
+		return
+	}
+
+	vars := mux.Vars(r)
+	memberID, err := uuid.Parse(vars["userId"])
+	if err != nil {
+		problem.Error(w, http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid user ID")
+		return
+	}
+
+	if role != models.WorkspaceRoleOwner && memberID != userID {
+		problem.Error(w, http.StatusForbidden, problem.CodeForbidden, "Only workspace owners can remove other members")
+		return
+	}
+
+	workspace, err := c.workspaceRepo.GetByID(workspaceID)
+	if err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to get workspace")
+		return
+	}
+	if workspace.OwnerID == memberID {
+		problem.Error(w, http.StatusBadRequest, problem.CodeInvalidRequest, "The workspace creator cannot be removed")
+		return
+	}
+
+	if err := c.workspaceRepo.RemoveMember(workspaceID, memberID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			problem.Error(w, http.StatusNotFound, problem.CodeNotFound, "Member not found")
+			return
+		}
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to remove member")
+		return
+	}
+
+	// Return success
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CreateProject handles creating a project in a workspace
+func (c *WorkspaceController) CreateProject(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	workspaceID, _, ok := c.memberRole(w, r, userID)
+	if !ok {
+		return
+	}
+
+	// Parse the request body
+	var req models.CreateProjectRequest
+	if !decodeRequest(w, r.Body, &req) {
+		return
+	}
+
+	// Create the project
+	project := &models.Project{
+		WorkspaceID: workspaceID,
+		Name:        req.Name,
+		Description: req.Description,
+		CreatedBy:   userID,
+	}
+
+	if err := c.workspaceRepo.CreateProject(project); err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to create project")
+		return
+	}
+
+	// Return the created project
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(project)
+}
+
+// GetProjects handles listing the projects of a workspace
+func (c *WorkspaceController) GetProjects(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the context
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	workspaceID, _, ok := c.memberRole(w, r, userID)
+	if !ok {
+		return
+	}
+
+	projects, err := c.workspaceRepo.GetProjectsByWorkspaceID(workspaceID)
+	if err != nil {
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to get projects")
+		return
+	}
+
+	// Return the projects
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(projects)
+}
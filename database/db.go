@@ -11,19 +11,27 @@ import (
 
 var DB *sql.DB
 
-// InitDB initializes the database connection
-func InitDB() {
+// FullTextSearch reports whether the database has the full-text search
+// column on todos
+var FullTextSearch bool
+
+// ConnString returns the connection string for the database configured in
+// the environment
+func ConnString() string {
 	dbHost := os.Getenv("DB_HOST")
 	dbPort := os.Getenv("DB_PORT")
 	dbUser := os.Getenv("DB_USER")
 	dbPassword := os.Getenv("DB_PASSWORD")
 	dbName := os.Getenv("DB_NAME")
 
-	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
 		dbHost, dbPort, dbUser, dbPassword, dbName)
+}
 
+// InitDB initializes the database connection
+func InitDB() {
 	var err error
-	DB, err = sql.Open("postgres", connStr)
+	DB, err = sql.Open("postgres", ConnString())
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
@@ -36,11 +44,11 @@ func InitDB() {
 	log.Println("Successfully connected to database")
 
 	// Create tables if they don't exist
-	createTablesIfNotExist()
+	CreateTables()
 }
 
-// createTablesIfNotExist creates the necessary tables if they don't exist
-func createTablesIfNotExist() {
+// CreateTables creates the necessary tables if they don't exist
+func CreateTables() {
 	// Create users table
 	usersTable := `
 	CREATE TABLE IF NOT EXISTS users (
@@ -77,21 +85,400 @@ func createTablesIfNotExist() {
 	);
 	`
 
-	// Execute the queries
-	_, err := DB.Exec(usersTable)
-	if err != nil {
-		log.Fatalf("Failed to create users table: %v", err)
+	// Create workspaces table
+	workspacesTable := `
+	CREATE TABLE IF NOT EXISTS workspaces (
+		id UUID PRIMARY KEY,
+		name VARCHAR(100) NOT NULL,
+		owner_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		created_at TIMESTAMP NOT NULL,
+		updated_at TIMESTAMP NOT NULL
+	);
+	`
+
+	// Create workspace members table
+	workspaceMembersTable := `
+	CREATE TABLE IF NOT EXISTS workspace_members (
+		workspace_id UUID NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+		user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		role VARCHAR(20) NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (workspace_id, user_id)
+	);
+	`
+
+	// Create projects table
+	projectsTable := `
+	CREATE TABLE IF NOT EXISTS projects (
+		id UUID PRIMARY KEY,
+		workspace_id UUID NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+		name VARCHAR(100) NOT NULL,
+		description TEXT,
+		created_by UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		created_at TIMESTAMP NOT NULL,
+		updated_at TIMESTAMP NOT NULL
+	);
+	`
+
+	// Add workspace and project columns to todos
+	todosWorkspaceColumns := `
+	ALTER TABLE todos
+		ADD COLUMN IF NOT EXISTS workspace_id UUID REFERENCES workspaces(id) ON DELETE CASCADE,
+		ADD COLUMN IF NOT EXISTS project_id UUID REFERENCES projects(id) ON DELETE SET NULL;
+	CREATE INDEX IF NOT EXISTS idx_todos_workspace_id ON todos(workspace_id);
+	`
+
+	// Create todo assignees table
+	todoAssigneesTable := `
+	CREATE TABLE IF NOT EXISTS todo_assignees (
+		todo_id UUID NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+		user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		created_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (todo_id, user_id)
+	);
+	`
+
+	// Create comments table
+	commentsTable := `
+	CREATE TABLE IF NOT EXISTS comments (
+		id UUID PRIMARY KEY,
+		todo_id UUID NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+		parent_id UUID REFERENCES comments(id) ON DELETE CASCADE,
+		user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		body TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		updated_at TIMESTAMP NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_comments_todo_id ON comments(todo_id);
+	`
+
+	// Create notifications table
+	notificationsTable := `
+	CREATE TABLE IF NOT EXISTS notifications (
+		id UUID PRIMARY KEY,
+		user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		type VARCHAR(50) NOT NULL,
+		actor_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		todo_id UUID REFERENCES todos(id) ON DELETE CASCADE,
+		comment_id UUID REFERENCES comments(id) ON DELETE CASCADE,
+		message TEXT NOT NULL,
+		read_at TIMESTAMP,
+		created_at TIMESTAMP NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_notifications_user_id ON notifications(user_id, created_at);
+	`
+
+	// Create attachments table
+	attachmentsTable := `
+	CREATE TABLE IF NOT EXISTS attachments (
+		id UUID PRIMARY KEY,
+		todo_id UUID NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+		user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		filename VARCHAR(255) NOT NULL,
+		content_type VARCHAR(255) NOT NULL,
+		size BIGINT NOT NULL,
+		storage_key TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_attachments_todo_id ON attachments(todo_id);
+	CREATE INDEX IF NOT EXISTS idx_attachments_user_id ON attachments(user_id);
+	`
+
+	// Create todo events table; events are immutable and outlive their todo and
+	// actor, so todo_id and actor_id have no foreign keys
+	todoEventsTable := `
+	CREATE TABLE IF NOT EXISTS todo_events (
+		id UUID PRIMARY KEY,
+		todo_id UUID NOT NULL,
+		revision INTEGER NOT NULL,
+		action VARCHAR(20) NOT NULL,
+		actor_id UUID NOT NULL,
+		changes JSONB NOT NULL,
+		snapshot JSONB NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		UNIQUE (todo_id, revision)
+	);
+	`
+
+	// Add the soft delete column to todos
+	todosDeletedAtColumn := `
+	ALTER TABLE todos ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+	CREATE INDEX IF NOT EXISTS idx_todos_deleted_at ON todos(deleted_at) WHERE deleted_at IS NOT NULL;
+	`
+
+	// Add the optimistic concurrency version column to todos
+	todosVersionColumn := `
+	ALTER TABLE todos ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+	`
+
+	// Create idempotency keys table; a response status of 0 marks a request
+	// that is still in progress
+	idempotencyKeysTable := `
+	CREATE TABLE IF NOT EXISTS idempotency_keys (
+		user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		key VARCHAR(255) NOT NULL,
+		fingerprint VARCHAR(64) NOT NULL,
+		status INTEGER NOT NULL DEFAULT 0,
+		headers JSONB,
+		body BYTEA,
+		created_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (user_id, key)
+	);
+	CREATE INDEX IF NOT EXISTS idx_idempotency_keys_created_at ON idempotency_keys(created_at);
+	`
+
+	// Record the version each history entry brought the todo to, which lets
+	// offline edits be merged with the changes made since
+	todoEventsVersionColumn := `
+	ALTER TABLE todo_events ADD COLUMN IF NOT EXISTS version INTEGER;
+	`
+
+	// Create the sync tables: every user has a counter that is bumped for each
+	// change to a todo they can see, and a row per todo holding the counter
+	// value of its latest change (seq) and of the first one (first_seq)
+	syncTables := `
+	CREATE TABLE IF NOT EXISTS sync_counters (
+		user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+		seq BIGINT NOT NULL,
+		pruned_seq BIGINT NOT NULL DEFAULT 0
+	);
+	CREATE TABLE IF NOT EXISTS sync_changes (
+		user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		todo_id UUID NOT NULL,
+		seq BIGINT NOT NULL,
+		first_seq BIGINT NOT NULL,
+		changed_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (user_id, todo_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_sync_changes_user_seq ON sync_changes(user_id, seq, todo_id);
+	CREATE INDEX IF NOT EXISTS idx_sync_changes_changed_at ON sync_changes(changed_at);
+	`
+
+	// Start the sync log with every existing todo; this only does anything
+	// before the first change has been logged
+	syncBackfill := `
+	INSERT INTO sync_changes (user_id, todo_id, seq, first_seq, changed_at)
+	SELECT user_id, todo_id, n, n, NOW()
+	FROM (
+		SELECT user_id, todo_id, ROW_NUMBER() OVER (PARTITION BY user_id ORDER BY todo_id) AS n
+		FROM (
+			SELECT user_id, id AS todo_id FROM todos
+			UNION
+			SELECT m.user_id, t.id FROM todos t JOIN workspace_members m ON m.workspace_id = t.workspace_id
+		) audience
+	) numbered
+	WHERE NOT EXISTS (SELECT 1 FROM sync_counters);
+	INSERT INTO sync_counters (user_id, seq)
+	SELECT user_id, MAX(seq) FROM sync_changes GROUP BY user_id
+	ON CONFLICT (user_id) DO NOTHING;
+	`
+
+	// Create webhooks table
+	webhooksTable := `
+	CREATE TABLE IF NOT EXISTS webhooks (
+		id UUID PRIMARY KEY,
+		user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		url TEXT NOT NULL,
+		description VARCHAR(255) NOT NULL DEFAULT '',
+		secret VARCHAR(100) NOT NULL,
+		events TEXT[] NOT NULL,
+		active BOOLEAN NOT NULL DEFAULT TRUE,
+		failure_count INTEGER NOT NULL DEFAULT 0,
+		disabled_at TIMESTAMP,
+		created_at TIMESTAMP NOT NULL,
+		updated_at TIMESTAMP NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_webhooks_user_id ON webhooks(user_id);
+	`
+
+	// Create webhook deliveries table, which is both the delivery queue and
+	// the delivery log
+	webhookDeliveriesTable := `
+	CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id UUID PRIMARY KEY,
+		webhook_id UUID NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+		event_id UUID NOT NULL,
+		event_type VARCHAR(50) NOT NULL,
+		payload JSONB NOT NULL,
+		status VARCHAR(20) NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		response_status INTEGER,
+		error TEXT NOT NULL DEFAULT '',
+		next_attempt_at TIMESTAMP,
+		last_attempt_at TIMESTAMP,
+		created_at TIMESTAMP NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_id ON webhook_deliveries(webhook_id, created_at);
+	CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_pending ON webhook_deliveries(next_attempt_at) WHERE status = 'pending';
+	`
+
+	// Create calendar feeds table. Each user has at most one feed; only a
+	// hash of its secret token is stored.
+	calendarFeedsTable := `
+	CREATE TABLE IF NOT EXISTS calendar_feeds (
+		user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+		token_hash VARCHAR(64) NOT NULL UNIQUE,
+		created_at TIMESTAMP NOT NULL
+	);
+	`
+
+	// Create app passwords table. Only a hash of each password is stored.
+	appPasswordsTable := `
+	CREATE TABLE IF NOT EXISTS app_passwords (
+		id UUID PRIMARY KEY,
+		user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		name VARCHAR(100) NOT NULL,
+		password_hash VARCHAR(64) NOT NULL UNIQUE,
+		created_at TIMESTAMP NOT NULL,
+		last_used_at TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_app_passwords_user_id ON app_passwords(user_id);
+	`
+
+	// Create CalDAV resources table, which keeps the resource name and UID a
+	// CalDAV client chose for a todo it created, so the client finds the todo
+	// where it put it
+	caldavResourcesTable := `
+	CREATE TABLE IF NOT EXISTS caldav_resources (
+		todo_id UUID PRIMARY KEY REFERENCES todos(id) ON DELETE CASCADE,
+		name VARCHAR(255) NOT NULL,
+		uid VARCHAR(255) NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_caldav_resources_name ON caldav_resources(name);
+	`
+
+	// Create import jobs table. The uploaded file is kept until the job
+	// finishes; locked_until is when a worker's claim on a running job lapses.
+	importJobsTable := `
+	CREATE TABLE IF NOT EXISTS import_jobs (
+		id UUID PRIMARY KEY,
+		user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		workspace_id UUID REFERENCES workspaces(id) ON DELETE CASCADE,
+		source VARCHAR(20) NOT NULL,
+		filename VARCHAR(255) NOT NULL,
+		data BYTEA,
+		status VARCHAR(20) NOT NULL,
+		total INTEGER NOT NULL DEFAULT 0,
+		processed INTEGER NOT NULL DEFAULT 0,
+		error TEXT NOT NULL DEFAULT '',
+		locked_until TIMESTAMP,
+		created_at TIMESTAMP NOT NULL,
+		started_at TIMESTAMP,
+		finished_at TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_import_jobs_user_id ON import_jobs(user_id, created_at);
+	CREATE INDEX IF NOT EXISTS idx_import_jobs_pending ON import_jobs(created_at) WHERE status IN ('queued', 'running');
+	`
+
+	// Add the column recording when a user asked for their account to be
+	// deleted; it's deleted for good once the grace period ends
+	usersDeletionColumn := `
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS deletion_scheduled_at TIMESTAMP;
+	CREATE INDEX IF NOT EXISTS idx_users_deletion_scheduled_at ON users(deletion_scheduled_at) WHERE deletion_scheduled_at IS NOT NULL;
+	`
+
+	// Create account exports table. The archive is kept in the blob store
+	// until expires_at; locked_until is when a worker's claim on a running
+	// export lapses.
+	accountExportsTable := `
+	CREATE TABLE IF NOT EXISTS account_exports (
+		id UUID PRIMARY KEY,
+		user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		status VARCHAR(20) NOT NULL,
+		storage_key VARCHAR(255) NOT NULL DEFAULT '',
+		size BIGINT NOT NULL DEFAULT 0,
+		error TEXT NOT NULL DEFAULT '',
+		locked_until TIMESTAMP,
+		created_at TIMESTAMP NOT NULL,
+		finished_at TIMESTAMP,
+		expires_at TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_account_exports_user_id ON account_exports(user_id, created_at);
+	CREATE INDEX IF NOT EXISTS idx_account_exports_pending ON account_exports(created_at) WHERE status IN ('queued', 'running');
+	CREATE INDEX IF NOT EXISTS idx_account_exports_expires_at ON account_exports(expires_at);
+	`
+
+	// Create stream tickets table. A ticket lets an event stream be opened
+	// once, on behalf of the bearer token it was created with, without putting
+	// that token in the stream's URL
+	streamTicketsTable := `
+	CREATE TABLE IF NOT EXISTS stream_tickets (
+		ticket_hash VARCHAR(64) PRIMARY KEY,
+		user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		access_token TEXT NOT NULL,
+		expires_at TIMESTAMP NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_stream_tickets_expires_at ON stream_tickets(expires_at);
+	`
+
+	// Add the tags, priority and due date columns to todos. Priorities are
+	// stored by rank, 0 (none) to 3 (high), so they sort and compare.
+	todosPlanningColumns := `
+	ALTER TABLE todos ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+	ALTER TABLE todos ADD COLUMN IF NOT EXISTS priority SMALLINT NOT NULL DEFAULT 0;
+	ALTER TABLE todos ADD COLUMN IF NOT EXISTS due_at TIMESTAMP;
+	CREATE INDEX IF NOT EXISTS idx_todos_due_at ON todos(due_at) WHERE due_at IS NOT NULL;
+	`
+
+	// Add the full-text search column to todos, kept up to date by the database.
+	// The simple configuration doesn't stem words or drop stop words, so
+	// searches work the same in any language; titles weigh more than
+	// descriptions.
+	todosSearchColumn := `
+	ALTER TABLE todos ADD COLUMN IF NOT EXISTS search_vector TSVECTOR GENERATED ALWAYS AS (
+		setweight(to_tsvector('simple', title), 'A') || setweight(to_tsvector('simple', COALESCE(description, '')), 'B')
+	) STORED;
+	CREATE INDEX IF NOT EXISTS idx_todos_search_vector ON todos USING GIN (search_vector);
+	`
+
+	// Execute the queries in order, since later tables reference earlier ones
+	migrations := []struct {
+		name  string
+		query string
+	}{
+		{"users table", usersTable},
+		{"todos table", todosTable},
+		{"blacklisted_tokens table", blacklistedTokensTable},
+		{"workspaces table", workspacesTable},
+		{"workspace_members table", workspaceMembersTable},
+		{"projects table", projectsTable},
+		{"todos workspace columns", todosWorkspaceColumns},
+		{"todo_assignees table", todoAssigneesTable},
+		{"comments table", commentsTable},
+		{"notifications table", notificationsTable},
+		{"attachments table", attachmentsTable},
+		{"todo_events table", todoEventsTable},
+		{"todos deleted_at column", todosDeletedAtColumn},
+		{"todos version column", todosVersionColumn},
+		{"idempotency_keys table", idempotencyKeysTable},
+		{"todo_events version column", todoEventsVersionColumn},
+		{"sync tables", syncTables},
+		{"sync log", syncBackfill},
+		{"webhooks table", webhooksTable},
+		{"webhook_deliveries table", webhookDeliveriesTable},
+		{"calendar_feeds table", calendarFeedsTable},
+		{"app_passwords table", appPasswordsTable},
+		{"caldav_resources table", caldavResourcesTable},
+		{"import_jobs table", importJobsTable},
+		{"users deletion_scheduled_at column", usersDeletionColumn},
+		{"account_exports table", accountExportsTable},
+		{"stream_tickets table", streamTicketsTable},
+		{"todos tags, priority and due_at columns", todosPlanningColumns},
 	}
 
-	_, err = DB.Exec(todosTable)
-	if err != nil {
-		log.Fatalf("Failed to create todos table: %v", err)
+	for _, m := range migrations {
+		if _, err := DB.Exec(m.query); err != nil {
+			log.Fatalf("Failed to create %s: %v", m.name, err)
+		}
 	}
 
-	_, err = DB.Exec(blacklistedTokensTable)
-	if err != nil {
-		log.Fatalf("Failed to create blacklisted_tokens table: %v", err)
+	// Full-text search needs generated columns and text search functions;
+	// databases without them search todos by reading them instead
+	if _, err := DB.Exec(todosSearchColumn); err != nil {
+		log.Printf("Full-text search is unavailable, so searches will read every todo: %v", err)
+	} else {
+		FullTextSearch = true
 	}
 
 	log.Println("Database tables created successfully")
-}
\ No newline at end of file
+}
@@ -0,0 +1,339 @@
+// Package dav reads WebDAV (RFC 4918) and CalDAV (RFC 4791) request bodies
+// and writes multistatus responses
+package dav
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// XML namespaces
+const (
+	NamespaceDAV            = "DAV:"
+	NamespaceCalDAV         = "urn:ietf:params:xml:ns:caldav"
+	NamespaceCalendarServer = "http://calendarserver.org/ns/"
+)
+
+// prefixes maps the namespaces responses use to their prefixes
+var prefixes = map[string]string{
+	NamespaceDAV:            "D",
+	NamespaceCalDAV:         "C",
+	NamespaceCalendarServer: "CS",
+}
+
+// MediaType is the content type of XML request and response bodies
+const MediaType = "application/xml; charset=utf-8"
+
+// ErrInvalidBody is returned for request bodies that aren't well-formed or
+// aren't the expected element
+var ErrInvalidBody = errors.New("invalid request body")
+
+// Name returns the name of an element in the DAV: namespace
+func Name(local string) xml.Name {
+	return xml.Name{Space: NamespaceDAV, Local: local}
+}
+
+// CalDAVName returns the name of an element in the CalDAV namespace
+func CalDAVName(local string) xml.Name {
+	return xml.Name{Space: NamespaceCalDAV, Local: local}
+}
+
+// element is any XML element, keeping only its name
+type element struct {
+	XMLName xml.Name
+}
+
+// Prop is a list of property names, as in a PROPFIND or REPORT request
+type Prop struct {
+	Elements []element `xml:",any"`
+}
+
+// Names returns the property names
+func (p *Prop) Names() []xml.Name {
+	names := make([]xml.Name, len(p.Elements))
+	for i, e := range p.Elements {
+		names[i] = e.XMLName
+	}
+	return names
+}
+
+// Propfind is a PROPFIND request. An empty body asks for all properties,
+// the same as allprop.
+type Propfind struct {
+	XMLName  xml.Name  `xml:"DAV: propfind"`
+	AllProp  *struct{} `xml:"DAV: allprop"`
+	PropName *struct{} `xml:"DAV: propname"`
+	Prop     *Prop     `xml:"DAV: prop"`
+}
+
+// ReadPropfind reads the body of a PROPFIND request
+func ReadPropfind(r io.Reader) (*Propfind, error) {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	propfind := &Propfind{}
+	if len(bytes.TrimSpace(body)) == 0 {
+		propfind.AllProp = &struct{}{}
+		return propfind, nil
+	}
+	if err := xml.Unmarshal(body, propfind); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBody, err)
+	}
+	if propfind.AllProp == nil && propfind.PropName == nil && propfind.Prop == nil {
+		return nil, fmt.Errorf("%w: propfind needs allprop, propname or prop", ErrInvalidBody)
+	}
+	return propfind, nil
+}
+
+// PropertyUpdate is a PROPPATCH request
+type PropertyUpdate struct {
+	XMLName xml.Name `xml:"DAV: propertyupdate"`
+	Set     []struct {
+		Prop Prop `xml:"DAV: prop"`
+	} `xml:"DAV: set"`
+	Remove []struct {
+		Prop Prop `xml:"DAV: prop"`
+	} `xml:"DAV: remove"`
+}
+
+// Names returns the names of the properties the request sets or removes
+func (u *PropertyUpdate) Names() []xml.Name {
+	names := []xml.Name{}
+	for _, set := range u.Set {
+		names = append(names, set.Prop.Names()...)
+	}
+	for _, remove := range u.Remove {
+		names = append(names, remove.Prop.Names()...)
+	}
+	return names
+}
+
+// ReadPropertyUpdate reads the body of a PROPPATCH request
+func ReadPropertyUpdate(r io.Reader) (*PropertyUpdate, error) {
+	update := &PropertyUpdate{}
+	if err := xml.NewDecoder(r).Decode(update); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBody, err)
+	}
+	return update, nil
+}
+
+// TextMatch is a text-match filter: a case-insensitive substring match
+type TextMatch struct {
+	Text   string `xml:",chardata"`
+	Negate string `xml:"negate-condition,attr"`
+}
+
+// Matches checks if a value matches the filter
+func (t *TextMatch) Matches(value string) bool {
+	found := strings.Contains(strings.ToLower(value), strings.ToLower(t.Text))
+	return found != (t.Negate == "yes")
+}
+
+// PropFilter is a prop-filter of a calendar-query
+type PropFilter struct {
+	Name         string     `xml:"name,attr"`
+	IsNotDefined *struct{}  `xml:"urn:ietf:params:xml:ns:caldav is-not-defined"`
+	TextMatch    *TextMatch `xml:"urn:ietf:params:xml:ns:caldav text-match"`
+}
+
+// CompFilter is a comp-filter of a calendar-query. Time ranges aren't kept,
+// so they always match.
+type CompFilter struct {
+	Name         string       `xml:"name,attr"`
+	IsNotDefined *struct{}    `xml:"urn:ietf:params:xml:ns:caldav is-not-defined"`
+	CompFilters  []CompFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+	PropFilters  []PropFilter `xml:"urn:ietf:params:xml:ns:caldav prop-filter"`
+}
+
+// CalendarQuery is a calendar-query REPORT
+type CalendarQuery struct {
+	XMLName xml.Name  `xml:"urn:ietf:params:xml:ns:caldav calendar-query"`
+	AllProp *struct{} `xml:"DAV: allprop"`
+	Prop    *Prop     `xml:"DAV: prop"`
+	Filter  struct {
+		CompFilter CompFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+	} `xml:"urn:ietf:params:xml:ns:caldav filter"`
+}
+
+// CalendarMultiget is a calendar-multiget REPORT
+type CalendarMultiget struct {
+	XMLName xml.Name  `xml:"urn:ietf:params:xml:ns:caldav calendar-multiget"`
+	AllProp *struct{} `xml:"DAV: allprop"`
+	Prop    *Prop     `xml:"DAV: prop"`
+	Hrefs   []string  `xml:"DAV: href"`
+}
+
+// SyncCollection is a sync-collection REPORT (RFC 6578)
+type SyncCollection struct {
+	XMLName   xml.Name `xml:"DAV: sync-collection"`
+	SyncToken string   `xml:"DAV: sync-token"`
+	SyncLevel string   `xml:"DAV: sync-level"`
+	Limit     *struct {
+		NResults int `xml:"DAV: nresults"`
+	} `xml:"DAV: limit"`
+	Prop *Prop `xml:"DAV: prop"`
+}
+
+// ReadReport reads the body of a REPORT request, returning a *CalendarQuery,
+// *CalendarMultiget or *SyncCollection. Other reports are reported with the
+// name of their element and ErrUnsupportedReport.
+func ReadReport(r io.Reader) (interface{}, xml.Name, error) {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, xml.Name{}, err
+	}
+
+	// Find the report's type from its root element
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	var root xml.Name
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, xml.Name{}, fmt.Errorf("%w: %v", ErrInvalidBody, err)
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			root = start.Name
+			break
+		}
+	}
+
+	var report interface{}
+	switch root {
+	case CalDAVName("calendar-query"):
+		report = &CalendarQuery{}
+	case CalDAVName("calendar-multiget"):
+		report = &CalendarMultiget{}
+	case Name("sync-collection"):
+		report = &SyncCollection{}
+	default:
+		return nil, root, ErrUnsupportedReport
+	}
+
+	if err := xml.Unmarshal(body, report); err != nil {
+		return nil, root, fmt.Errorf("%w: %v", ErrInvalidBody, err)
+	}
+	return report, root, nil
+}
+
+// ErrUnsupportedReport is returned for reports that aren't supported
+var ErrUnsupportedReport = errors.New("unsupported report")
+
+// Property is a property value in a response. Value is escaped when written;
+// InnerXML is written as is and may use the D, C and CS prefixes.
+type Property struct {
+	Name     xml.Name
+	Value    string
+	InnerXML string
+}
+
+// Response is the result for one resource in a multistatus response: either
+// a status, such as 404 for a resource that was removed, or its properties.
+// Forbidden lists properties that can't be changed.
+type Response struct {
+	Href      string
+	Status    int
+	Found     []Property
+	NotFound  []xml.Name
+	Forbidden []xml.Name
+}
+
+// Multistatus is a 207 Multi-Status response body
+type Multistatus struct {
+	Responses []Response
+	SyncToken string // Only set for sync-collection reports
+}
+
+// Write writes a multistatus response
+func (m *Multistatus) Write(w http.ResponseWriter) {
+	var body strings.Builder
+	body.WriteString(xml.Header)
+	body.WriteString(`<D:multistatus xmlns:D="DAV:" xmlns:C="` + NamespaceCalDAV + `" xmlns:CS="` + NamespaceCalendarServer + `">`)
+	for _, response := range m.Responses {
+		body.WriteString("<D:response>")
+		body.WriteString(Href(response.Href))
+		if response.Status != 0 {
+			body.WriteString(statusLine(response.Status))
+		}
+		if len(response.Found) > 0 {
+			body.WriteString("<D:propstat><D:prop>")
+			for _, property := range response.Found {
+				open, closing := tags(property.Name)
+				body.WriteString(open)
+				xml.EscapeText(&body, []byte(property.Value))
+				body.WriteString(property.InnerXML)
+				body.WriteString(closing)
+			}
+			body.WriteString("</D:prop>" + statusLine(http.StatusOK) + "</D:propstat>")
+		}
+		writePropstat(&body, response.NotFound, http.StatusNotFound)
+		writePropstat(&body, response.Forbidden, http.StatusForbidden)
+		body.WriteString("</D:response>")
+	}
+	if m.SyncToken != "" {
+		body.WriteString("<D:sync-token>")
+		xml.EscapeText(&body, []byte(m.SyncToken))
+		body.WriteString("</D:sync-token>")
+	}
+	body.WriteString("</D:multistatus>")
+
+	w.Header().Set("Content-Type", MediaType)
+	w.WriteHeader(http.StatusMultiStatus)
+	io.WriteString(w, body.String())
+}
+
+// writePropstat writes a propstat element for properties without values
+func writePropstat(body *strings.Builder, names []xml.Name, status int) {
+	if len(names) == 0 {
+		return
+	}
+	body.WriteString("<D:propstat><D:prop>")
+	for _, name := range names {
+		open, closing := tags(name)
+		body.WriteString(open + closing)
+	}
+	body.WriteString("</D:prop>" + statusLine(status) + "</D:propstat>")
+}
+
+// Error writes an error response naming the precondition that failed
+func Error(w http.ResponseWriter, status int, precondition xml.Name) {
+	open, closing := tags(precondition)
+	w.Header().Set("Content-Type", MediaType)
+	w.WriteHeader(status)
+	io.WriteString(w, xml.Header+`<D:error xmlns:D="DAV:" xmlns:C="`+NamespaceCalDAV+`">`+open+closing+`</D:error>`)
+}
+
+// Href returns an href element for a path
+func Href(path string) string {
+	var href strings.Builder
+	href.WriteString("<D:href>")
+	xml.EscapeText(&href, []byte(path))
+	href.WriteString("</D:href>")
+	return href.String()
+}
+
+// statusLine returns a status element
+func statusLine(status int) string {
+	return fmt.Sprintf("<D:status>HTTP/1.1 %d %s</D:status>", status, http.StatusText(status))
+}
+
+// tags returns the opening and closing tags of an element, using the known
+// prefixes or declaring the element's namespace
+func tags(name xml.Name) (string, string) {
+	if prefix, ok := prefixes[name.Space]; ok {
+		return "<" + prefix + ":" + name.Local + ">", "</" + prefix + ":" + name.Local + ">"
+	}
+	if name.Space == "" {
+		return "<" + name.Local + ">", "</" + name.Local + ">"
+	}
+
+	var namespace strings.Builder
+	xml.EscapeText(&namespace, []byte(name.Space))
+	return `<X:` + name.Local + ` xmlns:X="` + namespace.String() + `">`, "</X:" + name.Local + ">"
+}
@@ -0,0 +1,174 @@
+package dav
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadPropfind(t *testing.T) {
+	// An empty body asks for every property
+	propfind, err := ReadPropfind(strings.NewReader(" \n"))
+	if err != nil || propfind.AllProp == nil {
+		t.Errorf("ReadPropfind(empty) = %+v, %v; want allprop", propfind, err)
+	}
+
+	body := `<?xml version="1.0"?>
+<d:propfind xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop><d:getetag/><c:calendar-data/><x:color xmlns:x="http://example.com/"/></d:prop>
+</d:propfind>`
+	propfind, err = ReadPropfind(strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []xml.Name{Name("getetag"), CalDAVName("calendar-data"), {Space: "http://example.com/", Local: "color"}}
+	if got := propfind.Prop.Names(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Names() = %v, want %v", got, want)
+	}
+
+	for _, body := range []string{`<d:propfind xmlns:d="DAV:"/>`, `<d:propfind xmlns:d="DAV:">`, `<propfind/>`} {
+		if _, err := ReadPropfind(strings.NewReader(body)); !errors.Is(err, ErrInvalidBody) {
+			t.Errorf("ReadPropfind(%s): got %v, want ErrInvalidBody", body, err)
+		}
+	}
+}
+
+func TestReadPropertyUpdate(t *testing.T) {
+	body := `<d:propertyupdate xmlns:d="DAV:" xmlns:a="http://apple.com/ns/ical/">
+  <d:set><d:prop><a:calendar-color>#ff0000</a:calendar-color></d:prop></d:set>
+  <d:remove><d:prop><d:displayname/></d:prop></d:remove>
+</d:propertyupdate>`
+	update, err := ReadPropertyUpdate(strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []xml.Name{{Space: "http://apple.com/ns/ical/", Local: "calendar-color"}, Name("displayname")}
+	if got := update.Names(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Names() = %v, want %v", got, want)
+	}
+}
+
+func TestReadReport(t *testing.T) {
+	query := `<c:calendar-query xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop><d:getetag/></d:prop>
+  <c:filter>
+    <c:comp-filter name="VCALENDAR">
+      <c:comp-filter name="VTODO">
+        <c:prop-filter name="COMPLETED"><c:is-not-defined/></c:prop-filter>
+        <c:prop-filter name="SUMMARY"><c:text-match negate-condition="yes">milk</c:text-match></c:prop-filter>
+      </c:comp-filter>
+    </c:comp-filter>
+  </c:filter>
+</c:calendar-query>`
+	report, root, err := ReadReport(strings.NewReader(query))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root != CalDAVName("calendar-query") {
+		t.Errorf("root = %v", root)
+	}
+	calendarQuery, ok := report.(*CalendarQuery)
+	if !ok {
+		t.Fatalf("report is %T, want *CalendarQuery", report)
+	}
+	vtodo := calendarQuery.Filter.CompFilter.CompFilters[0]
+	if vtodo.Name != "VTODO" || len(vtodo.PropFilters) != 2 {
+		t.Fatalf("comp-filter = %+v", vtodo)
+	}
+	if vtodo.PropFilters[0].IsNotDefined == nil {
+		t.Errorf("COMPLETED prop-filter has no is-not-defined")
+	}
+	match := vtodo.PropFilters[1].TextMatch
+	if match.Matches("Buy MILK") || !match.Matches("Buy bread") {
+		t.Errorf("negated text-match %+v matches wrongly", match)
+	}
+
+	multiget := `<c:calendar-multiget xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop><c:calendar-data/></d:prop><d:href>/a.ics</d:href><d:href>/b.ics</d:href>
+</c:calendar-multiget>`
+	report, _, err = ReadReport(strings.NewReader(multiget))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := report.(*CalendarMultiget).Hrefs; !reflect.DeepEqual(got, []string{"/a.ics", "/b.ics"}) {
+		t.Errorf("Hrefs = %v", got)
+	}
+
+	sync := `<d:sync-collection xmlns:d="DAV:"><d:sync-token>tok</d:sync-token><d:sync-level>1</d:sync-level>
+  <d:limit><d:nresults>10</d:nresults></d:limit><d:prop><d:getetag/></d:prop></d:sync-collection>`
+	report, _, err = ReadReport(strings.NewReader(sync))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := report.(*SyncCollection); s.SyncToken != "tok" || s.Limit == nil || s.Limit.NResults != 10 {
+		t.Errorf("sync-collection = %+v", s)
+	}
+
+	_, root, err = ReadReport(strings.NewReader(`<d:expand-property xmlns:d="DAV:"/>`))
+	if !errors.Is(err, ErrUnsupportedReport) || root != Name("expand-property") {
+		t.Errorf("ReadReport(expand-property) = %v, %v; want ErrUnsupportedReport", root, err)
+	}
+	if _, _, err := ReadReport(strings.NewReader("not xml")); !errors.Is(err, ErrInvalidBody) {
+		t.Errorf("ReadReport(not xml): got %v, want ErrInvalidBody", err)
+	}
+}
+
+func TestMultistatusWrite(t *testing.T) {
+	m := &Multistatus{
+		Responses: []Response{
+			{
+				Href:     "/caldav/a b&c.ics",
+				Found:    []Property{{Name: Name("getetag"), Value: `"1.0"`}, {Name: CalDAVName("calendar-data"), Value: "BEGIN:VCALENDAR<>"}},
+				NotFound: []xml.Name{{Space: "http://example.com/", Local: "color"}},
+			},
+			{Href: "/caldav/gone.ics", Status: http.StatusNotFound},
+		},
+		SyncToken: "token&1",
+	}
+	rec := httptest.NewRecorder()
+	m.Write(rec)
+
+	if rec.Code != http.StatusMultiStatus {
+		t.Errorf("status = %d, want 207", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		"<D:href>/caldav/a b&amp;c.ics</D:href>",
+		"<D:getetag>&#34;1.0&#34;</D:getetag>",
+		"<C:calendar-data>BEGIN:VCALENDAR&lt;&gt;</C:calendar-data>",
+		`<X:color xmlns:X="http://example.com/"></X:color></D:prop><D:status>HTTP/1.1 404 Not Found</D:status>`,
+		"<D:href>/caldav/gone.ics</D:href><D:status>HTTP/1.1 404 Not Found</D:status>",
+		"<D:sync-token>token&amp;1</D:sync-token>",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body is missing %s:\n%s", want, body)
+		}
+	}
+
+	// The response is well-formed XML
+	decoder := xml.NewDecoder(strings.NewReader(body))
+	for {
+		if _, err := decoder.Token(); err != nil {
+			if err != io.EOF {
+				t.Errorf("body isn't well-formed: %v", err)
+			}
+			break
+		}
+	}
+}
+
+func TestError(t *testing.T) {
+	rec := httptest.NewRecorder()
+	Error(rec, http.StatusForbidden, CalDAVName("valid-calendar-data"))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want 403", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "<C:valid-calendar-data></C:valid-calendar-data>") {
+		t.Errorf("body = %s", rec.Body.String())
+	}
+}
@@ -0,0 +1,23 @@
+package events
+
+import (
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RevokeAccess tells a user's streams that one of their tokens stopped
+// working, so the streams opened with it end. A failure is logged; the
+// streams still notice when they next check their token.
+func RevokeAccess(broker Broker, userID uuid.UUID) {
+	event := Event{
+		ID:         uuid.New(),
+		Type:       TypeAccessRevoked,
+		CreatedAt:  time.Now(),
+		Recipients: []uuid.UUID{userID},
+	}
+	if err := broker.Publish(event); err != nil {
+		log.Printf("Failed to publish %s event: %v", event.Type, err)
+	}
+}
@@ -0,0 +1,86 @@
+// Package events delivers changes to todos to the users who can see them, so
+// clients can show them as they happen
+package events
+
+import (
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/noman/todo-application/database"
+	"github.com/noman/todo-application/models"
+)
+
+// Event types
+const (
+	TypeTodoCreated = "todo.created"
+	TypeTodoUpdated = "todo.updated"
+	TypeTodoDeleted = "todo.deleted"
+
+	// TypeAccessRevoked tells a user's streams to check their token again,
+	// after a logout or account deletion. It isn't sent to clients.
+	TypeAccessRevoked = "access.revoked"
+)
+
+// Event is a change to a todo
+type Event struct {
+	ID         uuid.UUID            `json:"id"`
+	Type       string               `json:"type"`
+	TodoID     uuid.UUID            `json:"todo_id"`
+	Todo       *models.TodoResponse `json:"todo,omitempty"` // Omitted for deleted todos
+	ActorID    uuid.UUID            `json:"actor_id"`
+	CreatedAt  time.Time            `json:"created_at"`
+	Recipients []uuid.UUID          `json:"-"` // Users the event is delivered to
+}
+
+// Broker delivers published events to the subscriptions of their recipients
+type Broker interface {
+	// Publish sends an event to its recipients
+	Publish(event Event) error
+	// Subscribe starts receiving the events sent to a user
+	Subscribe(userID uuid.UUID) *Subscription
+}
+
+// Subscription receives the events sent to a user until it's closed
+type Subscription struct {
+	// C is closed when the subscription is closed, or dropped for falling too
+	// far behind; subscribers should then reload what they show
+	C <-chan Event
+
+	close func()
+	once  sync.Once
+}
+
+// Close stops the subscription
+func (s *Subscription) Close() {
+	s.once.Do(s.close)
+}
+
+// Default is the broker used by the application
+var Default Broker
+
+// InitBroker initializes the broker selected by the EVENTS_BROKER environment
+// variable: "memory", the default, delivers events within this process, and
+// "postgres" sends them through PostgreSQL LISTEN/NOTIFY so every instance of
+// the server receives them
+func InitBroker() {
+	switch strings.ToLower(os.Getenv("EVENTS_BROKER")) {
+	case "", "memory":
+		Default = NewMemoryBroker()
+		log.Println("Delivering events in process")
+
+	case "postgres":
+		broker, err := NewPostgresBroker(database.DB, database.ConnString())
+		if err != nil {
+			log.Fatalf("Failed to initialize PostgreSQL event broker: %v", err)
+		}
+		Default = broker
+		log.Println("Delivering events through PostgreSQL LISTEN/NOTIFY")
+
+	default:
+		log.Fatalf("Unknown EVENTS_BROKER %q", os.Getenv("EVENTS_BROKER"))
+	}
+}
@@ -0,0 +1,94 @@
+package events
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// subscriptionBuffer is how many events a subscription may fall behind by
+// before it's dropped
+const subscriptionBuffer = 64
+
+// MemoryBroker delivers events to subscriptions in this process
+type MemoryBroker struct {
+	mu   sync.Mutex
+	subs map[uuid.UUID]map[chan Event]struct{}
+}
+
+// NewMemoryBroker creates a new MemoryBroker
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{subs: make(map[uuid.UUID]map[chan Event]struct{})}
+}
+
+// Publish sends an event to the subscriptions of its recipients. It never
+// blocks: a subscription whose buffer is full is dropped instead.
+func (b *MemoryBroker) Publish(event Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	seen := make(map[uuid.UUID]bool, len(event.Recipients))
+	for _, userID := range event.Recipients {
+		if seen[userID] {
+			continue
+		}
+		seen[userID] = true
+
+		for ch := range b.subs[userID] {
+			select {
+			case ch <- event:
+			default:
+				b.remove(userID, ch)
+			}
+		}
+	}
+	return nil
+}
+
+// Subscribe starts receiving the events sent to a user
+func (b *MemoryBroker) Subscribe(userID uuid.UUID) *Subscription {
+	ch := make(chan Event, subscriptionBuffer)
+
+	b.mu.Lock()
+	if b.subs[userID] == nil {
+		b.subs[userID] = make(map[chan Event]struct{})
+	}
+	b.subs[userID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	return &Subscription{C: ch, close: func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.remove(userID, ch)
+	}}
+}
+
+// dropAll closes every subscription, telling subscribers that events may have
+// been missed
+func (b *MemoryBroker) dropAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for userID, chans := range b.subs {
+		for ch := range chans {
+			b.remove(userID, ch)
+		}
+	}
+}
+
+// remove closes a subscription's channel unless it's already closed; the
+// caller must hold the lock
+func (b *MemoryBroker) remove(userID uuid.UUID, ch chan Event) {
+	chans, ok := b.subs[userID]
+	if !ok {
+		return
+	}
+	if _, ok := chans[ch]; !ok {
+		return
+	}
+	delete(chans, ch)
+	if len(chans) == 0 {
+		delete(b.subs, userID)
+	}
+	close(ch)
+}
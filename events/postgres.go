@@ -0,0 +1,112 @@
+package events
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// notifyChannel is the PostgreSQL channel events are sent on
+const notifyChannel = "todo_events"
+
+// maxPayload is the largest NOTIFY payload sent; PostgreSQL's limit is 8000 bytes
+const maxPayload = 7900
+
+// listenerPingInterval is how often an idle listener checks its connection
+const listenerPingInterval = 90 * time.Second
+
+// PostgresBroker sends events through PostgreSQL LISTEN/NOTIFY, so every
+// server instance connected to the database delivers them to its own
+// subscriptions
+type PostgresBroker struct {
+	db       *sql.DB
+	listener *pq.Listener
+	local    *MemoryBroker
+}
+
+// envelope is an event as sent through NOTIFY, which unlike the JSON sent to
+// clients includes its recipients
+type envelope struct {
+	Event
+	Recipients []uuid.UUID `json:"recipients"`
+}
+
+// NewPostgresBroker creates a broker that publishes through db and listens on
+// a dedicated connection opened with connStr
+func NewPostgresBroker(db *sql.DB, connStr string) (*PostgresBroker, error) {
+	b := &PostgresBroker{db: db, local: NewMemoryBroker()}
+	b.listener = pq.NewListener(connStr, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("Event listener: %v", err)
+		}
+	})
+	if err := b.listener.Listen(notifyChannel); err != nil {
+		b.listener.Close()
+		return nil, err
+	}
+
+	go b.listen()
+	return b, nil
+}
+
+// listen delivers the events received from PostgreSQL to local subscriptions
+func (b *PostgresBroker) listen() {
+	for {
+		select {
+		case n, ok := <-b.listener.Notify:
+			if !ok {
+				return
+			}
+			// A nil notification means the connection was re-established and
+			// events sent in the meantime were lost
+			if n == nil {
+				b.local.dropAll()
+				continue
+			}
+
+			var env envelope
+			if err := json.Unmarshal([]byte(n.Extra), &env); err != nil {
+				log.Printf("Failed to decode event: %v", err)
+				continue
+			}
+			env.Event.Recipients = env.Recipients
+			b.local.Publish(env.Event)
+
+		case <-time.After(listenerPingInterval):
+			go b.listener.Ping()
+		}
+	}
+}
+
+// Publish sends an event to every server instance
+func (b *PostgresBroker) Publish(event Event) error {
+	payload, err := json.Marshal(envelope{event, event.Recipients})
+	if err != nil {
+		return err
+	}
+
+	// Send large events without the todo; clients fetch it instead
+	if len(payload) > maxPayload && event.Todo != nil {
+		event.Todo = nil
+		payload, err = json.Marshal(envelope{event, event.Recipients})
+		if err != nil {
+			return err
+		}
+	}
+	if len(payload) > maxPayload {
+		return errors.New("event is too large to send")
+	}
+
+	_, err = b.db.Exec(`SELECT pg_notify($1, $2)`, notifyChannel, string(payload))
+	return err
+}
+
+// Subscribe starts receiving the events sent to a user
+func (b *PostgresBroker) Subscribe(userID uuid.UUID) *Subscription {
+	return b.local.Subscribe(userID)
+}
@@ -0,0 +1,41 @@
+package events
+
+import (
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/noman/todo-application/models"
+)
+
+// Audience finds the users who can see a todo
+type Audience interface {
+	TodoAudience(todo *models.Todo) ([]uuid.UUID, error)
+}
+
+// PublishTodo tells everyone who can see a todo that it changed. A failure is
+// logged rather than returned, since the change itself already succeeded.
+func PublishTodo(broker Broker, audience Audience, eventType string, todo *models.Todo, actorID uuid.UUID) {
+	recipients, err := audience.TodoAudience(todo)
+	if err != nil {
+		log.Printf("Failed to publish %s event: %v", eventType, err)
+		return
+	}
+
+	event := Event{
+		ID:         uuid.New(),
+		Type:       eventType,
+		TodoID:     todo.ID,
+		ActorID:    actorID,
+		CreatedAt:  time.Now(),
+		Recipients: recipients,
+	}
+	if eventType != TypeTodoDeleted {
+		response := todo.ToResponse()
+		event.Todo = &response
+	}
+
+	if err := broker.Publish(event); err != nil {
+		log.Printf("Failed to publish %s event: %v", eventType, err)
+	}
+}
@@ -0,0 +1,51 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/noman/todo-application/models"
+)
+
+// workspace is an Audience of a todo's creator and one other member
+type workspace struct {
+	member uuid.UUID
+}
+
+func (a workspace) TodoAudience(todo *models.Todo) ([]uuid.UUID, error) {
+	return []uuid.UUID{todo.UserID, a.member}, nil
+}
+
+func TestPublishTodo(t *testing.T) {
+	broker := NewMemoryBroker()
+	creator, member, outsider := uuid.New(), uuid.New(), uuid.New()
+	subs := map[uuid.UUID]*Subscription{}
+	for _, id := range []uuid.UUID{creator, member, outsider} {
+		subs[id] = broker.Subscribe(id)
+		defer subs[id].Close()
+	}
+
+	todo := &models.Todo{ID: uuid.New(), Title: "Write tests", UserID: creator}
+	PublishTodo(broker, workspace{member}, TypeTodoUpdated, todo, member)
+	for _, id := range []uuid.UUID{creator, member} {
+		select {
+		case event := <-subs[id].C:
+			if event.Type != TypeTodoUpdated || event.TodoID != todo.ID || event.ActorID != member || event.Todo == nil || event.Todo.Title != "Write tests" {
+				t.Errorf("got %+v", event)
+			}
+		default:
+			t.Errorf("%s didn't get the event", id)
+		}
+	}
+	select {
+	case event := <-subs[outsider].C:
+		t.Errorf("outsider got %+v", event)
+	default:
+	}
+
+	// Deleted todos aren't sent along
+	PublishTodo(broker, workspace{member}, TypeTodoDeleted, todo, creator)
+	if event := <-subs[member].C; event.Todo != nil {
+		t.Errorf("got the deleted todo in %+v", event)
+	}
+}
@@ -0,0 +1,385 @@
+package filterexpr
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ValueKind is the kind of a Value
+type ValueKind int
+
+const (
+	TextValue     ValueKind = iota // Text to compare titles and descriptions with
+	BoolValue                      // Bool
+	DateValue                      // The day in Date
+	TodayValue                     // The current day
+	AgeValue                       // Age before now, or for due dates, the time from now
+	IDValue                        // ID of a workspace, project or user
+	NameValue                      // Text is the name of a workspace or project, or a username
+	MeValue                        // The user whose todos are filtered
+	NoneValue                      // No workspace, project, assignee, tag or due date
+	TagValue                       // Text is a tag
+	PriorityValue                  // Priority, from 0 (none) to 3 (high)
+)
+
+// Value is the value a field is compared with
+type Value struct {
+	Kind     ValueKind
+	Text     string
+	Bool     bool
+	Date     time.Time // Midnight UTC of the day
+	Age      time.Duration
+	ID       uuid.UUID
+	Priority int
+}
+
+// field is a field of todos that filters can compare
+type field struct {
+	name string
+	ops  []string
+
+	// parse reads a value of the field, compared with op, returning a
+	// message saying what's allowed instead if it's invalid
+	parse func(text string, quoted bool, op string) (Value, string)
+
+	// sql returns the condition comparing the field with a value. Every
+	// condition is true or false, never NULL, so that NOT works on it.
+	sql func(c *compiler, op string, value Value) string
+}
+
+// allows reports whether a field can be compared with an operator
+func (f field) allows(op string) bool {
+	for _, allowed := range f.ops {
+		if op == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// Operators of each kind of field. For text, : matches text contained in the
+// field and = the whole field.
+var (
+	equalityOps   = []string{":", "=", "!="}
+	comparisonOps = []string{":", "=", "!=", "<", "<=", ">", ">="}
+)
+
+// fieldNames lists the fields in the order they're documented
+var fieldNames = []string{"completed", "title", "description", "created", "updated", "due", "priority", "tag", "workspace", "project", "assignee", "creator"}
+
+// priorities lists the priorities from lowest to highest, like
+// models.Priorities; a priority is stored as its index
+var priorities = []string{"none", "low", "medium", "high"}
+
+// tagField compares the tags of todos; labels, as other todo apps call
+// them, are the same
+var tagField = field{"tag", equalityOps, parseTag, tagSQL}
+
+// fields are the fields of todos that filters can compare, by name
+var fields = map[string]field{
+	"completed":   {"completed", equalityOps, parseBool, boolSQL("completed")},
+	"title":       {"title", equalityOps, parseText, textSQL("title")},
+	"description": {"description", equalityOps, parseText, textSQL("COALESCE(description, '')")},
+	"created":     {"created", comparisonOps, parseDate, dateSQL("created_at")},
+	"updated":     {"updated", comparisonOps, parseDate, dateSQL("updated_at")},
+	"due":         {"due", comparisonOps, parseDue, dueSQL},
+	"priority":    {"priority", comparisonOps, parsePriority, prioritySQL},
+	"tag":         tagField,
+	"tags":        tagField,
+	"label":       tagField,
+	"labels":      tagField,
+	"workspace":   {"workspace", equalityOps, parseGroup, groupSQL("workspace_id", "workspaces")},
+	"project":     {"project", equalityOps, parseGroup, groupSQL("project_id", "projects")},
+	"assignee":    {"assignee", equalityOps, parseUser(true), assigneeSQL},
+	"creator":     {"creator", equalityOps, parseUser(false), creatorSQL},
+}
+
+// list joins words into a list like "a, b or c"
+func list(words []string, conjunction string) string {
+	if len(words) == 1 {
+		return words[0]
+	}
+	return strings.Join(words[:len(words)-1], ", ") + " " + conjunction + " " + words[len(words)-1]
+}
+
+// parseBool reads true or false
+func parseBool(text string, quoted bool, op string) (Value, string) {
+	if text != "true" && text != "false" {
+		return Value{}, "use true or false"
+	}
+	return Value{Kind: BoolValue, Bool: text == "true"}, ""
+}
+
+// parseText reads any text
+func parseText(text string, quoted bool, op string) (Value, string) {
+	return Value{Kind: TextValue, Text: text}, ""
+}
+
+// agePattern matches ages like 12h, 7d and 2w
+var agePattern = regexp.MustCompile(`^([0-9]{1,5})([hdw])$`)
+
+// parseDate reads a date like 2024-05-01, today, or an age like 7d
+func parseDate(text string, quoted bool, op string) (Value, string) {
+	if text == "today" {
+		return Value{Kind: TodayValue}, ""
+	}
+	if date, err := time.Parse("2006-01-02", text); err == nil {
+		return Value{Kind: DateValue, Date: date}, ""
+	}
+
+	match := agePattern.FindStringSubmatch(text)
+	if match == nil {
+		return Value{}, "use a date like 2024-05-01, today, or an age like 12h, 7d or 2w"
+	}
+	if op == ":" || op == "=" || op == "!=" {
+		return Value{}, "compare ages with <, <=, > or >="
+	}
+
+	n, _ := strconv.Atoi(match[1])
+	unit := map[string]time.Duration{"h": time.Hour, "d": 24 * time.Hour, "w": 7 * 24 * time.Hour}[match[2]]
+	return Value{Kind: AgeValue, Age: time.Duration(n) * unit}, ""
+}
+
+// parseDue reads a due date like a date, or none. Ages are how far ahead of
+// now a todo is due.
+func parseDue(text string, quoted bool, op string) (Value, string) {
+	if text == "none" && !quoted {
+		if op != ":" && op != "=" && op != "!=" {
+			return Value{}, "compare none with :, = or !="
+		}
+		return Value{Kind: NoneValue}, ""
+	}
+	return parseDate(text, quoted, op)
+}
+
+// parsePriority reads a priority
+func parsePriority(text string, quoted bool, op string) (Value, string) {
+	for rank, priority := range priorities {
+		if strings.EqualFold(text, priority) {
+			return Value{Kind: PriorityValue, Priority: rank}, ""
+		}
+	}
+	return Value{}, "use " + list(priorities, "or")
+}
+
+// parseTag reads a tag, or none. A quoted "none" is a tag.
+func parseTag(text string, quoted bool, op string) (Value, string) {
+	if text == "none" && !quoted {
+		return Value{Kind: NoneValue}, ""
+	}
+	return Value{Kind: TagValue, Text: text}, ""
+}
+
+// parseGroup reads a workspace or project: none, an ID or a name. A quoted
+// "none" is a name.
+func parseGroup(text string, quoted bool, op string) (Value, string) {
+	if text == "none" && !quoted {
+		return Value{Kind: NoneValue}, ""
+	}
+	if id, err := uuid.Parse(text); err == nil {
+		return Value{Kind: IDValue, ID: id}, ""
+	}
+	return Value{Kind: NameValue, Text: text}, ""
+}
+
+// parseUser reads a user: me, an ID or a username, and also none if a todo
+// can be without one. A quoted "me" or "none" is a username.
+func parseUser(none bool) func(text string, quoted bool, op string) (Value, string) {
+	return func(text string, quoted bool, op string) (Value, string) {
+		if text == "me" && !quoted {
+			return Value{Kind: MeValue}, ""
+		}
+		if text == "none" && !quoted && none {
+			return Value{Kind: NoneValue}, ""
+		}
+		if id, err := uuid.Parse(text); err == nil {
+			return Value{Kind: IDValue, ID: id}, ""
+		}
+		return Value{Kind: NameValue, Text: text}, ""
+	}
+}
+
+// compiler builds the SQL of a filter, collecting its arguments
+type compiler struct {
+	userID uuid.UUID
+	now    time.Time
+	args   []interface{}
+}
+
+// arg adds an argument and returns its placeholder
+func (c *compiler) arg(value interface{}) string {
+	c.args = append(c.args, value)
+	return fmt.Sprintf("$%d", len(c.args))
+}
+
+// Compile compiles a filter into an SQL condition on the columns of todos,
+// for the user whose todos are filtered at the time now. The values are
+// placeholders numbered after args, and are returned appended to them.
+func Compile(node Node, userID uuid.UUID, now time.Time, args []interface{}) (string, []interface{}) {
+	c := &compiler{userID: userID, now: now, args: args}
+	return c.compile(node), c.args
+}
+
+// compile returns the condition of a node
+func (c *compiler) compile(node Node) string {
+	switch node := node.(type) {
+	case *And:
+		return "(" + c.compile(node.Left) + " AND " + c.compile(node.Right) + ")"
+	case *Or:
+		return "(" + c.compile(node.Left) + " OR " + c.compile(node.Right) + ")"
+	case *Not:
+		return "NOT " + c.compile(node.Operand)
+	case *Text:
+		pattern := c.arg(containsPattern(node.Text))
+		return "(title ILIKE " + pattern + " OR COALESCE(description, '') ILIKE " + pattern + ")"
+	case *Comparison:
+		f := fields[node.Field]
+		if node.Op == "!=" {
+			return "NOT (" + f.sql(c, "=", node.Value) + ")"
+		}
+		return "(" + f.sql(c, node.Op, node.Value) + ")"
+	default:
+		panic(fmt.Sprintf("filterexpr: unknown node %T", node))
+	}
+}
+
+// containsPattern returns a LIKE pattern matching text anywhere, with the
+// wildcards in it escaped
+func containsPattern(text string) string {
+	return "%" + strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(text) + "%"
+}
+
+// boolSQL compares a boolean column
+func boolSQL(column string) func(c *compiler, op string, value Value) string {
+	return func(c *compiler, op string, value Value) string {
+		return column + " = " + c.arg(value.Bool)
+	}
+}
+
+// textSQL compares a text column: : finds the text in it, and = compares
+// the whole of it, ignoring case either way
+func textSQL(column string) func(c *compiler, op string, value Value) string {
+	return func(c *compiler, op string, value Value) string {
+		if op == ":" {
+			return column + " ILIKE " + c.arg(containsPattern(value.Text))
+		}
+		return "LOWER(" + column + ") = LOWER(" + c.arg(value.Text) + ")"
+	}
+}
+
+// dateSQL compares a timestamp column with a day, or with how long ago it was
+func dateSQL(column string) func(c *compiler, op string, value Value) string {
+	return func(c *compiler, op string, value Value) string {
+		if value.Kind == AgeValue {
+			// A younger todo has a later timestamp
+			at := c.arg(c.now.Add(-value.Age))
+			switch op {
+			case "<":
+				return column + " > " + at
+			case "<=":
+				return column + " >= " + at
+			case ">":
+				return column + " < " + at
+			default:
+				return column + " <= " + at
+			}
+		}
+
+		// Days are in the server's time zone, like the timestamps
+		year, month, day := value.Date.Date()
+		if value.Kind == TodayValue {
+			year, month, day = c.now.Date()
+		}
+		start := time.Date(year, month, day, 0, 0, 0, 0, c.now.Location())
+		end := start.AddDate(0, 0, 1)
+
+		switch op {
+		case "<":
+			return column + " < " + c.arg(start)
+		case "<=":
+			return column + " < " + c.arg(end)
+		case ">":
+			return column + " >= " + c.arg(end)
+		case ">=":
+			return column + " >= " + c.arg(start)
+		default:
+			return column + " >= " + c.arg(start) + " AND " + column + " < " + c.arg(end)
+		}
+	}
+}
+
+// dueSQL compares the due dates of todos. Todos without one are never
+// before or after anything.
+func dueSQL(c *compiler, op string, value Value) string {
+	switch value.Kind {
+	case NoneValue:
+		return "due_at IS NULL"
+	case AgeValue:
+		// due<7d is due less than 7 days from now, including overdue todos
+		return "due_at IS NOT NULL AND due_at " + op + " " + c.arg(c.now.Add(value.Age))
+	default:
+		return "due_at IS NOT NULL AND " + dateSQL("due_at")(c, op, value)
+	}
+}
+
+// prioritySQL compares the priorities of todos
+func prioritySQL(c *compiler, op string, value Value) string {
+	if op == ":" {
+		op = "="
+	}
+	return "priority " + op + " " + c.arg(value.Priority)
+}
+
+// tagSQL compares the tags of todos: : and = both match a whole tag,
+// ignoring case
+func tagSQL(c *compiler, op string, value Value) string {
+	if value.Kind == NoneValue {
+		return "cardinality(tags) = 0"
+	}
+	return "EXISTS (SELECT 1 FROM unnest(tags) AS t(tag) WHERE LOWER(t.tag) = LOWER(" + c.arg(value.Text) + "))"
+}
+
+// groupSQL compares the workspace or project column of todos
+func groupSQL(column, table string) func(c *compiler, op string, value Value) string {
+	return func(c *compiler, op string, value Value) string {
+		switch value.Kind {
+		case NoneValue:
+			return column + " IS NULL"
+		case IDValue:
+			return column + " IS NOT NULL AND " + column + " = " + c.arg(value.ID)
+		default:
+			return column + " IS NOT NULL AND " + column + " IN (SELECT id FROM " + table + " WHERE LOWER(name) = LOWER(" + c.arg(value.Text) + "))"
+		}
+	}
+}
+
+// assigneeSQL compares the users todos are assigned to
+func assigneeSQL(c *compiler, op string, value Value) string {
+	switch value.Kind {
+	case NoneValue:
+		return "NOT EXISTS (SELECT 1 FROM todo_assignees WHERE todo_id = todos.id)"
+	case MeValue:
+		return "id IN (SELECT todo_id FROM todo_assignees WHERE user_id = " + c.arg(c.userID) + ")"
+	case IDValue:
+		return "id IN (SELECT todo_id FROM todo_assignees WHERE user_id = " + c.arg(value.ID) + ")"
+	default:
+		return "id IN (SELECT a.todo_id FROM todo_assignees a JOIN users u ON u.id = a.user_id WHERE LOWER(u.username) = LOWER(" + c.arg(value.Text) + "))"
+	}
+}
+
+// creatorSQL compares the users who created todos
+func creatorSQL(c *compiler, op string, value Value) string {
+	switch value.Kind {
+	case MeValue:
+		return "user_id = " + c.arg(c.userID)
+	case IDValue:
+		return "user_id = " + c.arg(value.ID)
+	default:
+		return "user_id IN (SELECT id FROM users WHERE LOWER(username) = LOWER(" + c.arg(value.Text) + "))"
+	}
+}
@@ -0,0 +1,352 @@
+// Package filterexpr parses the filter expressions that power users narrow
+// todo lists with, like `completed:false AND (assignee:me OR creator:me)
+// AND created<7d AND "invoice"`, and compiles them into parameterized SQL
+package filterexpr
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Limits on filters, so they compile to reasonable SQL
+const (
+	MaxLength = 1000 // Characters
+	maxDepth  = 32   // Nested parentheses and NOTs
+)
+
+// Error is an error in a filter, at a position counted in characters from 1
+type Error struct {
+	Pos int
+	Msg string
+}
+
+func (e *Error) Error() string {
+	if e.Pos == 0 {
+		return e.Msg
+	}
+	return fmt.Sprintf("%s at position %d", e.Msg, e.Pos)
+}
+
+// Node is a node of a parsed filter
+type Node interface {
+	node()
+}
+
+// And matches todos that match both of its operands
+type And struct {
+	Left, Right Node
+}
+
+// Or matches todos that match either of its operands
+type Or struct {
+	Left, Right Node
+}
+
+// Not matches todos that don't match its operand
+type Not struct {
+	Operand Node
+}
+
+// Text matches todos whose title or description contains the text, ignoring case
+type Text struct {
+	Text string
+}
+
+// Comparison matches todos whose field compares to a value
+type Comparison struct {
+	Field string
+	Op    string // One of : = != < <= > >=
+	Value Value
+}
+
+func (*And) node()        {}
+func (*Or) node()         {}
+func (*Not) node()        {}
+func (*Text) node()       {}
+func (*Comparison) node() {}
+
+// Token kinds
+type tokenKind int
+
+const (
+	tokenEnd tokenKind = iota
+	tokenWord
+	tokenString
+	tokenOperator
+	tokenNot // A leading -
+	tokenOpen
+	tokenClose
+)
+
+// token is a token of a filter, with its position counted in characters from 1
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// isWordRune reports whether r can be part of a word
+func isWordRune(r rune) bool {
+	return !unicode.IsSpace(r) && !strings.ContainsRune(`()":<>=!`, r)
+}
+
+// lex splits a filter into tokens, ending with a tokenEnd
+func lex(s string) ([]token, error) {
+	runes := []rune(s)
+	tokens := []token{}
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		pos := i + 1
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case r == '(' || r == ')':
+			kind := tokenOpen
+			if r == ')' {
+				kind = tokenClose
+			}
+			tokens = append(tokens, token{kind, string(r), pos})
+			i++
+
+		case r == '"':
+			// Backslashes escape quotes and backslashes in quoted strings
+			var b strings.Builder
+			i++
+			for {
+				if i == len(runes) {
+					return nil, &Error{pos, "Quoted text is missing its closing quote"}
+				}
+				if runes[i] == '"' {
+					i++
+					break
+				}
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				b.WriteRune(runes[i])
+				i++
+			}
+			tokens = append(tokens, token{tokenString, b.String(), pos})
+
+		case r == ':' || r == '=':
+			tokens = append(tokens, token{tokenOperator, string(r), pos})
+			i++
+
+		case r == '<' || r == '>' || r == '!':
+			op := string(r)
+			if i+1 < len(runes) && runes[i+1] == '=' {
+				op += "="
+			}
+			if op == "!" {
+				return nil, &Error{pos, `Unexpected "!"; use NOT or - to exclude todos, or != to compare`}
+			}
+			tokens = append(tokens, token{tokenOperator, op, pos})
+			i += utf8.RuneCountInString(op)
+
+		case r == '-' && i+1 < len(runes) && (isWordRune(runes[i+1]) || runes[i+1] == '"' || runes[i+1] == '('):
+			tokens = append(tokens, token{tokenNot, "-", pos})
+			i++
+
+		default:
+			start := i
+			for i < len(runes) && isWordRune(runes[i]) {
+				i++
+			}
+			tokens = append(tokens, token{tokenWord, string(runes[start:i]), pos})
+		}
+	}
+	return append(tokens, token{kind: tokenEnd, pos: len(runes) + 1}), nil
+}
+
+// Parse parses a filter. Terms are field comparisons like completed:false or
+// created<7d, and words or "quoted text" to find in titles and descriptions.
+// NOT (or a leading -) binds tightest, then AND, then OR; terms next to each
+// other are joined with AND, and parentheses group them.
+func Parse(s string) (Node, error) {
+	if utf8.RuneCountInString(s) > MaxLength {
+		return nil, &Error{Msg: fmt.Sprintf("Filter is longer than %d characters", MaxLength)}
+	}
+
+	tokens, err := lex(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 1 {
+		return nil, &Error{Msg: "Filter is empty"}
+	}
+
+	p := &parser{tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	// Everything must have been parsed; only a stray ) can be left over
+	if tok := p.peek(); tok.kind != tokenEnd {
+		return nil, &Error{tok.pos, "Unexpected )"}
+	}
+	return node, nil
+}
+
+// parser is a recursive descent parser over a filter's tokens
+type parser struct {
+	tokens []token
+	next   int
+	depth  int
+}
+
+// peek returns the next token without consuming it
+func (p *parser) peek() token {
+	return p.tokens[p.next]
+}
+
+// take consumes the next token and returns it
+func (p *parser) take() token {
+	tok := p.tokens[p.next]
+	if tok.kind != tokenEnd {
+		p.next++
+	}
+	return tok
+}
+
+// isKeyword reports whether a token is one of the keywords, which are
+// written in capitals so that lowercase words are searched for
+func isKeyword(tok token, keyword string) bool {
+	return tok.kind == tokenWord && tok.text == keyword
+}
+
+// nest enters a parenthesis or NOT, failing if they're nested too deeply
+func (p *parser) nest(tok token) error {
+	p.depth++
+	if p.depth > maxDepth {
+		return &Error{tok.pos, "Filter is nested too deeply"}
+	}
+	return nil
+}
+
+// parseOr parses terms joined by OR
+func (p *parser) parseOr() (Node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for isKeyword(p.peek(), "OR") {
+		p.take()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &Or{left, right}
+	}
+	return left, nil
+}
+
+// parseAnd parses terms joined by AND, or written next to each other
+func (p *parser) parseAnd() (Node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok := p.peek()
+		if isKeyword(tok, "AND") {
+			p.take()
+		} else if tok.kind == tokenEnd || tok.kind == tokenClose || isKeyword(tok, "OR") {
+			return left, nil
+		}
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &And{left, right}
+	}
+}
+
+// parseNot parses a term, negated by any number of NOTs
+func (p *parser) parseNot() (Node, error) {
+	tok := p.peek()
+	if !isKeyword(tok, "NOT") && tok.kind != tokenNot {
+		return p.parseTerm()
+	}
+
+	p.take()
+	if err := p.nest(tok); err != nil {
+		return nil, err
+	}
+	operand, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	p.depth--
+	return &Not{operand}, nil
+}
+
+// parseTerm parses a comparison, text, or an expression in parentheses
+func (p *parser) parseTerm() (Node, error) {
+	tok := p.take()
+	switch tok.kind {
+	case tokenOpen:
+		if err := p.nest(tok); err != nil {
+			return nil, err
+		}
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek().kind != tokenClose {
+			return nil, &Error{tok.pos, "Missing the ) that closes the ("}
+		}
+		p.take()
+		p.depth--
+		return node, nil
+
+	case tokenString:
+		return &Text{tok.text}, nil
+
+	case tokenWord:
+		if isKeyword(tok, "AND") || isKeyword(tok, "OR") {
+			return nil, &Error{tok.pos, "Expected a term before " + tok.text}
+		}
+		if p.peek().kind == tokenOperator {
+			return p.parseComparison(tok)
+		}
+		return &Text{tok.text}, nil
+
+	case tokenOperator:
+		return nil, &Error{tok.pos, fmt.Sprintf("Expected a field name before %s", tok.text)}
+
+	case tokenClose:
+		return nil, &Error{tok.pos, "Expected a term before )"}
+
+	default:
+		return nil, &Error{Msg: "Expected a term at the end of the filter"}
+	}
+}
+
+// parseComparison parses the operator and value of a comparison with a field
+func (p *parser) parseComparison(name token) (Node, error) {
+	f, ok := fields[strings.ToLower(name.text)]
+	if !ok {
+		return nil, &Error{name.pos, fmt.Sprintf("Unknown field %q; the fields are %s", name.text, list(fieldNames, "and"))}
+	}
+
+	op := p.take()
+	if !f.allows(op.text) {
+		return nil, &Error{op.pos, fmt.Sprintf("%s can't be compared with %s; use %s", f.name, op.text, list(f.ops, "or"))}
+	}
+
+	tok := p.take()
+	if tok.kind != tokenWord && tok.kind != tokenString {
+		return nil, &Error{tok.pos, fmt.Sprintf("Expected a value for %s after %s", f.name, op.text)}
+	}
+
+	value, msg := f.parse(tok.text, tok.kind == tokenString, op.text)
+	if msg != "" {
+		return nil, &Error{tok.pos, fmt.Sprintf("Invalid value %q for %s; %s", tok.text, f.name, msg)}
+	}
+
+	return &Comparison{Field: f.name, Op: op.text, Value: value}, nil
+}
@@ -0,0 +1,356 @@
+package filterexpr
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// format writes a parsed filter with every group in parentheses, so tests
+// can see how it was grouped
+func format(node Node) string {
+	switch node := node.(type) {
+	case *And:
+		return "(" + format(node.Left) + " AND " + format(node.Right) + ")"
+	case *Or:
+		return "(" + format(node.Left) + " OR " + format(node.Right) + ")"
+	case *Not:
+		return "NOT " + format(node.Operand)
+	case *Text:
+		return fmt.Sprintf("%q", node.Text)
+	case *Comparison:
+		return node.Field + node.Op + formatValue(node.Value)
+	default:
+		panic(fmt.Sprintf("unknown node %T", node))
+	}
+}
+
+// formatValue writes a value with its kind
+func formatValue(value Value) string {
+	switch value.Kind {
+	case TextValue:
+		return fmt.Sprintf("text(%q)", value.Text)
+	case BoolValue:
+		return fmt.Sprintf("bool(%v)", value.Bool)
+	case DateValue:
+		return "date(" + value.Date.Format("2006-01-02") + ")"
+	case TodayValue:
+		return "today"
+	case AgeValue:
+		return "age(" + value.Age.String() + ")"
+	case IDValue:
+		return "id(" + value.ID.String() + ")"
+	case NameValue:
+		return fmt.Sprintf("name(%q)", value.Text)
+	case MeValue:
+		return "me"
+	case NoneValue:
+		return "none"
+	case TagValue:
+		return fmt.Sprintf("tag(%q)", value.Text)
+	case PriorityValue:
+		return fmt.Sprintf("priority(%d)", value.Priority)
+	default:
+		return "?"
+	}
+}
+
+func TestParse(t *testing.T) {
+	id := "0d4f7d2c-8c4e-4f0a-9a57-6a0f1f3c2b11"
+
+	tests := []struct {
+		filter string
+		want   string
+	}{
+		// Terms
+		{`invoice`, `"invoice"`},
+		{`"quarterly invoice"`, `"quarterly invoice"`},
+		{`completed:false`, `completed:bool(false)`},
+		{`COMPLETED=true`, `completed=bool(true)`},
+		{`title:report`, `title:text("report")`},
+		{`title="Weekly report"`, `title=text("Weekly report")`},
+		{`description!=""`, `description!=text("")`},
+		{`created<7d`, `created<age(168h0m0s)`},
+		{`updated>=12h`, `updated>=age(12h0m0s)`},
+		{`created>2w`, `created>age(336h0m0s)`},
+		{`created:2024-05-01`, `created:date(2024-05-01)`},
+		{`updated<=today`, `updated<=today`},
+		{`workspace:none`, `workspace:none`},
+		{`workspace:"none"`, `workspace:name("none")`},
+		{`project:` + id, `project:id(` + id + `)`},
+		{`project:"Q3 launch"`, `project:name("Q3 launch")`},
+		{`assignee:me`, `assignee:me`},
+		{`assignee:none`, `assignee:none`},
+		{`assignee:"me"`, `assignee:name("me")`},
+		{`creator:alice`, `creator:name("alice")`},
+		{`creator:none`, `creator:name("none")`},
+		{`due<7d`, `due<age(168h0m0s)`},
+		{`due:today`, `due:today`},
+		{`due:none`, `due:none`},
+		{`due!=none`, `due!=none`},
+		{`priority:high`, `priority:priority(3)`},
+		{`priority>=Medium`, `priority>=priority(2)`},
+		{`tag:work`, `tag:tag("work")`},
+		{`tag:none`, `tag:none`},
+		{`tag:"none"`, `tag:tag("none")`},
+		{`labels=Urgent`, `tag=tag("Urgent")`},
+
+		// NOT binds tightest, then AND, then OR
+		{`a OR b AND c`, `("a" OR ("b" AND "c"))`},
+		{`a AND b OR c`, `(("a" AND "b") OR "c")`},
+		{`NOT a AND b`, `(NOT "a" AND "b")`},
+		{`NOT a OR b`, `(NOT "a" OR "b")`},
+		{`-a b`, `(NOT "a" AND "b")`},
+		{`NOT NOT a`, `NOT NOT "a"`},
+		{`--a`, `NOT NOT "a"`},
+		{`a OR b OR c`, `(("a" OR "b") OR "c")`},
+		{`a AND b AND c`, `(("a" AND "b") AND "c")`},
+
+		// Terms next to each other are joined with AND
+		{`a b`, `("a" AND "b")`},
+		{`a b OR c`, `(("a" AND "b") OR "c")`},
+		{`a OR b c`, `("a" OR ("b" AND "c"))`},
+		{`completed:false "invoice" created<7d`, `((completed:bool(false) AND "invoice") AND created<age(168h0m0s))`},
+
+		// Parentheses group
+		{`(a OR b) c`, `(("a" OR "b") AND "c")`},
+		{`a (b OR c)`, `("a" AND ("b" OR "c"))`},
+		{`NOT (a OR b)`, `NOT ("a" OR "b")`},
+		{`-(a OR b)`, `NOT ("a" OR "b")`},
+		{`((a))`, `"a"`},
+		{`(a OR (b AND (c OR -d)))`, `("a" OR ("b" AND ("c" OR NOT "d")))`},
+		{`completed:false AND (assignee:me OR creator:me) AND created<7d AND "invoice"`,
+			`(((completed:bool(false) AND (assignee:me OR creator:me)) AND created<age(168h0m0s)) AND "invoice")`},
+		{`completed:false AND (tag:work OR priority:high) AND due<7d AND "invoice"`,
+			`(((completed:bool(false) AND (tag:tag("work") OR priority:priority(3))) AND due<age(168h0m0s)) AND "invoice")`},
+		{strings.Repeat("(", 32) + "a" + strings.Repeat(")", 32), `"a"`},
+		{strings.Repeat("NOT ", 32) + "a", strings.Repeat("NOT ", 32) + `"a"`},
+
+		// Keywords are capitals; lowercase ones are words to search for
+		{`a and b`, `(("a" AND "and") AND "b")`},
+		{`not a`, `("not" AND "a")`},
+
+		// Escaping in quoted text
+		{`"say \"hi\""`, `"say \"hi\""`},
+		{`"back\\slash"`, `"back\\slash"`},
+		{`"trailing\\"`, `"trailing\\"`},
+		{`"\a"`, `"a"`},
+		{`"AND"`, `"AND"`},
+		{`"(a OR b)"`, `"(a OR b)"`},
+		{`title:"a \"b\" c"`, `title:text("a \"b\" c")`},
+		{`"100%_done"`, `"100%_done"`},
+
+		// A - inside or after a word isn't NOT
+		{`well-known`, `"well-known"`},
+		{`a -`, `("a" AND "-")`},
+		{`"été" ünïcode`, `("été" AND "ünïcode")`},
+	}
+	for _, tt := range tests {
+		node, err := Parse(tt.filter)
+		if err != nil {
+			t.Errorf("Parse(%s): %v", tt.filter, err)
+			continue
+		}
+		if got := format(node); got != tt.want {
+			t.Errorf("Parse(%s)\n got %s\nwant %s", tt.filter, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		filter string
+		want   string
+	}{
+		{``, `Filter is empty`},
+		{`   `, `Filter is empty`},
+		{strings.Repeat("a", MaxLength+1), `Filter is longer than 1000 characters`},
+		{`"unclosed`, `Quoted text is missing its closing quote at position 1`},
+		{`a "b\"`, `Quoted text is missing its closing quote at position 3`},
+		{`a !b`, `Unexpected "!"; use NOT or - to exclude todos, or != to compare at position 3`},
+		{`(a`, `Missing the ) that closes the ( at position 1`},
+		{`a (b (c)`, `Missing the ) that closes the ( at position 3`},
+		{`a)`, `Unexpected ) at position 2`},
+		{`)`, `Expected a term before ) at position 1`},
+		{`()`, `Expected a term before ) at position 2`},
+		{`AND a`, `Expected a term before AND at position 1`},
+		{`a OR`, `Expected a term at the end of the filter`},
+		{`a OR OR b`, `Expected a term before OR at position 6`},
+		{`a AND AND b`, `Expected a term before AND at position 7`},
+		{`NOT`, `Expected a term at the end of the filter`},
+		{`:a`, `Expected a field name before : at position 1`},
+		{`a <= b`, `Unknown field "a"; the fields are completed, title, description, created, updated, due, priority, tag, workspace, project, assignee and creator at position 1`},
+		{`completed:false AND colour:red`, `Unknown field "colour"; the fields are completed, title, description, created, updated, due, priority, tag, workspace, project, assignee and creator at position 21`},
+		{`completed:maybe`, `Invalid value "maybe" for completed; use true or false at position 11`},
+		{`completed<true`, `completed can't be compared with <; use :, = or != at position 10`},
+		{`title:`, `Expected a value for title after : at position 7`},
+		{`title:(a)`, `Expected a value for title after : at position 7`},
+		{`created:7d`, `Invalid value "7d" for created; compare ages with <, <=, > or >= at position 9`},
+		{`created<7y`, `Invalid value "7y" for created; use a date like 2024-05-01, today, or an age like 12h, 7d or 2w at position 9`},
+		{`created<2024-13-01`, `Invalid value "2024-13-01" for created; use a date like 2024-05-01, today, or an age like 12h, 7d or 2w at position 9`},
+		{`"été" completed:nope`, `Invalid value "nope" for completed; use true or false at position 17`},
+		{strings.Repeat("(", 33) + "a" + strings.Repeat(")", 33), `Filter is nested too deeply at position 33`},
+		{strings.Repeat("NOT ", 33) + "a", `Filter is nested too deeply at position 129`},
+		{strings.Repeat("-", 33) + "a", `Filter is nested too deeply at position 33`},
+		{`due:7d`, `Invalid value "7d" for due; compare ages with <, <=, > or >= at position 5`},
+		{`due<none`, `Invalid value "none" for due; compare none with :, = or != at position 5`},
+		{`priority:urgent`, `Invalid value "urgent" for priority; use none, low, medium or high at position 10`},
+		{`tag<work`, `tag can't be compared with <; use :, = or != at position 4`},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.filter)
+		if err == nil {
+			t.Errorf("Parse(%.40s): got no error, want %s", tt.filter, tt.want)
+			continue
+		}
+		if _, ok := err.(*Error); !ok {
+			t.Errorf("Parse(%.40s): got %T, want *Error", tt.filter, err)
+		}
+		if err.Error() != tt.want {
+			t.Errorf("Parse(%.40s)\n got %s\nwant %s", tt.filter, err, tt.want)
+		}
+	}
+}
+
+func TestCompile(t *testing.T) {
+	userID := uuid.MustParse("11111111-1111-1111-1111-111111111111")
+	id := uuid.MustParse("0d4f7d2c-8c4e-4f0a-9a57-6a0f1f3c2b11")
+	now := time.Date(2024, 5, 10, 15, 30, 0, 0, time.UTC)
+	day := func(d int) time.Time { return time.Date(2024, 5, d, 0, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		filter string
+		sql    string
+		args   []interface{}
+	}{
+		{`invoice`,
+			`(title ILIKE $2 OR COALESCE(description, '') ILIKE $2)`,
+			[]interface{}{"%invoice%"}},
+		{`completed:false`,
+			`(completed = $2)`,
+			[]interface{}{false}},
+		{`completed!=true`,
+			`NOT (completed = $2)`,
+			[]interface{}{true}},
+		{`title:report`,
+			`(title ILIKE $2)`,
+			[]interface{}{"%report%"}},
+		{`description="Weekly Report"`,
+			`(LOWER(COALESCE(description, '')) = LOWER($2))`,
+			[]interface{}{"Weekly Report"}},
+		{`created<7d`,
+			`(created_at > $2)`,
+			[]interface{}{now.Add(-7 * 24 * time.Hour)}},
+		{`updated>=12h`,
+			`(updated_at <= $2)`,
+			[]interface{}{now.Add(-12 * time.Hour)}},
+		{`created:2024-05-01`,
+			`(created_at >= $2 AND created_at < $3)`,
+			[]interface{}{day(1), day(2)}},
+		{`created<2024-05-01`,
+			`(created_at < $2)`,
+			[]interface{}{day(1)}},
+		{`created<=2024-05-01`,
+			`(created_at < $2)`,
+			[]interface{}{day(2)}},
+		{`created>2024-05-01`,
+			`(created_at >= $2)`,
+			[]interface{}{day(2)}},
+		{`updated:today`,
+			`(updated_at >= $2 AND updated_at < $3)`,
+			[]interface{}{day(10), day(11)}},
+		{`workspace:none`,
+			`(workspace_id IS NULL)`,
+			nil},
+		{`project:` + id.String(),
+			`(project_id IS NOT NULL AND project_id = $2)`,
+			[]interface{}{id}},
+		{`project!="Q3 launch"`,
+			`NOT (project_id IS NOT NULL AND project_id IN (SELECT id FROM projects WHERE LOWER(name) = LOWER($2)))`,
+			[]interface{}{"Q3 launch"}},
+		{`assignee:me`,
+			`(id IN (SELECT todo_id FROM todo_assignees WHERE user_id = $2))`,
+			[]interface{}{userID}},
+		{`assignee:none`,
+			`(NOT EXISTS (SELECT 1 FROM todo_assignees WHERE todo_id = todos.id))`,
+			nil},
+		{`creator:alice`,
+			`(user_id IN (SELECT id FROM users WHERE LOWER(username) = LOWER($2)))`,
+			[]interface{}{"alice"}},
+		{`due<7d`,
+			`(due_at IS NOT NULL AND due_at < $2)`,
+			[]interface{}{now.Add(7 * 24 * time.Hour)}},
+		{`due:today`,
+			`(due_at IS NOT NULL AND due_at >= $2 AND due_at < $3)`,
+			[]interface{}{day(10), day(11)}},
+		{`due!=none`,
+			`NOT (due_at IS NULL)`,
+			nil},
+		{`-due<2024-05-01`,
+			`NOT (due_at IS NOT NULL AND due_at < $2)`,
+			[]interface{}{day(1)}},
+		{`priority:high`,
+			`(priority = $2)`,
+			[]interface{}{3}},
+		{`priority>=medium`,
+			`(priority >= $2)`,
+			[]interface{}{2}},
+		{`tag:Work`,
+			`(EXISTS (SELECT 1 FROM unnest(tags) AS t(tag) WHERE LOWER(t.tag) = LOWER($2)))`,
+			[]interface{}{"Work"}},
+		{`tag!=none`,
+			`NOT (cardinality(tags) = 0)`,
+			nil},
+		{`completed:false AND (tag:work OR priority:high) AND due<7d AND "invoice"`,
+			`((((completed = $2) AND ((EXISTS (SELECT 1 FROM unnest(tags) AS t(tag) WHERE LOWER(t.tag) = LOWER($3))) OR (priority = $4))) AND (due_at IS NOT NULL AND due_at < $5)) AND (title ILIKE $6 OR COALESCE(description, '') ILIKE $6))`,
+			[]interface{}{false, "work", 3, now.Add(7 * 24 * time.Hour), "%invoice%"}},
+
+		// Precedence shows in the grouping of the SQL
+		{`a OR b c`,
+			`((title ILIKE $2 OR COALESCE(description, '') ILIKE $2) OR ((title ILIKE $3 OR COALESCE(description, '') ILIKE $3) AND (title ILIKE $4 OR COALESCE(description, '') ILIKE $4)))`,
+			[]interface{}{"%a%", "%b%", "%c%"}},
+		{`-(completed:true OR assignee:me) creator:me`,
+			`(NOT ((completed = $2) OR (id IN (SELECT todo_id FROM todo_assignees WHERE user_id = $3))) AND (user_id = $4))`,
+			[]interface{}{true, userID, userID}},
+
+		// LIKE wildcards in text are matched literally
+		{`"100%"`,
+			`(title ILIKE $2 OR COALESCE(description, '') ILIKE $2)`,
+			[]interface{}{`%100\%%`}},
+		{`title:snake_case`,
+			`(title ILIKE $2)`,
+			[]interface{}{`%snake\_case%`}},
+		{`"C:\\temp"`,
+			`(title ILIKE $2 OR COALESCE(description, '') ILIKE $2)`,
+			[]interface{}{`%C:\\temp%`}},
+		{`title="100%_\\"`,
+			`(LOWER(title) = LOWER($2))`,
+			[]interface{}{`100%_\`}},
+
+		// Values never end up in the SQL
+		{`"'; DROP TABLE todos; --"`,
+			`(title ILIKE $2 OR COALESCE(description, '') ILIKE $2)`,
+			[]interface{}{`%'; DROP TABLE todos; --%`}},
+	}
+	for _, tt := range tests {
+		node, err := Parse(tt.filter)
+		if err != nil {
+			t.Errorf("Parse(%s): %v", tt.filter, err)
+			continue
+		}
+
+		// Placeholders are numbered after the arguments already there
+		sql, args := Compile(node, userID, now, []interface{}{"existing"})
+		if sql != tt.sql {
+			t.Errorf("Compile(%s)\n got %s\nwant %s", tt.filter, sql, tt.sql)
+		}
+		want := append([]interface{}{"existing"}, tt.args...)
+		if !reflect.DeepEqual(args, want) {
+			t.Errorf("Compile(%s): got args %v, want %v", tt.filter, args, want)
+		}
+	}
+}
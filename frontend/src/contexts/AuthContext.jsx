@@ -5,6 +5,16 @@ const AuthContext = createContext();
 
 export const useAuth = () => useContext(AuthContext);
 
+// Turn an API problem response into a message, preferring the first field error
+const problemMessage = (err, fallback) => {
+  const problem = err.response?.data;
+  const fieldError = problem?.errors?.[0];
+  if (fieldError) {
+    return `${fieldError.field} ${fieldError.message}`;
+  }
+  return problem?.detail || fallback;
+};
+
 export const AuthProvider = ({ children }) => {
   const [currentUser, setCurrentUser] = useState(null);
   const [loading, setLoading] = useState(true);
@@ -37,7 +47,7 @@ export const AuthProvider = ({ children }) => {
       setCurrentUser({ token });
       return true;
     } catch (err) {
-      setError(err.response?.data || 'Registration failed');
+      setError(problemMessage(err, 'Registration failed'));
       return false;
     } finally {
       setLoading(false);
@@ -59,7 +69,7 @@ export const AuthProvider = ({ children }) => {
       setCurrentUser({ token });
       return true;
     } catch (err) {
-      setError(err.response?.data || 'Invalid credentials');
+      setError(problemMessage(err, 'Invalid credentials'));
       return false;
     } finally {
       setLoading(false);
@@ -19,6 +19,9 @@ import {
 import { Delete as DeleteIcon, Edit as EditIcon, Save as SaveIcon, Cancel as CancelIcon } from '@mui/icons-material';
 import axios from 'axios';
 
+// Partial updates are sent as JSON Merge Patch documents
+const mergePatchConfig = { headers: { 'Content-Type': 'application/merge-patch+json' } };
+
 const TodoList = () => {
   const [todos, setTodos] = useState([]);
   const [loading, setLoading] = useState(true);
@@ -27,11 +30,86 @@ const TodoList = () => {
   const [editingTodo, setEditingTodo] = useState(null);
   const [editFormData, setEditFormData] = useState({ title: '', description: '', completed: false });
 
-  // Fetch todos on component mount
+  // Load todos and follow changes made in other tabs and devices. Events
+  // missed while disconnected aren't replayed, so the list is reloaded every
+  // time the stream (re)connects.
   useEffect(() => {
-    fetchTodos();
+    if (!window.EventSource) {
+      fetchTodos();
+      return undefined;
+    }
+
+    let source = null;
+    let retryTimer = null;
+    let stopped = false;
+
+    const handleChange = (e) => {
+      const { todo } = JSON.parse(e.data);
+      // This page only shows personal todos
+      if (todo && !todo.workspace_id) {
+        applyTodo(todo);
+      }
+    };
+
+    // Stream tickets only work once, so rather than letting EventSource
+    // reconnect with a used one, every connection gets a new ticket
+    const connect = async () => {
+      let ticket;
+      try {
+        const response = await axios.post('/api/events/tickets');
+        ticket = response.data.ticket;
+      } catch (err) {
+        if (err.response && err.response.status === 401) {
+          setError('Your session has ended. Log in again to see new changes.');
+          return;
+        }
+        // Until it reconnects the list may go stale, but it's still usable
+        retryTimer = setTimeout(connect, 3000);
+        return;
+      }
+      if (stopped) {
+        return;
+      }
+
+      source = new EventSource(`/api/events?ticket=${encodeURIComponent(ticket)}`);
+      source.onopen = () => fetchTodos();
+      source.onerror = () => {
+        source.close();
+        retryTimer = setTimeout(connect, 3000);
+      };
+      source.addEventListener('todo.created', handleChange);
+      source.addEventListener('todo.updated', handleChange);
+      source.addEventListener('todo.deleted', (e) => removeTodo(JSON.parse(e.data).todo_id));
+    };
+    connect();
+
+    return () => {
+      stopped = true;
+      clearTimeout(retryTimer);
+      if (source) {
+        source.close();
+      }
+    };
   }, []);
 
+  // Add or replace a todo, ignoring versions older than the one shown
+  const applyTodo = (todo) => {
+    setTodos(prev => {
+      const index = prev.findIndex(t => t.id === todo.id);
+      if (index === -1) {
+        return [todo, ...prev];
+      }
+      if (prev[index].version > todo.version) {
+        return prev;
+      }
+      return prev.map(t => (t.id === todo.id ? todo : t));
+    });
+  };
+
+  const removeTodo = (id) => {
+    setTodos(prev => prev.filter(t => t.id !== id));
+  };
+
   const fetchTodos = async () => {
     try {
       setLoading(true);
@@ -69,9 +147,9 @@ const TodoList = () => {
     try {
       setLoading(true);
       setError('');
-      await axios.post('/api/todos', newTodo);
+      const response = await axios.post('/api/todos', newTodo);
       setNewTodo({ title: '', description: '' });
-      await fetchTodos();
+      applyTodo(response.data);
     } catch (err) {
       console.error('Error adding todo:', err);
       setError('Failed to add todo. Please try again.');
@@ -90,9 +168,9 @@ const TodoList = () => {
     try {
       setLoading(true);
       setError('');
-      await axios.put(`/api/todos/${editingTodo.id}`, editFormData);
+      const response = await axios.patch(`/api/todos/${editingTodo.id}`, editFormData, mergePatchConfig);
       setEditingTodo(null);
-      await fetchTodos();
+      applyTodo(response.data);
     } catch (err) {
       console.error('Error updating todo:', err);
       setError('Failed to update todo. Please try again.');
@@ -106,7 +184,7 @@ const TodoList = () => {
       setLoading(true);
       setError('');
       await axios.delete(`/api/todos/${id}`);
-      await fetchTodos();
+      removeTodo(id);
     } catch (err) {
       console.error('Error deleting todo:', err);
       setError('Failed to delete todo. Please try again.');
@@ -119,10 +197,10 @@ const TodoList = () => {
     try {
       setLoading(true);
       setError('');
-      await axios.put(`/api/todos/${todo.id}`, {
+      const response = await axios.patch(`/api/todos/${todo.id}`, {
         completed: !todo.completed
-      });
-      await fetchTodos();
+      }, mergePatchConfig);
+      applyTodo(response.data);
     } catch (err) {
       console.error('Error updating todo:', err);
       setError('Failed to update todo. Please try again.');
@@ -0,0 +1,383 @@
+// Package ical reads and writes iCalendar (RFC 5545) objects
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// MediaType is the content type of iCalendar objects
+const MediaType = "text/calendar; charset=utf-8"
+
+// maxLineOctets is the longest a content line may be, not counting the line
+// break; longer lines are folded
+const maxLineOctets = 75
+
+// Property is a content line of a component. Value is written as is, so text
+// must be escaped with EscapeText first; the Add methods of Component do that.
+type Property struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// Component is an iCalendar component, such as a VCALENDAR or a VTODO, with
+// its properties and sub-components
+type Component struct {
+	Name       string
+	Properties []Property
+	Components []*Component
+}
+
+// NewCalendar creates a VCALENDAR with the properties every calendar needs
+func NewCalendar(prodID string) *Component {
+	calendar := &Component{Name: "VCALENDAR"}
+	calendar.Add("VERSION", "2.0")
+	calendar.Add("PRODID", prodID)
+	calendar.Add("CALSCALE", "GREGORIAN")
+	return calendar
+}
+
+// Add adds a property whose value is already in iCalendar form
+func (c *Component) Add(name, value string) {
+	c.Properties = append(c.Properties, Property{Name: name, Value: value})
+}
+
+// AddText adds a TEXT property, escaping its value
+func (c *Component) AddText(name, text string) {
+	c.Add(name, EscapeText(text))
+}
+
+// AddTextList adds a property whose value is a list of TEXT values, such as
+// CATEGORIES, escaping each of them
+func (c *Component) AddTextList(name string, texts []string) {
+	escaped := make([]string, len(texts))
+	for i, text := range texts {
+		escaped[i] = EscapeText(text)
+	}
+	c.Add(name, strings.Join(escaped, ","))
+}
+
+// AddTime adds a DATE-TIME property in UTC
+func (c *Component) AddTime(name string, t time.Time) {
+	c.Add(name, FormatTime(t))
+}
+
+// AddComponent adds a sub-component
+func (c *Component) AddComponent(component *Component) {
+	c.Components = append(c.Components, component)
+}
+
+// Get returns the first property with a name, or nil if there is none
+func (c *Component) Get(name string) *Property {
+	for i := range c.Properties {
+		if strings.EqualFold(c.Properties[i].Name, name) {
+			return &c.Properties[i]
+		}
+	}
+	return nil
+}
+
+// Text returns the unescaped value of the first TEXT property with a name,
+// or "" if there is none
+func (c *Component) Text(name string) string {
+	property := c.Get(name)
+	if property == nil {
+		return ""
+	}
+	return UnescapeText(property.Value)
+}
+
+// TextList returns the unescaped values of every property with a name whose
+// value is a list of TEXT values, such as CATEGORIES
+func (c *Component) TextList(name string) []string {
+	var texts []string
+	for _, property := range c.Properties {
+		if !strings.EqualFold(property.Name, name) {
+			continue
+		}
+
+		// Commas separate the values unless they're escaped
+		start := 0
+		for i := 0; i < len(property.Value); i++ {
+			switch property.Value[i] {
+			case '\\':
+				i++
+			case ',':
+				texts = append(texts, UnescapeText(property.Value[start:i]))
+				start = i + 1
+			}
+		}
+		texts = append(texts, UnescapeText(property.Value[start:]))
+	}
+	return texts
+}
+
+// Time returns the value of the first DATE or DATE-TIME property with a name,
+// or nil if there is none. A DATE-TIME ending in Z is in UTC; others are in
+// the time zone of their TZID parameter, or in loc if they have none or it
+// isn't a known zone. A DATE is midnight of the day in loc.
+func (c *Component) Time(name string, loc *time.Location) (*time.Time, error) {
+	property := c.Get(name)
+	if property == nil {
+		return nil, nil
+	}
+
+	if strings.EqualFold(property.Params["VALUE"], "DATE") || len(property.Value) == len("20060102") {
+		t, err := time.ParseInLocation("20060102", property.Value, loc)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid date %q", ErrInvalid, property.Value)
+		}
+		return &t, nil
+	}
+
+	if strings.HasSuffix(property.Value, "Z") {
+		loc = time.UTC
+	} else if tzid := property.Params["TZID"]; tzid != "" {
+		if zone, err := time.LoadLocation(strings.TrimPrefix(tzid, "/")); err == nil {
+			loc = zone
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", strings.TrimSuffix(property.Value, "Z"), loc)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid date-time %q", ErrInvalid, property.Value)
+	}
+	return &t, nil
+}
+
+// Encode writes the component and its sub-components to w
+func (c *Component) Encode(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	c.encode(bw)
+	return bw.Flush()
+}
+
+// encode writes the component to a buffered writer, whose first write error
+// is returned by Flush
+func (c *Component) encode(w *bufio.Writer) {
+	writeLine(w, "BEGIN:"+c.Name)
+	for _, property := range c.Properties {
+		writeLine(w, property.contentLine())
+	}
+	for _, component := range c.Components {
+		component.encode(w)
+	}
+	writeLine(w, "END:"+c.Name)
+}
+
+// contentLine returns the property as an unfolded content line. Parameters
+// are written in name order, quoted when their value needs it.
+func (p *Property) contentLine() string {
+	var line strings.Builder
+	line.WriteString(p.Name)
+
+	names := make([]string, 0, len(p.Params))
+	for name := range p.Params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := p.Params[name]
+		line.WriteString(";" + name + "=")
+		if strings.ContainsAny(value, ";:,") {
+			line.WriteString(`"` + value + `"`)
+		} else {
+			line.WriteString(value)
+		}
+	}
+
+	line.WriteString(":" + p.Value)
+	return line.String()
+}
+
+// writeLine writes a content line, folding it so no line is longer than 75
+// octets. Folds never split a UTF-8 sequence.
+func writeLine(w *bufio.Writer, line string) {
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		w.WriteString(line[:cut])
+		w.WriteString("\r\n ")
+		line = line[cut:]
+		// Continuation lines start with a space, which counts towards the limit
+		limit = maxLineOctets - 1
+	}
+	w.WriteString(line)
+	w.WriteString("\r\n")
+}
+
+// textEscaper escapes the characters that are special in TEXT values
+var textEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	";", `\;`,
+	",", `\,`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+	"\r", `\n`,
+)
+
+// EscapeText escapes a TEXT value
+func EscapeText(text string) string {
+	return textEscaper.Replace(text)
+}
+
+// textUnescaper undoes textEscaper
+var textUnescaper = strings.NewReplacer(
+	`\\`, `\`,
+	`\;`, ";",
+	`\,`, ",",
+	`\n`, "\n",
+	`\N`, "\n",
+)
+
+// UnescapeText unescapes a TEXT value
+func UnescapeText(text string) string {
+	return textUnescaper.Replace(text)
+}
+
+// FormatTime formats a time as a UTC DATE-TIME value
+func FormatTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// ErrInvalid is wrapped by the errors Decode returns for malformed objects
+var ErrInvalid = errors.New("invalid iCalendar object")
+
+// Decode reads an iCalendar object, such as a VCALENDAR, and its
+// sub-components. Names of components, properties and parameters are
+// upper-cased; values are kept as they are, so TEXT values still need
+// unescaping.
+func Decode(r io.Reader) (*Component, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var root *Component
+	var stack []*Component
+	for i, line := range lines {
+		if line == "" {
+			continue
+		}
+		property, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrInvalid, i+1, err)
+		}
+
+		switch property.Name {
+		case "BEGIN":
+			if root != nil && len(stack) == 0 {
+				return nil, fmt.Errorf("%w: line %d: more than one object", ErrInvalid, i+1)
+			}
+			component := &Component{Name: strings.ToUpper(property.Value)}
+			if len(stack) > 0 {
+				stack[len(stack)-1].AddComponent(component)
+			} else {
+				root = component
+			}
+			stack = append(stack, component)
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1].Name != strings.ToUpper(property.Value) {
+				return nil, fmt.Errorf("%w: line %d: unexpected END:%s", ErrInvalid, i+1, property.Value)
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("%w: line %d: property outside a component", ErrInvalid, i+1)
+			}
+			component := stack[len(stack)-1]
+			component.Properties = append(component.Properties, *property)
+		}
+	}
+
+	if root == nil {
+		return nil, fmt.Errorf("%w: no object", ErrInvalid)
+	}
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("%w: missing END:%s", ErrInvalid, stack[len(stack)-1].Name)
+	}
+	return root, nil
+}
+
+// unfold reads the content lines of an object, joining folded lines back
+// together. Bare LF line breaks are accepted as well as CRLF.
+func unfold(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if len(lines) > 0 && line != "" && (line[0] == ' ' || line[0] == '\t') {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+// parseLine splits an unfolded content line into its name, parameters and value
+func parseLine(line string) (*Property, error) {
+	property := &Property{}
+
+	// The name ends at the first parameter or at the value
+	end := strings.IndexAny(line, ";:")
+	if end <= 0 {
+		return nil, errors.New("missing property name")
+	}
+	property.Name = strings.ToUpper(line[:end])
+	line = line[end:]
+
+	for line[0] == ';' {
+		line = line[1:]
+		eq := strings.IndexByte(line, '=')
+		if eq <= 0 {
+			return nil, errors.New("malformed parameter")
+		}
+		name := strings.ToUpper(line[:eq])
+		line = line[eq+1:]
+
+		// Values are quoted when they contain ";", ":" or ","
+		var value string
+		if strings.HasPrefix(line, `"`) {
+			closing := strings.IndexByte(line[1:], '"')
+			if closing < 0 {
+				return nil, errors.New("unterminated parameter value")
+			}
+			value = line[1 : closing+1]
+			line = line[closing+2:]
+		} else {
+			end := strings.IndexAny(line, ";:")
+			if end < 0 {
+				return nil, errors.New("missing property value")
+			}
+			value = line[:end]
+			line = line[end:]
+		}
+
+		if property.Params == nil {
+			property.Params = map[string]string{}
+		}
+		property.Params[name] = value
+
+		if line == "" {
+			return nil, errors.New("missing property value")
+		}
+	}
+
+	if line[0] != ':' {
+		return nil, errors.New("missing property value")
+	}
+	property.Value = line[1:]
+	return property, nil
+}
@@ -0,0 +1,208 @@
+package ical
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestEscapeText(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"plain", "plain"},
+		{`a\b`, `a\\b`},
+		{"a;b,c", `a\;b\,c`},
+		{"line\nbreak", `line\nbreak`},
+		{"windows\r\nbreak", `windows\nbreak`},
+		{"mac\rbreak", `mac\nbreak`},
+		{`\n`, `\\n`},
+	}
+	for _, tt := range tests {
+		if got := EscapeText(tt.text); got != tt.want {
+			t.Errorf("EscapeText(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+
+	// Unescaping gives the text back, with every line break as \n
+	for _, text := range []string{"plain", `a\b`, "a;b,c", "line\nbreak", `\n`, `\\;`} {
+		if got := UnescapeText(EscapeText(text)); got != text {
+			t.Errorf("UnescapeText(EscapeText(%q)) = %q", text, got)
+		}
+	}
+	if got := UnescapeText(`a\Nb`); got != "a\nb" {
+		t.Errorf(`UnescapeText("a\\Nb") = %q, want "a\nb"`, got)
+	}
+}
+
+func TestEncodeFoldsLongLines(t *testing.T) {
+	calendar := NewCalendar("-//test//EN")
+	vtodo := &Component{Name: "VTODO"}
+	vtodo.AddText("SUMMARY", strings.Repeat("a", 200))
+	vtodo.AddText("DESCRIPTION", strings.Repeat("é", 100))
+	calendar.AddComponent(vtodo)
+
+	var buf bytes.Buffer
+	if err := calendar.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.HasSuffix(out, "END:VCALENDAR\r\n") {
+		t.Errorf("Encode: lines don't end with CRLF: %q", out)
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("Encode: line of %d octets: %q", len(line), line)
+		}
+		if !utf8.ValidString(line) {
+			t.Errorf("Encode: fold splits a UTF-8 sequence: %q", line)
+		}
+	}
+
+	// Decoding unfolds the lines again
+	decoded, err := Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := decoded.Components[0]
+	if got.Text("SUMMARY") != strings.Repeat("a", 200) {
+		t.Errorf("SUMMARY = %q", got.Text("SUMMARY"))
+	}
+	if got.Text("DESCRIPTION") != strings.Repeat("é", 100) {
+		t.Errorf("DESCRIPTION = %q", got.Text("DESCRIPTION"))
+	}
+}
+
+func TestDecode(t *testing.T) {
+	object := "BEGIN:VCALENDAR\n" +
+		"VERSION:2.0\n" +
+		"BEGIN:VTODO\n" +
+		"uid:abc\n" +
+		"SUMMARY;LANGUAGE=en:Buy milk\\, eggs\n" +
+		"DESCRIPTION:first\n" +
+		"  line\n" +
+		"X-NOTE;X-PARAM=\"a;b:c\":value\n" +
+		"DUE;TZID=Europe/Berlin:20240517T170000\n" +
+		"END:VTODO\n" +
+		"END:VCALENDAR\n"
+
+	calendar, err := Decode(strings.NewReader(object))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calendar.Name != "VCALENDAR" || len(calendar.Components) != 1 {
+		t.Fatalf("Decode: got %+v", calendar)
+	}
+	vtodo := calendar.Components[0]
+	if vtodo.Text("UID") != "abc" {
+		t.Errorf("UID = %q, want abc", vtodo.Text("UID"))
+	}
+	if vtodo.Text("SUMMARY") != "Buy milk, eggs" {
+		t.Errorf("SUMMARY = %q", vtodo.Text("SUMMARY"))
+	}
+	if vtodo.Get("SUMMARY").Params["LANGUAGE"] != "en" {
+		t.Errorf("SUMMARY params = %v", vtodo.Get("SUMMARY").Params)
+	}
+	if vtodo.Text("DESCRIPTION") != "first line" {
+		t.Errorf("DESCRIPTION = %q", vtodo.Text("DESCRIPTION"))
+	}
+	if p := vtodo.Get("X-NOTE"); p == nil || p.Params["X-PARAM"] != "a;b:c" || p.Value != "value" {
+		t.Errorf("X-NOTE = %+v", p)
+	}
+	if p := vtodo.Get("DUE"); p == nil || p.Params["TZID"] != "Europe/Berlin" || p.Value != "20240517T170000" {
+		t.Errorf("DUE = %+v", p)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	for _, object := range []string{
+		"",
+		"SUMMARY:outside\n",
+		"BEGIN:VCALENDAR\nBEGIN:VTODO\nEND:VCALENDAR\n",
+		"BEGIN:VCALENDAR\n",
+		"BEGIN:VCALENDAR\nEND:VCALENDAR\nBEGIN:VCALENDAR\nEND:VCALENDAR\n",
+		"BEGIN:VCALENDAR\nnovalue\nEND:VCALENDAR\n",
+		"BEGIN:VCALENDAR\nX;P=\"open:v\nEND:VCALENDAR\n",
+	} {
+		if _, err := Decode(strings.NewReader(object)); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Decode(%q): got %v, want ErrInvalid", object, err)
+		}
+	}
+}
+
+func TestAddTextList(t *testing.T) {
+	vtodo := &Component{Name: "VTODO"}
+	vtodo.AddTextList("CATEGORIES", []string{"work", "a,b"})
+	if got := vtodo.Get("CATEGORIES").Value; got != `work,a\,b` {
+		t.Errorf("CATEGORIES = %q", got)
+	}
+}
+
+func TestFormatTime(t *testing.T) {
+	at := time.Date(2024, 5, 17, 19, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+	if got := FormatTime(at); got != "20240517T170000Z" {
+		t.Errorf("FormatTime = %q, want 20240517T170000Z", got)
+	}
+}
+
+func TestTextList(t *testing.T) {
+	vtodo := &Component{Name: "VTODO"}
+	vtodo.Add("CATEGORIES", `work,a\,b,back\\`)
+	vtodo.Add("categories", "home")
+	got := vtodo.TextList("CATEGORIES")
+	want := []string{"work", "a,b", `back\`, "home"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("TextList = %q, want %q", got, want)
+	}
+	if got := vtodo.TextList("RESOURCES"); got != nil {
+		t.Errorf("TextList of a missing property = %q, want nil", got)
+	}
+}
+
+func TestTime(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("time zone database unavailable")
+	}
+	local := time.FixedZone("server", -5*60*60)
+
+	tests := []struct {
+		line string
+		want time.Time
+	}{
+		{"DUE:20240517T170000Z", time.Date(2024, 5, 17, 17, 0, 0, 0, time.UTC)},
+		{"DUE;TZID=Europe/Berlin:20240517T170000", time.Date(2024, 5, 17, 17, 0, 0, 0, berlin)},
+		{"DUE;TZID=Custom Zone:20240517T170000", time.Date(2024, 5, 17, 17, 0, 0, 0, local)},
+		{"DUE:20240517T170000", time.Date(2024, 5, 17, 17, 0, 0, 0, local)},
+		{"DUE;VALUE=DATE:20240517", time.Date(2024, 5, 17, 0, 0, 0, 0, local)},
+	}
+	for _, tt := range tests {
+		property, err := parseLine(tt.line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vtodo := &Component{Name: "VTODO", Properties: []Property{*property}}
+		got, err := vtodo.Time("DUE", local)
+		if err != nil {
+			t.Errorf("Time(%s): %v", tt.line, err)
+			continue
+		}
+		if got == nil || !got.Equal(tt.want) {
+			t.Errorf("Time(%s) = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+
+	vtodo := &Component{Name: "VTODO"}
+	if got, err := vtodo.Time("DUE", local); got != nil || err != nil {
+		t.Errorf("Time of a missing property = %v, %v; want nil", got, err)
+	}
+	vtodo.Add("DUE", "tomorrow")
+	if _, err := vtodo.Time("DUE", local); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Time(tomorrow): got %v, want ErrInvalid", err)
+	}
+}
@@ -0,0 +1,203 @@
+// Package importers reads the export files of other todo apps: Todoist,
+// Trello and Microsoft To Do
+package importers
+
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Sources that can be imported
+const (
+	SourceTodoist       = "todoist"
+	SourceTrello        = "trello"
+	SourceMicrosoftToDo = "microsoft_todo"
+)
+
+// The longest titles and descriptions todos can have, and how many tags of
+// what length
+const (
+	maxTitleLength       = 100
+	maxDescriptionLength = 10000
+	maxTags              = 20
+	maxTagLength         = 50
+)
+
+// Priorities of items, as todos have them
+const (
+	PriorityNone   = "none"
+	PriorityLow    = "low"
+	PriorityMedium = "medium"
+	PriorityHigh   = "high"
+)
+
+// ErrUnsupportedSource is returned for sources there is no importer for
+var ErrUnsupportedSource = errors.New("unsupported import source")
+
+// Item is a task read from another app's export. Sections and checklists
+// have no counterpart in todos, so they're kept as text.
+type Item struct {
+	Title     string
+	Notes     string
+	Completed bool
+	Project   string // The project, list or board the task was in
+	Section   string // The section or list within the project
+	Labels    []string
+	Priority  string     // One of the Priority constants, or empty for none
+	DueAt     *time.Time // When the task was due
+	DueText   string     // A due date that couldn't be read as one, such as "every monday"
+	Checklist []ChecklistItem
+}
+
+// ChecklistItem is a checklist entry or subtask of an Item
+type ChecklistItem struct {
+	Text    string
+	Checked bool
+}
+
+// Parse reads the items in an export file. The file name tells formats
+// apart where a source has several.
+func Parse(source, filename string, data []byte) ([]*Item, error) {
+	var items []*Item
+	var err error
+	switch source {
+	case SourceTodoist:
+		items, err = parseTodoist(filename, data)
+	case SourceTrello:
+		items, err = parseTrello(data)
+	case SourceMicrosoftToDo:
+		items, err = parseMicrosoftToDo(data)
+	default:
+		return nil, ErrUnsupportedSource
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Tasks without a title can't be todos
+	kept := items[:0]
+	for _, item := range items {
+		item.Title = strings.TrimSpace(item.Title)
+		if item.Title != "" {
+			kept = append(kept, item)
+		}
+	}
+	return kept, nil
+}
+
+// Fields returns the title and description of the todo an item becomes.
+// Titles that are too long are shortened and kept in full in the
+// description, which ends with the item's section, a due date that couldn't
+// be read, and checklist, and its project if projectInText is set.
+func (i *Item) Fields(projectInText bool) (string, string) {
+	title := i.Title
+	var description []string
+	if utf8.RuneCountInString(title) > maxTitleLength {
+		title = truncate(title, maxTitleLength)
+		description = append(description, i.Title)
+	}
+	if notes := strings.TrimSpace(i.Notes); notes != "" {
+		description = append(description, notes)
+	}
+
+	var details []string
+	if projectInText && i.Project != "" {
+		details = append(details, "Project: "+i.Project)
+	}
+	if i.Section != "" {
+		details = append(details, "Section: "+i.Section)
+	}
+	if i.DueText != "" {
+		details = append(details, "Due: "+i.DueText)
+	}
+	if len(details) > 0 {
+		description = append(description, strings.Join(details, "\n"))
+	}
+
+	if len(i.Checklist) > 0 {
+		lines := make([]string, len(i.Checklist))
+		for j, entry := range i.Checklist {
+			box := "[ ]"
+			if entry.Checked {
+				box = "[x]"
+			}
+			lines[j] = "- " + box + " " + strings.Join(strings.Fields(entry.Text), " ")
+		}
+		description = append(description, strings.Join(lines, "\n"))
+	}
+
+	return title, truncate(strings.Join(description, "\n\n"), maxDescriptionLength)
+}
+
+// Tags returns the tags of the todo an item becomes: its labels, each a
+// single word, shortened to the length tags can have. Labels beyond the
+// number of tags a todo can have are dropped.
+func (i *Item) Tags() []string {
+	tags := []string{}
+	seen := map[string]bool{}
+	for _, label := range i.Labels {
+		tag := truncate(strings.Join(strings.Fields(label), "-"), maxTagLength)
+		if tag == "" || seen[strings.ToLower(tag)] {
+			continue
+		}
+		if len(tags) == maxTags {
+			break
+		}
+		seen[strings.ToLower(tag)] = true
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// dueLayouts are the forms of due dates in exports, without a time zone
+var dueLayouts = []string{
+	"2006-01-02T15:04:05.9999999",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// parseDue reads a due date, or date and time, in an export. Times in UTC or
+// with an offset keep it; others are in loc, and dates are midnight in loc.
+func parseDue(value string, loc *time.Location) (*time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return &t, true
+	}
+	for _, layout := range dueLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return &t, true
+		}
+	}
+	return nil, false
+}
+
+// location returns the time zone with an IANA name, or the server's time
+// zone for an empty or unknown name
+func location(name string) *time.Location {
+	if name == "" {
+		return time.Local
+	}
+	if loc, err := time.LoadLocation(name); err == nil {
+		return loc
+	}
+	return time.Local
+}
+
+// ProjectName returns the name of the workspace project an item goes in
+func (i *Item) ProjectName() string {
+	return truncate(strings.TrimSpace(i.Project), maxTitleLength)
+}
+
+// truncate shortens s to at most n characters, ending it with an ellipsis if
+// anything was cut
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	runes := []rune(s)
+	return strings.TrimSpace(string(runes[:n-1])) + "…"
+}
@@ -0,0 +1,230 @@
+package importers
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func parseFixture(t *testing.T, source, name string, data []byte) []*Item {
+	t.Helper()
+	items, err := Parse(source, name, data)
+	if err != nil {
+		t.Fatalf("Parse(%s, %s): %v", source, name, err)
+	}
+	return items
+}
+
+// sameDue compares an item's due date
+func sameDue(got *time.Time, want time.Time) bool {
+	return got != nil && got.Equal(want)
+}
+
+func TestTodoistCSV(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("time zone database unavailable")
+	}
+	name := "Home [2203306141].csv"
+	items := parseFixture(t, SourceTodoist, name, readFixture(t, name))
+	if len(items) != 2 {
+		t.Fatalf("got %d items, want 2", len(items))
+	}
+
+	milk := items[0]
+	if milk.Title != "Buy milk errand" || milk.Project != "Home" || milk.Section != "Errands" ||
+		milk.Notes != "Whole milk\n\nCheck the price" || milk.Priority != PriorityHigh {
+		t.Errorf("item 1 = %+v", milk)
+	}
+	if !reflect.DeepEqual(milk.Labels, []string{"shopping", "quick"}) {
+		t.Errorf("item 1 labels = %q", milk.Labels)
+	}
+	if !sameDue(milk.DueAt, time.Date(2024, 5, 17, 0, 0, 0, 0, berlin)) || milk.DueText != "" {
+		t.Errorf("item 1 due = %v %q", milk.DueAt, milk.DueText)
+	}
+	if !reflect.DeepEqual(milk.Checklist, []ChecklistItem{{Text: "Oat milk too"}}) {
+		t.Errorf("item 1 checklist = %+v", milk.Checklist)
+	}
+
+	// Dates as typed are kept as text
+	plants := items[1]
+	if plants.DueAt != nil || plants.DueText != "every monday" || plants.Priority != "" {
+		t.Errorf("item 2 = %+v", plants)
+	}
+	if _, description := plants.Fields(false); !strings.Contains(description, "Due: every monday") {
+		t.Errorf("item 2 description = %q", description)
+	}
+}
+
+func TestTodoistBackup(t *testing.T) {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for _, name := range []string{"Home [2203306141].csv", "Work [1].csv", "README.txt"} {
+		file, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.Write(readFixture(t, "Home [2203306141].csv"))
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	items := parseFixture(t, SourceTodoist, "backup.zip", buf.Bytes())
+	var projects []string
+	for _, item := range items {
+		projects = append(projects, item.Project)
+	}
+	if want := []string{"Home", "Home", "Work", "Work"}; !reflect.DeepEqual(projects, want) {
+		t.Errorf("projects = %q, want %q", projects, want)
+	}
+}
+
+func TestTodoistJSON(t *testing.T) {
+	items := parseFixture(t, SourceTodoist, "todoist.json", readFixture(t, "todoist.json"))
+	if len(items) != 2 {
+		t.Fatalf("got %d items, want 2", len(items))
+	}
+
+	taxes := items[0]
+	if taxes.Title != "File taxes" || taxes.Project != "Work" || taxes.Section != "Reports" ||
+		taxes.Notes != "Before May\n\nAsk the bank" || taxes.Priority != PriorityHigh || taxes.Completed {
+		t.Errorf("item 1 = %+v", taxes)
+	}
+	if !reflect.DeepEqual(taxes.Labels, []string{"finance", "urgent"}) {
+		t.Errorf("item 1 labels = %q", taxes.Labels)
+	}
+	if !sameDue(taxes.DueAt, time.Date(2024, 5, 17, 17, 0, 0, 0, time.UTC)) || taxes.DueText != "" {
+		t.Errorf("item 1 due = %v %q", taxes.DueAt, taxes.DueText)
+	}
+	if !reflect.DeepEqual(taxes.Checklist, []ChecklistItem{{Text: "Gather receipts", Checked: true}}) {
+		t.Errorf("item 1 checklist = %+v", taxes.Checklist)
+	}
+
+	// Recurring tasks are due next time, and keep how they recur
+	standup := items[1]
+	if !sameDue(standup.DueAt, time.Date(2024, 5, 20, 0, 0, 0, 0, time.Local)) || standup.DueText != "every weekday" || standup.Priority != PriorityLow {
+		t.Errorf("item 2 = %+v", standup)
+	}
+}
+
+func TestTrello(t *testing.T) {
+	items := parseFixture(t, SourceTrello, "trello.json", readFixture(t, "trello.json"))
+	if len(items) != 1 {
+		t.Fatalf("got %d items, want 1", len(items))
+	}
+
+	card := items[0]
+	if card.Title != "Write announcement" || card.Project != "Launch" || card.Section != "Doing" || !card.Completed || card.Priority != "" {
+		t.Errorf("card = %+v", card)
+	}
+	if !sameDue(card.DueAt, time.Date(2024, 5, 17, 17, 0, 0, 0, time.UTC)) {
+		t.Errorf("card due = %v", card.DueAt)
+	}
+	if got, want := card.Tags(), []string{"Marketing-team", "red"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("card tags = %q, want %q", got, want)
+	}
+	want := []ChecklistItem{{Text: "Draft post", Checked: true}, {Text: "Send mail"}}
+	if !reflect.DeepEqual(card.Checklist, want) {
+		t.Errorf("card checklist = %+v", card.Checklist)
+	}
+}
+
+func TestMicrosoftToDo(t *testing.T) {
+	items := parseFixture(t, SourceMicrosoftToDo, "microsoft_todo.json", readFixture(t, "microsoft_todo.json"))
+	if len(items) != 2 {
+		t.Fatalf("got %d items, want 2", len(items))
+	}
+
+	bread := items[0]
+	if bread.Title != "Bread" || bread.Project != "Groceries" || bread.Notes != "Rye & wheat" || !bread.Completed || bread.Priority != PriorityHigh {
+		t.Errorf("item 1 = %+v", bread)
+	}
+	if !sameDue(bread.DueAt, time.Date(2024, 5, 17, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("item 1 due = %v", bread.DueAt)
+	}
+	if got, want := bread.Tags(), []string{"Red-category", "shop"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("item 1 tags = %q, want %q", got, want)
+	}
+
+	eggs := items[1]
+	if eggs.Completed || eggs.Priority != "" || eggs.DueAt != nil {
+		t.Errorf("item 2 = %+v", eggs)
+	}
+
+	// A plain array of lists works too
+	if _, err := Parse(SourceMicrosoftToDo, "lists.json", []byte(`[{"displayName": "A", "tasks": []}]`)); err != nil {
+		t.Errorf("Parse(array): %v", err)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	if _, err := Parse("asana", "a.json", []byte("{}")); !errors.Is(err, ErrUnsupportedSource) {
+		t.Errorf("Parse(asana): got %v, want ErrUnsupportedSource", err)
+	}
+	for source, data := range map[string]string{
+		SourceTodoist:       "name,date\nx,y\n",
+		SourceTrello:        `{"name": "no cards"}`,
+		SourceMicrosoftToDo: `{"lists": []}`,
+	} {
+		if _, err := Parse(source, "file", []byte(data)); err == nil {
+			t.Errorf("Parse(%s, %q): got no error", source, data)
+		}
+	}
+}
+
+func TestFields(t *testing.T) {
+	item := &Item{
+		Title:     strings.Repeat("a", 120),
+		Notes:     " notes ",
+		Project:   "Home",
+		Section:   "Errands",
+		Labels:    []string{"x"},
+		Checklist: []ChecklistItem{{Text: "one  two", Checked: true}, {Text: "three"}},
+	}
+	title, description := item.Fields(true)
+	if title != strings.Repeat("a", 99)+"…" {
+		t.Errorf("title = %q", title)
+	}
+	want := strings.Repeat("a", 120) + "\n\nnotes\n\nProject: Home\nSection: Errands\n\n- [x] one two\n- [ ] three"
+	if description != want {
+		t.Errorf("description = %q, want %q", description, want)
+	}
+
+	// Workspace imports have the project as a project
+	if _, description := item.Fields(false); strings.Contains(description, "Project:") {
+		t.Errorf("description = %q, has the project", description)
+	}
+}
+
+func TestTags(t *testing.T) {
+	labels := []string{"  spaced   out ", "", "Dup", "dup", strings.Repeat("é", 60)}
+	for i := 0; i < 30; i++ {
+		labels = append(labels, "extra"+string(rune('a'+i)))
+	}
+	tags := (&Item{Labels: labels}).Tags()
+	if len(tags) != maxTags {
+		t.Fatalf("got %d tags, want %d", len(tags), maxTags)
+	}
+	if tags[0] != "spaced-out" || tags[1] != "Dup" || tags[2] != strings.Repeat("é", maxTagLength-1)+"…" || tags[3] != "extraa" {
+		t.Errorf("tags = %q", tags)
+	}
+	if tags := (&Item{}).Tags(); tags == nil || len(tags) != 0 {
+		t.Errorf("Tags() of no labels = %#v, want empty", tags)
+	}
+}
@@ -0,0 +1,93 @@
+package importers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"html"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// htmlTag matches the tags of task notes written as HTML
+var htmlTag = regexp.MustCompile(`(?s)<[^>]*>`)
+
+// microsoftToDoPriorities maps the importance of tasks to priorities
+var microsoftToDoPriorities = map[string]string{
+	"high": PriorityHigh,
+	"low":  PriorityLow,
+}
+
+// microsoftToDoList is a task list as the Microsoft Graph To Do API returns
+// it, with its tasks included
+type microsoftToDoList struct {
+	DisplayName string `json:"displayName"`
+	Tasks       []struct {
+		Title      string `json:"title"`
+		Status     string `json:"status"`
+		Importance string `json:"importance"`
+		Body       struct {
+			Content     string `json:"content"`
+			ContentType string `json:"contentType"`
+		} `json:"body"`
+		DueDateTime *struct {
+			DateTime string `json:"dateTime"`
+		} `json:"dueDateTime"`
+		Categories     []string `json:"categories"`
+		ChecklistItems []struct {
+			DisplayName string `json:"displayName"`
+			IsChecked   bool   `json:"isChecked"`
+		} `json:"checklistItems"`
+	} `json:"tasks"`
+}
+
+// parseMicrosoftToDo reads Microsoft To Do lists exported from the Graph API:
+// an array of task lists, or an object with them under "value", each with its
+// tasks under "tasks". Each list is a project; categories are labels and
+// steps are checklist entries. Normal importance is no priority.
+func parseMicrosoftToDo(data []byte) ([]*Item, error) {
+	var lists []microsoftToDoList
+	data = bytes.TrimSpace(data)
+	if bytes.HasPrefix(data, []byte("{")) {
+		var page struct {
+			Value []microsoftToDoList `json:"value"`
+		}
+		if err := json.Unmarshal(data, &page); err != nil || page.Value == nil {
+			return nil, errors.New("not a Microsoft To Do export")
+		}
+		lists = page.Value
+	} else if err := json.Unmarshal(data, &lists); err != nil {
+		return nil, errors.New("not a Microsoft To Do export")
+	}
+
+	items := []*Item{}
+	for _, list := range lists {
+		for _, task := range list.Tasks {
+			item := &Item{
+				Title:     task.Title,
+				Completed: task.Status == "completed",
+				Project:   list.DisplayName,
+				Labels:    task.Categories,
+				Priority:  microsoftToDoPriorities[strings.ToLower(task.Importance)],
+			}
+
+			item.Notes = task.Body.Content
+			if strings.EqualFold(task.Body.ContentType, "html") {
+				item.Notes = strings.TrimSpace(html.UnescapeString(htmlTag.ReplaceAllString(task.Body.Content, "")))
+			}
+
+			// Due dates are dates, written as midnight in the time zone the
+			// task was read in; they're midnight here too
+			if task.DueDateTime != nil && len(task.DueDateTime.DateTime) >= len("2006-01-02") {
+				item.DueAt, _ = parseDue(task.DueDateTime.DateTime[:len("2006-01-02")], time.Local)
+			}
+
+			for _, step := range task.ChecklistItems {
+				item.Checklist = append(item.Checklist, ChecklistItem{Text: step.DisplayName, Checked: step.IsChecked})
+			}
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
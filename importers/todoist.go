@@ -0,0 +1,336 @@
+package importers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// todoistBackupSuffix matches the project ID Todoist backups add to the
+// names of their CSV files, as in "Inbox [2203306141].csv"
+var todoistBackupSuffix = regexp.MustCompile(`\s*\[\d+\]$`)
+
+// parseTodoist reads a Todoist export: a project's CSV export, a backup,
+// which is a zip file of them, or a JSON dump of the Sync API
+func parseTodoist(filename string, data []byte) ([]*Item, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		return parseTodoistBackup(data)
+	case bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")):
+		return parseTodoistJSON(data)
+	default:
+		return parseTodoistCSV(todoistProjectName(filename), data)
+	}
+}
+
+// todoistProjectName returns the name of the project a CSV file is for
+func todoistProjectName(filename string) string {
+	name := strings.TrimSuffix(path.Base(filename), path.Ext(filename))
+	return strings.TrimSpace(todoistBackupSuffix.ReplaceAllString(name, ""))
+}
+
+// parseTodoistBackup reads every project CSV in a Todoist backup
+func parseTodoistBackup(data []byte) ([]*Item, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, errors.New("not a valid Todoist backup")
+	}
+
+	items := []*Item{}
+	for _, file := range archive.File {
+		if !strings.EqualFold(path.Ext(file.Name), ".csv") {
+			continue
+		}
+
+		reader, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %v", file.Name, err)
+		}
+		contents, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %v", file.Name, err)
+		}
+
+		projectItems, err := parseTodoistCSV(todoistProjectName(file.Name), contents)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %v", file.Name, err)
+		}
+		items = append(items, projectItems...)
+	}
+	return items, nil
+}
+
+// parseTodoistCSV reads a project exported as a CSV template. Its rows are
+// sections, tasks and the notes of the task above them; INDENT nests
+// subtasks under tasks. Labels are written into the content as @label.
+// DATE is as the user typed it, so dates that aren't ISO dates, such as
+// "every monday", are kept as text. Templates don't include completed tasks.
+func parseTodoistCSV(project string, data []byte) ([]*Item, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.New("not a Todoist CSV export")
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToUpper(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["TYPE"]; !ok {
+		return nil, errors.New("not a Todoist CSV export")
+	}
+	if _, ok := columns["CONTENT"]; !ok {
+		return nil, errors.New("not a Todoist CSV export")
+	}
+
+	items := []*Item{}
+	var section string
+	var parent *Item // The last top-level task, which subtasks and notes belong to
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %v", err)
+		}
+		get := func(column string) string {
+			if i, ok := columns[column]; ok && i < len(fields) {
+				return strings.TrimSpace(fields[i])
+			}
+			return ""
+		}
+
+		switch strings.ToLower(get("TYPE")) {
+		case "section":
+			section = get("CONTENT")
+			parent = nil
+
+		case "task":
+			title, labels := todoistLabels(get("CONTENT"))
+			indent, _ := strconv.Atoi(get("INDENT"))
+			if indent > 1 && parent != nil {
+				parent.Checklist = append(parent.Checklist, ChecklistItem{Text: title})
+				continue
+			}
+
+			parent = &Item{
+				Title:    title,
+				Notes:    get("DESCRIPTION"),
+				Project:  project,
+				Section:  section,
+				Labels:   labels,
+				Priority: todoistCSVPriorities[get("PRIORITY")],
+			}
+			if due := get("DATE"); due != "" {
+				if dueAt, ok := parseDue(due, location(get("TIMEZONE"))); ok {
+					parent.DueAt = dueAt
+				} else {
+					parent.DueText = due
+				}
+			}
+			items = append(items, parent)
+
+		case "note":
+			if parent != nil && get("CONTENT") != "" {
+				parent.Notes = strings.TrimSpace(parent.Notes + "\n\n" + get("CONTENT"))
+			}
+		}
+	}
+	return items, nil
+}
+
+// todoistCSVPriorities maps the PRIORITY of CSV templates, which is 1 for
+// the highest priority as in the app, to priorities. 4 is no priority.
+var todoistCSVPriorities = map[string]string{
+	"1": PriorityHigh,
+	"2": PriorityMedium,
+	"3": PriorityLow,
+}
+
+// todoistPriorities maps the priority of the Sync API, which is 4 for the
+// highest priority, to priorities. 1 is no priority.
+var todoistPriorities = map[int]string{
+	4: PriorityHigh,
+	3: PriorityMedium,
+	2: PriorityLow,
+}
+
+// todoistLabels splits the @labels out of a task's content
+func todoistLabels(content string) (string, []string) {
+	var words, labels []string
+	for _, word := range strings.Fields(content) {
+		if len(word) > 1 && strings.HasPrefix(word, "@") {
+			labels = append(labels, word[1:])
+			continue
+		}
+		words = append(words, word)
+	}
+	return strings.Join(words, " "), labels
+}
+
+// todoistID is an ID in a Todoist JSON dump, which older versions of the API
+// write as numbers and newer ones as strings
+type todoistID string
+
+// UnmarshalJSON implements json.Unmarshaler
+func (id *todoistID) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		*id = ""
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*id = todoistID(s)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return err
+	}
+	*id = todoistID(n.String())
+	return nil
+}
+
+// todoistDump is the part of a Sync API dump that's imported
+type todoistDump struct {
+	Projects []struct {
+		ID   todoistID `json:"id"`
+		Name string    `json:"name"`
+	} `json:"projects"`
+	Sections []struct {
+		ID   todoistID `json:"id"`
+		Name string    `json:"name"`
+	} `json:"sections"`
+	Labels []struct {
+		ID   todoistID `json:"id"`
+		Name string    `json:"name"`
+	} `json:"labels"`
+	Items []struct {
+		ID          todoistID   `json:"id"`
+		Content     string      `json:"content"`
+		Description string      `json:"description"`
+		ProjectID   todoistID   `json:"project_id"`
+		SectionID   todoistID   `json:"section_id"`
+		ParentID    todoistID   `json:"parent_id"`
+		Labels      []todoistID `json:"labels"`
+		Checked     interface{} `json:"checked"` // A boolean, or 0 or 1 in older versions
+		Priority    int         `json:"priority"`
+		Due         *struct {
+			Date        string `json:"date"`
+			Timezone    string `json:"timezone"` // Empty for floating times
+			String      string `json:"string"`
+			IsRecurring bool   `json:"is_recurring"`
+		} `json:"due"`
+	} `json:"items"`
+	Notes []struct {
+		ItemID  todoistID `json:"item_id"`
+		Content string    `json:"content"`
+	} `json:"notes"`
+}
+
+// parseTodoistJSON reads a JSON dump of the Todoist Sync API. Subtasks
+// become checklist entries of their top-level task. Recurring tasks are due
+// at their next occurrence, and keep how they recur as text.
+func parseTodoistJSON(data []byte) ([]*Item, error) {
+	var dump todoistDump
+	if err := json.Unmarshal(data, &dump); err != nil || dump.Items == nil {
+		return nil, errors.New("not a Todoist JSON export")
+	}
+
+	projects := make(map[todoistID]string, len(dump.Projects))
+	for _, project := range dump.Projects {
+		projects[project.ID] = project.Name
+	}
+	sections := make(map[todoistID]string, len(dump.Sections))
+	for _, section := range dump.Sections {
+		sections[section.ID] = section.Name
+	}
+	// Older versions refer to labels by ID, newer ones by name
+	labels := make(map[todoistID]string, len(dump.Labels))
+	for _, label := range dump.Labels {
+		labels[label.ID] = label.Name
+	}
+
+	parents := make(map[todoistID]todoistID, len(dump.Items))
+	for _, task := range dump.Items {
+		parents[task.ID] = task.ParentID
+	}
+	// root finds the top-level task a subtask is nested under
+	root := func(id todoistID) todoistID {
+		for depth := 0; parents[id] != "" && depth < len(parents); depth++ {
+			id = parents[id]
+		}
+		return id
+	}
+
+	items := []*Item{}
+	byID := make(map[todoistID]*Item, len(dump.Items))
+	for _, task := range dump.Items {
+		if task.ParentID != "" {
+			continue
+		}
+		item := &Item{
+			Title:     task.Content,
+			Notes:     task.Description,
+			Completed: todoistChecked(task.Checked),
+			Project:   projects[task.ProjectID],
+			Section:   sections[task.SectionID],
+			Priority:  todoistPriorities[task.Priority],
+		}
+		for _, label := range task.Labels {
+			if name, ok := labels[label]; ok {
+				item.Labels = append(item.Labels, name)
+			} else {
+				item.Labels = append(item.Labels, string(label))
+			}
+		}
+		if task.Due != nil {
+			dueAt, ok := parseDue(task.Due.Date, location(task.Due.Timezone))
+			item.DueAt = dueAt
+			if !ok || task.Due.IsRecurring {
+				item.DueText = task.Due.String
+			}
+		}
+		items = append(items, item)
+		byID[task.ID] = item
+	}
+
+	for _, task := range dump.Items {
+		if task.ParentID == "" {
+			continue
+		}
+		if parent, ok := byID[root(task.ID)]; ok {
+			parent.Checklist = append(parent.Checklist, ChecklistItem{Text: task.Content, Checked: todoistChecked(task.Checked)})
+		}
+	}
+
+	for _, note := range dump.Notes {
+		if item, ok := byID[root(note.ItemID)]; ok && strings.TrimSpace(note.Content) != "" {
+			item.Notes = strings.TrimSpace(item.Notes + "\n\n" + note.Content)
+		}
+	}
+
+	return items, nil
+}
+
+// todoistChecked reads a task's checked field
+func todoistChecked(checked interface{}) bool {
+	switch value := checked.(type) {
+	case bool:
+		return value
+	case float64:
+		return value != 0
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package importers
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+)
+
+// trelloBoard is the part of a Trello board's JSON export that's imported
+type trelloBoard struct {
+	Name  string `json:"name"`
+	Lists []struct {
+		ID     string `json:"id"`
+		Name   string `json:"name"`
+		Closed bool   `json:"closed"`
+	} `json:"lists"`
+	Cards []struct {
+		ID           string   `json:"id"`
+		Name         string   `json:"name"`
+		Desc         string   `json:"desc"`
+		IDList       string   `json:"idList"`
+		Closed       bool     `json:"closed"`
+		Due          string   `json:"due"`
+		DueComplete  bool     `json:"dueComplete"`
+		IDChecklists []string `json:"idChecklists"`
+		Labels       []struct {
+			Name  string `json:"name"`
+			Color string `json:"color"`
+		} `json:"labels"`
+	} `json:"cards"`
+	Checklists []struct {
+		ID         string `json:"id"`
+		IDCard     string `json:"idCard"`
+		CheckItems []struct {
+			Name  string  `json:"name"`
+			State string  `json:"state"`
+			Pos   float64 `json:"pos"`
+		} `json:"checkItems"`
+	} `json:"checklists"`
+}
+
+// parseTrello reads a Trello board's JSON export. The board is the project
+// and each list a section; labels are tags. Archived cards, and the cards of archived lists,
+// are skipped.
+func parseTrello(data []byte) ([]*Item, error) {
+	var board trelloBoard
+	if err := json.Unmarshal(data, &board); err != nil || board.Cards == nil {
+		return nil, errors.New("not a Trello board export")
+	}
+
+	lists := make(map[string]string, len(board.Lists))
+	closedLists := make(map[string]bool)
+	for _, list := range board.Lists {
+		lists[list.ID] = list.Name
+		if list.Closed {
+			closedLists[list.ID] = true
+		}
+	}
+
+	// Cards list their checklists in order; checklists' items have positions
+	checklists := make(map[string][]ChecklistItem, len(board.Checklists))
+	for _, checklist := range board.Checklists {
+		checkItems := checklist.CheckItems
+		sort.SliceStable(checkItems, func(i, j int) bool { return checkItems[i].Pos < checkItems[j].Pos })
+		entries := make([]ChecklistItem, len(checkItems))
+		for i, checkItem := range checkItems {
+			entries[i] = ChecklistItem{Text: checkItem.Name, Checked: checkItem.State == "complete"}
+		}
+		checklists[checklist.ID] = entries
+	}
+
+	items := []*Item{}
+	for _, card := range board.Cards {
+		if card.Closed || closedLists[card.IDList] {
+			continue
+		}
+
+		item := &Item{
+			Title:     card.Name,
+			Notes:     card.Desc,
+			Completed: card.DueComplete,
+			Project:   board.Name,
+			Section:   lists[card.IDList],
+		}
+		for _, label := range card.Labels {
+			// Labels can be just a color
+			name := label.Name
+			if name == "" {
+				name = label.Color
+			}
+			if name != "" {
+				item.Labels = append(item.Labels, name)
+			}
+		}
+		if due, err := time.Parse(time.RFC3339, card.Due); err == nil {
+			item.DueAt = &due
+		}
+		for _, id := range card.IDChecklists {
+			item.Checklist = append(item.Checklist, checklists[id]...)
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
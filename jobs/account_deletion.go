@@ -0,0 +1,53 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/noman/todo-application/events"
+	"github.com/noman/todo-application/repository"
+	"github.com/noman/todo-application/storage"
+)
+
+// StartAccountDeletion starts a background job that hourly deletes the
+// accounts whose deletion grace period has ended
+func StartAccountDeletion() {
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+
+		for {
+			deleteAccounts()
+			<-ticker.C
+		}
+	}()
+}
+
+// deleteAccounts deletes every account that was scheduled for deletion by now
+func deleteAccounts() {
+	accountRepo := repository.NewAccountRepository()
+
+	now := time.Now()
+	userIDs, err := accountRepo.GetDueForDeletion(now)
+	if err != nil {
+		log.Printf("Failed to find accounts to delete: %v", err)
+		return
+	}
+
+	for _, userID := range userIDs {
+		keys, err := accountRepo.Delete(userID, now)
+		if errors.Is(err, repository.ErrUserNotFound) {
+			// The user cancelled the deletion meanwhile
+			continue
+		}
+		if err != nil {
+			log.Printf("Failed to delete account %s: %v", userID, err)
+			continue
+		}
+		storage.DeleteAll(context.Background(), storage.Store, keys)
+		events.RevokeAccess(events.Default, userID)
+		log.Printf("Deleted account %s", userID)
+	}
+}
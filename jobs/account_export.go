@@ -0,0 +1,238 @@
+package jobs
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/noman/todo-application/models"
+	"github.com/noman/todo-application/repository"
+	"github.com/noman/todo-application/storage"
+)
+
+// Account export settings
+const (
+	exportPollInterval = 5 * time.Second
+	exportLease        = 15 * time.Minute // How long a claimed export is reserved for building its archive
+)
+
+// StartAccountExports starts a background job that builds queued account
+// exports, and another that hourly deletes archives older than
+// ACCOUNT_EXPORT_RETENTION
+func StartAccountExports() {
+	retention, err := time.ParseDuration(os.Getenv("ACCOUNT_EXPORT_RETENTION"))
+	if err != nil || retention <= 0 {
+		retention = 7 * 24 * time.Hour // Default to 7 days if not specified
+	}
+
+	log.Printf("Keeping account exports for %s", retention)
+
+	go func() {
+		ticker := time.NewTicker(exportPollInterval)
+		defer ticker.Stop()
+
+		for {
+			// Keep going while exports are waiting
+			for buildAccountExport(retention) {
+			}
+			<-ticker.C
+		}
+	}()
+
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+
+		for {
+			cleanupAccountExports()
+			<-ticker.C
+		}
+	}()
+}
+
+// buildAccountExport builds the oldest queued export, and returns whether
+// there was one
+func buildAccountExport(retention time.Duration) bool {
+	exportRepo := repository.NewAccountExportRepository()
+
+	export, err := exportRepo.Claim(exportLease)
+	if err != nil {
+		log.Printf("Failed to claim account export: %v", err)
+		return false
+	}
+	if export == nil {
+		return false
+	}
+
+	export.StorageKey = "exports/" + export.UserID.String() + "/" + export.ID.String() + ".zip"
+	export.Size, err = storeAccountArchive(export.UserID, export.StorageKey)
+	if err != nil {
+		log.Printf("Failed to build account export %s: %v", export.ID, err)
+		export.StorageKey = ""
+		export.Size = 0
+		export.Error = "Export failed because of a server error"
+	}
+
+	// Failed exports expire too, so they're cleaned up
+	expiresAt := time.Now().Add(retention)
+	export.ExpiresAt = &expiresAt
+	if err := exportRepo.Finish(export); err != nil {
+		log.Printf("Failed to record account export %s: %v", export.ID, err)
+	}
+
+	return true
+}
+
+// storeAccountArchive writes a user's archive to a temporary file, then
+// stores it under key and returns its size
+func storeAccountArchive(userID uuid.UUID, key string) (int64, error) {
+	file, err := os.CreateTemp("", "account-export-*.zip")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	if err := writeAccountArchive(file, userID); err != nil {
+		return 0, err
+	}
+
+	size, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	if err := storage.Store.Put(context.Background(), key, file, size, "application/zip"); err != nil {
+		return 0, err
+	}
+
+	return size, nil
+}
+
+// writeAccountArchive writes a zip archive of everything that's a user's:
+// their profile, the todos they created with their history, their comments,
+// workspaces, sessions and attachments, which are included in full
+func writeAccountArchive(w io.Writer, userID uuid.UUID) error {
+	account, err := repository.NewAccountRepository().GetByID(userID)
+	if err != nil {
+		return err
+	}
+	todos, err := repository.NewTodoRepository().ListCreatedBy(userID)
+	if err != nil {
+		return err
+	}
+	history, err := repository.NewTodoEventRepository().GetByUserID(userID)
+	if err != nil {
+		return err
+	}
+	comments, err := repository.NewCommentRepository().GetAllByUserID(userID)
+	if err != nil {
+		return err
+	}
+	workspaces, err := repository.NewWorkspaceRepository().GetAllByUserID(userID)
+	if err != nil {
+		return err
+	}
+	attachments, err := repository.NewAttachmentRepository().GetAllByUserID(userID)
+	if err != nil {
+		return err
+	}
+
+	sessions := models.AccountSessions{}
+	sessions.AppPasswords, err = repository.NewAppPasswordRepository().GetAllByUserID(userID)
+	if err != nil {
+		return err
+	}
+	sessions.SignedOut, err = repository.NewTokenRepository().GetAllByUserID(userID)
+	if err != nil {
+		return err
+	}
+
+	archive := zip.NewWriter(w)
+	files := []struct {
+		name    string
+		content interface{}
+	}{
+		{"profile.json", account},
+		{"todos.json", todos},
+		{"history.json", history},
+		{"comments.json", comments},
+		{"workspaces.json", workspaces},
+		{"sessions.json", sessions},
+		{"attachments.json", attachments},
+	}
+	for _, f := range files {
+		entry, err := archive.Create(f.name)
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(entry)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(f.content); err != nil {
+			return err
+		}
+	}
+
+	// Each attachment goes in a folder named after its ID, since file names
+	// needn't be unique
+	for _, attachment := range attachments {
+		if err := copyAttachment(archive, attachment); err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+// copyAttachment adds an attachment's file to an archive
+func copyAttachment(archive *zip.Writer, attachment *models.Attachment) error {
+	blob, err := storage.Store.Open(context.Background(), attachment.StorageKey, attachment.Size)
+	if err != nil {
+		return err
+	}
+	defer blob.Close()
+
+	entry, err := archive.Create("attachments/" + attachment.ID.String() + "/" + path.Base(attachment.Filename))
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(entry, blob)
+	return err
+}
+
+// cleanupAccountExports deletes every export that has expired, with its archive
+func cleanupAccountExports() {
+	exportRepo := repository.NewAccountExportRepository()
+
+	exports, err := exportRepo.GetExpired(time.Now())
+	if err != nil {
+		log.Printf("Failed to find expired account exports: %v", err)
+		return
+	}
+
+	for _, export := range exports {
+		if export.StorageKey != "" {
+			if err := storage.Store.Delete(context.Background(), export.StorageKey); err != nil {
+				log.Printf("Failed to delete account export %s: %v", export.ID, err)
+				continue
+			}
+		}
+		if err := exportRepo.Delete(export.ID); err != nil {
+			log.Printf("Failed to delete account export %s: %v", export.ID, err)
+		}
+	}
+
+	if len(exports) > 0 {
+		log.Printf("Deleted %d expired account exports", len(exports))
+	}
+}
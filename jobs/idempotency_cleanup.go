@@ -0,0 +1,39 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"github.com/noman/todo-application/middleware"
+	"github.com/noman/todo-application/repository"
+)
+
+// StartIdempotencyKeyCleanup starts a background job that hourly deletes
+// idempotency keys older than IDEMPOTENCY_KEY_TTL
+func StartIdempotencyKeyCleanup() {
+	ttl := middleware.IdempotencyKeyTTL()
+	log.Printf("Keeping idempotent responses for %s", ttl)
+
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+
+		for {
+			cleanupIdempotencyKeys(ttl)
+			<-ticker.C
+		}
+	}()
+}
+
+// cleanupIdempotencyKeys deletes every idempotency key created more than ttl ago
+func cleanupIdempotencyKeys(ttl time.Duration) {
+	deleted, err := repository.NewIdempotencyRepository().DeleteBefore(time.Now().Add(-ttl))
+	if err != nil {
+		log.Printf("Failed to delete expired idempotency keys: %v", err)
+		return
+	}
+
+	if deleted > 0 {
+		log.Printf("Deleted %d expired idempotency keys", deleted)
+	}
+}
@@ -0,0 +1,204 @@
+package jobs
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/noman/todo-application/events"
+	"github.com/noman/todo-application/importers"
+	"github.com/noman/todo-application/models"
+	"github.com/noman/todo-application/repository"
+)
+
+// Import settings
+const (
+	importPollInterval = 2 * time.Second
+	importChunkSize    = 50              // Todos created per transaction
+	importLease        = 2 * time.Minute // How long a claimed job is reserved between chunks
+)
+
+// StartImports starts a background job that runs queued imports
+func StartImports() {
+	go func() {
+		ticker := time.NewTicker(importPollInterval)
+		defer ticker.Stop()
+
+		for {
+			// Keep going while jobs are waiting
+			for runImport() {
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// runImport runs the oldest queued import, and returns whether there was one
+func runImport() bool {
+	importJobRepo := repository.NewImportJobRepository()
+
+	job, err := importJobRepo.Claim(importLease)
+	if err != nil {
+		log.Printf("Failed to claim import job: %v", err)
+		return false
+	}
+	if job == nil {
+		return false
+	}
+
+	failure, err := processImport(job)
+	if err != nil {
+		if errors.Is(err, repository.ErrImportJobNotFound) {
+			// Another worker took over the job, or it was deleted
+			log.Printf("Lost import job %s", job.ID)
+			return true
+		}
+		log.Printf("Failed to run import job %s: %v", job.ID, err)
+		failure = "Import failed because of a server error"
+	}
+
+	job.Error = failure
+	if err := importJobRepo.Finish(job); err != nil {
+		log.Printf("Failed to record import job %s: %v", job.ID, err)
+	}
+
+	return true
+}
+
+// processImport creates a job's todos, a chunk at a time, recording its
+// progress with each chunk so a job that's claimed again resumes after the
+// last one. It returns why the import failed if the file can't be imported.
+func processImport(job *models.ImportJob) (string, error) {
+	items, err := importers.Parse(job.Source, job.Filename, job.Data)
+	if err != nil {
+		return "Couldn't read the file: " + err.Error(), nil
+	}
+
+	// Record the total so clients can show progress
+	job.Total = len(items)
+	if err := repository.NewImportJobRepository().UpdateProgress(job, job.Processed, importLease); err != nil {
+		return "", err
+	}
+
+	// Workspace imports put each project's todos in a workspace project
+	var projects map[string]uuid.UUID
+	if job.WorkspaceID != nil {
+		workspaceRepo := repository.NewWorkspaceRepository()
+		isMember, err := workspaceRepo.IsMember(*job.WorkspaceID, job.UserID)
+		if err != nil {
+			return "", err
+		}
+		if !isMember {
+			return "You're no longer a member of the workspace", nil
+		}
+
+		projects, err = importProjects(workspaceRepo, job, items)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	for start := job.Processed; start < len(items); start += importChunkSize {
+		end := start + importChunkSize
+		if end > len(items) {
+			end = len(items)
+		}
+
+		if err := importChunk(job, items[start:end], projects); err != nil {
+			return "", err
+		}
+	}
+
+	return "", nil
+}
+
+// importChunk creates the todos for a chunk of items and records the
+// progress in the same transaction, which also queues their webhooks. Clients
+// are told about the todos once the chunk commits.
+func importChunk(job *models.ImportJob, items []*importers.Item, projects map[string]uuid.UUID) error {
+	batch, err := repository.BeginBatch()
+	if err != nil {
+		return err
+	}
+	defer batch.Rollback()
+
+	todoRepo := batch.Todos()
+	todos := make([]*models.Todo, 0, len(items))
+	for _, item := range items {
+		title, description := item.Fields(job.WorkspaceID == nil)
+		todo := &models.Todo{
+			Title:       title,
+			Description: description,
+			Completed:   item.Completed,
+			UserID:      job.UserID,
+			WorkspaceID: job.WorkspaceID,
+			Tags:        item.Tags(),
+			Priority:    models.NormalizePriority(item.Priority),
+			DueAt:       item.DueAt,
+		}
+		if projectID, ok := projects[strings.ToLower(item.ProjectName())]; ok {
+			todo.ProjectID = &projectID
+		}
+
+		if err := todoRepo.Create(todo); err != nil {
+			return err
+		}
+		todos = append(todos, todo)
+	}
+
+	if err := batch.ImportJobs().UpdateProgress(job, job.Processed+len(items), importLease); err != nil {
+		return err
+	}
+
+	if err := batch.Commit(); err != nil {
+		return err
+	}
+
+	workspaceRepo := repository.NewWorkspaceRepository()
+	for _, todo := range todos {
+		events.PublishTodo(events.Default, workspaceRepo, events.TypeTodoCreated, todo, job.UserID)
+	}
+	return nil
+}
+
+// importProjects finds the workspace project for each project named by the
+// items, matching names case-insensitively, and creates those that are missing
+func importProjects(workspaceRepo *repository.WorkspaceRepository, job *models.ImportJob, items []*importers.Item) (map[string]uuid.UUID, error) {
+	existing, err := workspaceRepo.GetProjectsByWorkspaceID(*job.WorkspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	projects := make(map[string]uuid.UUID)
+	for _, project := range existing {
+		key := strings.ToLower(project.Name)
+		if _, ok := projects[key]; !ok {
+			projects[key] = project.ID
+		}
+	}
+
+	for _, item := range items {
+		name := item.ProjectName()
+		key := strings.ToLower(name)
+		if name == "" {
+			continue
+		}
+		if _, ok := projects[key]; ok {
+			continue
+		}
+
+		project := &models.Project{
+			WorkspaceID: *job.WorkspaceID,
+			Name:        name,
+			CreatedBy:   job.UserID,
+		}
+		if err := workspaceRepo.CreateProject(project); err != nil {
+			return nil, err
+		}
+		projects[key] = project.ID
+	}
+
+	return projects, nil
+}
@@ -0,0 +1,45 @@
+package jobs
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"github.com/noman/todo-application/repository"
+)
+
+// StartSyncTombstoneCleanup starts a background job that hourly removes
+// deleted todos from the sync change log once they are older than
+// SYNC_TOMBSTONE_TTL. Clients that last synced before then have to sync again
+// from scratch.
+func StartSyncTombstoneCleanup() {
+	ttl, err := time.ParseDuration(os.Getenv("SYNC_TOMBSTONE_TTL"))
+	if err != nil || ttl <= 0 {
+		ttl = 30 * 24 * time.Hour // Default to 30 days if not specified
+	}
+
+	log.Printf("Keeping sync tombstones for %s", ttl)
+
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+
+		for {
+			cleanupSyncTombstones(ttl)
+			<-ticker.C
+		}
+	}()
+}
+
+// cleanupSyncTombstones removes every sync tombstone recorded more than ttl ago
+func cleanupSyncTombstones(ttl time.Duration) {
+	deleted, err := repository.NewSyncRepository().DeleteTombstonesBefore(time.Now().Add(-ttl))
+	if err != nil {
+		log.Printf("Failed to delete expired sync tombstones: %v", err)
+		return
+	}
+
+	if deleted > 0 {
+		log.Printf("Deleted %d expired sync tombstones", deleted)
+	}
+}
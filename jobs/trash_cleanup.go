@@ -0,0 +1,65 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/noman/todo-application/repository"
+	"github.com/noman/todo-application/storage"
+)
+
+// StartTrashCleanup starts a background job that permanently deletes todos
+// that have been in the trash for longer than TRASH_RETENTION
+func StartTrashCleanup() {
+	retention, err := time.ParseDuration(os.Getenv("TRASH_RETENTION"))
+	if err != nil || retention <= 0 {
+		retention = 30 * 24 * time.Hour // Default to 30 days if not specified
+	}
+
+	interval, err := time.ParseDuration(os.Getenv("TRASH_CLEANUP_INTERVAL"))
+	if err != nil || interval <= 0 {
+		interval = time.Hour // Default to hourly if not specified
+	}
+
+	log.Printf("Emptying trash older than %s every %s", retention, interval)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			cleanupTrash(retention)
+			<-ticker.C
+		}
+	}()
+}
+
+// cleanupTrash purges every todo that was deleted more than retention ago
+func cleanupTrash(retention time.Duration) {
+	todoRepo := repository.NewTodoRepository()
+
+	ids, err := todoRepo.GetDeletedBefore(time.Now().Add(-retention))
+	if err != nil {
+		log.Printf("Failed to find expired todos in the trash: %v", err)
+		return
+	}
+
+	purged := 0
+	for _, id := range ids {
+		// Purges done by the system have no actor
+		keys, err := todoRepo.Purge(id, uuid.Nil)
+		if err != nil {
+			log.Printf("Failed to purge todo %s: %v", id, err)
+			continue
+		}
+		storage.DeleteAll(context.Background(), storage.Store, keys)
+		purged++
+	}
+
+	if purged > 0 {
+		log.Printf("Purged %d todos from the trash", purged)
+	}
+}
@@ -0,0 +1,133 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"github.com/noman/todo-application/models"
+	"github.com/noman/todo-application/repository"
+	"github.com/noman/todo-application/webhooks"
+)
+
+// Webhook delivery settings
+const (
+	webhookPollInterval = 5 * time.Second
+	webhookBatchSize    = 20
+
+	// webhookLease is how long a claimed delivery is reserved for its
+	// attempt. Deliveries are sent one after another, so it covers sending
+	// the whole batch with every endpoint timing out, plus time to record the
+	// outcomes; otherwise a delivery could be claimed again and sent twice.
+	webhookLease = webhookBatchSize*webhooks.SendTimeout + time.Minute
+)
+
+// StartWebhookDelivery starts a background job that sends queued webhook
+// deliveries and retries failed ones, and another that hourly deletes
+// delivery logs older than WEBHOOK_LOG_RETENTION
+func StartWebhookDelivery() {
+	retention, err := time.ParseDuration(os.Getenv("WEBHOOK_LOG_RETENTION"))
+	if err != nil || retention <= 0 {
+		retention = 30 * 24 * time.Hour // Default to 30 days if not specified
+	}
+
+	allowPrivate := os.Getenv("WEBHOOK_ALLOW_PRIVATE_NETWORKS") == "true"
+	if allowPrivate {
+		log.Println("Webhooks may be delivered to private networks")
+	}
+	sender := webhooks.NewSender(allowPrivate)
+
+	log.Printf("Keeping webhook delivery logs for %s", retention)
+
+	go func() {
+		ticker := time.NewTicker(webhookPollInterval)
+		defer ticker.Stop()
+
+		for {
+			// Keep going while there's a backlog
+			for deliverWebhooks(sender) == webhookBatchSize {
+			}
+			<-ticker.C
+		}
+	}()
+
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+
+		for {
+			cleanupWebhookDeliveries(retention)
+			<-ticker.C
+		}
+	}()
+}
+
+// deliverWebhooks makes an attempt at every due delivery, up to a batch, and
+// returns how many it attempted
+func deliverWebhooks(sender *webhooks.Sender) int {
+	webhookRepo := repository.NewWebhookRepository()
+
+	dispatches, err := webhookRepo.ClaimDeliveries(webhookBatchSize, webhookLease)
+	if err != nil {
+		log.Printf("Failed to claim webhook deliveries: %v", err)
+		return 0
+	}
+
+	for _, dispatch := range dispatches {
+		deliverWebhook(webhookRepo, sender, dispatch)
+	}
+
+	return len(dispatches)
+}
+
+// deliverWebhook makes one attempt at a delivery and records the outcome
+func deliverWebhook(webhookRepo *repository.WebhookRepository, sender *webhooks.Sender, dispatch *models.WebhookDispatch) {
+	delivery := &dispatch.Delivery
+	status, sendErr := sender.Send(context.Background(), dispatch.URL, dispatch.Secret, delivery.ID.String(), delivery.EventType, delivery.Payload)
+	if sendErr == nil {
+		if err := webhookRepo.RecordSuccess(delivery, status); err != nil {
+			log.Printf("Failed to record webhook delivery %s: %v", delivery.ID, err)
+		}
+		return
+	}
+
+	// Retry with exponential backoff until the attempts run out
+	var retryAt *time.Time
+	if attempts := delivery.Attempts + 1; attempts < webhooks.MaxAttempts {
+		next := time.Now().Add(webhooks.RetryDelay(attempts))
+		retryAt = &next
+	}
+
+	disabled, err := webhookRepo.RecordFailure(delivery, status, sendErr.Error(), retryAt, webhooks.DisableAfter)
+	if err != nil {
+		log.Printf("Failed to record webhook delivery %s: %v", delivery.ID, err)
+		return
+	}
+
+	if disabled {
+		log.Printf("Disabled webhook %s after %d failed attempts in a row", delivery.WebhookID, webhooks.DisableAfter)
+		notification := &models.Notification{
+			UserID:  dispatch.UserID,
+			Type:    models.NotificationTypeWebhookDisabled,
+			ActorID: dispatch.UserID,
+			Message: "Your webhook to " + dispatch.URL + " was disabled after repeated delivery failures",
+		}
+		if err := repository.NewNotificationRepository().Create(notification); err != nil {
+			log.Printf("Failed to notify about disabled webhook %s: %v", delivery.WebhookID, err)
+		}
+	}
+}
+
+// cleanupWebhookDeliveries deletes every finished delivery older than retention
+func cleanupWebhookDeliveries(retention time.Duration) {
+	deleted, err := repository.NewWebhookRepository().DeleteDeliveriesBefore(time.Now().Add(-retention))
+	if err != nil {
+		log.Printf("Failed to delete old webhook deliveries: %v", err)
+		return
+	}
+
+	if deleted > 0 {
+		log.Printf("Deleted %d old webhook deliveries", deleted)
+	}
+}
@@ -9,7 +9,12 @@ import (
 	"github.com/joho/godotenv"
 	"github.com/noman/todo-application/controllers"
 	"github.com/noman/todo-application/database"
+	"github.com/noman/todo-application/events"
+	"github.com/noman/todo-application/jobs"
 	"github.com/noman/todo-application/middleware"
+	"github.com/noman/todo-application/openapi"
+	"github.com/noman/todo-application/problem"
+	"github.com/noman/todo-application/storage"
 )
 
 func main() {
@@ -21,46 +26,211 @@ func main() {
 	// Initialize database
 	database.InitDB()
 
+	// Initialize attachment storage
+	storage.InitBlobStore()
+
+	// Initialize the real-time event broker
+	events.InitBroker()
+
+	// Start background jobs
+	jobs.StartTrashCleanup()
+	jobs.StartIdempotencyKeyCleanup()
+	jobs.StartSyncTombstoneCleanup()
+	jobs.StartWebhookDelivery()
+	jobs.StartImports()
+	jobs.StartAccountExports()
+	jobs.StartAccountDeletion()
+
+	// Initialize router
+	router := newRouter()
+
+	// Make sure every route is in the API documentation
+	if err := openapi.Verify(router); err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	// Get server port from environment variable
+	port := os.Getenv("SERVER_PORT")
+	if port == "" {
+		port = "8080" // Default port if not specified
+	}
+
+	// Initialize server
+	server := &http.Server{
+		Addr:    ":" + port,
+		Handler: middleware.RequestIDMiddleware(router),
+	}
+
+	// Start server
+	log.Printf("Server is running on port %s", port)
+	if err := server.ListenAndServe(); err != nil {
+		log.Fatal("Server failed to start:", err)
+	}
+}
+
+// newRouter registers every route. It needs no database, so tests can check
+// the routes against the API documentation.
+func newRouter() *mux.Router {
 	// Initialize controllers
 	authController := controllers.NewAuthController()
+	accountController := controllers.NewAccountController()
 	todoController := controllers.NewTodoController()
+	workspaceController := controllers.NewWorkspaceController()
+	commentController := controllers.NewCommentController()
+	notificationController := controllers.NewNotificationController()
+	attachmentController := controllers.NewAttachmentController()
+	trashController := controllers.NewTrashController()
+	eventController := controllers.NewEventController()
+	webhookController := controllers.NewWebhookController()
+	calendarController := controllers.NewCalendarController()
+	appPasswordController := controllers.NewAppPasswordController()
+	importJobController := controllers.NewImportJobController()
 
-	// Initialize router
 	router := mux.NewRouter()
+	router.NotFoundHandler = problem.NotFoundHandler()
+	router.MethodNotAllowedHandler = problem.MethodNotAllowedHandler()
 
 	// Public routes
 	router.HandleFunc("/api/auth/register", authController.Register).Methods("POST")
 	router.HandleFunc("/api/auth/login", authController.Login).Methods("POST")
-	
+	router.Handle("/api/openapi.json", openapi.Handler()).Methods("GET")
+	router.Handle("/api/docs", openapi.DocsHandler()).Methods("GET")
+
+	// The documentation page's scripts and styles aren't API routes, so they
+	// are listed in openapi.OtherRoutes instead of the documentation
+	router.PathPrefix("/api/docs/").Handler(openapi.DocsAssetsHandler()).Methods("GET", "HEAD")
+
+	// Calendar feeds are authenticated by the secret token in their URL, since
+	// calendar apps can't send a bearer token
+	router.HandleFunc("/api/calendar/{token}.ics", calendarController.Feed).Methods("GET")
+
+	// CalDAV clients sign in with an app password, and use methods like
+	// PROPFIND that the API documentation doesn't cover, so its routes are
+	// listed in openapi.OtherRoutes instead of the documentation
+	caldav := middleware.AppPasswordMiddleware(http.HandlerFunc(todoController.CalDAV))
+	router.Handle("/.well-known/caldav", http.RedirectHandler(controllers.CalDAVRoot, http.StatusMovedPermanently)).Methods(controllers.CalDAVMethods...)
+	router.Handle("/caldav", caldav).Methods(controllers.CalDAVMethods...)
+	router.PathPrefix(controllers.CalDAVRoot).Handler(caldav).Methods(controllers.CalDAVMethods...)
+
 	// Protected auth routes
 	authRouter := router.PathPrefix("/api/auth").Subrouter()
 	authRouter.Use(middleware.AuthMiddleware)
+	authRouter.Use(middleware.IdempotencyMiddleware)
 	authRouter.HandleFunc("/logout", authController.Logout).Methods("POST")
 
+	accountRouter := router.PathPrefix("/api/users/me").Subrouter()
+	accountRouter.Use(middleware.AuthMiddleware)
+	accountRouter.Use(middleware.IdempotencyMiddleware)
+	accountRouter.HandleFunc("", accountController.Get).Methods("GET")
+	accountRouter.HandleFunc("", accountController.Delete).Methods("DELETE")
+	accountRouter.HandleFunc("/restore", accountController.Restore).Methods("POST")
+	accountRouter.HandleFunc("/export", accountController.CreateExport).Methods("POST")
+	accountRouter.HandleFunc("/export", accountController.GetExport).Methods("GET")
+	accountRouter.HandleFunc("/export/archive", accountController.DownloadExport).Methods("GET")
+
 	// Protected routes
+	// The iCalendar export can't go on the todo subrouter, whose paths have to
+	// start with a slash
+	router.Handle("/api/todos.ics", middleware.AuthMiddleware(http.HandlerFunc(calendarController.Export))).Methods("GET")
+
+	router.Handle("/api/export", middleware.AuthMiddleware(http.HandlerFunc(todoController.Export))).Methods("GET")
+	router.Handle("/api/import", middleware.AuthMiddleware(middleware.IdempotencyMiddleware(http.HandlerFunc(todoController.Import)))).Methods("POST")
+
+	importJobRouter := router.PathPrefix("/api/import/jobs").Subrouter()
+	importJobRouter.Use(middleware.AuthMiddleware)
+	importJobRouter.Use(middleware.IdempotencyMiddleware)
+	importJobRouter.HandleFunc("", importJobController.GetAll).Methods("GET")
+	importJobRouter.HandleFunc("", importJobController.Create).Methods("POST")
+	importJobRouter.HandleFunc("/{id}", importJobController.Get).Methods("GET")
+
 	todoRouter := router.PathPrefix("/api/todos").Subrouter()
 	todoRouter.Use(middleware.AuthMiddleware)
+	todoRouter.Use(middleware.IdempotencyMiddleware)
 	todoRouter.HandleFunc("", todoController.Create).Methods("POST")
 	todoRouter.HandleFunc("", todoController.GetAll).Methods("GET")
+	todoRouter.HandleFunc("/batch", todoController.Batch).Methods("POST")
+	todoRouter.HandleFunc("/search", todoController.Search).Methods("GET")
 	todoRouter.HandleFunc("/{id}", todoController.GetByID).Methods("GET")
 	todoRouter.HandleFunc("/{id}", todoController.Update).Methods("PUT")
+	todoRouter.HandleFunc("/{id}", todoController.Patch).Methods("PATCH")
 	todoRouter.HandleFunc("/{id}", todoController.Delete).Methods("DELETE")
+	todoRouter.HandleFunc("/{id}/assignees", todoController.Assign).Methods("PUT")
+	todoRouter.HandleFunc("/{id}/history", todoController.History).Methods("GET")
+	todoRouter.HandleFunc("/{id}/history/{revision}/restore", todoController.RestoreRevision).Methods("POST")
+	todoRouter.HandleFunc("/{id}/restore", trashController.Restore).Methods("POST")
+	todoRouter.HandleFunc("/{id}/comments", commentController.GetAll).Methods("GET")
+	todoRouter.HandleFunc("/{id}/comments", commentController.Create).Methods("POST")
+	todoRouter.HandleFunc("/{id}/comments/{commentId}", commentController.Update).Methods("PUT")
+	todoRouter.HandleFunc("/{id}/comments/{commentId}", commentController.Delete).Methods("DELETE")
+	todoRouter.HandleFunc("/{id}/attachments", attachmentController.GetAll).Methods("GET")
+	todoRouter.HandleFunc("/{id}/attachments", attachmentController.Upload).Methods("POST")
+	todoRouter.HandleFunc("/{id}/attachments/{attachmentId}", attachmentController.Download).Methods("GET")
+	todoRouter.HandleFunc("/{id}/attachments/{attachmentId}", attachmentController.Delete).Methods("DELETE")
 
-	// Get server port from environment variable
-	port := os.Getenv("SERVER_PORT")
-	if port == "" {
-		port = "8080" // Default port if not specified
-	}
+	workspaceRouter := router.PathPrefix("/api/workspaces").Subrouter()
+	workspaceRouter.Use(middleware.AuthMiddleware)
+	workspaceRouter.Use(middleware.IdempotencyMiddleware)
+	workspaceRouter.HandleFunc("", workspaceController.Create).Methods("POST")
+	workspaceRouter.HandleFunc("", workspaceController.GetAll).Methods("GET")
+	workspaceRouter.HandleFunc("/{id}/members", workspaceController.GetMembers).Methods("GET")
+	workspaceRouter.HandleFunc("/{id}/members", workspaceController.AddMember).Methods("POST")
+	workspaceRouter.HandleFunc("/{id}/members/{userId}", workspaceController.RemoveMember).Methods("DELETE")
+	workspaceRouter.HandleFunc("/{id}/projects", workspaceController.CreateProject).Methods("POST")
+	workspaceRouter.HandleFunc("/{id}/projects", workspaceController.GetProjects).Methods("GET")
 
-	// Initialize server
-	server := &http.Server{
-		Addr:    ":" + port,
-		Handler: router,
-	}
+	notificationRouter := router.PathPrefix("/api/notifications").Subrouter()
+	notificationRouter.Use(middleware.AuthMiddleware)
+	notificationRouter.Use(middleware.IdempotencyMiddleware)
+	notificationRouter.HandleFunc("", notificationController.GetAll).Methods("GET")
+	notificationRouter.HandleFunc("/read", notificationController.MarkAllRead).Methods("POST")
+	notificationRouter.HandleFunc("/{id}/read", notificationController.MarkRead).Methods("POST")
 
-	// Start server
-	log.Printf("Server is running on port %s", port)
-	if err := server.ListenAndServe(); err != nil {
-		log.Fatal("Server failed to start:", err)
-	}
+	trashRouter := router.PathPrefix("/api/trash").Subrouter()
+	trashRouter.Use(middleware.AuthMiddleware)
+	trashRouter.Use(middleware.IdempotencyMiddleware)
+	trashRouter.HandleFunc("", trashController.GetAll).Methods("GET")
+	trashRouter.HandleFunc("", trashController.Empty).Methods("DELETE")
+	trashRouter.HandleFunc("/{id}", trashController.Purge).Methods("DELETE")
+
+	webhookRouter := router.PathPrefix("/api/webhooks").Subrouter()
+	webhookRouter.Use(middleware.AuthMiddleware)
+	webhookRouter.Use(middleware.IdempotencyMiddleware)
+	webhookRouter.HandleFunc("", webhookController.GetAll).Methods("GET")
+	webhookRouter.HandleFunc("", webhookController.Create).Methods("POST")
+	webhookRouter.HandleFunc("/{id}", webhookController.Get).Methods("GET")
+	webhookRouter.HandleFunc("/{id}", webhookController.Update).Methods("PUT")
+	webhookRouter.HandleFunc("/{id}", webhookController.Delete).Methods("DELETE")
+	webhookRouter.HandleFunc("/{id}/deliveries", webhookController.GetDeliveries).Methods("GET")
+
+	calendarRouter := router.PathPrefix("/api/calendar").Subrouter()
+	calendarRouter.Use(middleware.AuthMiddleware)
+	calendarRouter.Use(middleware.IdempotencyMiddleware)
+	calendarRouter.HandleFunc("/feed", calendarController.GetFeed).Methods("GET")
+	calendarRouter.HandleFunc("/feed", calendarController.CreateFeed).Methods("POST")
+	calendarRouter.HandleFunc("/feed", calendarController.DeleteFeed).Methods("DELETE")
+
+	appPasswordRouter := router.PathPrefix("/api/app-passwords").Subrouter()
+	appPasswordRouter.Use(middleware.AuthMiddleware)
+	appPasswordRouter.Use(middleware.IdempotencyMiddleware)
+	appPasswordRouter.HandleFunc("", appPasswordController.GetAll).Methods("GET")
+	appPasswordRouter.HandleFunc("", appPasswordController.Create).Methods("POST")
+	appPasswordRouter.HandleFunc("/{id}", appPasswordController.Delete).Methods("DELETE")
+
+	syncRouter := router.PathPrefix("/api/sync").Subrouter()
+	syncRouter.Use(middleware.AuthMiddleware)
+	syncRouter.Use(middleware.IdempotencyMiddleware)
+	syncRouter.HandleFunc("", todoController.Changes).Methods("GET")
+	syncRouter.HandleFunc("", todoController.PushChanges).Methods("POST")
+
+	// The event stream also takes a stream ticket as ?ticket=, since browsers
+	// can't set headers on EventSource requests
+	eventRouter := router.PathPrefix("/api/events").Subrouter()
+	eventRouter.Handle("", middleware.StreamAuthMiddleware(http.HandlerFunc(eventController.Stream))).Methods("GET")
+	eventRouter.Handle("/tickets", middleware.AuthMiddleware(middleware.IdempotencyMiddleware(http.HandlerFunc(eventController.CreateTicket)))).Methods("POST")
+
+	storageRouter := router.PathPrefix("/api/storage").Subrouter()
+	storageRouter.Use(middleware.AuthMiddleware)
+	storageRouter.HandleFunc("/usage", attachmentController.Usage).Methods("GET")
+
+	return router
 }
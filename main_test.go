@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/noman/todo-application/openapi"
+)
+
+func TestRoutesAreDocumented(t *testing.T) {
+	if err := openapi.Verify(newRouter()); err != nil {
+		t.Fatal(err)
+	}
+
+	// A route that takes every method can't be documented
+	router := newRouter()
+	router.Handle("/api/undocumented", http.NotFoundHandler())
+	if err := openapi.Verify(router); err == nil || !strings.Contains(err.Error(), "* /api/undocumented") {
+		t.Errorf("got %v for a route without methods", err)
+	}
+}
+
+func TestCalDAVRoutes(t *testing.T) {
+	router := newRouter()
+
+	tests := []struct {
+		method, path string
+		status       int
+	}{
+		{"PROPFIND", "/.well-known/caldav", http.StatusMovedPermanently},
+		{"PROPFIND", "/caldav", http.StatusUnauthorized},
+		{"PROPFIND", "/caldav/", http.StatusUnauthorized},
+		{"GET", "/caldav/user/todos/todo.ics", http.StatusUnauthorized},
+		{"PROPFIND", "/caldavfoo", http.StatusNotFound},
+		{"POST", "/caldav/", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+		if w.Code != tt.status {
+			t.Errorf("%s %s: got status %d, want %d", tt.method, tt.path, w.Code, tt.status)
+		}
+	}
+}
+
+func TestDocsAreServedWithoutExternalAssets(t *testing.T) {
+	router := newRouter()
+
+	tests := []struct {
+		method, path string
+		status       int
+		contentType  string
+	}{
+		{"GET", "/api/docs", http.StatusOK, "text/html; charset=utf-8"},
+		{"GET", "/api/docs/docs.js", http.StatusOK, "text/javascript; charset=utf-8"},
+		{"GET", "/api/docs/docs.css", http.StatusOK, "text/css; charset=utf-8"},
+		{"GET", "/api/docs/missing.js", http.StatusNotFound, ""},
+		{"POST", "/api/docs/docs.js", http.StatusMethodNotAllowed, ""},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+		if w.Code != tt.status {
+			t.Errorf("%s %s: got status %d, want %d", tt.method, tt.path, w.Code, tt.status)
+			continue
+		}
+		if tt.status != http.StatusOK {
+			continue
+		}
+		if got := w.Header().Get("Content-Type"); got != tt.contentType {
+			t.Errorf("%s %s: got Content-Type %q, want %q", tt.method, tt.path, got, tt.contentType)
+		}
+		if got := w.Header().Get("Content-Security-Policy"); got == "" {
+			t.Errorf("%s %s: missing Content-Security-Policy", tt.method, tt.path)
+		}
+	}
+}
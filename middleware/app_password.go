@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/noman/todo-application/problem"
+	"github.com/noman/todo-application/repository"
+)
+
+// AppPasswordMiddleware authenticates requests with HTTP Basic credentials:
+// the user's email address and one of their app passwords. It's for clients,
+// such as CalDAV apps, that can't use bearer tokens.
+func AppPasswordMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Ask for credentials if there are none
+		email, password, ok := r.BasicAuth()
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Basic realm="todo-application", charset="UTF-8"`)
+			problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Authorization header is required")
+			return
+		}
+
+		// Check the app password
+		appPasswordRepo := repository.NewAppPasswordRepository()
+		userID, err := appPasswordRepo.Authenticate(email, password)
+		if err != nil {
+			if !errors.Is(err, repository.ErrInvalidPassword) {
+				problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to check app password")
+				return
+			}
+			w.Header().Set("WWW-Authenticate", `Basic realm="todo-application", charset="UTF-8"`)
+			problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Invalid email or app password")
+			return
+		}
+
+		// Add the user ID to the request context
+		ctx := context.WithValue(r.Context(), "userID", userID)
+
+		// Call the next handler with the updated context
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
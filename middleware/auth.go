@@ -11,6 +11,7 @@ import (
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"github.com/noman/todo-application/problem"
 	"github.com/noman/todo-application/repository"
 )
 
@@ -49,6 +50,10 @@ func GenerateToken(userID uuid.UUID) (string, error) {
 	return tokenString, nil
 }
 
+// ErrInvalidToken is returned by ValidateToken for tokens that don't
+// authenticate anyone; other errors are failures to check the token
+var ErrInvalidToken = errors.New("invalid token")
+
 // ValidateToken validates a JWT token
 func ValidateToken(tokenString string) (*Claims, error) {
 	// Parse the token
@@ -63,7 +68,7 @@ func ValidateToken(tokenString string) (*Claims, error) {
 	})
 
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
 	}
 
 	// Validate the token
@@ -75,12 +80,21 @@ func ValidateToken(tokenString string) (*Claims, error) {
 			return nil, err
 		}
 		if blacklisted {
-			return nil, errors.New("token is blacklisted")
+			return nil, fmt.Errorf("%w: token is blacklisted", ErrInvalidToken)
+		}
+
+		// Tokens stop working when their user's account is deleted
+		userRepo := repository.NewUserRepository()
+		if _, err := userRepo.GetByID(claims.UserID); err != nil {
+			if errors.Is(err, repository.ErrUserNotFound) {
+				return nil, fmt.Errorf("%w: user no longer exists", ErrInvalidToken)
+			}
+			return nil, err
 		}
 		return claims, nil
 	}
 
-	return nil, errors.New("invalid token")
+	return nil, ErrInvalidToken
 }
 
 // AuthMiddleware is a middleware that validates JWT tokens
@@ -89,13 +103,13 @@ func AuthMiddleware(next http.Handler) http.Handler {
 		// Get the Authorization header
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
-			http.Error(w, "Authorization header is required", http.StatusUnauthorized)
+			problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Authorization header is required")
 			return
 		}
 
 		// Check if the Authorization header has the Bearer prefix
 		if !strings.HasPrefix(authHeader, "Bearer ") {
-			http.Error(w, "Invalid authorization format", http.StatusUnauthorized)
+			problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Invalid authorization format")
 			return
 		}
 
@@ -105,12 +119,17 @@ func AuthMiddleware(next http.Handler) http.Handler {
 		// Validate the token
 		claims, err := ValidateToken(tokenString)
 		if err != nil {
-			http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
+			if errors.Is(err, ErrInvalidToken) {
+				problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Invalid or expired token")
+				return
+			}
+			problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to validate token")
 			return
 		}
 
-		// Add the user ID to the request context
+		// Add the user ID and token to the request context
 		ctx := context.WithValue(r.Context(), "userID", claims.UserID)
+		ctx = context.WithValue(ctx, "token", tokenString)
 		
 		// Call the next handler with the updated context
 		next.ServeHTTP(w, r.WithContext(ctx))
@@ -124,4 +143,13 @@ func GetUserIDFromContext(r *http.Request) (uuid.UUID, error) {
 		return uuid.Nil, errors.New("user ID not found in context")
 	}
 	return userID, nil
+}
+
+// GetTokenFromContext extracts the bearer token from the request context
+func GetTokenFromContext(r *http.Request) (string, error) {
+	token, ok := r.Context().Value("token").(string)
+	if !ok {
+		return "", errors.New("token not found in context")
+	}
+	return token, nil
 }
\ No newline at end of file
@@ -0,0 +1,231 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/noman/todo-application/models"
+	"github.com/noman/todo-application/problem"
+	"github.com/noman/todo-application/repository"
+)
+
+// IdempotencyKeyHeader is the request header carrying a client's idempotency key
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// maxIdempotencyKeyLength is the longest accepted idempotency key
+const maxIdempotencyKeyLength = 255
+
+// Bodies of requests with an idempotency key are read before the handler
+// runs, to fingerprint them: small ones are kept in memory and larger ones,
+// like attachment uploads, in a temporary file
+const (
+	maxIdempotentBodyInMemory = 1 << 20
+	maxIdempotentBodySize     = 128 << 20
+)
+
+// IdempotencyKeyTTL returns how long responses are kept for replay, set with
+// IDEMPOTENCY_KEY_TTL
+func IdempotencyKeyTTL() time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv("IDEMPOTENCY_KEY_TTL"))
+	if err != nil || ttl <= 0 {
+		ttl = 24 * time.Hour // Default to 24 hours if not specified
+	}
+	return ttl
+}
+
+// responseRecorder passes a response through while keeping a copy of it
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+// WriteHeader records the status code
+func (rec *responseRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the body
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+// IdempotencyMiddleware makes POST, PUT, PATCH and DELETE requests that carry
+// an Idempotency-Key header safe to retry: the first response for a key is
+// stored and replayed for later requests with the same key and body. It must
+// run after AuthMiddleware, since keys are scoped to the user.
+func IdempotencyMiddleware(next http.Handler) http.Handler {
+	idempotencyRepo := repository.NewIdempotencyRepository()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		switch r.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			key = ""
+		}
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			problem.Error(w, http.StatusBadRequest, problem.CodeInvalidRequest, "Idempotency key is too long")
+			return
+		}
+
+		// Get the user ID from the context
+		userID, err := GetUserIDFromContext(r)
+		if err != nil {
+			problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		// Fingerprint the request so a key can't be reused for a different one
+		hash := sha256.New()
+		io.WriteString(hash, r.Method+" "+r.URL.Path+"?"+r.URL.RawQuery+"\n")
+		cleanup, err := spoolBody(w, r, hash)
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				problem.Error(w, http.StatusRequestEntityTooLarge, problem.CodePayloadTooLarge, "Request body is too large to send with an idempotency key")
+				return
+			}
+			problem.Error(w, http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid request payload")
+			return
+		}
+		defer cleanup()
+		fingerprint := hex.EncodeToString(hash.Sum(nil))
+
+		// Claim the key, or replay the response stored for it
+		record, err := claimIdempotencyKey(idempotencyRepo, userID, key, fingerprint)
+		if err != nil {
+			problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to check idempotency key")
+			return
+		}
+		if record != nil {
+			if record.Fingerprint != fingerprint {
+				problem.Error(w, http.StatusUnprocessableEntity, problem.CodeIdempotencyKeyReused, "Idempotency key was used for a different request")
+				return
+			}
+			if record.Status == 0 {
+				problem.Error(w, http.StatusConflict, problem.CodeRequestInProgress, "A request with this idempotency key is in progress")
+				return
+			}
+
+			for name, values := range record.Headers {
+				if name != problem.RequestIDHeader {
+					w.Header()[name] = values
+				}
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(record.Status)
+			w.Write(record.Body)
+			return
+		}
+
+		// Release the key unless a response is stored for it, so the request
+		// can be retried rather than being reported in progress until the
+		// key expires: when the handler panics, fails with a server error,
+		// or its response can't be stored
+		saved := false
+		defer func() {
+			if !saved {
+				if err := idempotencyRepo.Delete(userID, key); err != nil {
+					log.Printf("Failed to release idempotency key: %v", err)
+				}
+			}
+		}()
+
+		rec := &responseRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		if rec.status >= http.StatusInternalServerError {
+			return
+		}
+
+		if err := idempotencyRepo.SaveResponse(userID, key, rec.status, w.Header(), rec.body.Bytes()); err != nil {
+			log.Printf("Failed to store idempotent response: %v", err)
+			return
+		}
+		saved = true
+	})
+}
+
+// maxIdempotencyKeyClaims is how many times a request tries to claim a key
+// that keeps being released by the request holding it
+const maxIdempotencyKeyClaims = 3
+
+// claimIdempotencyKey reserves a key for a request, returning nil, or returns
+// the key's record when another request holds it. A key released between the
+// two steps, because the request holding it failed, is claimed again.
+func claimIdempotencyKey(idempotencyRepo *repository.IdempotencyRepository, userID uuid.UUID, key, fingerprint string) (*models.IdempotencyKey, error) {
+	for attempt := 1; ; attempt++ {
+		reserved, err := idempotencyRepo.Reserve(userID, key, fingerprint, time.Now().Add(-IdempotencyKeyTTL()))
+		if err != nil || reserved {
+			return nil, err
+		}
+
+		record, err := idempotencyRepo.Get(userID, key)
+		if errors.Is(err, repository.ErrIdempotencyKeyNotFound) && attempt < maxIdempotencyKeyClaims {
+			continue
+		}
+		return record, err
+	}
+}
+
+// spoolBody reads a request's body into memory or a temporary file, writing
+// it to hash as well, and replaces the body with the copy so the handler can
+// still read it. Bodies over maxIdempotentBodySize fail with an
+// *http.MaxBytesError. The returned function removes the temporary file.
+func spoolBody(w http.ResponseWriter, r *http.Request, hash io.Writer) (func(), error) {
+	src := io.TeeReader(http.MaxBytesReader(w, r.Body, maxIdempotentBodySize), hash)
+
+	head := &bytes.Buffer{}
+	n, err := io.Copy(head, io.LimitReader(src, maxIdempotentBodyInMemory+1))
+	if err != nil {
+		return nil, err
+	}
+	if n <= maxIdempotentBodyInMemory {
+		r.Body = io.NopCloser(head)
+		return func() {}, nil
+	}
+
+	tmp, err := os.CreateTemp("", "idempotent-body-*")
+	if err != nil {
+		return nil, err
+	}
+	cleanup := func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}
+
+	_, err = io.Copy(tmp, io.MultiReader(head, src))
+	if err == nil {
+		_, err = tmp.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+
+	r.Body = io.NopCloser(tmp)
+	return cleanup, nil
+}
@@ -0,0 +1,243 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/noman/todo-application/database/databasetest"
+	"github.com/noman/todo-application/problem"
+)
+
+// idempotentRequest is a request as AuthMiddleware passes it on, with an
+// idempotency key if key isn't empty
+func idempotentRequest(method, target, body, key string, userID uuid.UUID) *http.Request {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	if key != "" {
+		r.Header.Set(IdempotencyKeyHeader, key)
+	}
+	if userID != uuid.Nil {
+		r = r.WithContext(context.WithValue(r.Context(), "userID", userID))
+	}
+	return r
+}
+
+// countingHandler creates todos, answering with the request body and how
+// many requests it has handled
+func countingHandler(calls *atomic.Int32) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set(problem.RequestIDHeader, "original")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{"call": n, "body": string(body)})
+	})
+}
+
+// problemCode returns the code of a problem response
+func problemCode(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	var p problem.Problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+		t.Fatalf("response isn't a problem: %s", rec.Body.String())
+	}
+	return p.Code
+}
+
+func TestIdempotencyMiddlewarePassesThrough(t *testing.T) {
+	var calls atomic.Int32
+	handler := IdempotencyMiddleware(countingHandler(&calls))
+
+	// Requests without a key, and reads, aren't stored
+	for _, r := range []*http.Request{
+		idempotentRequest(http.MethodPost, "/api/todos", `{}`, "", uuid.Nil),
+		idempotentRequest(http.MethodGet, "/api/todos", "", "key-1", uuid.Nil),
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		if rec.Code != http.StatusCreated {
+			t.Errorf("%s without a stored key: status %d", r.Method, rec.Code)
+		}
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("handler called %d times, want 2", n)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, idempotentRequest(http.MethodPost, "/api/todos", `{}`, strings.Repeat("k", 256), uuid.New()))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("key too long: status %d, want 400", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, idempotentRequest(http.MethodPost, "/api/todos", `{}`, "key-1", uuid.Nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("key without a user: status %d, want 401", rec.Code)
+	}
+}
+
+func TestSpoolBody(t *testing.T) {
+	for _, size := range []int{0, 10, maxIdempotentBodyInMemory, maxIdempotentBodyInMemory + 1, 3 * maxIdempotentBodyInMemory} {
+		body := bytes.Repeat([]byte("a"), size)
+		r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		hash := sha256.New()
+
+		cleanup, err := spoolBody(httptest.NewRecorder(), r, hash)
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		got, err := io.ReadAll(r.Body)
+		cleanup()
+		if err != nil || !bytes.Equal(got, body) {
+			t.Errorf("size %d: handler reads %d bytes, %v", size, len(got), err)
+		}
+		if want := sha256.Sum256(body); !bytes.Equal(hash.Sum(nil), want[:]) {
+			t.Errorf("size %d: hash doesn't match the body", size)
+		}
+	}
+}
+
+func TestIdempotencyMiddlewareReplays(t *testing.T) {
+	databasetest.Open(t)
+	user := databasetest.NewUser(t)
+	var calls atomic.Int32
+	handler := IdempotencyMiddleware(countingHandler(&calls))
+	key := uuid.NewString()
+
+	first := httptest.NewRecorder()
+	handler.ServeHTTP(first, idempotentRequest(http.MethodPost, "/api/todos", `{"title":"a"}`, key, user.ID))
+	if first.Code != http.StatusCreated || first.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("first request: status %d, headers %v", first.Code, first.Header())
+	}
+
+	// The same request gets the stored response without running again
+	replay := httptest.NewRecorder()
+	replay.Header().Set(problem.RequestIDHeader, "retry")
+	handler.ServeHTTP(replay, idempotentRequest(http.MethodPost, "/api/todos", `{"title":"a"}`, key, user.ID))
+	if replay.Code != http.StatusCreated || replay.Body.String() != first.Body.String() {
+		t.Errorf("replay: status %d, body %s; want %d, %s", replay.Code, replay.Body.String(), first.Code, first.Body.String())
+	}
+	if replay.Header().Get("Idempotent-Replayed") != "true" || replay.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("replay headers = %v", replay.Header())
+	}
+	if got := replay.Header().Get(problem.RequestIDHeader); got != "retry" {
+		t.Errorf("replay has request ID %q, want the retry's own", got)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("handler called %d times, want 1", n)
+	}
+
+	// Keys are scoped to the user
+	other := databasetest.NewUser(t)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, idempotentRequest(http.MethodPost, "/api/todos", `{"title":"a"}`, key, other.ID))
+	if rec.Code != http.StatusCreated || rec.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("another user's request: status %d, headers %v", rec.Code, rec.Header())
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("handler called %d times, want 2", n)
+	}
+}
+
+func TestIdempotencyMiddlewareRejectsADifferentRequest(t *testing.T) {
+	databasetest.Open(t)
+	user := databasetest.NewUser(t)
+	var calls atomic.Int32
+	handler := IdempotencyMiddleware(countingHandler(&calls))
+	key := uuid.NewString()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, idempotentRequest(http.MethodPost, "/api/todos", `{"title":"a"}`, key, user.ID))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("first request: status %d", rec.Code)
+	}
+
+	for _, r := range []*http.Request{
+		idempotentRequest(http.MethodPost, "/api/todos", `{"title":"b"}`, key, user.ID),
+		idempotentRequest(http.MethodPost, "/api/todos?workspace_id=x", `{"title":"a"}`, key, user.ID),
+		idempotentRequest(http.MethodPut, "/api/todos", `{"title":"a"}`, key, user.ID),
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		if rec.Code != http.StatusUnprocessableEntity || problemCode(t, rec) != problem.CodeIdempotencyKeyReused {
+			t.Errorf("%s %s with a used key: status %d, body %s", r.Method, r.URL, rec.Code, rec.Body.String())
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("handler called %d times, want 1", n)
+	}
+}
+
+func TestIdempotencyMiddlewareReleasesFailedRequests(t *testing.T) {
+	databasetest.Open(t)
+	user := databasetest.NewUser(t)
+	key := uuid.NewString()
+
+	// Server errors aren't stored, so the request can be retried
+	var calls atomic.Int32
+	handler := IdempotencyMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	for _, want := range []int{http.StatusInternalServerError, http.StatusNoContent, http.StatusNoContent} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, idempotentRequest(http.MethodDelete, "/api/todos/1", "", key, user.ID))
+		if rec.Code != want {
+			t.Errorf("status %d, want %d", rec.Code, want)
+		}
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("handler called %d times, want 2", n)
+	}
+
+	// A panicking handler releases the key too
+	key = uuid.NewString()
+	panicking := IdempotencyMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+	func() {
+		defer func() { recover() }()
+		panicking.ServeHTTP(httptest.NewRecorder(), idempotentRequest(http.MethodPost, "/api/todos", `{}`, key, user.ID))
+	}()
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, idempotentRequest(http.MethodPost, "/api/todos", `{}`, key, user.ID))
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("retry after a panic: status %d, want 204", rec.Code)
+	}
+}
+
+func TestIdempotencyMiddlewareRequestInProgress(t *testing.T) {
+	databasetest.Open(t)
+	user := databasetest.NewUser(t)
+	key := uuid.NewString()
+
+	// While the first request runs, a retry with the same key is turned away
+	var inner *httptest.ResponseRecorder
+	var handler http.Handler
+	handler = IdempotencyMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inner = httptest.NewRecorder()
+		handler.ServeHTTP(inner, idempotentRequest(http.MethodPost, "/api/todos", `{}`, key, user.ID))
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, idempotentRequest(http.MethodPost, "/api/todos", `{}`, key, user.ID))
+	if rec.Code != http.StatusCreated {
+		t.Errorf("first request: status %d", rec.Code)
+	}
+	if inner == nil || inner.Code != http.StatusConflict || problemCode(t, inner) != problem.CodeRequestInProgress {
+		t.Errorf("concurrent retry: got %+v", inner)
+	}
+}
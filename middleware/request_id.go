@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/noman/todo-application/problem"
+)
+
+// maxRequestIDLength is the longest client-supplied request ID that is kept
+const maxRequestIDLength = 128
+
+// RequestIDMiddleware gives every request an ID, taken from the client's
+// X-Request-ID header or generated, and echoes it in the response
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(problem.RequestIDHeader)
+		if requestID == "" || len(requestID) > maxRequestIDLength {
+			requestID = uuid.New().String()
+		}
+
+		w.Header().Set(problem.RequestIDHeader, requestID)
+		next.ServeHTTP(w, r)
+	})
+}
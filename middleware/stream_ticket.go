@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/noman/todo-application/problem"
+	"github.com/noman/todo-application/repository"
+)
+
+// StreamAuthMiddleware authenticates event stream requests. Clients that
+// can't set headers, such as the browser's EventSource, send a single-use
+// ticket from POST /api/events/tickets as the ticket query parameter, rather
+// than their bearer token, which would end up in logs along with the URL.
+// Other requests go through AuthMiddleware. Either way the bearer token is
+// added to the context, so the stream can end when the token stops working.
+func StreamAuthMiddleware(next http.Handler) http.Handler {
+	auth := AuthMiddleware(next)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ticket := r.URL.Query().Get("ticket")
+		if ticket == "" {
+			auth.ServeHTTP(w, r)
+			return
+		}
+
+		_, token, err := repository.NewStreamTicketRepository().Redeem(ticket)
+		if err != nil {
+			if errors.Is(err, repository.ErrStreamTicketNotFound) {
+				problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Invalid or expired stream ticket")
+				return
+			}
+			problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to check stream ticket")
+			return
+		}
+
+		// The token may have been revoked since the ticket was created
+		claims, err := ValidateToken(token)
+		if err != nil {
+			if errors.Is(err, ErrInvalidToken) {
+				problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Invalid or expired token")
+				return
+			}
+			problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "Failed to validate token")
+			return
+		}
+
+		// Add the user ID and token to the request context
+		ctx := context.WithValue(r.Context(), "userID", claims.UserID)
+		ctx = context.WithValue(ctx, "token", token)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
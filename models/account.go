@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Account is a user's profile as they see it, with when the account will be
+// deleted if they asked for that
+type Account struct {
+	ID                  uuid.UUID  `json:"id"`
+	Username            string     `json:"username"`
+	Email               string     `json:"email"`
+	CreatedAt           time.Time  `json:"created_at"`
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at"`
+}
+
+// DeleteAccountRequest represents the delete account request payload
+type DeleteAccountRequest struct {
+	Password string `json:"password" validate:"required"`
+}
+
+// Account export statuses
+const (
+	AccountExportQueued    = "queued"
+	AccountExportRunning   = "running"
+	AccountExportCompleted = "completed"
+	AccountExportFailed    = "failed"
+)
+
+// AccountExport is an archive of all of a user's data, built in the
+// background. Completed archives can be downloaded until they expire.
+type AccountExport struct {
+	ID         uuid.UUID  `json:"id"`
+	UserID     uuid.UUID  `json:"user_id"`
+	Status     string     `json:"status"`
+	Size       int64      `json:"size"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	StorageKey string     `json:"-"` // Location in the blob store is not exposed to clients
+}
+
+// AccountSessions lists the ways a user has signed in: their app passwords
+// and the tokens they signed out of
+type AccountSessions struct {
+	AppPasswords []*AppPassword      `json:"app_passwords"`
+	SignedOut    []*BlacklistedToken `json:"signed_out"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AppPassword is a password for signing in to apps that can't use bearer
+// tokens, such as CalDAV clients. The password is only returned when it's
+// created.
+type AppPassword struct {
+	ID         uuid.UUID  `json:"id"`
+	UserID     uuid.UUID  `json:"user_id"`
+	Name       string     `json:"name"`
+	Password   string     `json:"password,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+// CreateAppPasswordRequest represents the create app password request payload
+type CreateAppPasswordRequest struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
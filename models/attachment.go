@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Attachment represents a file attached to a todo
+type Attachment struct {
+	ID          uuid.UUID `json:"id"`
+	TodoID      uuid.UUID `json:"todo_id"`
+	UserID      uuid.UUID `json:"user_id"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	StorageKey  string    `json:"-"` // Location in the blob store is not exposed to clients
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// StorageUsageResponse represents a user's attachment storage usage
+type StorageUsageResponse struct {
+	UsedBytes  int64 `json:"used_bytes"`
+	QuotaBytes int64 `json:"quota_bytes"`
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"encoding/json"
+
+	"github.com/google/uuid"
+)
+
+// Batch modes
+const (
+	BatchModeAtomic      = "atomic"      // All operations succeed or none are applied
+	BatchModeIndependent = "independent" // Each operation succeeds or fails on its own
+)
+
+// Batch operations
+const (
+	BatchOpCreate   = "create"
+	BatchOpUpdate   = "update"
+	BatchOpDelete   = "delete"
+	BatchOpComplete = "complete"
+)
+
+// BatchRequest represents the batch todo request payload
+type BatchRequest struct {
+	Mode       string           `json:"mode" validate:"omitempty,oneof=atomic independent"`
+	Operations []BatchOperation `json:"operations" validate:"required,max=100"`
+}
+
+// BatchOperation is a single operation in a batch. Todo is a CreateTodoRequest
+// for "create" and a JSON Merge Patch of the todo's editable fields for
+// "update". Version, if set, must match the todo's current version.
+type BatchOperation struct {
+	Op      string          `json:"op" validate:"required,oneof=create update delete complete"`
+	ID      *uuid.UUID      `json:"id,omitempty"`
+	Todo    json.RawMessage `json:"todo,omitempty"`
+	Version *int            `json:"version,omitempty"`
+}
+
+// BatchResult is the outcome of a single batch operation
+type BatchResult struct {
+	Index  int           `json:"index"`
+	Op     string        `json:"op"`
+	Status int           `json:"status"`
+	ID     *uuid.UUID    `json:"id,omitempty"`
+	Todo   *TodoResponse `json:"todo,omitempty"`
+	Code   string        `json:"code,omitempty"` // Error code, as in problem responses
+	Error  string        `json:"error,omitempty"`
+}
+
+// BatchResponse is the structure returned for a batch request
+type BatchResponse struct {
+	Committed bool          `json:"committed"`
+	Results   []BatchResult `json:"results"`
+}
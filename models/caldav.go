@@ -0,0 +1,11 @@
+package models
+
+import "github.com/google/uuid"
+
+// CalDAVResource is the resource name and UID a CalDAV client chose for a
+// todo it created. Other todos are served as "<id>.ics" with their ID as UID.
+type CalDAVResource struct {
+	TodoID uuid.UUID
+	Name   string
+	UID    string
+}
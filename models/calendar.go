@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CalendarFeed is a user's secret calendar feed, which calendar apps can
+// subscribe to without a bearer token
+type CalendarFeed struct {
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CalendarFeedResponse is returned when a calendar feed is created. The URL
+// contains the feed's secret token and is only shown once.
+type CalendarFeedResponse struct {
+	URL       string    `json:"url"`
+	CreatedAt time.Time `json:"created_at"`
+}
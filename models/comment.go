@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Comment represents a comment on a todo; comments with a parent are replies
+type Comment struct {
+	ID        uuid.UUID  `json:"id"`
+	TodoID    uuid.UUID  `json:"todo_id"`
+	ParentID  *uuid.UUID `json:"parent_id"`
+	UserID    uuid.UUID  `json:"user_id"`
+	Username  string     `json:"username"`
+	Body      string     `json:"body"` // Markdown source, rendered by clients
+	Edited    bool       `json:"edited"`
+	Replies   []*Comment `json:"replies"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// CreateCommentRequest represents the create comment request payload
+type CreateCommentRequest struct {
+	Body     string     `json:"body" validate:"required,max=10000"`
+	ParentID *uuid.UUID `json:"parent_id,omitempty"`
+}
+
+// UpdateCommentRequest represents the update comment request payload
+type UpdateCommentRequest struct {
+	Body string `json:"body" validate:"required,max=10000"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// IdempotencyKey is a client-supplied Idempotency-Key and the response stored
+// for the request first made with it
+type IdempotencyKey struct {
+	UserID      uuid.UUID
+	Key         string
+	Fingerprint string      // Hash of the request's method, path and body
+	Status      int         // 0 while the first request is still in progress
+	Headers     http.Header // Response headers to replay
+	Body        []byte      // Response body to replay
+	CreatedAt   time.Time
+}
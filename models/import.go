@@ -0,0 +1,78 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/noman/todo-application/problem"
+)
+
+// Import and export formats
+const (
+	TransferFormatJSON    = "json"
+	TransferFormatCSV     = "csv"
+	TransferFormatTodoTxt = "todotxt"
+)
+
+// ExportedTodo is a todo as it's exported, and as it's read back on import.
+// Imports ignore the ID and timestamps: every row becomes a new todo.
+type ExportedTodo struct {
+	ID          *uuid.UUID `json:"id,omitempty"`
+	Title       string     `json:"title" validate:"required,max=100"`
+	Description string     `json:"description" validate:"max=10000"`
+	Completed   bool       `json:"completed"`
+	WorkspaceID *uuid.UUID `json:"workspace_id"`
+	ProjectID   *uuid.UUID `json:"project_id"`
+	Tags        []string   `json:"tags,omitempty" validate:"max=20,itemmax=50"`
+	Priority    string     `json:"priority,omitempty" validate:"omitempty,oneof=none low medium high"`
+	DueAt       *time.Time `json:"due_at,omitempty"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"`
+	UpdatedAt   *time.Time `json:"updated_at,omitempty"`
+}
+
+// Export converts a Todo to an ExportedTodo
+func (t *Todo) Export() ExportedTodo {
+	return ExportedTodo{
+		ID:          &t.ID,
+		Title:       t.Title,
+		Description: t.Description,
+		Completed:   t.Completed,
+		WorkspaceID: t.WorkspaceID,
+		ProjectID:   t.ProjectID,
+		Tags:        t.Tags,
+		Priority:    t.Priority,
+		DueAt:       t.DueAt,
+		CreatedAt:   &t.CreatedAt,
+		UpdatedAt:   &t.UpdatedAt,
+	}
+}
+
+// Import row statuses
+const (
+	ImportRowOK        = "ok"        // The row is, or in a dry run would be, imported
+	ImportRowDuplicate = "duplicate" // The todo already exists, or appears earlier in the file; skipped
+	ImportRowInvalid   = "invalid"   // The row has errors; nothing is imported
+)
+
+// ImportRow is the outcome of a single row of an import. Row is the line
+// number for CSV and todo.txt files, counting the CSV header, and the
+// 1-based position in the array for JSON. ID is set once the todo is created.
+type ImportRow struct {
+	Row    int                  `json:"row"`
+	Status string               `json:"status"`
+	Title  string               `json:"title"`
+	ID     *uuid.UUID           `json:"id,omitempty"`
+	Errors []problem.FieldError `json:"errors,omitempty"`
+}
+
+// ImportResponse is the structure returned for an import. Imported counts
+// the rows that are, or would be, imported. Committed is false for dry runs
+// and for imports with invalid rows, which write nothing.
+type ImportResponse struct {
+	DryRun     bool        `json:"dry_run"`
+	Committed  bool        `json:"committed"`
+	Imported   int         `json:"imported"`
+	Duplicates int         `json:"duplicates"`
+	Invalid    int         `json:"invalid"`
+	Rows       []ImportRow `json:"rows"`
+}
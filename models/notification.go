@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Notification types
+const (
+	NotificationTypeMention         = "mention"
+	NotificationTypeWebhookDisabled = "webhook_disabled"
+)
+
+// Notification represents something a user should be told about
+type Notification struct {
+	ID        uuid.UUID  `json:"id"`
+	UserID    uuid.UUID  `json:"user_id"`
+	Type      string     `json:"type"`
+	ActorID   uuid.UUID  `json:"actor_id"`
+	TodoID    *uuid.UUID `json:"todo_id"`
+	CommentID *uuid.UUID `json:"comment_id"`
+	Message   string     `json:"message"`
+	ReadAt    *time.Time `json:"read_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
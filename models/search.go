@@ -0,0 +1,11 @@
+package models
+
+// SearchResult is a todo found by a search, with the matches in its title and
+// description highlighted. The highlights are HTML, with the text escaped and
+// the matched words in <mark>.
+type SearchResult struct {
+	Todo           TodoResponse `json:"todo"`
+	Rank           float64      `json:"rank"`
+	TitleHighlight string       `json:"title_highlight"`
+	Snippet        string       `json:"snippet"` // An excerpt of the description around the matches
+}
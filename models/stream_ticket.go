@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// StreamTicket opens an event stream once, before it expires, without
+// putting a bearer token in the stream's URL
+type StreamTicket struct {
+	Ticket    string    `json:"ticket"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// SyncCursor is a position in a user's change log. Clients see it as an
+// opaque sync token.
+type SyncCursor struct {
+	Seq    int64
+	TodoID uuid.UUID
+}
+
+// String encodes the cursor as a sync token
+func (c SyncCursor) String() string {
+	if c.TodoID == uuid.Nil {
+		return strconv.FormatInt(c.Seq, 10)
+	}
+	return strconv.FormatInt(c.Seq, 10) + "." + c.TodoID.String()
+}
+
+// ParseSyncCursor decodes a sync token; the empty token is the start of the log
+func ParseSyncCursor(token string) (SyncCursor, error) {
+	if token == "" {
+		return SyncCursor{}, nil
+	}
+
+	seqPart, idPart, hasID := strings.Cut(token, ".")
+	seq, err := strconv.ParseInt(seqPart, 10, 64)
+	if err != nil || seq < 0 {
+		return SyncCursor{}, errors.New("invalid sync token")
+	}
+
+	cursor := SyncCursor{Seq: seq}
+	if hasID {
+		cursor.TodoID, err = uuid.Parse(idPart)
+		if err != nil {
+			return SyncCursor{}, errors.New("invalid sync token")
+		}
+	}
+
+	return cursor, nil
+}
+
+// SyncLogEntry is the latest change to a todo in a user's change log.
+// FirstSeq is where the todo entered the log and Visible says whether the
+// user can still see the todo.
+type SyncLogEntry struct {
+	TodoID   uuid.UUID
+	Seq      int64
+	FirstSeq int64
+	Visible  bool
+}
+
+// Cursor returns the position just after the entry
+func (e *SyncLogEntry) Cursor() SyncCursor {
+	return SyncCursor{Seq: e.Seq, TodoID: e.TodoID}
+}
+
+// SyncResponse is the structure returned when pulling changes
+type SyncResponse struct {
+	Created   []TodoResponse `json:"created"`
+	Updated   []TodoResponse `json:"updated"`
+	Deleted   []uuid.UUID    `json:"deleted"`
+	SyncToken string         `json:"sync_token"`
+	HasMore   bool           `json:"has_more"`
+}
+
+// Sync change operations
+const (
+	SyncOpCreate = "create"
+	SyncOpUpdate = "update"
+	SyncOpDelete = "delete"
+)
+
+// SyncPushRequest represents the payload of edits made while offline
+type SyncPushRequest struct {
+	Changes []SyncChange `json:"changes" validate:"required,max=500"`
+}
+
+// SyncChange is a single offline edit. ID is chosen by the client for
+// creates. Todo is a CreateTodoRequest for "create" and a JSON Merge Patch of
+// the todo's editable fields for "update". BaseVersion is the version the
+// client last saw, for updates and deletes.
+type SyncChange struct {
+	Op          string          `json:"op" validate:"required,oneof=create update delete"`
+	ID          uuid.UUID       `json:"id" validate:"required"`
+	BaseVersion int             `json:"base_version,omitempty"`
+	Todo        json.RawMessage `json:"todo,omitempty"`
+}
+
+// Sync change statuses
+const (
+	SyncStatusApplied  = "applied"  // The change was applied as sent
+	SyncStatusMerged   = "merged"   // The change was applied except for conflicting fields
+	SyncStatusRejected = "rejected" // Nothing was applied
+)
+
+// SyncChangeResult is the outcome of a single offline edit. Conflicts lists
+// the fields where the server's value was kept, and Todo is the todo as it
+// now is on the server.
+type SyncChangeResult struct {
+	Index     int           `json:"index"`
+	Op        string        `json:"op"`
+	ID        uuid.UUID     `json:"id"`
+	Status    string        `json:"status"`
+	Conflicts []string      `json:"conflicts,omitempty"`
+	Todo      *TodoResponse `json:"todo,omitempty"`
+	Code      string        `json:"code,omitempty"` // Error code, as in problem responses
+	Error     string        `json:"error,omitempty"`
+}
+
+// SyncPushResponse is the structure returned for pushed changes
+type SyncPushResponse struct {
+	Results []SyncChangeResult `json:"results"`
+}
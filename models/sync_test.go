@@ -0,0 +1,30 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestSyncCursor(t *testing.T) {
+	for _, want := range []SyncCursor{{}, {Seq: 42}, {Seq: 42, TodoID: uuid.New()}} {
+		cursor, err := ParseSyncCursor(want.String())
+		if err != nil {
+			t.Fatalf("ParseSyncCursor(%q): %v", want.String(), err)
+		}
+		if cursor != want {
+			t.Errorf("ParseSyncCursor(%q) = %+v, want %+v", want.String(), cursor, want)
+		}
+	}
+
+	// The empty token is the start of the log
+	if cursor, err := ParseSyncCursor(""); err != nil || cursor != (SyncCursor{}) {
+		t.Errorf(`ParseSyncCursor("") = %+v, %v`, cursor, err)
+	}
+
+	for _, token := range []string{"abc", "-1", "1.", "1.not-a-uuid", "." + uuid.NewString()} {
+		if _, err := ParseSyncCursor(token); err == nil {
+			t.Errorf("ParseSyncCursor(%q): got no error", token)
+		}
+	}
+}
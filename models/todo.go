@@ -1,53 +1,237 @@
 package models
 
 import (
+	"errors"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/noman/todo-application/filterexpr"
 )
 
 // Todo represents a todo item in the system
 type Todo struct {
-	ID          uuid.UUID `json:"id"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	Completed   bool      `json:"completed"`
-	UserID      uuid.UUID `json:"user_id"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID           uuid.UUID   `json:"id"`
+	Title        string      `json:"title"`
+	Description  string      `json:"description"`
+	Completed    bool        `json:"completed"`
+	UserID       uuid.UUID   `json:"user_id"`
+	WorkspaceID  *uuid.UUID  `json:"workspace_id"`
+	ProjectID    *uuid.UUID  `json:"project_id"`
+	AssigneeIDs  []uuid.UUID `json:"assignee_ids"`
+	Tags         []string    `json:"tags"`
+	Priority     string      `json:"priority"`
+	DueAt        *time.Time  `json:"due_at"`
+	CommentCount int         `json:"comment_count"`
+	Version      int         `json:"version"`
+	CreatedAt    time.Time   `json:"created_at"`
+	UpdatedAt    time.Time   `json:"updated_at"`
+	DeletedAt    *time.Time  `json:"deleted_at"`
 }
 
 // TodoResponse is the structure returned to clients
 type TodoResponse struct {
-	ID          uuid.UUID `json:"id"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	Completed   bool      `json:"completed"`
-	UserID      uuid.UUID `json:"user_id"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID           uuid.UUID   `json:"id"`
+	Title        string      `json:"title"`
+	Description  string      `json:"description"`
+	Completed    bool        `json:"completed"`
+	UserID       uuid.UUID   `json:"user_id"`
+	WorkspaceID  *uuid.UUID  `json:"workspace_id"`
+	ProjectID    *uuid.UUID  `json:"project_id"`
+	AssigneeIDs  []uuid.UUID `json:"assignee_ids"`
+	Tags         []string    `json:"tags"`
+	Priority     string      `json:"priority"`
+	DueAt        *time.Time  `json:"due_at"`
+	CommentCount int         `json:"comment_count"`
+	Version      int         `json:"version"`
+	CreatedAt    time.Time   `json:"created_at"`
+	DeletedAt    *time.Time  `json:"deleted_at,omitempty"`
 }
 
 // ToResponse converts a Todo to a TodoResponse
 func (t *Todo) ToResponse() TodoResponse {
 	return TodoResponse{
-		ID:          t.ID,
-		Title:       t.Title,
-		Description: t.Description,
-		Completed:   t.Completed,
-		UserID:      t.UserID,
-		CreatedAt:   t.CreatedAt,
+		ID:           t.ID,
+		Title:        t.Title,
+		Description:  t.Description,
+		Completed:    t.Completed,
+		UserID:       t.UserID,
+		WorkspaceID:  t.WorkspaceID,
+		ProjectID:    t.ProjectID,
+		AssigneeIDs:  t.AssigneeIDs,
+		Tags:         t.Tags,
+		Priority:     t.Priority,
+		DueAt:        t.DueAt,
+		CommentCount: t.CommentCount,
+		Version:      t.Version,
+		CreatedAt:    t.CreatedAt,
+		DeletedAt:    t.DeletedAt,
 	}
 }
 
+// ETag returns the entity tag of a todo, made of its version and its comment
+// count, which changes without a new version. Send it as If-Match to only
+// change the todo if it's still as the client last saw it.
+func (t TodoResponse) ETag() string {
+	return TodoETag(t.Version, t.CommentCount)
+}
+
+// TodoETag returns the entity tag of a todo with the given version and
+// comment count
+func TodoETag(version, commentCount int) string {
+	return `"` + strconv.Itoa(version) + "." + strconv.Itoa(commentCount) + `"`
+}
+
 // CreateTodoRequest represents the create todo request payload
 type CreateTodoRequest struct {
-	Title       string `json:"title"`
-	Description string `json:"description"`
+	Title       string      `json:"title" validate:"required,max=100"`
+	Description string      `json:"description" validate:"max=10000"`
+	WorkspaceID *uuid.UUID  `json:"workspace_id,omitempty"`
+	ProjectID   *uuid.UUID  `json:"project_id,omitempty"`
+	AssigneeIDs []uuid.UUID `json:"assignee_ids,omitempty" validate:"max=50"`
+	Tags        []string    `json:"tags,omitempty" validate:"max=20,itemmax=50"`
+	Priority    string      `json:"priority,omitempty" validate:"omitempty,oneof=none low medium high"`
+	DueAt       *time.Time  `json:"due_at,omitempty"`
 }
 
-// UpdateTodoRequest represents the update todo request payload
+// UpdateTodoRequest represents the full set of a todo's editable fields. It
+// is the PUT payload, which replaces the todo entirely, and the document PATCH
+// requests are applied to. Missing or null fields take their zero value;
+// title is required.
 type UpdateTodoRequest struct {
-	Title       string `json:"title,omitempty"`
-	Description string `json:"description,omitempty"`
-	Completed   *bool  `json:"completed,omitempty"`
-}
\ No newline at end of file
+	Title       *string    `json:"title" validate:"required,max=100"`
+	Description string     `json:"description" validate:"max=10000"`
+	Completed   bool       `json:"completed"`
+	ProjectID   *uuid.UUID `json:"project_id"`
+	Tags        []string   `json:"tags" validate:"max=20,itemmax=50"`
+	Priority    string     `json:"priority" validate:"omitempty,oneof=none low medium high"`
+	DueAt       *time.Time `json:"due_at"`
+}
+
+// Document returns the editable fields of a todo as an UpdateTodoRequest
+func (t *Todo) Document() UpdateTodoRequest {
+	title := t.Title
+	return UpdateTodoRequest{
+		Title:       &title,
+		Description: t.Description,
+		Completed:   t.Completed,
+		ProjectID:   t.ProjectID,
+		Tags:        NormalizeTags(t.Tags),
+		Priority:    NormalizePriority(t.Priority),
+		DueAt:       t.DueAt,
+	}
+}
+
+// Apply replaces a todo's editable fields with those of a validated document
+func (t *Todo) Apply(doc UpdateTodoRequest) {
+	t.Title = *doc.Title
+	t.Description = doc.Description
+	t.Completed = doc.Completed
+	t.ProjectID = doc.ProjectID
+	t.Tags = NormalizeTags(doc.Tags)
+	t.Priority = NormalizePriority(doc.Priority)
+	t.DueAt = doc.DueAt
+}
+
+// Todo priorities
+const (
+	PriorityNone   = "none"
+	PriorityLow    = "low"
+	PriorityMedium = "medium"
+	PriorityHigh   = "high"
+)
+
+// Priorities lists the priorities from lowest to highest; a priority is
+// stored as its index in the list
+var Priorities = []string{PriorityNone, PriorityLow, PriorityMedium, PriorityHigh}
+
+// NormalizePriority returns a priority, or none if it is empty or unknown
+func NormalizePriority(priority string) string {
+	for _, p := range Priorities {
+		if p == priority {
+			return p
+		}
+	}
+	return PriorityNone
+}
+
+// PriorityRank returns the index of a priority in Priorities
+func PriorityRank(priority string) int {
+	for i, p := range Priorities {
+		if p == priority {
+			return i
+		}
+	}
+	return 0
+}
+
+// NormalizeTags trims tags, joins the words of each with hyphens so every tag
+// is a single word, and drops empty tags and repeats of a tag in any case. The
+// result is never nil.
+func NormalizeTags(tags []string) []string {
+	normalized := []string{}
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		tag = strings.Join(strings.Fields(tag), "-")
+		key := strings.ToLower(tag)
+		if tag == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
+
+// Todo list views
+const (
+	TodoViewAll          = "all"
+	TodoViewAssignedToMe = "assigned"
+	TodoViewCreatedByMe  = "created"
+)
+
+// TodoFilter describes which todos to list
+type TodoFilter struct {
+	UserID      uuid.UUID
+	WorkspaceID *uuid.UUID
+	View        string
+	Expr        filterexpr.Node // Narrows the todos further; without a workspace, every todo the user can see is filtered
+	After       *TodoPageCursor // Lists the todos after this one
+	Limit       int             // Lists at most this many todos; 0 lists them all
+}
+
+// TodoPageCursor is the last todo of a page of a todo list, which is ordered
+// newest first. Clients see it as an opaque cursor.
+type TodoPageCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// PageCursor returns the cursor of the page that ends with the todo
+func (t *Todo) PageCursor() TodoPageCursor {
+	return TodoPageCursor{CreatedAt: t.CreatedAt, ID: t.ID}
+}
+
+// String encodes the cursor. Creation times are kept to the microsecond, as
+// the database stores them.
+func (c TodoPageCursor) String() string {
+	return strconv.FormatInt(c.CreatedAt.UnixMicro(), 10) + "." + c.ID.String()
+}
+
+// ParseTodoPageCursor decodes a cursor
+func ParseTodoPageCursor(cursor string) (TodoPageCursor, error) {
+	microPart, idPart, ok := strings.Cut(cursor, ".")
+	if !ok {
+		return TodoPageCursor{}, errors.New("invalid cursor")
+	}
+	micro, err := strconv.ParseInt(microPart, 10, 64)
+	if err != nil {
+		return TodoPageCursor{}, errors.New("invalid cursor")
+	}
+	id, err := uuid.Parse(idPart)
+	if err != nil {
+		return TodoPageCursor{}, errors.New("invalid cursor")
+	}
+	return TodoPageCursor{CreatedAt: time.UnixMicro(micro).UTC(), ID: id}, nil
+}
@@ -0,0 +1,140 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Todo event actions
+const (
+	TodoEventCreated   = "created"
+	TodoEventUpdated   = "updated"
+	TodoEventDeleted   = "deleted"
+	TodoEventRestored  = "restored"
+	TodoEventUndeleted = "undeleted"
+	TodoEventPurged    = "purged"
+)
+
+// TodoSnapshot is the state of a todo's user-editable fields at a revision
+type TodoSnapshot struct {
+	Title       string      `json:"title"`
+	Description string      `json:"description"`
+	Completed   bool        `json:"completed"`
+	ProjectID   *uuid.UUID  `json:"project_id"`
+	AssigneeIDs []uuid.UUID `json:"assignee_ids"`
+	Tags        []string    `json:"tags"`
+	Priority    string      `json:"priority"`
+	DueAt       *time.Time  `json:"due_at"`
+}
+
+// Snapshot returns the current state of a todo's user-editable fields
+func (t *Todo) Snapshot() TodoSnapshot {
+	assigneeIDs := t.AssigneeIDs
+	if assigneeIDs == nil {
+		assigneeIDs = []uuid.UUID{}
+	}
+
+	return TodoSnapshot{
+		Title:       t.Title,
+		Description: t.Description,
+		Completed:   t.Completed,
+		ProjectID:   t.ProjectID,
+		AssigneeIDs: assigneeIDs,
+		Tags:        NormalizeTags(t.Tags),
+		Priority:    NormalizePriority(t.Priority),
+		DueAt:       t.DueAt,
+	}
+}
+
+// FieldChange is the before and after value of a changed field
+type FieldChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// TodoEvent is an immutable record of a change to a todo
+type TodoEvent struct {
+	ID        uuid.UUID              `json:"id"`
+	TodoID    uuid.UUID              `json:"todo_id"`
+	Revision  int                    `json:"revision"`
+	Action    string                 `json:"action"`
+	ActorID   uuid.UUID              `json:"actor_id"`
+	Changes   map[string]FieldChange `json:"changes"`
+	Snapshot  TodoSnapshot           `json:"snapshot"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// DiffSnapshots returns the fields that differ between two snapshots, keyed by
+// their JSON name. A nil before means the todo didn't exist yet.
+func DiffSnapshots(before *TodoSnapshot, after TodoSnapshot) map[string]FieldChange {
+	var from TodoSnapshot
+	if before != nil {
+		from = *before
+	}
+
+	changes := map[string]FieldChange{}
+	add := func(field string, fromValue, toValue interface{}, changed bool) {
+		if !changed {
+			return
+		}
+		if before == nil {
+			fromValue = nil
+		}
+		changes[field] = FieldChange{From: fromValue, To: toValue}
+	}
+
+	add("title", from.Title, after.Title, before == nil || from.Title != after.Title)
+	add("description", from.Description, after.Description, before == nil || from.Description != after.Description)
+	add("completed", from.Completed, after.Completed, before == nil || from.Completed != after.Completed)
+	add("project_id", from.ProjectID, after.ProjectID, before == nil || !sameUUIDPtr(from.ProjectID, after.ProjectID))
+	add("assignee_ids", from.AssigneeIDs, after.AssigneeIDs, before == nil || !sameUUIDSet(from.AssigneeIDs, after.AssigneeIDs))
+	add("tags", from.Tags, after.Tags, before == nil || !sameTags(from.Tags, after.Tags))
+	add("priority", from.Priority, after.Priority, before == nil || NormalizePriority(from.Priority) != NormalizePriority(after.Priority))
+	add("due_at", from.DueAt, after.DueAt, before == nil || !sameTimePtr(from.DueAt, after.DueAt))
+
+	return changes
+}
+
+func sameUUIDPtr(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func sameTimePtr(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// sameTags compares tags in order, since their order is shown to users
+func sameTags(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sameUUIDSet(a, b []uuid.UUID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	set := make(map[uuid.UUID]bool, len(a))
+	for _, id := range a {
+		set[id] = true
+	}
+	for _, id := range b {
+		if !set[id] {
+			return false
+		}
+	}
+	return true
+}
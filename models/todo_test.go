@@ -0,0 +1,95 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	tests := []struct {
+		tags []string
+		want []string
+	}{
+		{nil, []string{}},
+		{[]string{"work", " home ", ""}, []string{"work", "home"}},
+		{[]string{"Work", "work", "WORK"}, []string{"Work"}},
+		{[]string{"to  read", "\tq3 launch\n"}, []string{"to-read", "q3-launch"}},
+	}
+	for _, tt := range tests {
+		if got := NormalizeTags(tt.tags); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("NormalizeTags(%q) = %q, want %q", tt.tags, got, tt.want)
+		}
+	}
+}
+
+func TestPriorities(t *testing.T) {
+	for rank, priority := range Priorities {
+		if got := PriorityRank(priority); got != rank {
+			t.Errorf("PriorityRank(%q) = %d, want %d", priority, got, rank)
+		}
+	}
+	if got := NormalizePriority(""); got != PriorityNone {
+		t.Errorf(`NormalizePriority("") = %q, want none`, got)
+	}
+	if got := NormalizePriority("urgent"); got != PriorityNone {
+		t.Errorf(`NormalizePriority("urgent") = %q, want none`, got)
+	}
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	due := time.Date(2024, 5, 17, 17, 0, 0, 0, time.UTC)
+	before := TodoSnapshot{Title: "Invoice", Tags: []string{"work"}, Priority: PriorityNone}
+	after := before
+	after.Tags = []string{"work", "billing"}
+	after.Priority = PriorityHigh
+	after.DueAt = &due
+
+	changes := DiffSnapshots(&before, after)
+	for _, field := range []string{"tags", "priority", "due_at"} {
+		if _, ok := changes[field]; !ok {
+			t.Errorf("DiffSnapshots: %s didn't change", field)
+		}
+	}
+	if len(changes) != 3 {
+		t.Errorf("DiffSnapshots: got %d changes, want 3: %v", len(changes), changes)
+	}
+
+	// The same instant in another zone is the same due date, and snapshots
+	// from before priorities existed have none
+	sameDue := due.In(time.FixedZone("CEST", 2*60*60))
+	old := TodoSnapshot{Title: "Invoice", Tags: []string{"work"}, DueAt: &sameDue}
+	current := TodoSnapshot{Title: "Invoice", Tags: []string{"work"}, Priority: PriorityNone, DueAt: &due}
+	if changes := DiffSnapshots(&old, current); len(changes) != 0 {
+		t.Errorf("DiffSnapshots: got changes %v, want none", changes)
+	}
+}
+
+func TestApply(t *testing.T) {
+	title := "Invoice"
+	todo := &Todo{Title: "Old", Tags: []string{"old"}, Priority: PriorityHigh}
+	todo.Apply(UpdateTodoRequest{Title: &title, Tags: []string{" work ", "Work"}})
+
+	if todo.Title != "Invoice" || !reflect.DeepEqual(todo.Tags, []string{"work"}) || todo.Priority != PriorityNone || todo.DueAt != nil {
+		t.Errorf("Apply: got %+v", todo)
+	}
+}
+
+func TestTodoPageCursor(t *testing.T) {
+	todo := &Todo{ID: uuid.New(), CreatedAt: time.Date(2024, 5, 17, 9, 30, 0, 123456000, time.UTC)}
+	cursor, err := ParseTodoPageCursor(todo.PageCursor().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cursor.ID != todo.ID || !cursor.CreatedAt.Equal(todo.CreatedAt) {
+		t.Errorf("cursor = %+v, want %s at %v", cursor, todo.ID, todo.CreatedAt)
+	}
+
+	for _, value := range []string{"", "123", "abc." + todo.ID.String(), "123.not-a-uuid"} {
+		if _, err := ParseTodoPageCursor(value); err == nil {
+			t.Errorf("ParseTodoPageCursor(%q): got no error", value)
+		}
+	}
+}
@@ -36,15 +36,15 @@ func (u *User) ToResponse() UserResponse {
 
 // LoginRequest represents the login request payload
 type LoginRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email    string `json:"email" validate:"required"`
+	Password string `json:"password" validate:"required"`
 }
 
 // RegisterRequest represents the registration request payload
 type RegisterRequest struct {
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Username string `json:"username" validate:"required,max=50"`
+	Email    string `json:"email" validate:"required,max=100,email"`
+	Password string `json:"password" validate:"required,maxbytes=72"` // bcrypt accepts at most 72 bytes
 }
 
 // TokenResponse represents the authentication token response
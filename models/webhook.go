@@ -0,0 +1,80 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Webhook event types
+const (
+	WebhookEventTodoCreated   = "todo.created"
+	WebhookEventTodoCompleted = "todo.completed"
+	WebhookEventTodoDeleted   = "todo.deleted"
+)
+
+// Webhook delivery statuses
+const (
+	WebhookDeliveryPending   = "pending"   // Waiting for its first or next attempt
+	WebhookDeliverySucceeded = "succeeded" // The endpoint responded with a 2xx status
+	WebhookDeliveryFailed    = "failed"    // Every attempt failed
+)
+
+// Webhook is an endpoint that a user's todo events are posted to. The secret
+// signs every delivery and is only returned when the webhook is created.
+type Webhook struct {
+	ID           uuid.UUID  `json:"id"`
+	UserID       uuid.UUID  `json:"user_id"`
+	URL          string     `json:"url"`
+	Description  string     `json:"description"`
+	Events       []string   `json:"events"`
+	Active       bool       `json:"active"`
+	FailureCount int        `json:"failure_count"` // Failed attempts since the last success
+	DisabledAt   *time.Time `json:"disabled_at"`   // Set when the webhook was disabled for failing
+	Secret       string     `json:"secret,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// WebhookDelivery is an event queued for, or delivered to, a webhook. It
+// describes the latest attempt.
+type WebhookDelivery struct {
+	ID             uuid.UUID       `json:"id"`
+	WebhookID      uuid.UUID       `json:"webhook_id"`
+	EventID        uuid.UUID       `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	ResponseStatus *int            `json:"response_status"`
+	Error          string          `json:"error,omitempty"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at"` // Only set while pending
+	LastAttemptAt  *time.Time      `json:"last_attempt_at"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// WebhookDispatch is a delivery claimed for an attempt, along with where to
+// send it and the secret to sign it with
+type WebhookDispatch struct {
+	Delivery WebhookDelivery
+	UserID   uuid.UUID
+	URL      string
+	Secret   string
+}
+
+// CreateWebhookRequest represents the create webhook request payload
+type CreateWebhookRequest struct {
+	URL         string   `json:"url" validate:"required,max=2000,url"`
+	Description string   `json:"description" validate:"max=255"`
+	Events      []string `json:"events" validate:"required,max=10,oneof=todo.created todo.completed todo.deleted"`
+}
+
+// UpdateWebhookRequest represents the update webhook request payload.
+// Setting active re-enables a webhook that was disabled for failing.
+type UpdateWebhookRequest struct {
+	URL         string   `json:"url" validate:"required,max=2000,url"`
+	Description string   `json:"description" validate:"max=255"`
+	Events      []string `json:"events" validate:"required,max=10,oneof=todo.created todo.completed todo.deleted"`
+	Active      *bool    `json:"active" validate:"required"`
+}
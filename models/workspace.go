@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Workspace member roles
+const (
+	WorkspaceRoleOwner  = "owner"
+	WorkspaceRoleMember = "member"
+)
+
+// Workspace represents a team (organization) that shares todos and projects
+type Workspace struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	OwnerID   uuid.UUID `json:"owner_id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// WorkspaceMember represents a user's membership in a workspace
+type WorkspaceMember struct {
+	WorkspaceID uuid.UUID `json:"workspace_id"`
+	UserID      uuid.UUID `json:"user_id"`
+	Username    string    `json:"username"`
+	Email       string    `json:"email"`
+	Role        string    `json:"role"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Project represents a shared project inside a workspace
+type Project struct {
+	ID          uuid.UUID `json:"id"`
+	WorkspaceID uuid.UUID `json:"workspace_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	CreatedBy   uuid.UUID `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// CreateWorkspaceRequest represents the create workspace request payload
+type CreateWorkspaceRequest struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+// AddMemberRequest represents the add workspace member request payload
+type AddMemberRequest struct {
+	Email string `json:"email" validate:"required,max=100,email"`
+	Role  string `json:"role,omitempty" validate:"omitempty,oneof=owner member"`
+}
+
+// CreateProjectRequest represents the create project request payload
+type CreateProjectRequest struct {
+	Name        string `json:"name" validate:"required,max=100"`
+	Description string `json:"description" validate:"max=10000"`
+}
+
+// AssignTodoRequest represents the assign todo request payload
+type AssignTodoRequest struct {
+	AssigneeIDs []uuid.UUID `json:"assignee_ids" validate:"max=50"`
+}
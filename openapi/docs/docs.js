@@ -0,0 +1,229 @@
+// Renders the OpenAPI document as a list of operations that can be tried out
+// from the page. It runs from a file rather than inline so the page works
+// under a Content-Security-Policy without 'unsafe-inline'.
+'use strict';
+
+const tokenKey = 'todo-api-docs-token';
+
+// el creates an element with text and children; text is never parsed as HTML
+function el(tag, props, ...children) {
+  const node = document.createElement(tag);
+  Object.assign(node, props || {});
+  for (const child of children) {
+    node.append(child);
+  }
+  return node;
+}
+
+// resolve follows a $ref into the document's components
+function resolve(doc, schema) {
+  while (schema && schema.$ref) {
+    schema = doc.components.schemas[schema.$ref.split('/').pop()];
+  }
+  return schema || {};
+}
+
+// example builds an example value of a schema, for request bodies
+function example(doc, schema, depth) {
+  schema = resolve(doc, schema);
+  if (depth > 5) {
+    return null;
+  }
+  if (schema.example !== undefined) {
+    return schema.example;
+  }
+  if (schema.enum) {
+    return schema.enum[0];
+  }
+  if (schema.oneOf) {
+    return example(doc, schema.oneOf[0], depth + 1);
+  }
+  const type = Array.isArray(schema.type) ? schema.type[0] : schema.type;
+  switch (type) {
+    case 'object': {
+      const value = {};
+      for (const [name, property] of Object.entries(schema.properties || {})) {
+        if (!property.readOnly) {
+          value[name] = example(doc, property, depth + 1);
+        }
+      }
+      return value;
+    }
+    case 'array':
+      return [example(doc, schema.items, depth + 1)];
+    case 'integer':
+    case 'number':
+      return schema.minimum || 0;
+    case 'boolean':
+      return false;
+    case 'string':
+      if (schema.format === 'uuid') {
+        return '00000000-0000-0000-0000-000000000000';
+      }
+      if (schema.format === 'date-time') {
+        return new Date().toISOString();
+      }
+      return '';
+    default:
+      return null;
+  }
+}
+
+// describe returns a short description of a schema's type
+function describe(doc, schema) {
+  if (schema && schema.$ref) {
+    return schema.$ref.split('/').pop();
+  }
+  schema = schema || {};
+  if (schema.oneOf) {
+    return schema.oneOf.map((s) => describe(doc, s)).join(' | ');
+  }
+  const type = Array.isArray(schema.type) ? schema.type.join(' | ') : schema.type || 'any';
+  if (type === 'array') {
+    return describe(doc, schema.items) + '[]';
+  }
+  return schema.format ? type + ' (' + schema.format + ')' : type;
+}
+
+// renderOperation renders an operation with a form to send it
+function renderOperation(doc, path, method, op) {
+  const body = el('div');
+
+  if (op.description) {
+    body.append(el('p', { textContent: op.description }));
+  }
+
+  // Parameters, each with an input
+  const inputs = [];
+  if (op.parameters && op.parameters.length > 0) {
+    const rows = op.parameters.map((param) => {
+      const input = el('input', { placeholder: describe(doc, param.schema) });
+      inputs.push({ param, input });
+      return el('tr', {},
+        el('td', {}, el('code', { textContent: param.name }), param.required ? ' *' : ''),
+        el('td', { textContent: param.in }),
+        el('td', { textContent: param.description || '' }),
+        el('td', {}, input));
+    });
+    body.append(el('table', {},
+      el('thead', {}, el('tr', {}, ...['Parameter', 'In', 'Description', 'Value'].map((h) => el('th', { textContent: h })))),
+      el('tbody', {}, ...rows)));
+  }
+
+  // A JSON request body, prefilled with an example
+  let textarea = null;
+  const content = op.requestBody && op.requestBody.content;
+  if (content && content['application/json']) {
+    const schema = content['application/json'].schema;
+    textarea = el('textarea', { rows: 8, value: JSON.stringify(example(doc, schema, 0), null, 2) });
+    body.append(el('h4', { textContent: 'Request body: ' + describe(doc, schema) }), textarea);
+  } else if (content) {
+    body.append(el('p', { textContent: 'Request body: ' + Object.keys(content).join(', ') + ' (not supported on this page)' }));
+  }
+
+  // Responses
+  for (const [status, response] of Object.entries(op.responses || {})) {
+    const schemas = Object.entries(response.content || {}).map(([type, c]) => type + ': ' + describe(doc, c.schema));
+    body.append(el('p', {}, el('strong', { textContent: status }), ' ' + response.description + (schemas.length ? ' — ' + schemas.join(', ') : '')));
+  }
+
+  // Sending the request
+  const output = el('pre', { hidden: true });
+  const send = el('button', { type: 'button', textContent: 'Send' });
+  send.addEventListener('click', async () => {
+    let url = path;
+    const query = new URLSearchParams();
+    const headers = {};
+    for (const { param, input } of inputs) {
+      if (input.value === '') {
+        continue;
+      }
+      if (param.in === 'path') {
+        url = url.replace('{' + param.name + '}', encodeURIComponent(input.value));
+      } else if (param.in === 'query') {
+        query.append(param.name, input.value);
+      } else if (param.in === 'header') {
+        headers[param.name] = input.value;
+      }
+    }
+    if (query.toString() !== '') {
+      url += '?' + query.toString();
+    }
+
+    const token = document.getElementById('token').value;
+    if (token !== '') {
+      headers.Authorization = 'Bearer ' + token;
+    }
+    const init = { method: method.toUpperCase(), headers };
+    if (textarea) {
+      headers['Content-Type'] = 'application/json';
+      init.body = textarea.value;
+    }
+
+    output.hidden = false;
+    output.textContent = 'Sending…';
+    try {
+      const response = await fetch(url, init);
+      let text = await response.text();
+      try {
+        text = JSON.stringify(JSON.parse(text), null, 2);
+      } catch (e) {
+        // Not JSON; show it as it is
+      }
+      const lines = [response.status + ' ' + response.statusText];
+      response.headers.forEach((value, name) => lines.push(name + ': ' + value));
+      output.textContent = lines.join('\n') + '\n\n' + text;
+    } catch (e) {
+      output.textContent = 'Request failed: ' + e.message;
+    }
+  });
+  body.append(send, output);
+
+  return el('details', { className: 'operation' },
+    el('summary', {},
+      el('span', { className: 'method ' + method, textContent: method }),
+      el('span', { className: 'path', textContent: path }),
+      el('span', { className: 'summary', textContent: op.summary || '' })),
+    body);
+}
+
+// render renders the document, grouping operations by tag in document order
+function render(doc) {
+  document.getElementById('title').textContent = doc.info.title;
+  document.getElementById('description').textContent = doc.info.description || '';
+
+  const groups = new Map();
+  for (const [path, item] of Object.entries(doc.paths)) {
+    for (const [method, op] of Object.entries(item)) {
+      const tag = (op.tags && op.tags[0]) || 'Other';
+      if (!groups.has(tag)) {
+        groups.set(tag, []);
+      }
+      groups.get(tag).push(renderOperation(doc, path, method, op));
+    }
+  }
+
+  const main = document.getElementById('operations');
+  main.replaceChildren();
+  for (const [tag, operations] of groups) {
+    main.append(el('h2', { textContent: tag }), ...operations);
+  }
+}
+
+document.addEventListener('DOMContentLoaded', async () => {
+  // Keep the token across visits, like the rest of the session
+  const token = document.getElementById('token');
+  token.value = localStorage.getItem(tokenKey) || '';
+  token.addEventListener('input', () => localStorage.setItem(tokenKey, token.value));
+  document.getElementById('forget').addEventListener('click', () => {
+    token.value = '';
+    localStorage.removeItem(tokenKey);
+  });
+
+  try {
+    const response = await fetch('/api/openapi.json');
+    render(await response.json());
+  } catch (e) {
+    document.getElementById('operations').textContent = 'Failed to load the API description: ' + e.message;
+  }
+});
@@ -0,0 +1,256 @@
+// Package openapi describes the API as an OpenAPI 3.1 document, generated from
+// the route table in Operations and the request and response types in models,
+// and serves it along with an interactive documentation page.
+package openapi
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/noman/todo-application/problem"
+)
+
+// The documentation page and its assets are embedded, so the page works
+// offline and under a Content-Security-Policy that only allows this server
+//
+//go:embed docs
+var docsFiles embed.FS
+
+// docsPolicy is the Content-Security-Policy of the documentation page
+const docsPolicy = "default-src 'self'; frame-ancestors 'none'"
+
+// pathParamPattern matches the parameters of a route's path template
+var pathParamPattern = regexp.MustCompile(`\{(\w+)\}`)
+
+// Document builds the OpenAPI document
+func Document() Schema {
+	registry := &schemaRegistry{components: map[string]Schema{}}
+	problemSchema := registry.ref(problem.Problem{})
+
+	paths := Schema{}
+	for _, op := range Operations {
+		item, ok := paths[op.Path].(Schema)
+		if !ok {
+			item = Schema{}
+			paths[op.Path] = item
+		}
+		item[strings.ToLower(op.Method)] = operationObject(registry, op, problemSchema)
+	}
+
+	return Schema{
+		"openapi": "3.1.0",
+		"info": Schema{
+			"title":       "Todo Application API",
+			"version":     "1.0.0",
+			"description": "Errors are returned as RFC 7807 problem details. Mutating requests accept an Idempotency-Key header.",
+		},
+		"paths": paths,
+		"components": Schema{
+			"schemas": registry.components,
+			"securitySchemes": Schema{
+				"bearerAuth": Schema{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+			},
+		},
+		"security": []Schema{{"bearerAuth": []string{}}},
+	}
+}
+
+// operationObject builds the OpenAPI operation object of a route
+func operationObject(registry *schemaRegistry, op Operation, problemSchema Schema) Schema {
+	parameters := []Schema{}
+	for _, match := range pathParamPattern.FindAllStringSubmatch(op.Path, -1) {
+		schema := Schema{"type": "string", "format": "uuid"}
+		switch match[1] {
+		case "revision":
+			schema = Schema{"type": "integer", "minimum": 1}
+		case "token":
+			schema = Schema{"type": "string"}
+		}
+		parameters = append(parameters, Schema{"name": match[1], "in": "path", "required": true, "schema": schema})
+	}
+	for _, param := range op.Parameters {
+		parameters = append(parameters, parameterObject(param))
+	}
+	if !op.Public && op.Method != http.MethodGet {
+		parameters = append(parameters, parameterObject(Parameter{
+			Name:        "Idempotency-Key",
+			In:          "header",
+			Description: "Replay the stored response when the request is retried with the same key",
+			Schema:      Schema{"type": "string", "maxLength": 255},
+		}))
+	}
+
+	success := Schema{"description": http.StatusText(op.Status)}
+	if content := contentObject(registry, op.Response, op.ResponseContent); content != nil {
+		success["content"] = content
+	}
+
+	operation := Schema{
+		"operationId": op.ID,
+		"tags":        []string{op.Tag},
+		"summary":     op.Summary,
+		"parameters":  parameters,
+		"responses": Schema{
+			strconv.Itoa(op.Status): success,
+			"default": Schema{
+				"description": "Error",
+				"content":     Schema{problem.MediaType: Schema{"schema": problemSchema}},
+			},
+		},
+	}
+	if op.Description != "" {
+		operation["description"] = op.Description
+	}
+	if op.Public {
+		operation["security"] = []Schema{}
+	}
+	if content := contentObject(registry, op.Request, op.RequestContent); content != nil {
+		operation["requestBody"] = Schema{"required": true, "content": content}
+	}
+
+	return operation
+}
+
+// parameterObject builds an OpenAPI parameter object
+func parameterObject(param Parameter) Schema {
+	return Schema{
+		"name":        param.Name,
+		"in":          param.In,
+		"description": param.Description,
+		"schema":      param.Schema,
+	}
+}
+
+// contentObject builds the content of a request or response body: a JSON body
+// of the type of body, or the schemas given per media type
+func contentObject(registry *schemaRegistry, body interface{}, overrides map[string]interface{}) Schema {
+	if overrides == nil {
+		if body == nil {
+			return nil
+		}
+		overrides = map[string]interface{}{"application/json": body}
+	}
+
+	content := Schema{}
+	for mediaType, value := range overrides {
+		schema, ok := value.(Schema)
+		if !ok {
+			schema = registry.ref(value)
+		}
+		content[mediaType] = Schema{"schema": schema}
+	}
+	return content
+}
+
+// Verify checks that every route registered on a router is documented in
+// Operations or listed in OtherRoutes, and that every documented route is
+// registered. Routes that match any method fail the check.
+func Verify(router *mux.Router) error {
+	documented := map[string]bool{}
+	for _, op := range Operations {
+		documented[op.Method+" "+op.Path] = true
+	}
+	for _, route := range OtherRoutes {
+		for _, method := range route.Methods {
+			documented[method+" "+route.Path] = true
+		}
+	}
+
+	registered := map[string]bool{}
+	var undocumented []string
+	err := router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		// Subrouter prefixes have no handler; their routes are walked next
+		if route.GetHandler() == nil {
+			return nil
+		}
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return fmt.Errorf("route %q has no path: %w", route.GetName(), err)
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			undocumented = append(undocumented, "* "+path)
+			return nil
+		}
+		for _, method := range methods {
+			key := method + " " + path
+			registered[key] = true
+			if !documented[key] {
+				undocumented = append(undocumented, key)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var unregistered []string
+	for key := range documented {
+		if !registered[key] {
+			unregistered = append(unregistered, key)
+		}
+	}
+
+	if len(undocumented) > 0 || len(unregistered) > 0 {
+		sort.Strings(undocumented)
+		sort.Strings(unregistered)
+		return fmt.Errorf("OpenAPI document is out of date: undocumented routes %v, documented routes that don't exist %v", undocumented, unregistered)
+	}
+	return nil
+}
+
+// Handler serves the OpenAPI document
+func Handler() http.Handler {
+	document, err := json.MarshalIndent(Document(), "", "  ")
+	if err != nil {
+		panic(fmt.Sprintf("openapi: failed to encode document: %v", err))
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(document)
+	})
+}
+
+// DocsHandler serves the interactive documentation page
+func DocsHandler() http.Handler {
+	page, err := docsFiles.ReadFile("docs/index.html")
+	if err != nil {
+		panic(fmt.Sprintf("openapi: failed to read documentation page: %v", err))
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Content-Security-Policy", docsPolicy)
+		w.Write(page)
+	})
+}
+
+// DocsAssetsHandler serves the scripts and styles of the documentation page
+// under /api/docs/, for GET and HEAD requests
+func DocsAssetsHandler() http.Handler {
+	assets, err := fs.Sub(docsFiles, "docs")
+	if err != nil {
+		panic(fmt.Sprintf("openapi: failed to read documentation assets: %v", err))
+	}
+	files := http.StripPrefix("/api/docs/", http.FileServerFS(assets))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The page itself is served at /api/docs
+		if r.URL.Path == "/api/docs/" || r.URL.Path == "/api/docs/index.html" {
+			problem.NotFoundHandler().ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Content-Security-Policy", docsPolicy)
+		files.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,542 @@
+package openapi
+
+import (
+	"net/http"
+
+	"github.com/noman/todo-application/importers"
+	"github.com/noman/todo-application/models"
+	"github.com/noman/todo-application/patch"
+)
+
+// Parameter describes a query or header parameter
+type Parameter struct {
+	Name        string
+	In          string // "query" or "header"
+	Description string
+	Schema      Schema
+}
+
+// Operation documents one route. Request and Response are zero values of the
+// JSON body types; path parameters are taken from the path template.
+type Operation struct {
+	Method      string
+	Path        string
+	ID          string
+	Tag         string
+	Summary     string
+	Description string
+	Public      bool // Doesn't require a bearer token
+	Parameters  []Parameter
+	Request     interface{}
+	Status      int // Success status; the response has no body if Response is nil
+	Response    interface{}
+
+	// RequestContent and ResponseContent override Request and Response for
+	// bodies that aren't plain JSON, mapping media types to a Schema or to a
+	// zero value of the body type
+	RequestContent  map[string]interface{}
+	ResponseContent map[string]interface{}
+}
+
+// Common parameters
+var (
+	ifMatch = Parameter{
+		Name:        "If-Match",
+		In:          "header",
+		Description: "Only apply the change if the todo still has this ETag",
+		Schema:      Schema{"type": "string"},
+	}
+	ifNoneMatch = Parameter{
+		Name:        "If-None-Match",
+		In:          "header",
+		Description: "Respond with 304 Not Modified if the todo's ETag matches",
+		Schema:      Schema{"type": "string"},
+	}
+)
+
+// Route is a route served outside the JSON API, which the document leaves out
+type Route struct {
+	Methods []string
+	Path    string
+}
+
+// caldavMethods are the methods the CalDAV server accepts
+var caldavMethods = []string{"OPTIONS", "GET", "HEAD", "PUT", "DELETE", "PROPFIND", "PROPPATCH", "REPORT"}
+
+// OtherRoutes are the routes that aren't part of the JSON API: the assets of
+// the documentation page and the CalDAV server. Verify checks them like
+// Operations, so no other route can be registered without documentation.
+var OtherRoutes = []Route{
+	{Methods: []string{"GET", "HEAD"}, Path: "/api/docs/"},
+	{Methods: caldavMethods, Path: "/.well-known/caldav"},
+	{Methods: caldavMethods, Path: "/caldav"},
+	{Methods: caldavMethods, Path: "/caldav/"},
+}
+
+// Operations documents every route the API serves. Verify checks it against
+// the router, so a route can't be added without being documented here.
+var Operations = []Operation{
+	// Auth
+	{
+		Method: http.MethodPost, Path: "/api/auth/register", ID: "register", Tag: "Auth",
+		Summary: "Register a new user", Public: true,
+		Request: models.RegisterRequest{}, Status: http.StatusOK, Response: models.TokenResponse{},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/auth/login", ID: "login", Tag: "Auth",
+		Summary: "Log in", Public: true,
+		Request: models.LoginRequest{}, Status: http.StatusOK, Response: models.TokenResponse{},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/auth/logout", ID: "logout", Tag: "Auth",
+		Summary: "Log out, revoking the bearer token", Status: http.StatusOK, Response: map[string]string{},
+	},
+
+	// Account
+	{
+		Method: http.MethodGet, Path: "/api/users/me", ID: "getAccount", Tag: "Account",
+		Summary: "Get your account",
+		Status:  http.StatusOK, Response: models.Account{},
+	},
+	{
+		Method: http.MethodDelete, Path: "/api/users/me", ID: "deleteAccount", Tag: "Account",
+		Summary: "Delete your account and all of its data, after a grace period",
+		Description: "Requires your password. The account keeps working until deletion_scheduled_at, and the deletion " +
+			"can be cancelled until then. Workspaces you own go to another member, or are deleted if there is none.",
+		Request: models.DeleteAccountRequest{}, Status: http.StatusAccepted, Response: models.Account{},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/users/me/restore", ID: "restoreAccount", Tag: "Account",
+		Summary: "Cancel your account's scheduled deletion",
+		Status:  http.StatusOK, Response: models.Account{},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/users/me/export", ID: "exportAccount", Tag: "Account",
+		Summary:     "Start building an archive of all your data",
+		Description: "The archive is built in the background; if one is already being built, that export is returned.",
+		Status:      http.StatusAccepted, Response: models.AccountExport{},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/users/me/export", ID: "getAccountExport", Tag: "Account",
+		Summary: "Get your latest export, with its status",
+		Status:  http.StatusOK, Response: models.AccountExport{},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/users/me/export/archive", ID: "downloadAccountExport", Tag: "Account",
+		Summary: "Download the zip archive of your latest completed export; supports range requests",
+		Status:  http.StatusOK,
+		ResponseContent: map[string]interface{}{
+			"application/zip": Schema{"type": "string", "contentMediaType": "application/zip"},
+		},
+	},
+
+	// Todos
+	{
+		Method: http.MethodPost, Path: "/api/todos", ID: "createTodo", Tag: "Todos",
+		Summary: "Create a todo",
+		Request: models.CreateTodoRequest{}, Status: http.StatusCreated, Response: models.TodoResponse{},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/todos", ID: "listTodos", Tag: "Todos",
+		Summary: "List todos",
+		Parameters: []Parameter{
+			{Name: "workspace_id", In: "query", Description: "List the todos of a workspace instead of personal todos", Schema: Schema{"type": "string", "format": "uuid"}},
+			{Name: "view", In: "query", Description: "Narrow the list", Schema: Schema{"type": "string", "enum": []string{models.TodoViewAll, models.TodoViewAssignedToMe, models.TodoViewCreatedByMe}}},
+			{Name: "filter", In: "query", Description: "A filter expression like completed:false AND (tag:work OR priority:high) AND due<7d AND \"invoice\". " +
+				"Without workspace_id, every todo you can see is filtered.", Schema: Schema{"type": "string", "maxLength": 1000}},
+			{Name: "limit", In: "query", Description: "Page the list, at most this many todos a page (at most 100). Without it every todo is listed.", Schema: Schema{"type": "integer", "minimum": 1, "maximum": 100}},
+			{Name: "cursor", In: "query", Description: "Where the page starts, from the next link of the previous page", Schema: Schema{"type": "string"}},
+		},
+		Description: "Todos are listed newest first. A paged list has a Link header with rel=\"next\" for the next page, unless it's the last.",
+		Status:      http.StatusOK, Response: []models.TodoResponse{},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/todos/batch", ID: "batchTodos", Tag: "Todos",
+		Summary:     "Run a batch of todo operations in one transaction",
+		Description: "A failed atomic batch responds with 422 and the same body, with committed set to false.",
+		Request:     models.BatchRequest{}, Status: http.StatusOK, Response: models.BatchResponse{},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/todos/search", ID: "searchTodos", Tag: "Todos",
+		Summary: "Search todos by the words in their titles and descriptions",
+		Description: "Every word must match. \"Quoted words\" match a phrase and word* matches every word it starts. " +
+			"Results are ranked with title matches first; title_highlight and snippet are HTML with the matches in <mark>.",
+		Parameters: []Parameter{
+			{Name: "q", In: "query", Description: "The search query, at most 256 characters (required)", Schema: Schema{"type": "string", "maxLength": 256}},
+			{Name: "limit", In: "query", Description: "Maximum number of results to return (default 20, at most 100)", Schema: Schema{"type": "integer", "minimum": 1, "maximum": 100}},
+		},
+		Status: http.StatusOK, Response: []models.SearchResult{},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/todos.ics", ID: "exportTodosICalendar", Tag: "Calendar",
+		Summary:     "Download every todo you can see as an iCalendar file",
+		Description: "Each todo is a VTODO whose UID is the todo's ID. Responds with 304 Not Modified if the If-None-Match header matches the calendar's ETag.",
+		Status:      http.StatusOK, ResponseContent: map[string]interface{}{"text/calendar": Schema{"type": "string"}},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/todos/{id}", ID: "getTodo", Tag: "Todos",
+		Summary: "Get a todo", Parameters: []Parameter{ifNoneMatch},
+		Status: http.StatusOK, Response: models.TodoResponse{},
+	},
+	{
+		Method: http.MethodPut, Path: "/api/todos/{id}", ID: "replaceTodo", Tag: "Todos",
+		Summary: "Replace a todo's editable fields", Parameters: []Parameter{ifMatch},
+		Request: models.UpdateTodoRequest{}, Status: http.StatusOK, Response: models.TodoResponse{},
+	},
+	{
+		Method: http.MethodPatch, Path: "/api/todos/{id}", ID: "patchTodo", Tag: "Todos",
+		Summary: "Patch a todo with a JSON Merge Patch or a JSON Patch", Parameters: []Parameter{ifMatch},
+		RequestContent: map[string]interface{}{
+			patch.MergePatchMediaType: models.UpdateTodoRequest{},
+			patch.JSONPatchMediaType:  []patch.Operation{},
+		},
+		Status: http.StatusOK, Response: models.TodoResponse{},
+	},
+	{
+		Method: http.MethodDelete, Path: "/api/todos/{id}", ID: "deleteTodo", Tag: "Todos",
+		Summary: "Move a todo to the trash", Parameters: []Parameter{ifMatch},
+		Status: http.StatusNoContent,
+	},
+	{
+		Method: http.MethodPut, Path: "/api/todos/{id}/assignees", ID: "assignTodo", Tag: "Todos",
+		Summary: "Replace the assignees of a todo", Parameters: []Parameter{ifMatch},
+		Request: models.AssignTodoRequest{}, Status: http.StatusOK, Response: models.TodoResponse{},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/todos/{id}/history", ID: "getTodoHistory", Tag: "History",
+		Summary: "Get the change history of a todo, oldest first",
+		Status:  http.StatusOK, Response: []models.TodoEvent{},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/todos/{id}/history/{revision}/restore", ID: "restoreTodoRevision", Tag: "History",
+		Summary: "Restore a todo to a previous revision",
+		Status:  http.StatusOK, Response: models.TodoResponse{},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/todos/{id}/restore", ID: "restoreTodo", Tag: "Trash",
+		Summary: "Restore a todo from the trash",
+		Status:  http.StatusOK, Response: models.TodoResponse{},
+	},
+
+	// Comments
+	{
+		Method: http.MethodGet, Path: "/api/todos/{id}/comments", ID: "listComments", Tag: "Comments",
+		Summary: "List the comment threads of a todo",
+		Status:  http.StatusOK, Response: []models.Comment{},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/todos/{id}/comments", ID: "createComment", Tag: "Comments",
+		Summary: "Comment on a todo or reply to a comment",
+		Request: models.CreateCommentRequest{}, Status: http.StatusCreated, Response: models.Comment{},
+	},
+	{
+		Method: http.MethodPut, Path: "/api/todos/{id}/comments/{commentId}", ID: "updateComment", Tag: "Comments",
+		Summary: "Edit your comment",
+		Request: models.UpdateCommentRequest{}, Status: http.StatusOK, Response: models.Comment{},
+	},
+	{
+		Method: http.MethodDelete, Path: "/api/todos/{id}/comments/{commentId}", ID: "deleteComment", Tag: "Comments",
+		Summary: "Delete your comment and its replies",
+		Status:  http.StatusNoContent,
+	},
+
+	// Attachments
+	{
+		Method: http.MethodGet, Path: "/api/todos/{id}/attachments", ID: "listAttachments", Tag: "Attachments",
+		Summary: "List the attachments of a todo",
+		Status:  http.StatusOK, Response: []models.Attachment{},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/todos/{id}/attachments", ID: "uploadAttachments", Tag: "Attachments",
+		Summary: "Upload files; every file part of the form is attached",
+		RequestContent: map[string]interface{}{
+			"multipart/form-data": Schema{
+				"type":       "object",
+				"properties": Schema{"file": Schema{"type": "string", "contentMediaType": "application/octet-stream"}},
+			},
+		},
+		Status: http.StatusCreated, Response: []models.Attachment{},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/todos/{id}/attachments/{attachmentId}", ID: "downloadAttachment", Tag: "Attachments",
+		Summary: "Download an attachment; supports range requests",
+		Status:  http.StatusOK,
+		ResponseContent: map[string]interface{}{
+			"application/octet-stream": Schema{"type": "string", "contentMediaType": "application/octet-stream"},
+		},
+	},
+	{
+		Method: http.MethodDelete, Path: "/api/todos/{id}/attachments/{attachmentId}", ID: "deleteAttachment", Tag: "Attachments",
+		Summary: "Delete an attachment",
+		Status:  http.StatusNoContent,
+	},
+	{
+		Method: http.MethodGet, Path: "/api/storage/usage", ID: "getStorageUsage", Tag: "Attachments",
+		Summary: "Get your attachment storage usage",
+		Status:  http.StatusOK, Response: models.StorageUsageResponse{},
+	},
+
+	// Workspaces
+	{
+		Method: http.MethodPost, Path: "/api/workspaces", ID: "createWorkspace", Tag: "Workspaces",
+		Summary: "Create a workspace",
+		Request: models.CreateWorkspaceRequest{}, Status: http.StatusCreated, Response: models.Workspace{},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/workspaces", ID: "listWorkspaces", Tag: "Workspaces",
+		Summary: "List your workspaces",
+		Status:  http.StatusOK, Response: []models.Workspace{},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/workspaces/{id}/members", ID: "listMembers", Tag: "Workspaces",
+		Summary: "List the members of a workspace",
+		Status:  http.StatusOK, Response: []models.WorkspaceMember{},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/workspaces/{id}/members", ID: "addMember", Tag: "Workspaces",
+		Summary: "Add a member to a workspace", Description: "Responds with the updated member list.",
+		Request: models.AddMemberRequest{}, Status: http.StatusCreated, Response: []models.WorkspaceMember{},
+	},
+	{
+		Method: http.MethodDelete, Path: "/api/workspaces/{id}/members/{userId}", ID: "removeMember", Tag: "Workspaces",
+		Summary: "Remove a member from a workspace",
+		Status:  http.StatusNoContent,
+	},
+	{
+		Method: http.MethodPost, Path: "/api/workspaces/{id}/projects", ID: "createProject", Tag: "Workspaces",
+		Summary: "Create a project in a workspace",
+		Request: models.CreateProjectRequest{}, Status: http.StatusCreated, Response: models.Project{},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/workspaces/{id}/projects", ID: "listProjects", Tag: "Workspaces",
+		Summary: "List the projects of a workspace",
+		Status:  http.StatusOK, Response: []models.Project{},
+	},
+
+	// Notifications
+	{
+		Method: http.MethodGet, Path: "/api/notifications", ID: "listNotifications", Tag: "Notifications",
+		Summary: "List your notifications",
+		Parameters: []Parameter{
+			{Name: "unread", In: "query", Description: "Only list unread notifications", Schema: Schema{"type": "boolean"}},
+		},
+		Status: http.StatusOK, Response: []models.Notification{},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/notifications/read", ID: "markAllNotificationsRead", Tag: "Notifications",
+		Summary: "Mark all your notifications as read",
+		Status:  http.StatusNoContent,
+	},
+	{
+		Method: http.MethodPost, Path: "/api/notifications/{id}/read", ID: "markNotificationRead", Tag: "Notifications",
+		Summary: "Mark a notification as read",
+		Status:  http.StatusNoContent,
+	},
+
+	// Trash
+	{
+		Method: http.MethodGet, Path: "/api/trash", ID: "listTrash", Tag: "Trash",
+		Summary: "List the todos in your trash",
+		Status:  http.StatusOK, Response: []models.TodoResponse{},
+	},
+	{
+		Method: http.MethodDelete, Path: "/api/trash", ID: "emptyTrash", Tag: "Trash",
+		Summary: "Permanently delete every todo in your trash",
+		Status:  http.StatusNoContent,
+	},
+	{
+		Method: http.MethodDelete, Path: "/api/trash/{id}", ID: "purgeTodo", Tag: "Trash",
+		Summary: "Permanently delete a todo from the trash",
+		Status:  http.StatusNoContent,
+	},
+
+	// Events
+	{
+		Method: http.MethodGet, Path: "/api/events", ID: "streamEvents", Tag: "Events",
+		Summary: "Stream changes to your todos as Server-Sent Events",
+		Description: "Sends `todo.created`, `todo.updated` and `todo.deleted` events for every todo you can see. " +
+			"Each event's data is a JSON object with `id`, `type`, `todo_id`, `actor_id`, `created_at` and, " +
+			"except for deleted todos, `todo`. Events missed while disconnected aren't replayed, so reload your " +
+			"todos whenever the stream connects. The stream ends when its token expires or is logged out of.",
+		Parameters: []Parameter{
+			{Name: "ticket", In: "query", Description: "A stream ticket, for clients that can't set the Authorization header", Schema: Schema{"type": "string"}},
+		},
+		Status: http.StatusOK, ResponseContent: map[string]interface{}{"text/event-stream": Schema{"type": "string"}},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/events/tickets", ID: "createStreamTicket", Tag: "Events",
+		Summary:     "Create a ticket to open the event stream with",
+		Description: "The ticket opens one stream, within 30 seconds, as `GET /api/events?ticket=<ticket>`, so clients that can't set headers don't put their token in the URL.",
+		Status:      http.StatusCreated, Response: models.StreamTicket{},
+	},
+
+	// Webhooks
+	{
+		Method: http.MethodGet, Path: "/api/webhooks", ID: "listWebhooks", Tag: "Webhooks",
+		Summary: "List your webhooks",
+		Status:  http.StatusOK, Response: []models.Webhook{},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/webhooks", ID: "createWebhook", Tag: "Webhooks",
+		Summary:     "Register a webhook",
+		Description: "The response is the only one that includes the signing `secret`.",
+		Request:     models.CreateWebhookRequest{}, Status: http.StatusCreated, Response: models.Webhook{},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/webhooks/{id}", ID: "getWebhook", Tag: "Webhooks",
+		Summary: "Get a webhook",
+		Status:  http.StatusOK, Response: models.Webhook{},
+	},
+	{
+		Method: http.MethodPut, Path: "/api/webhooks/{id}", ID: "updateWebhook", Tag: "Webhooks",
+		Summary:     "Update a webhook",
+		Description: "Setting `active` to true re-enables a webhook that was disabled for failing and clears its failures.",
+		Request:     models.UpdateWebhookRequest{}, Status: http.StatusOK, Response: models.Webhook{},
+	},
+	{
+		Method: http.MethodDelete, Path: "/api/webhooks/{id}", ID: "deleteWebhook", Tag: "Webhooks",
+		Summary: "Delete a webhook and its delivery log",
+		Status:  http.StatusNoContent,
+	},
+	{
+		Method: http.MethodGet, Path: "/api/webhooks/{id}/deliveries", ID: "listWebhookDeliveries", Tag: "Webhooks",
+		Summary: "List a webhook's 100 most recent deliveries",
+		Status:  http.StatusOK, Response: []models.WebhookDelivery{},
+	},
+
+	// Calendar
+	{
+		Method: http.MethodGet, Path: "/api/calendar/feed", ID: "getCalendarFeed", Tag: "Calendar",
+		Summary: "Check whether you have a calendar feed; its URL is only shown when it's created",
+		Status:  http.StatusOK, Response: models.CalendarFeed{},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/calendar/feed", ID: "createCalendarFeed", Tag: "Calendar",
+		Summary:     "Create a secret calendar feed URL, replacing any existing one",
+		Description: "Calendar apps can subscribe to the URL without a bearer token. Anyone with the URL can read your todos, so this response is the only one that includes it.",
+		Status:      http.StatusCreated, Response: models.CalendarFeedResponse{},
+	},
+	{
+		Method: http.MethodDelete, Path: "/api/calendar/feed", ID: "deleteCalendarFeed", Tag: "Calendar",
+		Summary: "Revoke your calendar feed, so its URL stops working",
+		Status:  http.StatusNoContent,
+	},
+	{
+		Method: http.MethodGet, Path: "/api/calendar/{token}.ics", ID: "getCalendarFeedICalendar", Tag: "Calendar",
+		Summary:     "Get the todos of a calendar feed as iCalendar",
+		Description: "Authenticated by the secret token in the URL. Responds with 304 Not Modified if the If-None-Match header matches the calendar's ETag.",
+		Public:      true,
+		Status:      http.StatusOK, ResponseContent: map[string]interface{}{"text/calendar": Schema{"type": "string"}},
+	},
+
+	// App passwords
+	{
+		Method: http.MethodGet, Path: "/api/app-passwords", ID: "listAppPasswords", Tag: "App passwords",
+		Summary: "List your app passwords, without the passwords",
+		Status:  http.StatusOK, Response: []models.AppPassword{},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/app-passwords", ID: "createAppPassword", Tag: "App passwords",
+		Summary:     "Create an app password for a CalDAV client",
+		Description: "CalDAV clients sign in at `/caldav/` with your email address and an app password. The response is the only one that includes the `password`.",
+		Request:     models.CreateAppPasswordRequest{}, Status: http.StatusCreated, Response: models.AppPassword{},
+	},
+	{
+		Method: http.MethodDelete, Path: "/api/app-passwords/{id}", ID: "deleteAppPassword", Tag: "App passwords",
+		Summary: "Revoke an app password",
+		Status:  http.StatusNoContent,
+	},
+
+	// Sync
+	{
+		Method: http.MethodGet, Path: "/api/sync", ID: "pullChanges", Tag: "Sync",
+		Summary: "Get the todos created, updated and deleted since a sync token",
+		Description: "Pass the `sync_token` of the previous response as `since`; without it every todo you can see is returned as created. " +
+			"Keep pulling while `has_more` is true. A token older than the server's tombstone retention responds with 410 " +
+			"and the `sync_token_expired` code, after which the client must sync again without a token.",
+		Parameters: []Parameter{
+			{Name: "since", In: "query", Description: "Opaque sync token from a previous response", Schema: Schema{"type": "string"}},
+			{Name: "limit", In: "query", Description: "Maximum number of changes to return (default 500, at most 1000)", Schema: Schema{"type": "integer", "minimum": 1, "maximum": 1000}},
+		},
+		Status: http.StatusOK, Response: models.SyncResponse{},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/sync", ID: "pushChanges", Tag: "Sync",
+		Summary: "Apply edits made while offline",
+		Description: "Changes are applied one at a time, in order, and each gets a result. Fields also changed on the server " +
+			"since `base_version` keep the server's value and are listed in `conflicts`; edits to todos deleted on the server " +
+			"are rejected, and so are deletes of todos edited on the server.",
+		Request: models.SyncPushRequest{}, Status: http.StatusOK, Response: models.SyncPushResponse{},
+	},
+
+	// Import and export
+	{
+		Method: http.MethodGet, Path: "/api/export", ID: "exportTodos", Tag: "Import and export",
+		Summary:     "Download every todo you can see",
+		Description: "todo.txt files only hold each todo's title, completion and dates.",
+		Parameters: []Parameter{
+			{Name: "format", In: "query", Description: "File format (default json)", Schema: Schema{"type": "string", "enum": []string{models.TransferFormatJSON, models.TransferFormatCSV, models.TransferFormatTodoTxt}}},
+		},
+		Status: http.StatusOK,
+		ResponseContent: map[string]interface{}{
+			"application/json": []models.ExportedTodo{},
+			"text/csv":         Schema{"type": "string"},
+			"text/plain":       Schema{"type": "string"},
+		},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/import", ID: "importTodos", Tag: "Import and export",
+		Summary: "Create todos from a file in one of the export formats",
+		Description: "Rows that duplicate a todo in the same list, or an earlier row, are skipped. If any row is invalid " +
+			"nothing is imported and the response is 422, with the errors of each row. A dry run only reports what would be imported.",
+		Parameters: []Parameter{
+			{Name: "format", In: "query", Description: "File format; taken from the Content-Type if missing", Schema: Schema{"type": "string", "enum": []string{models.TransferFormatJSON, models.TransferFormatCSV, models.TransferFormatTodoTxt}}},
+			{Name: "dry_run", In: "query", Description: "Check the file without importing it", Schema: Schema{"type": "boolean"}},
+		},
+		RequestContent: map[string]interface{}{
+			"application/json": []models.ExportedTodo{},
+			"text/csv":         Schema{"type": "string"},
+			"text/plain":       Schema{"type": "string"},
+		},
+		Status: http.StatusOK, Response: models.ImportResponse{},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/import/jobs", ID: "listImportJobs", Tag: "Import and export",
+		Summary: "List your 50 most recent imports from other apps",
+		Status:  http.StatusOK, Response: []models.ImportJob{},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/import/jobs", ID: "createImportJob", Tag: "Import and export",
+		Summary: "Import a Todoist, Trello or Microsoft To Do export in the background",
+		Description: "The first file part of the form is imported; files are limited to 20 MB. Follow the job's progress " +
+			"at the URL in the Location header. Imported todos send todo.created events and webhooks as each chunk of 50 is committed.",
+		Parameters: []Parameter{
+			{Name: "source", In: "query", Description: "App the file was exported from (required)", Schema: Schema{"type": "string", "enum": []string{importers.SourceTodoist, importers.SourceTrello, importers.SourceMicrosoftToDo}}},
+			{Name: "workspace_id", In: "query", Description: "Workspace to import into; projects become workspace projects", Schema: Schema{"type": "string", "format": "uuid"}},
+		},
+		RequestContent: map[string]interface{}{
+			"multipart/form-data": Schema{
+				"type":       "object",
+				"properties": Schema{"file": Schema{"type": "string", "contentMediaType": "application/octet-stream"}},
+			},
+		},
+		Status: http.StatusAccepted, Response: models.ImportJob{},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/import/jobs/{id}", ID: "getImportJob", Tag: "Import and export",
+		Summary: "Get an import, with its progress",
+		Status:  http.StatusOK, Response: models.ImportJob{},
+	},
+
+	// Documentation
+	{
+		Method: http.MethodGet, Path: "/api/openapi.json", ID: "getOpenAPI", Tag: "Documentation",
+		Summary: "Get this OpenAPI document", Public: true,
+		Status: http.StatusOK, ResponseContent: map[string]interface{}{"application/json": Schema{"type": "object"}},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/docs", ID: "getDocs", Tag: "Documentation",
+		Summary: "Browse the interactive API documentation", Public: true,
+		Status: http.StatusOK, ResponseContent: map[string]interface{}{"text/html": Schema{"type": "string"}},
+	},
+}
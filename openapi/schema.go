@@ -0,0 +1,157 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Schema is a JSON Schema object as used by OpenAPI 3.1
+type Schema map[string]interface{}
+
+var (
+	uuidType       = reflect.TypeOf(uuid.UUID{})
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemaRegistry generates schemas for Go types by reflection, collecting
+// named struct types as reusable components
+type schemaRegistry struct {
+	components map[string]Schema
+}
+
+// ref returns a schema for the type of v, which is usually a zero value of a
+// model type
+func (s *schemaRegistry) ref(v interface{}) Schema {
+	return s.schemaFor(reflect.TypeOf(v))
+}
+
+// schemaFor returns the schema of a type, referencing a component for named structs
+func (s *schemaRegistry) schemaFor(t reflect.Type) Schema {
+	switch t {
+	case uuidType:
+		return Schema{"type": "string", "format": "uuid"}
+	case timeType:
+		return Schema{"type": "string", "format": "date-time"}
+	case rawMessageType:
+		return Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return nullable(s.schemaFor(t.Elem()))
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Schema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return Schema{"type": "array", "items": s.schemaFor(t.Elem())}
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": s.schemaFor(t.Elem())}
+	case reflect.Interface:
+		return Schema{}
+	case reflect.Struct:
+		name := t.Name()
+		if _, ok := s.components[name]; !ok {
+			// Register the name first so recursive types terminate
+			s.components[name] = Schema{}
+			s.components[name] = s.structSchema(t)
+		}
+		return Schema{"$ref": "#/components/schemas/" + name}
+	default:
+		return Schema{}
+	}
+}
+
+// structSchema returns the object schema of a struct from its json and validate tags
+func (s *schemaRegistry) structSchema(t reflect.Type) Schema {
+	properties := Schema{}
+	required := []string{}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := s.schemaFor(field.Type)
+		if applyRules(property, field.Type, field.Tag.Get("validate")) {
+			required = append(required, name)
+		}
+		properties[name] = property
+	}
+
+	schema := Schema{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// applyRules adds the constraints of a validate tag to a property schema and
+// reports whether the field is required
+func applyRules(property Schema, t reflect.Type, tag string) bool {
+	if tag == "" {
+		return false
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	required := false
+	for _, rule := range strings.Split(tag, ",") {
+		name, arg, _ := strings.Cut(rule, "=")
+		limit, _ := strconv.Atoi(arg)
+
+		switch name {
+		case "required":
+			required = true
+		case "min", "max":
+			key := "Length"
+			if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+				key = "Items"
+			}
+			property[name+key] = limit
+		case "itemmax":
+			if items, ok := property["items"].(Schema); ok {
+				items["maxLength"] = limit
+			}
+		case "email":
+			property["format"] = "email"
+		case "oneof":
+			property["enum"] = strings.Fields(arg)
+		}
+	}
+
+	return required
+}
+
+// nullable allows null as well as the values of a schema
+func nullable(schema Schema) Schema {
+	if typ, ok := schema["type"].(string); ok {
+		copied := Schema{}
+		for key, value := range schema {
+			copied[key] = value
+		}
+		copied["type"] = []string{typ, "null"}
+		return copied
+	}
+	return Schema{"oneOf": []Schema{schema, {"type": "null"}}}
+}
@@ -0,0 +1,53 @@
+// Package patch applies JSON Merge Patch (RFC 7396) and JSON Patch (RFC 6902)
+// documents to generic JSON values as produced by encoding/json.
+package patch
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Media types of the supported patch formats
+const (
+	MergePatchMediaType = "application/merge-patch+json"
+	JSONPatchMediaType  = "application/json-patch+json"
+)
+
+// MergePatch applies a JSON Merge Patch to a target document. Both are raw
+// JSON; the patched document is returned as raw JSON.
+func MergePatch(target, patch []byte) ([]byte, error) {
+	var targetValue, patchValue interface{}
+	if err := json.Unmarshal(target, &targetValue); err != nil {
+		return nil, fmt.Errorf("invalid target document: %w", err)
+	}
+	if err := json.Unmarshal(patch, &patchValue); err != nil {
+		return nil, fmt.Errorf("invalid merge patch: %w", err)
+	}
+
+	return json.Marshal(mergeValue(targetValue, patchValue))
+}
+
+// mergeValue implements the MergePatch algorithm from RFC 7396 section 2:
+// objects are merged member by member, a null member removes the member from
+// the target, and any other patch value replaces the target outright
+func mergeValue(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = map[string]interface{}{}
+	}
+
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+		} else {
+			targetObject[name] = mergeValue(targetObject[name], value)
+		}
+	}
+
+	return targetObject
+}
@@ -0,0 +1,110 @@
+// Package problem writes error responses as RFC 7807 problem details
+// (application/problem+json) with stable, machine-readable error codes.
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// MediaType is the content type of problem responses
+const MediaType = "application/problem+json"
+
+// RequestIDHeader is the header carrying the ID of a request, which is echoed
+// in problem responses so clients can quote it when reporting errors
+const RequestIDHeader = "X-Request-ID"
+
+// Stable error codes. Clients should branch on these rather than on the
+// human-readable detail, which may change.
+const (
+	CodeInvalidRequest       = "invalid_request"
+	CodeUnauthorized         = "unauthorized"
+	CodeForbidden            = "forbidden"
+	CodeNotFound             = "not_found"
+	CodeMethodNotAllowed     = "method_not_allowed"
+	CodeConflict             = "conflict"
+	CodeVersionConflict      = "version_conflict"
+	CodePayloadTooLarge      = "payload_too_large"
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodeValidationFailed     = "validation_failed"
+	CodePatchTestFailed      = "patch_test_failed"
+	CodeQuotaExceeded        = "quota_exceeded"
+	CodeIdempotencyKeyReused = "idempotency_key_reused"
+	CodeRequestInProgress    = "request_in_progress"
+	CodeBatchRolledBack      = "batch_rolled_back"
+	CodeSyncTokenExpired     = "sync_token_expired"
+	CodeInternal             = "internal_error"
+)
+
+// Problem is an RFC 7807 problem details object, extended with an error code,
+// the request ID and field-level validation errors
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError describes what is wrong with one field of a request
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error implements the error interface
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// Write writes a problem response, filling in the type, title and request ID
+func Write(w http.ResponseWriter, p *Problem) {
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+	p.RequestID = w.Header().Get(RequestIDHeader)
+
+	w.Header().Set("Content-Type", MediaType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// Error writes a problem response with a status, error code and detail message
+func Error(w http.ResponseWriter, status int, code, detail string) {
+	Write(w, &Problem{
+		Status: status,
+		Code:   code,
+		Detail: detail,
+	})
+}
+
+// Invalid writes a validation problem listing what is wrong with each field
+func Invalid(w http.ResponseWriter, status int, detail string, fields ...FieldError) {
+	Write(w, &Problem{
+		Status: status,
+		Code:   CodeValidationFailed,
+		Detail: detail,
+		Errors: fields,
+	})
+}
+
+// NotFoundHandler responds to requests that match no route
+func NotFoundHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Error(w, http.StatusNotFound, CodeNotFound, "No such endpoint")
+	})
+}
+
+// MethodNotAllowedHandler responds to requests whose route doesn't support the method
+func MethodNotAllowedHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Error(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed")
+	})
+}
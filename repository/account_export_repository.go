@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/noman/todo-application/database"
+	"github.com/noman/todo-application/models"
+)
+
+// accountExportColumns is the list of columns selected for an account export
+const accountExportColumns = `id, user_id, status, storage_key, size, error, created_at, finished_at, expires_at`
+
+// AccountExportRepository handles database operations for account exports
+type AccountExportRepository struct {
+	db *sql.DB
+}
+
+// NewAccountExportRepository creates a new AccountExportRepository
+func NewAccountExportRepository() *AccountExportRepository {
+	return &AccountExportRepository{
+		db: database.DB,
+	}
+}
+
+// scanAccountExport scans an account export selected with accountExportColumns
+func scanAccountExport(row rowScanner) (*models.AccountExport, error) {
+	export := &models.AccountExport{}
+	var finishedAt, expiresAt sql.NullTime
+	err := row.Scan(&export.ID, &export.UserID, &export.Status, &export.StorageKey, &export.Size, &export.Error, &export.CreatedAt, &finishedAt, &expiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if finishedAt.Valid {
+		export.FinishedAt = &finishedAt.Time
+	}
+	if expiresAt.Valid {
+		export.ExpiresAt = &expiresAt.Time
+	}
+
+	return export, nil
+}
+
+// Create queues a new export of a user's account
+func (r *AccountExportRepository) Create(export *models.AccountExport) error {
+	// Set the ID, status and timestamp
+	export.ID = uuid.New()
+	export.Status = models.AccountExportQueued
+	export.CreatedAt = time.Now()
+
+	query := `
+	INSERT INTO account_exports (id, user_id, status, created_at)
+	VALUES ($1, $2, $3, $4)
+	`
+
+	_, err := r.db.Exec(query, export.ID, export.UserID, export.Status, export.CreatedAt)
+	return err
+}
+
+// GetLatest gets a user's most recent export
+func (r *AccountExportRepository) GetLatest(userID uuid.UUID) (*models.AccountExport, error) {
+	query := `
+	SELECT ` + accountExportColumns + `
+	FROM account_exports
+	WHERE user_id = $1
+	ORDER BY created_at DESC
+	LIMIT 1
+	`
+
+	export, err := scanAccountExport(r.db.QueryRow(query, userID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrAccountExportNotFound
+		}
+		return nil, err
+	}
+
+	return export, nil
+}
+
+// Claim reserves the oldest queued export for lease and marks it running.
+// Running exports whose lease lapsed, because their worker stopped, are
+// claimed again and start over. It returns nil if no export is waiting.
+func (r *AccountExportRepository) Claim(lease time.Duration) (*models.AccountExport, error) {
+	now := time.Now()
+	query := `
+	UPDATE account_exports
+	SET status = $1, locked_until = $2
+	WHERE id = (
+		SELECT id
+		FROM account_exports
+		WHERE status = $3 OR (status = $1 AND locked_until < $4)
+		ORDER BY created_at
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING ` + accountExportColumns
+
+	export, err := scanAccountExport(r.db.QueryRow(query, models.AccountExportRunning, now.Add(lease), models.AccountExportQueued, now))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return export, nil
+}
+
+// Finish records that an export completed, with its archive, or failed if
+// export.Error is set
+func (r *AccountExportRepository) Finish(export *models.AccountExport) error {
+	export.Status = models.AccountExportCompleted
+	if export.Error != "" {
+		export.Status = models.AccountExportFailed
+	}
+	now := time.Now()
+	export.FinishedAt = &now
+
+	query := `
+	UPDATE account_exports
+	SET status = $1, storage_key = $2, size = $3, error = $4, finished_at = $5, expires_at = $6, locked_until = NULL
+	WHERE id = $7
+	`
+
+	_, err := r.db.Exec(query, export.Status, export.StorageKey, export.Size, export.Error, export.FinishedAt, export.ExpiresAt, export.ID)
+	return err
+}
+
+// GetExpired gets the exports that expired before cutoff
+func (r *AccountExportRepository) GetExpired(cutoff time.Time) ([]*models.AccountExport, error) {
+	query := `
+	SELECT ` + accountExportColumns + `
+	FROM account_exports
+	WHERE expires_at < $1
+	`
+
+	rows, err := r.db.Query(query, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	exports := []*models.AccountExport{}
+	for rows.Next() {
+		export, err := scanAccountExport(rows)
+		if err != nil {
+			return nil, err
+		}
+		exports = append(exports, export)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return exports, nil
+}
+
+// Delete deletes an export
+func (r *AccountExportRepository) Delete(id uuid.UUID) error {
+	_, err := r.db.Exec(`DELETE FROM account_exports WHERE id = $1`, id)
+	return err
+}
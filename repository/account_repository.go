@@ -0,0 +1,278 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/noman/todo-application/database"
+	"github.com/noman/todo-application/models"
+)
+
+// AccountRepository handles database operations for whole accounts: their
+// scheduled deletion, and the deletion itself
+type AccountRepository struct {
+	db *sql.DB
+}
+
+// NewAccountRepository creates a new AccountRepository
+func NewAccountRepository() *AccountRepository {
+	return &AccountRepository{
+		db: database.DB,
+	}
+}
+
+// GetByID gets a user's account
+func (r *AccountRepository) GetByID(userID uuid.UUID) (*models.Account, error) {
+	query := `
+	SELECT id, username, email, created_at, deletion_scheduled_at
+	FROM users
+	WHERE id = $1
+	`
+
+	account := &models.Account{}
+	var deletionScheduledAt sql.NullTime
+	err := r.db.QueryRow(query, userID).Scan(&account.ID, &account.Username, &account.Email, &account.CreatedAt, &deletionScheduledAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	if deletionScheduledAt.Valid {
+		account.DeletionScheduledAt = &deletionScheduledAt.Time
+	}
+
+	return account, nil
+}
+
+// ScheduleDeletion schedules a user's account to be deleted at the given
+// time, unless it already is, and returns when it will be deleted
+func (r *AccountRepository) ScheduleDeletion(userID uuid.UUID, at time.Time) (time.Time, error) {
+	query := `
+	UPDATE users
+	SET deletion_scheduled_at = COALESCE(deletion_scheduled_at, $1), updated_at = $2
+	WHERE id = $3
+	RETURNING deletion_scheduled_at
+	`
+
+	var scheduledAt time.Time
+	err := r.db.QueryRow(query, at, time.Now(), userID).Scan(&scheduledAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return time.Time{}, ErrUserNotFound
+		}
+		return time.Time{}, err
+	}
+
+	return scheduledAt, nil
+}
+
+// CancelDeletion cancels a user's scheduled account deletion
+func (r *AccountRepository) CancelDeletion(userID uuid.UUID) error {
+	query := `
+	UPDATE users
+	SET deletion_scheduled_at = NULL, updated_at = $1
+	WHERE id = $2 AND deletion_scheduled_at IS NOT NULL
+	`
+
+	_, err := r.db.Exec(query, time.Now(), userID)
+	return err
+}
+
+// GetDueForDeletion gets the IDs of the users whose accounts were scheduled
+// to be deleted before cutoff
+func (r *AccountRepository) GetDueForDeletion(cutoff time.Time) ([]uuid.UUID, error) {
+	query := `
+	SELECT id
+	FROM users
+	WHERE deletion_scheduled_at <= $1
+	ORDER BY deletion_scheduled_at
+	`
+
+	return queryIDs(r.db, query, cutoff)
+}
+
+// Delete deletes a user and everything that's theirs, and returns the storage
+// keys of the blobs that are no longer referenced. Each workspace they own
+// goes to another of its members, preferring other owners and then the
+// longest-standing member; workspaces without other members are deleted.
+// The todos they created, and those in the workspaces that are deleted, go
+// with the user, and the other people who could see them are told through
+// sync. It fails with ErrUserNotFound unless the account is still scheduled
+// to be deleted by cutoff.
+// Every table referencing users cascades, except todo_events, which has no
+// foreign keys; the user's changes to other people's todos stay in those
+// todos' history.
+func (r *AccountRepository) Delete(userID uuid.UUID, cutoff time.Time) ([]string, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Lock the user, in case they cancel the deletion meanwhile
+	query := `
+	SELECT id
+	FROM users
+	WHERE id = $1 AND deletion_scheduled_at <= $2
+	FOR UPDATE
+	`
+
+	if err := tx.QueryRow(query, userID, cutoff).Scan(&userID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	transferQuery := `
+	WITH heirs AS (
+		SELECT DISTINCT ON (m.workspace_id) m.workspace_id, m.user_id
+		FROM workspace_members m
+		JOIN workspaces w ON w.id = m.workspace_id
+		WHERE w.owner_id = $1 AND m.user_id <> $1
+		ORDER BY m.workspace_id, m.role = $2 DESC, m.created_at, m.user_id
+	), transferred AS (
+		UPDATE workspaces w
+		SET owner_id = h.user_id, updated_at = $3
+		FROM heirs h
+		WHERE w.id = h.workspace_id
+		RETURNING w.id, h.user_id
+	)
+	UPDATE workspace_members m
+	SET role = $2
+	FROM transferred t
+	WHERE m.workspace_id = t.id AND m.user_id = t.user_id
+	`
+
+	if _, err := tx.Exec(transferQuery, userID, models.WorkspaceRoleOwner, time.Now()); err != nil {
+		return nil, err
+	}
+
+	// Lock the todos that go with the user
+	todoIDs, err := queryIDs(tx, `
+	SELECT id
+	FROM todos
+	WHERE user_id = $1 OR workspace_id IN (SELECT id FROM workspaces WHERE owner_id = $1)
+	ORDER BY id
+	FOR UPDATE
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Log the changes while the todos' audiences can still be worked out
+	for _, todoID := range todoIDs {
+		if err := recordTodoChange(tx, todoID); err != nil {
+			return nil, err
+		}
+	}
+
+	// Collect the blobs before their rows cascade away
+	keysQuery := `
+	SELECT storage_key FROM attachments
+	WHERE user_id = $1 OR todo_id IN (SELECT id FROM todos WHERE user_id = $1 OR workspace_id IN (SELECT id FROM workspaces WHERE owner_id = $1))
+	UNION ALL
+	SELECT storage_key FROM account_exports WHERE user_id = $1 AND storage_key <> ''
+	`
+
+	rows, err := tx.Query(keysQuery, userID)
+	if err != nil {
+		return nil, err
+	}
+	keys := []string{}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Delete the history of the todos, and of those the user created that
+	// were already purged
+	historyQuery := `
+	DELETE FROM todo_events
+	WHERE todo_id IN (SELECT id FROM todos WHERE user_id = $1 OR workspace_id IN (SELECT id FROM workspaces WHERE owner_id = $1))
+		OR todo_id IN (SELECT todo_id FROM todo_events WHERE action = $2 AND actor_id = $1)
+	`
+
+	if _, err := tx.Exec(historyQuery, userID, models.TodoEventCreated); err != nil {
+		return nil, err
+	}
+
+	todosQuery := `
+	DELETE FROM todos
+	WHERE user_id = $1 OR workspace_id IN (SELECT id FROM workspaces WHERE owner_id = $1)
+	`
+
+	if _, err := tx.Exec(todosQuery, userID); err != nil {
+		return nil, err
+	}
+
+	// Projects the user created in other people's workspaces now belong to
+	// the workspace's owner, rather than cascading away
+	projectsQuery := `
+	UPDATE projects p
+	SET created_by = w.owner_id
+	FROM workspaces w
+	WHERE w.id = p.workspace_id AND p.created_by = $1 AND w.owner_id <> $1
+	`
+
+	if _, err := tx.Exec(projectsQuery, userID); err != nil {
+		return nil, err
+	}
+
+	// The other members see the user's assignments go
+	unassigned, err := queryIDs(tx, `DELETE FROM todo_assignees WHERE user_id = $1 RETURNING todo_id`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, todoID := range unassigned {
+		if err := recordTodoChange(tx, todoID); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := tx.Exec(`DELETE FROM users WHERE id = $1`, userID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// queryIDs runs a query returning a single ID column
+func queryIDs(q querier, query string, args ...interface{}) ([]uuid.UUID, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
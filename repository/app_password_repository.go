@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/noman/todo-application/database"
+	"github.com/noman/todo-application/models"
+)
+
+// AppPasswordRepository handles database operations for app passwords
+type AppPasswordRepository struct {
+	db *sql.DB
+}
+
+// NewAppPasswordRepository creates a new AppPasswordRepository
+func NewAppPasswordRepository() *AppPasswordRepository {
+	return &AppPasswordRepository{
+		db: database.DB,
+	}
+}
+
+// hashAppPassword returns the hash an app password is stored as. App
+// passwords are long and random, so a fast hash is enough. Dashes, spaces and
+// case are ignored, since people type these passwords in by hand.
+func hashAppPassword(password string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(password))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// Create creates a new app password; its Password is the plain password
+func (r *AppPasswordRepository) Create(appPassword *models.AppPassword) error {
+	// Set the ID and timestamp
+	appPassword.ID = uuid.New()
+	appPassword.CreatedAt = time.Now()
+
+	query := `
+	INSERT INTO app_passwords (id, user_id, name, password_hash, created_at)
+	VALUES ($1, $2, $3, $4, $5)
+	`
+
+	_, err := r.db.Exec(query, appPassword.ID, appPassword.UserID, appPassword.Name, hashAppPassword(appPassword.Password), appPassword.CreatedAt)
+	return err
+}
+
+// GetAllByUserID gets all of a user's app passwords, without the passwords
+func (r *AppPasswordRepository) GetAllByUserID(userID uuid.UUID) ([]*models.AppPassword, error) {
+	query := `
+	SELECT id, user_id, name, created_at, last_used_at
+	FROM app_passwords
+	WHERE user_id = $1
+	ORDER BY created_at
+	`
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	appPasswords := []*models.AppPassword{}
+	for rows.Next() {
+		appPassword := &models.AppPassword{}
+		var lastUsedAt sql.NullTime
+		if err := rows.Scan(&appPassword.ID, &appPassword.UserID, &appPassword.Name, &appPassword.CreatedAt, &lastUsedAt); err != nil {
+			return nil, err
+		}
+		if lastUsedAt.Valid {
+			appPassword.LastUsedAt = &lastUsedAt.Time
+		}
+		appPasswords = append(appPasswords, appPassword)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return appPasswords, nil
+}
+
+// Delete deletes one of a user's app passwords
+func (r *AppPasswordRepository) Delete(id, userID uuid.UUID) error {
+	result, err := r.db.Exec(`DELETE FROM app_passwords WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+
+	// Check if the app password was found
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrAppPasswordNotFound
+	}
+
+	return nil
+}
+
+// Authenticate checks an email address and app password, returning the
+// user's ID and recording that the password was used
+func (r *AppPasswordRepository) Authenticate(email, password string) (uuid.UUID, error) {
+	query := `
+	UPDATE app_passwords a
+	SET last_used_at = $3
+	FROM users u
+	WHERE a.password_hash = $1 AND u.id = a.user_id AND u.email = $2
+	RETURNING a.user_id
+	`
+
+	var userID uuid.UUID
+	err := r.db.QueryRow(query, hashAppPassword(password), email, time.Now()).Scan(&userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return uuid.Nil, ErrInvalidPassword
+		}
+		return uuid.Nil, err
+	}
+
+	return userID, nil
+}
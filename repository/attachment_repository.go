@@ -0,0 +1,196 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/noman/todo-application/database"
+	"github.com/noman/todo-application/models"
+)
+
+// AttachmentRepository handles database operations for attachments
+type AttachmentRepository struct {
+	db *sql.DB
+}
+
+// NewAttachmentRepository creates a new AttachmentRepository
+func NewAttachmentRepository() *AttachmentRepository {
+	return &AttachmentRepository{
+		db: database.DB,
+	}
+}
+
+// CreateAll records new attachments uploaded by a user, all of them or none;
+// the caller sets their IDs so they can be used in the storage keys. It fails
+// with ErrQuotaExceeded if they would take the user's attachments over quota
+// bytes. The user stays locked until they're recorded, so concurrent uploads
+// can't each fit in the same remaining space.
+func (r *AttachmentRepository) CreateAll(userID uuid.UUID, attachments []*models.Attachment, quota int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	lockQuery := `SELECT id FROM users WHERE id = $1 FOR UPDATE`
+	var id uuid.UUID
+	if err := tx.QueryRow(lockQuery, userID).Scan(&id); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrUserNotFound
+		}
+		return err
+	}
+
+	usageQuery := `SELECT COALESCE(SUM(size), 0) FROM attachments WHERE user_id = $1`
+	var used int64
+	if err := tx.QueryRow(usageQuery, userID).Scan(&used); err != nil {
+		return err
+	}
+	for _, attachment := range attachments {
+		used += attachment.Size
+	}
+	if used > quota {
+		return ErrQuotaExceeded
+	}
+
+	query := `
+	INSERT INTO attachments (id, todo_id, user_id, filename, content_type, size, storage_key, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+
+	now := time.Now()
+	for _, attachment := range attachments {
+		attachment.CreatedAt = now
+		_, err := tx.Exec(query, attachment.ID, attachment.TodoID, userID, attachment.Filename, attachment.ContentType, attachment.Size, attachment.StorageKey, attachment.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetByID gets an attachment by ID
+func (r *AttachmentRepository) GetByID(id uuid.UUID) (*models.Attachment, error) {
+	query := `
+	SELECT id, todo_id, user_id, filename, content_type, size, storage_key, created_at
+	FROM attachments
+	WHERE id = $1
+	`
+
+	attachment := &models.Attachment{}
+	err := r.db.QueryRow(query, id).Scan(&attachment.ID, &attachment.TodoID, &attachment.UserID, &attachment.Filename, &attachment.ContentType, &attachment.Size, &attachment.StorageKey, &attachment.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrAttachmentNotFound
+		}
+		return nil, err
+	}
+
+	return attachment, nil
+}
+
+// GetAllByTodoID gets all attachments of a todo
+func (r *AttachmentRepository) GetAllByTodoID(todoID uuid.UUID) ([]*models.Attachment, error) {
+	query := `
+	SELECT id, todo_id, user_id, filename, content_type, size, storage_key, created_at
+	FROM attachments
+	WHERE todo_id = $1
+	ORDER BY created_at
+	`
+
+	rows, err := r.db.Query(query, todoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := []*models.Attachment{}
+	for rows.Next() {
+		attachment := &models.Attachment{}
+		err := rows.Scan(&attachment.ID, &attachment.TodoID, &attachment.UserID, &attachment.Filename, &attachment.ContentType, &attachment.Size, &attachment.StorageKey, &attachment.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, attachment)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return attachments, nil
+}
+
+// GetAllByUserID gets every attachment a user uploaded, oldest first
+func (r *AttachmentRepository) GetAllByUserID(userID uuid.UUID) ([]*models.Attachment, error) {
+	query := `
+	SELECT id, todo_id, user_id, filename, content_type, size, storage_key, created_at
+	FROM attachments
+	WHERE user_id = $1
+	ORDER BY created_at
+	`
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := []*models.Attachment{}
+	for rows.Next() {
+		attachment := &models.Attachment{}
+		err := rows.Scan(&attachment.ID, &attachment.TodoID, &attachment.UserID, &attachment.Filename, &attachment.ContentType, &attachment.Size, &attachment.StorageKey, &attachment.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, attachment)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return attachments, nil
+}
+
+// GetUsageByUserID gets the total size of the attachments a user has uploaded
+func (r *AttachmentRepository) GetUsageByUserID(userID uuid.UUID) (int64, error) {
+	query := `
+	SELECT COALESCE(SUM(size), 0) FROM attachments
+	WHERE user_id = $1
+	`
+
+	var used int64
+	if err := r.db.QueryRow(query, userID).Scan(&used); err != nil {
+		return 0, err
+	}
+
+	return used, nil
+}
+
+// Delete deletes an attachment record
+func (r *AttachmentRepository) Delete(id uuid.UUID) error {
+	query := `
+	DELETE FROM attachments
+	WHERE id = $1
+	`
+
+	result, err := r.db.Exec(query, id)
+	if err != nil {
+		return err
+	}
+
+	// Check if the attachment was found
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrAttachmentNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/noman/todo-application/database"
+)
+
+// querier is implemented by *sql.DB, *sql.Tx and txn
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// txn is a transaction used by a repository method. When the repository is
+// bound to a Batch the transaction belongs to the batch, and committing or
+// rolling it back is left to the batch's owner.
+type txn struct {
+	*sql.Tx
+	owned bool
+}
+
+// Commit commits the transaction if the repository method started it
+func (t *txn) Commit() error {
+	if !t.owned {
+		return nil
+	}
+	return t.Tx.Commit()
+}
+
+// Rollback rolls the transaction back if the repository method started it
+func (t *txn) Rollback() error {
+	if !t.owned {
+		return nil
+	}
+	return t.Tx.Rollback()
+}
+
+// Batch is a database transaction that repositories can be bound to, so that
+// several operations commit or roll back together. Savepoints let a single
+// operation be undone without abandoning the rest of the batch.
+type Batch struct {
+	tx         *sql.Tx
+	savepoints int
+}
+
+// BeginBatch starts a new batch
+func BeginBatch() (*Batch, error) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &Batch{tx: tx}, nil
+}
+
+// Todos returns a TodoRepository that runs every operation inside the batch
+func (b *Batch) Todos() *TodoRepository {
+	return &TodoRepository{
+		db: database.DB,
+		tx: b.tx,
+	}
+}
+
+// CalDAV returns a CalDAVRepository that runs every operation inside the batch
+func (b *Batch) CalDAV() *CalDAVRepository {
+	return &CalDAVRepository{
+		db: database.DB,
+		tx: b.tx,
+	}
+}
+
+// ImportJobs returns an ImportJobRepository that runs every operation inside the batch
+func (b *Batch) ImportJobs() *ImportJobRepository {
+	return &ImportJobRepository{
+		db: database.DB,
+		tx: b.tx,
+	}
+}
+
+// Savepoint marks the current state of the batch and returns the savepoint's name
+func (b *Batch) Savepoint() (string, error) {
+	b.savepoints++
+	name := fmt.Sprintf("batch_%d", b.savepoints)
+	if _, err := b.tx.Exec("SAVEPOINT " + name); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// RollbackTo undoes everything done since a savepoint
+func (b *Batch) RollbackTo(name string) error {
+	_, err := b.tx.Exec("ROLLBACK TO SAVEPOINT " + name)
+	return err
+}
+
+// Release forgets a savepoint, keeping everything done since it
+func (b *Batch) Release(name string) error {
+	_, err := b.tx.Exec("RELEASE SAVEPOINT " + name)
+	return err
+}
+
+// Commit commits the batch
+func (b *Batch) Commit() error {
+	return b.tx.Commit()
+}
+
+// Rollback abandons the batch; it is a no-op after Commit
+func (b *Batch) Rollback() error {
+	return b.tx.Rollback()
+}
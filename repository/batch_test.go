@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/noman/todo-application/database/databasetest"
+	"github.com/noman/todo-application/models"
+)
+
+func TestBatchSavepoints(t *testing.T) {
+	databasetest.Open(t)
+	user := databasetest.NewUser(t)
+	batch, err := BeginBatch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer batch.Rollback()
+	todos := batch.Todos()
+
+	kept := &models.Todo{Title: "Kept", UserID: user.ID}
+	if err := todos.Create(kept); err != nil {
+		t.Fatal(err)
+	}
+
+	// A failed statement aborts the transaction until it's rolled back to
+	// the savepoint before it, along with what else was done since
+	savepoint, err := batch.Savepoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	undone := &models.Todo{Title: "Undone", UserID: user.ID}
+	if err := todos.Create(undone); err != nil {
+		t.Fatal(err)
+	}
+	if err := todos.Create(&models.Todo{ID: kept.ID, Title: "Duplicate", UserID: user.ID}); err == nil {
+		t.Fatal("creating a todo with a used ID succeeded")
+	}
+	if err := batch.RollbackTo(savepoint); err != nil {
+		t.Fatal(err)
+	}
+
+	// Work after a released savepoint is kept
+	savepoint, err = batch.Savepoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	released := &models.Todo{Title: "Released", UserID: user.ID}
+	if err := todos.Create(released); err != nil {
+		t.Fatal(err)
+	}
+	if err := batch.Release(savepoint); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing is visible outside the batch until it's committed
+	outside := NewTodoRepository()
+	if _, err := outside.GetByID(kept.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("uncommitted todo: got %v, want ErrNotFound", err)
+	}
+	if err := batch.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, todo := range []*models.Todo{kept, released} {
+		if _, err := outside.GetByID(todo.ID); err != nil {
+			t.Errorf("%s: %v", todo.Title, err)
+		}
+	}
+	if _, err := outside.GetByID(undone.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("todo created before the rollback: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestBatchRollback(t *testing.T) {
+	databasetest.Open(t)
+	user := databasetest.NewUser(t)
+	existing := &models.Todo{Title: "Existing", UserID: user.ID}
+	if err := NewTodoRepository().Create(existing); err != nil {
+		t.Fatal(err)
+	}
+
+	batch, err := BeginBatch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	todos := batch.Todos()
+	created := &models.Todo{Title: "Created", UserID: user.ID}
+	if err := todos.Create(created); err != nil {
+		t.Fatal(err)
+	}
+	existing.Title = "Renamed"
+	if err := todos.Update(existing, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := batch.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	outside := NewTodoRepository()
+	if _, err := outside.GetByID(created.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("todo created in the batch: got %v, want ErrNotFound", err)
+	}
+	todo, err := outside.GetByID(existing.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if todo.Title != "Existing" || todo.Version != 1 {
+		t.Errorf("todo updated in the batch = %+v", todo)
+	}
+}
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/noman/todo-application/database"
+	"github.com/noman/todo-application/models"
+)
+
+// CalDAVRepository handles database operations for the CalDAV resource names
+// of todos
+type CalDAVRepository struct {
+	db *sql.DB
+	tx *sql.Tx // set when the repository is bound to a Batch
+}
+
+// NewCalDAVRepository creates a new CalDAVRepository
+func NewCalDAVRepository() *CalDAVRepository {
+	return &CalDAVRepository{
+		db: database.DB,
+	}
+}
+
+// conn returns the batch's transaction if the repository is bound to one, or the database
+func (r *CalDAVRepository) conn() querier {
+	if r.tx != nil {
+		return r.tx
+	}
+	return r.db
+}
+
+// Set stores the resource name and UID of a todo
+func (r *CalDAVRepository) Set(resource *models.CalDAVResource) error {
+	query := `
+	INSERT INTO caldav_resources (todo_id, name, uid)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (todo_id) DO UPDATE SET name = EXCLUDED.name, uid = EXCLUDED.uid
+	`
+
+	_, err := r.conn().Exec(query, resource.TodoID, resource.Name, resource.UID)
+	return err
+}
+
+// GetByTodoIDs gets the resources of the given todos, keyed by todo ID.
+// Todos without one are left out.
+func (r *CalDAVRepository) GetByTodoIDs(todoIDs []uuid.UUID) (map[uuid.UUID]*models.CalDAVResource, error) {
+	resources := map[uuid.UUID]*models.CalDAVResource{}
+	if len(todoIDs) == 0 {
+		return resources, nil
+	}
+
+	ids := make([]string, len(todoIDs))
+	for i, id := range todoIDs {
+		ids[i] = id.String()
+	}
+
+	rows, err := r.conn().Query(`SELECT todo_id, name, uid FROM caldav_resources WHERE todo_id = ANY($1::uuid[])`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		resource := &models.CalDAVResource{}
+		if err := rows.Scan(&resource.TodoID, &resource.Name, &resource.UID); err != nil {
+			return nil, err
+		}
+		resources[resource.TodoID] = resource
+	}
+
+	return resources, rows.Err()
+}
+
+// FindTodoID gets the ID of the live todo with a resource name in a
+// collection: a workspace's todos, or a user's personal todos when
+// workspaceID is nil
+func (r *CalDAVRepository) FindTodoID(name string, userID uuid.UUID, workspaceID *uuid.UUID) (uuid.UUID, error) {
+	query := `
+	SELECT t.id
+	FROM caldav_resources c
+	JOIN todos t ON t.id = c.todo_id
+	WHERE c.name = $1 AND t.deleted_at IS NULL
+		AND ((t.workspace_id IS NULL AND t.user_id = $2 AND $3::uuid IS NULL) OR t.workspace_id = $3)
+	`
+
+	var todoID uuid.UUID
+	err := r.conn().QueryRow(query, name, userID, workspaceID).Scan(&todoID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return uuid.Nil, ErrTodoNotFound
+		}
+		return uuid.Nil, err
+	}
+
+	return todoID, nil
+}
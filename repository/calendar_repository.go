@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/noman/todo-application/database"
+	"github.com/noman/todo-application/models"
+)
+
+// CalendarRepository handles database operations for calendar feeds
+type CalendarRepository struct {
+	db *sql.DB
+}
+
+// NewCalendarRepository creates a new CalendarRepository
+func NewCalendarRepository() *CalendarRepository {
+	return &CalendarRepository{
+		db: database.DB,
+	}
+}
+
+// hashFeedToken returns the hash a feed token is stored as, so a leaked
+// database doesn't leak working feed URLs
+func hashFeedToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// GetFeed gets a user's calendar feed
+func (r *CalendarRepository) GetFeed(userID uuid.UUID) (*models.CalendarFeed, error) {
+	feed := &models.CalendarFeed{UserID: userID}
+	err := r.db.QueryRow(`SELECT created_at FROM calendar_feeds WHERE user_id = $1`, userID).Scan(&feed.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrCalendarFeedNotFound
+		}
+		return nil, err
+	}
+
+	return feed, nil
+}
+
+// SetFeed creates a user's calendar feed with a token, replacing any feed the
+// user already had, whose URL stops working
+func (r *CalendarRepository) SetFeed(feed *models.CalendarFeed, token string) error {
+	// Set the timestamp
+	feed.CreatedAt = time.Now()
+
+	query := `
+	INSERT INTO calendar_feeds (user_id, token_hash, created_at)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (user_id) DO UPDATE SET token_hash = EXCLUDED.token_hash, created_at = EXCLUDED.created_at
+	`
+
+	_, err := r.db.Exec(query, feed.UserID, hashFeedToken(token), feed.CreatedAt)
+	return err
+}
+
+// DeleteFeed deletes a user's calendar feed, revoking its URL
+func (r *CalendarRepository) DeleteFeed(userID uuid.UUID) error {
+	result, err := r.db.Exec(`DELETE FROM calendar_feeds WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	// Check if the feed was found
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrCalendarFeedNotFound
+	}
+
+	return nil
+}
+
+// GetUserIDByFeedToken gets the ID of the user whose calendar feed has a token
+func (r *CalendarRepository) GetUserIDByFeedToken(token string) (uuid.UUID, error) {
+	var userID uuid.UUID
+	err := r.db.QueryRow(`SELECT user_id FROM calendar_feeds WHERE token_hash = $1`, hashFeedToken(token)).Scan(&userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return uuid.Nil, ErrCalendarFeedNotFound
+		}
+		return uuid.Nil, err
+	}
+
+	return userID, nil
+}
@@ -0,0 +1,215 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/noman/todo-application/database"
+	"github.com/noman/todo-application/models"
+)
+
+// CommentRepository handles database operations for comments
+type CommentRepository struct {
+	db *sql.DB
+}
+
+// NewCommentRepository creates a new CommentRepository
+func NewCommentRepository() *CommentRepository {
+	return &CommentRepository{
+		db: database.DB,
+	}
+}
+
+// scanComment scans a comment joined with its author's username
+func scanComment(row rowScanner) (*models.Comment, error) {
+	comment := &models.Comment{}
+	var parentID uuid.NullUUID
+	err := row.Scan(&comment.ID, &comment.TodoID, &parentID, &comment.UserID, &comment.Username, &comment.Body, &comment.CreatedAt, &comment.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if parentID.Valid {
+		comment.ParentID = &parentID.UUID
+	}
+	comment.Edited = comment.UpdatedAt.After(comment.CreatedAt)
+	comment.Replies = []*models.Comment{}
+
+	return comment, nil
+}
+
+// Create creates a new comment in the database
+func (r *CommentRepository) Create(comment *models.Comment) error {
+	// Set the ID and timestamps
+	comment.ID = uuid.New()
+	comment.CreatedAt = time.Now()
+	comment.UpdatedAt = comment.CreatedAt
+	comment.Replies = []*models.Comment{}
+
+	// Insert the comment into the database
+	query := `
+	INSERT INTO comments (id, todo_id, parent_id, user_id, body, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	_, err := r.db.Exec(query, comment.ID, comment.TodoID, comment.ParentID, comment.UserID, comment.Body, comment.CreatedAt, comment.UpdatedAt)
+	return err
+}
+
+// GetByID gets a comment by ID
+func (r *CommentRepository) GetByID(id uuid.UUID) (*models.Comment, error) {
+	query := `
+	SELECT c.id, c.todo_id, c.parent_id, c.user_id, u.username, c.body, c.created_at, c.updated_at
+	FROM comments c
+	JOIN users u ON u.id = c.user_id
+	WHERE c.id = $1
+	`
+
+	comment, err := scanComment(r.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrCommentNotFound
+		}
+		return nil, err
+	}
+
+	return comment, nil
+}
+
+// GetThreadByTodoID gets all comments on a todo arranged as threads: the
+// returned slice holds top-level comments with their replies nested inside
+func (r *CommentRepository) GetThreadByTodoID(todoID uuid.UUID) ([]*models.Comment, error) {
+	query := `
+	SELECT c.id, c.todo_id, c.parent_id, c.user_id, u.username, c.body, c.created_at, c.updated_at
+	FROM comments c
+	JOIN users u ON u.id = c.user_id
+	WHERE c.todo_id = $1
+	ORDER BY c.created_at
+	`
+
+	rows, err := r.db.Query(query, todoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []*models.Comment{}
+	for rows.Next() {
+		comment, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, comment)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Nest replies under their parents
+	byID := make(map[uuid.UUID]*models.Comment, len(comments))
+	for _, comment := range comments {
+		byID[comment.ID] = comment
+	}
+
+	threads := []*models.Comment{}
+	for _, comment := range comments {
+		if comment.ParentID != nil {
+			if parent, ok := byID[*comment.ParentID]; ok {
+				parent.Replies = append(parent.Replies, comment)
+				continue
+			}
+		}
+		threads = append(threads, comment)
+	}
+
+	return threads, nil
+}
+
+// GetAllByUserID gets every comment a user wrote, oldest first and not nested
+func (r *CommentRepository) GetAllByUserID(userID uuid.UUID) ([]*models.Comment, error) {
+	query := `
+	SELECT c.id, c.todo_id, c.parent_id, c.user_id, u.username, c.body, c.created_at, c.updated_at
+	FROM comments c
+	JOIN users u ON u.id = c.user_id
+	WHERE c.user_id = $1
+	ORDER BY c.created_at
+	`
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []*models.Comment{}
+	for rows.Next() {
+		comment, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, comment)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+// Update updates a comment's body
+func (r *CommentRepository) Update(comment *models.Comment) error {
+	// Update the timestamp
+	comment.UpdatedAt = time.Now()
+	comment.Edited = true
+
+	query := `
+	UPDATE comments
+	SET body = $1, updated_at = $2
+	WHERE id = $3
+	`
+
+	result, err := r.db.Exec(query, comment.Body, comment.UpdatedAt, comment.ID)
+	if err != nil {
+		return err
+	}
+
+	// Check if the comment was found
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrCommentNotFound
+	}
+
+	return nil
+}
+
+// Delete deletes a comment and, through the cascade, all of its replies
+func (r *CommentRepository) Delete(id uuid.UUID) error {
+	query := `
+	DELETE FROM comments
+	WHERE id = $1
+	`
+
+	result, err := r.db.Exec(query, id)
+	if err != nil {
+		return err
+	}
+
+	// Check if the comment was found
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrCommentNotFound
+	}
+
+	return nil
+}
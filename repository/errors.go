@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNotFound is returned when a record doesn't exist. The record-specific
+// errors below wrap it, so callers can check for either with errors.Is.
+var ErrNotFound = errors.New("not found")
+
+// Record-specific not found errors
+var (
+	ErrUserNotFound           = fmt.Errorf("user %w", ErrNotFound)
+	ErrTodoNotFound           = fmt.Errorf("todo %w", ErrNotFound)
+	ErrRevisionNotFound       = fmt.Errorf("revision %w", ErrNotFound)
+	ErrWorkspaceNotFound      = fmt.Errorf("workspace %w", ErrNotFound)
+	ErrMemberNotFound         = fmt.Errorf("member %w", ErrNotFound)
+	ErrProjectNotFound        = fmt.Errorf("project %w", ErrNotFound)
+	ErrCommentNotFound        = fmt.Errorf("comment %w", ErrNotFound)
+	ErrNotificationNotFound   = fmt.Errorf("notification %w", ErrNotFound)
+	ErrAttachmentNotFound     = fmt.Errorf("attachment %w", ErrNotFound)
+	ErrWebhookNotFound        = fmt.Errorf("webhook %w", ErrNotFound)
+	ErrCalendarFeedNotFound   = fmt.Errorf("calendar feed %w", ErrNotFound)
+	ErrAppPasswordNotFound    = fmt.Errorf("app password %w", ErrNotFound)
+	ErrImportJobNotFound      = fmt.Errorf("import job %w", ErrNotFound)
+	ErrAccountExportNotFound  = fmt.Errorf("account export %w", ErrNotFound)
+	ErrIdempotencyKeyNotFound = fmt.Errorf("idempotency key %w", ErrNotFound)
+	ErrStreamTicketNotFound   = fmt.Errorf("stream ticket %w", ErrNotFound)
+)
+
+// ErrVersionConflict is returned when a todo was modified after the caller read it
+var ErrVersionConflict = errors.New("todo was modified by another request")
+
+// ErrQuotaExceeded is returned when attachments would take their uploader
+// over their storage quota
+var ErrQuotaExceeded = errors.New("storage quota exceeded")
+
+// ErrInvalidPassword is returned when a password doesn't match the user's
+var ErrInvalidPassword = errors.New("invalid password")
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/noman/todo-application/database"
+	"github.com/noman/todo-application/models"
+)
+
+// IdempotencyRepository handles database operations for idempotency keys
+type IdempotencyRepository struct {
+	db *sql.DB
+}
+
+// NewIdempotencyRepository creates a new IdempotencyRepository
+func NewIdempotencyRepository() *IdempotencyRepository {
+	return &IdempotencyRepository{
+		db: database.DB,
+	}
+}
+
+// Reserve claims a key for a new request. It returns false if the key is
+// already in use and was created after cutoff; an older key is taken over.
+func (r *IdempotencyRepository) Reserve(userID uuid.UUID, key, fingerprint string, cutoff time.Time) (bool, error) {
+	query := `
+	INSERT INTO idempotency_keys (user_id, key, fingerprint, status, created_at)
+	VALUES ($1, $2, $3, 0, $4)
+	ON CONFLICT (user_id, key) DO UPDATE
+	SET fingerprint = EXCLUDED.fingerprint, status = 0, headers = NULL, body = NULL, created_at = EXCLUDED.created_at
+	WHERE idempotency_keys.created_at < $5
+	RETURNING user_id
+	`
+
+	var id uuid.UUID
+	err := r.db.QueryRow(query, userID, key, fingerprint, time.Now(), cutoff).Scan(&id)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Get gets a key
+func (r *IdempotencyRepository) Get(userID uuid.UUID, key string) (*models.IdempotencyKey, error) {
+	query := `
+	SELECT user_id, key, fingerprint, status, headers, body, created_at
+	FROM idempotency_keys
+	WHERE user_id = $1 AND key = $2
+	`
+
+	record := &models.IdempotencyKey{}
+	var headers []byte
+	err := r.db.QueryRow(query, userID, key).Scan(&record.UserID, &record.Key, &record.Fingerprint, &record.Status, &headers, &record.Body, &record.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrIdempotencyKeyNotFound
+		}
+		return nil, err
+	}
+
+	if headers != nil {
+		record.Headers = http.Header{}
+		if err := json.Unmarshal(headers, &record.Headers); err != nil {
+			return nil, err
+		}
+	}
+
+	return record, nil
+}
+
+// SaveResponse stores the response to replay for a reserved key
+func (r *IdempotencyRepository) SaveResponse(userID uuid.UUID, key string, status int, headers http.Header, body []byte) error {
+	encoded, err := json.Marshal(headers)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	UPDATE idempotency_keys
+	SET status = $3, headers = $4, body = $5
+	WHERE user_id = $1 AND key = $2
+	`
+
+	_, err = r.db.Exec(query, userID, key, status, encoded, body)
+	return err
+}
+
+// Delete releases a key so the request can be retried
+func (r *IdempotencyRepository) Delete(userID uuid.UUID, key string) error {
+	query := `DELETE FROM idempotency_keys WHERE user_id = $1 AND key = $2`
+
+	_, err := r.db.Exec(query, userID, key)
+	return err
+}
+
+// DeleteBefore deletes every key created before cutoff and returns how many were deleted
+func (r *IdempotencyRepository) DeleteBefore(cutoff time.Time) (int64, error) {
+	query := `DELETE FROM idempotency_keys WHERE created_at < $1`
+
+	result, err := r.db.Exec(query, cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,208 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/noman/todo-application/database"
+	"github.com/noman/todo-application/models"
+)
+
+// importJobColumns is the list of columns selected for an import job, without its file
+const importJobColumns = `id, user_id, workspace_id, source, filename, status, total, processed, error, created_at, started_at, finished_at`
+
+// ImportJobRepository handles database operations for import jobs
+type ImportJobRepository struct {
+	db *sql.DB
+	tx *sql.Tx // set when the repository is bound to a Batch
+}
+
+// NewImportJobRepository creates a new ImportJobRepository
+func NewImportJobRepository() *ImportJobRepository {
+	return &ImportJobRepository{
+		db: database.DB,
+	}
+}
+
+// conn returns the batch's transaction if the repository is bound to one, or the database
+func (r *ImportJobRepository) conn() querier {
+	if r.tx != nil {
+		return r.tx
+	}
+	return r.db
+}
+
+// scanImportJob scans an import job selected with importJobColumns, and
+// then any extra columns into extra
+func scanImportJob(row rowScanner, extra ...interface{}) (*models.ImportJob, error) {
+	job := &models.ImportJob{}
+	var workspaceID uuid.NullUUID
+	var startedAt, finishedAt sql.NullTime
+	dest := append([]interface{}{&job.ID, &job.UserID, &workspaceID, &job.Source, &job.Filename, &job.Status, &job.Total, &job.Processed, &job.Error, &job.CreatedAt, &startedAt, &finishedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	if workspaceID.Valid {
+		job.WorkspaceID = &workspaceID.UUID
+	}
+	if startedAt.Valid {
+		job.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+
+	return job, nil
+}
+
+// Create queues a new import job with its file
+func (r *ImportJobRepository) Create(job *models.ImportJob) error {
+	// Set the ID, status and timestamp
+	job.ID = uuid.New()
+	job.Status = models.ImportJobQueued
+	job.CreatedAt = time.Now()
+
+	query := `
+	INSERT INTO import_jobs (id, user_id, workspace_id, source, filename, data, status, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+
+	_, err := r.conn().Exec(query, job.ID, job.UserID, job.WorkspaceID, job.Source, job.Filename, job.Data, job.Status, job.CreatedAt)
+	return err
+}
+
+// GetByID gets one of a user's import jobs
+func (r *ImportJobRepository) GetByID(id, userID uuid.UUID) (*models.ImportJob, error) {
+	query := `
+	SELECT ` + importJobColumns + `
+	FROM import_jobs
+	WHERE id = $1 AND user_id = $2
+	`
+
+	job, err := scanImportJob(r.conn().QueryRow(query, id, userID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrImportJobNotFound
+		}
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// GetAllByUserID gets a user's 50 most recent import jobs, newest first
+func (r *ImportJobRepository) GetAllByUserID(userID uuid.UUID) ([]*models.ImportJob, error) {
+	query := `
+	SELECT ` + importJobColumns + `
+	FROM import_jobs
+	WHERE user_id = $1
+	ORDER BY created_at DESC
+	LIMIT 50
+	`
+
+	rows, err := r.conn().Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []*models.ImportJob{}
+	for rows.Next() {
+		job, err := scanImportJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+// Claim reserves the oldest queued job for lease, with its file, and marks
+// it running. Running jobs whose lease lapsed, because their worker stopped,
+// are claimed again and pick up where they left off. It returns nil if no
+// job is waiting.
+func (r *ImportJobRepository) Claim(lease time.Duration) (*models.ImportJob, error) {
+	now := time.Now()
+	query := `
+	UPDATE import_jobs
+	SET status = $1, locked_until = $2, started_at = COALESCE(started_at, $3)
+	WHERE id = (
+		SELECT id
+		FROM import_jobs
+		WHERE status = $4 OR (status = $1 AND locked_until < $3)
+		ORDER BY created_at
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING ` + importJobColumns + `, data
+	`
+
+	var data []byte
+	job, err := scanImportJob(r.conn().QueryRow(query, models.ImportJobRunning, now.Add(lease), now, models.ImportJobQueued), &data)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	job.Data = data
+
+	return job, nil
+}
+
+// UpdateProgress records a running job's total and progress and extends its
+// lease. It fails with ErrImportJobNotFound if another worker recorded
+// progress since job.Processed was read, which means this worker lost its
+// claim, or if the job was deleted.
+func (r *ImportJobRepository) UpdateProgress(job *models.ImportJob, processed int, lease time.Duration) error {
+	query := `
+	UPDATE import_jobs
+	SET total = $1, processed = $2, locked_until = $3
+	WHERE id = $4 AND status = $5 AND processed = $6
+	`
+
+	result, err := r.conn().Exec(query, job.Total, processed, time.Now().Add(lease), job.ID, models.ImportJobRunning, job.Processed)
+	if err != nil {
+		return err
+	}
+
+	// Check if the job was still ours
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrImportJobNotFound
+	}
+
+	job.Processed = processed
+	return nil
+}
+
+// Finish records that a job completed, or failed if job.Error is set, and
+// drops its file
+func (r *ImportJobRepository) Finish(job *models.ImportJob) error {
+	job.Status = models.ImportJobCompleted
+	if job.Error != "" {
+		job.Status = models.ImportJobFailed
+	}
+	now := time.Now()
+	job.FinishedAt = &now
+
+	query := `
+	UPDATE import_jobs
+	SET status = $1, error = $2, total = $3, finished_at = $4, data = NULL, locked_until = NULL
+	WHERE id = $5
+	`
+
+	_, err := r.conn().Exec(query, job.Status, job.Error, job.Total, job.FinishedAt, job.ID)
+	return err
+}
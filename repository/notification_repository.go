@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/noman/todo-application/database"
+	"github.com/noman/todo-application/models"
+)
+
+// NotificationRepository handles database operations for notifications
+type NotificationRepository struct {
+	db *sql.DB
+}
+
+// NewNotificationRepository creates a new NotificationRepository
+func NewNotificationRepository() *NotificationRepository {
+	return &NotificationRepository{
+		db: database.DB,
+	}
+}
+
+// Create creates a new notification in the database
+func (r *NotificationRepository) Create(notification *models.Notification) error {
+	// Set the ID and timestamp
+	notification.ID = uuid.New()
+	notification.CreatedAt = time.Now()
+
+	query := `
+	INSERT INTO notifications (id, user_id, type, actor_id, todo_id, comment_id, message, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+
+	_, err := r.db.Exec(query, notification.ID, notification.UserID, notification.Type, notification.ActorID, notification.TodoID, notification.CommentID, notification.Message, notification.CreatedAt)
+	return err
+}
+
+// GetAllByUserID gets a user's notifications, newest first
+func (r *NotificationRepository) GetAllByUserID(userID uuid.UUID, unreadOnly bool) ([]*models.Notification, error) {
+	query := `
+	SELECT id, user_id, type, actor_id, todo_id, comment_id, message, read_at, created_at
+	FROM notifications
+	WHERE user_id = $1 AND ($2 = FALSE OR read_at IS NULL)
+	ORDER BY created_at DESC
+	`
+
+	rows, err := r.db.Query(query, userID, unreadOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []*models.Notification{}
+	for rows.Next() {
+		notification := &models.Notification{}
+		var todoID, commentID uuid.NullUUID
+		var readAt sql.NullTime
+		err := rows.Scan(&notification.ID, &notification.UserID, &notification.Type, &notification.ActorID, &todoID, &commentID, &notification.Message, &readAt, &notification.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if todoID.Valid {
+			notification.TodoID = &todoID.UUID
+		}
+		if commentID.Valid {
+			notification.CommentID = &commentID.UUID
+		}
+		if readAt.Valid {
+			notification.ReadAt = &readAt.Time
+		}
+		notifications = append(notifications, notification)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return notifications, nil
+}
+
+// MarkRead marks one of a user's notifications as read
+func (r *NotificationRepository) MarkRead(id, userID uuid.UUID) error {
+	query := `
+	UPDATE notifications
+	SET read_at = COALESCE(read_at, $1)
+	WHERE id = $2 AND user_id = $3
+	`
+
+	result, err := r.db.Exec(query, time.Now(), id, userID)
+	if err != nil {
+		return err
+	}
+
+	// Check if the notification was found
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotificationNotFound
+	}
+
+	return nil
+}
+
+// MarkAllRead marks all of a user's notifications as read
+func (r *NotificationRepository) MarkAllRead(userID uuid.UUID) error {
+	query := `
+	UPDATE notifications
+	SET read_at = $1
+	WHERE user_id = $2 AND read_at IS NULL
+	`
+
+	_, err := r.db.Exec(query, time.Now(), userID)
+	return err
+}
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/noman/todo-application/database/databasetest"
+	"github.com/noman/todo-application/models"
+	"github.com/noman/todo-application/search"
+)
+
+func TestSearch(t *testing.T) {
+	databasetest.Open(t)
+	user, other := databasetest.NewUser(t), databasetest.NewUser(t)
+	todos := NewTodoRepository()
+
+	for _, todo := range []*models.Todo{
+		{Title: "Buy milk", Description: "And eggs", UserID: user.ID},
+		{Title: "Milk the cow", Description: "Twice", UserID: user.ID},
+		{Title: "Call mom", Description: "About the milkman", UserID: user.ID},
+		{Title: "Buy milk", UserID: other.ID},
+	} {
+		if err := todos.Create(todo); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deleted := &models.Todo{Title: "Buy more milk", UserID: user.ID}
+	if err := todos.Create(deleted); err != nil {
+		t.Fatal(err)
+	}
+	if err := todos.Delete(deleted, user.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query string
+		want  []string // Titles of the user's live todos that match
+	}{
+		{"milk", []string{"Buy milk", "Milk the cow"}},
+		{"mil*", []string{"Buy milk", "Call mom", "Milk the cow"}},
+		{`"buy milk"`, []string{"Buy milk"}},
+		{"milk eggs", []string{"Buy milk"}},
+		{"bread", nil},
+	}
+
+	// Both ways of searching find the same todos
+	engines := map[string]func(*search.Query) ([]*models.SearchResult, error){
+		"Search":     func(q *search.Query) ([]*models.SearchResult, error) { return todos.Search(user.ID, q, 10) },
+		"SearchScan": func(q *search.Query) ([]*models.SearchResult, error) { return todos.SearchScan(user.ID, q, 10) },
+	}
+	for name, find := range engines {
+		for _, tt := range tests {
+			query, err := search.Parse(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			results, err := find(query)
+			if err != nil {
+				t.Fatalf("%s(%q): %v", name, tt.query, err)
+			}
+
+			var titles []string
+			for i, result := range results {
+				titles = append(titles, result.Todo.Title)
+				if i > 0 && result.Rank > results[i-1].Rank {
+					t.Errorf("%s(%q): results aren't best first", name, tt.query)
+				}
+			}
+			sort.Strings(titles)
+			if !reflect.DeepEqual(titles, tt.want) {
+				t.Errorf("%s(%q) = %q, want %q", name, tt.query, titles, tt.want)
+			}
+		}
+
+		query, _ := search.Parse("milk")
+		results, err := find(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, result := range results {
+			if result.Todo.Title == "Buy milk" && result.TitleHighlight != "Buy <mark>milk</mark>" {
+				t.Errorf("%s: title highlighted as %q", name, result.TitleHighlight)
+			}
+		}
+	}
+
+	query, _ := search.Parse("milk")
+	if results, err := todos.Search(user.ID, query, 1); err != nil || len(results) != 1 {
+		t.Errorf("Search with a limit of 1 = %d results, %v", len(results), err)
+	}
+	if results, err := todos.SearchScan(user.ID, query, 1); err != nil || len(results) != 1 {
+		t.Errorf("SearchScan with a limit of 1 = %d results, %v", len(results), err)
+	}
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/noman/todo-application/database"
+)
+
+// StreamTicketRepository handles database operations for event stream tickets
+type StreamTicketRepository struct {
+	db *sql.DB
+}
+
+// NewStreamTicketRepository creates a new StreamTicketRepository
+func NewStreamTicketRepository() *StreamTicketRepository {
+	return &StreamTicketRepository{
+		db: database.DB,
+	}
+}
+
+// hashStreamTicket returns the hash a ticket is stored as
+func hashStreamTicket(ticket string) string {
+	sum := sha256.Sum256([]byte(ticket))
+	return hex.EncodeToString(sum[:])
+}
+
+// Create stores a ticket for a user's bearer token, valid until expiresAt.
+// Expired tickets are deleted along the way.
+func (r *StreamTicketRepository) Create(ticket string, userID uuid.UUID, accessToken string, expiresAt time.Time) error {
+	if _, err := r.db.Exec(`DELETE FROM stream_tickets WHERE expires_at <= $1`, time.Now()); err != nil {
+		return err
+	}
+
+	query := `
+	INSERT INTO stream_tickets (ticket_hash, user_id, access_token, expires_at)
+	VALUES ($1, $2, $3, $4)
+	`
+
+	_, err := r.db.Exec(query, hashStreamTicket(ticket), userID, accessToken, expiresAt)
+	return err
+}
+
+// Redeem uses up a ticket, returning the user and bearer token it was
+// created for. It fails with ErrStreamTicketNotFound if the ticket doesn't
+// exist, was already used or has expired.
+func (r *StreamTicketRepository) Redeem(ticket string) (uuid.UUID, string, error) {
+	// Expired tickets are deleted too, but not redeemed
+	query := `
+	DELETE FROM stream_tickets
+	WHERE ticket_hash = $1
+	RETURNING user_id, access_token, expires_at > $2
+	`
+
+	var userID uuid.UUID
+	var accessToken string
+	var valid bool
+	err := r.db.QueryRow(query, hashStreamTicket(ticket), time.Now()).Scan(&userID, &accessToken, &valid)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return uuid.Nil, "", ErrStreamTicketNotFound
+		}
+		return uuid.Nil, "", err
+	}
+	if !valid {
+		return uuid.Nil, "", ErrStreamTicketNotFound
+	}
+
+	return userID, accessToken, nil
+}
@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/noman/todo-application/database"
+	"github.com/noman/todo-application/models"
+)
+
+// SyncRepository handles database operations for the per-user change log that
+// clients sync from
+type SyncRepository struct {
+	db *sql.DB
+}
+
+// NewSyncRepository creates a new SyncRepository
+func NewSyncRepository() *SyncRepository {
+	return &SyncRepository{
+		db: database.DB,
+	}
+}
+
+// recordTodoChange logs a change to a todo for everyone who can see it: its
+// creator and the members of its workspace. Each of them gets the next value
+// of their own change sequence.
+// The counters stay locked until the transaction ends, so a user's changes
+// always commit in sequence order and a client can never skip one.
+func recordTodoChange(tx querier, todoID uuid.UUID) error {
+	// Counters are bumped in user ID order so concurrent changes to todos
+	// with overlapping audiences can't deadlock
+	query := `
+	WITH audience AS (
+		SELECT user_id FROM todos WHERE id = $1
+		UNION
+		SELECT m.user_id FROM todos t JOIN workspace_members m ON m.workspace_id = t.workspace_id WHERE t.id = $1
+	), bumped AS (
+		INSERT INTO sync_counters (user_id, seq)
+		SELECT user_id, 1 FROM audience ORDER BY user_id
+		ON CONFLICT (user_id) DO UPDATE SET seq = sync_counters.seq + 1
+		RETURNING user_id, seq
+	)
+	INSERT INTO sync_changes (user_id, todo_id, seq, first_seq, changed_at)
+	SELECT user_id, $1, seq, seq, $2 FROM bumped
+	ON CONFLICT (user_id, todo_id) DO UPDATE SET seq = EXCLUDED.seq, changed_at = EXCLUDED.changed_at
+	`
+
+	_, err := tx.Exec(query, todoID, time.Now())
+	return err
+}
+
+// recordAccessChange logs a change to every todo in a workspace for a single
+// user, after they joined or left it
+func recordAccessChange(tx querier, workspaceID, userID uuid.UUID) error {
+	// All of the workspace's todos share the one new sequence value
+	query := `
+	WITH bumped AS (
+		INSERT INTO sync_counters (user_id, seq)
+		VALUES ($2, 1)
+		ON CONFLICT (user_id) DO UPDATE SET seq = sync_counters.seq + 1
+		RETURNING user_id, seq
+	)
+	INSERT INTO sync_changes (user_id, todo_id, seq, first_seq, changed_at)
+	SELECT b.user_id, t.id, b.seq, b.seq, $3
+	FROM bumped b, todos t
+	WHERE t.workspace_id = $1
+	ON CONFLICT (user_id, todo_id) DO UPDATE SET seq = EXCLUDED.seq, changed_at = EXCLUDED.changed_at
+	`
+
+	_, err := tx.Exec(query, workspaceID, userID, time.Now())
+	return err
+}
+
+// Changes gets a page of a user's change log after a cursor, oldest first.
+// Each entry says whether the user can currently see the todo.
+func (r *SyncRepository) Changes(userID uuid.UUID, after models.SyncCursor, limit int) ([]*models.SyncLogEntry, error) {
+	query := `
+	SELECT c.todo_id, c.seq, c.first_seq,
+		COALESCE(t.id IS NOT NULL AND t.deleted_at IS NULL AND (t.user_id = $1 OR EXISTS (
+			SELECT 1 FROM workspace_members m WHERE m.workspace_id = t.workspace_id AND m.user_id = $1
+		)), FALSE)
+	FROM sync_changes c
+	LEFT JOIN todos t ON t.id = c.todo_id
+	WHERE c.user_id = $1 AND (c.seq, c.todo_id) > ($2, $3)
+	ORDER BY c.seq, c.todo_id
+	LIMIT $4
+	`
+
+	rows, err := r.db.Query(query, userID, after.Seq, after.TodoID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []*models.SyncLogEntry{}
+	for rows.Next() {
+		entry := &models.SyncLogEntry{}
+		if err := rows.Scan(&entry.TodoID, &entry.Seq, &entry.FirstSeq, &entry.Visible); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// Head gets the position of the latest entry in a user's change log, which
+// changes whenever any todo the user can see does
+func (r *SyncRepository) Head(userID uuid.UUID) (models.SyncCursor, error) {
+	query := `
+	SELECT seq, todo_id FROM sync_changes
+	WHERE user_id = $1
+	ORDER BY seq DESC, todo_id DESC
+	LIMIT 1
+	`
+
+	var cursor models.SyncCursor
+	err := r.db.QueryRow(query, userID).Scan(&cursor.Seq, &cursor.TodoID)
+	if err != nil && err != sql.ErrNoRows {
+		return models.SyncCursor{}, err
+	}
+
+	return cursor, nil
+}
+
+// PrunedSeq gets the highest sequence value whose tombstones were removed
+// from a user's change log; clients that synced before it must start over
+func (r *SyncRepository) PrunedSeq(userID uuid.UUID) (int64, error) {
+	var seq int64
+	err := r.db.QueryRow(`SELECT pruned_seq FROM sync_counters WHERE user_id = $1`, userID).Scan(&seq)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	return seq, nil
+}
+
+// DeleteTombstonesBefore removes log entries for todos that were deleted, or
+// that their user lost access to, before a cutoff. It returns the number of
+// entries removed.
+func (r *SyncRepository) DeleteTombstonesBefore(cutoff time.Time) (int64, error) {
+	query := `
+	WITH removed AS (
+		DELETE FROM sync_changes c
+		WHERE c.changed_at < $1 AND NOT EXISTS (
+			SELECT 1 FROM todos t
+			WHERE t.id = c.todo_id AND t.deleted_at IS NULL AND (t.user_id = c.user_id OR EXISTS (
+				SELECT 1 FROM workspace_members m WHERE m.workspace_id = t.workspace_id AND m.user_id = c.user_id
+			))
+		)
+		RETURNING c.user_id, c.seq
+	), pruned AS (
+		UPDATE sync_counters s
+		SET pruned_seq = GREATEST(s.pruned_seq, p.seq)
+		FROM (SELECT user_id, MAX(seq) AS seq FROM removed GROUP BY user_id) p
+		WHERE s.user_id = p.user_id
+	)
+	SELECT COUNT(*) FROM removed
+	`
+
+	var count int64
+	if err := r.db.QueryRow(query, cutoff).Scan(&count); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
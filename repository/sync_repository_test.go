@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/noman/todo-application/database"
+	"github.com/noman/todo-application/database/databasetest"
+	"github.com/noman/todo-application/models"
+)
+
+func TestSyncChanges(t *testing.T) {
+	databasetest.Open(t)
+	user := databasetest.NewUser(t)
+	todos, syncRepo := NewTodoRepository(), NewSyncRepository()
+
+	if head, err := syncRepo.Head(user.ID); err != nil || head != (models.SyncCursor{}) {
+		t.Fatalf("Head of an empty log = %+v, %v", head, err)
+	}
+
+	// Each change moves the todo to the end of the log; FirstSeq stays put
+	first := &models.Todo{Title: "First", UserID: user.ID}
+	second := &models.Todo{Title: "Second", UserID: user.ID}
+	for _, todo := range []*models.Todo{first, second} {
+		if err := todos.Create(todo); err != nil {
+			t.Fatal(err)
+		}
+	}
+	first.Completed = true
+	if err := todos.Update(first, user.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := syncRepo.Changes(user.ID, models.SyncCursor{}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*models.SyncLogEntry{
+		{TodoID: second.ID, Seq: 2, FirstSeq: 2, Visible: true},
+		{TodoID: first.ID, Seq: 3, FirstSeq: 1, Visible: true},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Fatalf("Changes = %+v, want %+v", entries, want)
+	}
+
+	// Pages continue after a cursor
+	page, err := syncRepo.Changes(user.ID, models.SyncCursor{}, 1)
+	if err != nil || len(page) != 1 || page[0].TodoID != second.ID {
+		t.Errorf("first page = %+v, %v", page, err)
+	}
+	page, err = syncRepo.Changes(user.ID, want[0].Cursor(), 10)
+	if err != nil || len(page) != 1 || page[0].TodoID != first.ID {
+		t.Errorf("page after %v = %+v, %v", want[0].Cursor(), page, err)
+	}
+	if head, err := syncRepo.Head(user.ID); err != nil || head != want[1].Cursor() {
+		t.Errorf("Head = %+v, %v; want %+v", head, err, want[1].Cursor())
+	}
+
+	// A deleted todo stays in the log as a tombstone
+	if err := todos.Delete(second, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	entries, err = syncRepo.Changes(user.ID, want[1].Cursor(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tombstone := []*models.SyncLogEntry{{TodoID: second.ID, Seq: 4, FirstSeq: 2, Visible: false}}
+	if !reflect.DeepEqual(entries, tombstone) {
+		t.Errorf("Changes after a delete = %+v, want %+v", entries, tombstone)
+	}
+}
+
+func TestSyncDeleteTombstonesBefore(t *testing.T) {
+	databasetest.Open(t)
+	user := databasetest.NewUser(t)
+	todos, syncRepo := NewTodoRepository(), NewSyncRepository()
+
+	live := &models.Todo{Title: "Live", UserID: user.ID}
+	deleted := &models.Todo{Title: "Deleted", UserID: user.ID}
+	for _, todo := range []*models.Todo{live, deleted} {
+		if err := todos.Create(todo); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := todos.Delete(deleted, user.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Age this user's log, so only its entries are old enough to prune
+	if _, err := database.DB.Exec(`UPDATE sync_changes SET changed_at = changed_at - INTERVAL '1 year' WHERE user_id = $1`, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	count, err := syncRepo.DeleteTombstonesBefore(time.Now().AddDate(0, -6, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count < 1 {
+		t.Errorf("DeleteTombstonesBefore removed %d entries, want the tombstone", count)
+	}
+
+	// Only the live todo is left, and clients that synced before the
+	// tombstone have to start over
+	entries, err := syncRepo.Changes(user.ID, models.SyncCursor{}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].TodoID != live.ID {
+		t.Errorf("Changes after pruning = %+v, want only %s", entries, live.ID)
+	}
+	if seq, err := syncRepo.PrunedSeq(user.ID); err != nil || seq != 3 {
+		t.Errorf("PrunedSeq = %d, %v; want 3", seq, err)
+	}
+}
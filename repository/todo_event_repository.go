@@ -0,0 +1,204 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/noman/todo-application/database"
+	"github.com/noman/todo-application/models"
+)
+
+// TodoEventRepository handles database operations for the todo history
+type TodoEventRepository struct {
+	db *sql.DB
+}
+
+// NewTodoEventRepository creates a new TodoEventRepository
+func NewTodoEventRepository() *TodoEventRepository {
+	return &TodoEventRepository{
+		db: database.DB,
+	}
+}
+
+// recordTodoEvent appends an event to a todo's history inside a transaction.
+// Callers must hold a lock on the todo row so revisions stay sequential.
+func recordTodoEvent(tx querier, todoID uuid.UUID, action string, actorID uuid.UUID, before *models.TodoSnapshot, after models.TodoSnapshot) (*models.TodoEvent, error) {
+	event := &models.TodoEvent{
+		ID:        uuid.New(),
+		TodoID:    todoID,
+		Action:    action,
+		ActorID:   actorID,
+		Changes:   models.DiffSnapshots(before, after),
+		Snapshot:  after,
+		CreatedAt: time.Now(),
+	}
+
+	changes, err := json.Marshal(event.Changes)
+	if err != nil {
+		return nil, err
+	}
+	snapshot, err := json.Marshal(event.Snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	// The event records the version the change brought the todo to, which is
+	// NULL once the todo is purged
+	query := `
+	INSERT INTO todo_events (id, todo_id, revision, action, actor_id, changes, snapshot, created_at, version)
+	VALUES ($1, $2, (SELECT COALESCE(MAX(revision), 0) + 1 FROM todo_events WHERE todo_id = $2), $3, $4, $5, $6, $7,
+		(SELECT version FROM todos WHERE id = $2))
+	RETURNING revision
+	`
+
+	err = tx.QueryRow(query, event.ID, event.TodoID, event.Action, event.ActorID, changes, snapshot, event.CreatedAt).Scan(&event.Revision)
+	if err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
+
+// scanTodoEvent scans a todo event row
+func scanTodoEvent(row rowScanner) (*models.TodoEvent, error) {
+	event := &models.TodoEvent{}
+	var changes, snapshot []byte
+	err := row.Scan(&event.ID, &event.TodoID, &event.Revision, &event.Action, &event.ActorID, &changes, &snapshot, &event.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(changes, &event.Changes); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(snapshot, &event.Snapshot); err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
+
+// GetByTodoID gets the full history of a todo, oldest first
+func (r *TodoEventRepository) GetByTodoID(todoID uuid.UUID) ([]*models.TodoEvent, error) {
+	query := `
+	SELECT id, todo_id, revision, action, actor_id, changes, snapshot, created_at
+	FROM todo_events
+	WHERE todo_id = $1
+	ORDER BY revision
+	`
+
+	rows, err := r.db.Query(query, todoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*models.TodoEvent{}
+	for rows.Next() {
+		event, err := scanTodoEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// GetByUserID gets the history of every todo a user created, and every change
+// they made to other todos, oldest first
+func (r *TodoEventRepository) GetByUserID(userID uuid.UUID) ([]*models.TodoEvent, error) {
+	query := `
+	SELECT id, todo_id, revision, action, actor_id, changes, snapshot, created_at
+	FROM todo_events
+	WHERE actor_id = $1 OR todo_id IN (
+		SELECT todo_id FROM todo_events WHERE action = $2 AND actor_id = $1
+	)
+	ORDER BY created_at, todo_id, revision
+	`
+
+	rows, err := r.db.Query(query, userID, models.TodoEventCreated)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*models.TodoEvent{}
+	for rows.Next() {
+		event, err := scanTodoEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// GetRevision gets a single revision of a todo
+func (r *TodoEventRepository) GetRevision(todoID uuid.UUID, revision int) (*models.TodoEvent, error) {
+	query := `
+	SELECT id, todo_id, revision, action, actor_id, changes, snapshot, created_at
+	FROM todo_events
+	WHERE todo_id = $1 AND revision = $2
+	`
+
+	event, err := scanTodoEvent(r.db.QueryRow(query, todoID, revision))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrRevisionNotFound
+		}
+		return nil, err
+	}
+
+	return event, nil
+}
+
+// ChangedFieldsSince returns the JSON names of the fields changed since a todo
+// was at the given version. Changes recorded before versions were tracked
+// always count, so they're never overwritten by mistake.
+func (r *TodoEventRepository) ChangedFieldsSince(todoID uuid.UUID, version int) (map[string]bool, error) {
+	query := `
+	SELECT changes
+	FROM todo_events
+	WHERE todo_id = $1 AND (version > $2 OR version IS NULL)
+	`
+
+	rows, err := r.db.Query(query, todoID, version)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	fields := map[string]bool{}
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+
+		var changes map[string]models.FieldChange
+		if err := json.Unmarshal(data, &changes); err != nil {
+			return nil, err
+		}
+		for field := range changes {
+			fields[field] = true
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return fields, nil
+}
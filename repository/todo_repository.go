@@ -2,17 +2,26 @@ package repository
 
 import (
 	"database/sql"
-	"errors"
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/noman/todo-application/database"
+	"github.com/noman/todo-application/filterexpr"
 	"github.com/noman/todo-application/models"
+	"github.com/noman/todo-application/search"
 )
 
+// todoColumns is the list of columns selected for a todo
+const todoColumns = `id, title, description, completed, user_id, workspace_id, project_id, tags, priority, due_at, version, created_at, updated_at, deleted_at`
+
 // TodoRepository handles database operations for todos
 type TodoRepository struct {
 	db *sql.DB
+	tx *sql.Tx // set when the repository is bound to a Batch
 }
 
 // NewTodoRepository creates a new TodoRepository
@@ -22,55 +31,392 @@ func NewTodoRepository() *TodoRepository {
 	}
 }
 
+// conn returns the batch's transaction if the repository is bound to one, or the database
+func (r *TodoRepository) conn() querier {
+	if r.tx != nil {
+		return r.tx
+	}
+	return r.db
+}
+
+// begin starts a transaction for a repository method, or joins the batch's
+// transaction if the repository is bound to one
+func (r *TodoRepository) begin() (*txn, error) {
+	if r.tx != nil {
+		return &txn{Tx: r.tx}, nil
+	}
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &txn{Tx: tx, owned: true}, nil
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanTodo scans a todo selected with todoColumns, and then any extra columns
+// into extra
+func scanTodo(row rowScanner, extra ...interface{}) (*models.Todo, error) {
+	todo := &models.Todo{}
+	var workspaceID, projectID uuid.NullUUID
+	var priority int
+	var dueAt, deletedAt sql.NullTime
+	dest := append([]interface{}{&todo.ID, &todo.Title, &todo.Description, &todo.Completed, &todo.UserID, &workspaceID, &projectID, pq.Array(&todo.Tags), &priority, &dueAt, &todo.Version, &todo.CreatedAt, &todo.UpdatedAt, &deletedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	if workspaceID.Valid {
+		todo.WorkspaceID = &workspaceID.UUID
+	}
+	if projectID.Valid {
+		todo.ProjectID = &projectID.UUID
+	}
+	if dueAt.Valid {
+		todo.DueAt = &dueAt.Time
+	}
+	if deletedAt.Valid {
+		todo.DeletedAt = &deletedAt.Time
+	}
+	todo.Tags = models.NormalizeTags(todo.Tags)
+	todo.Priority = priorityName(priority)
+	todo.AssigneeIDs = []uuid.UUID{}
+
+	return todo, nil
+}
+
+// priorityName returns the priority stored as a rank
+func priorityName(rank int) string {
+	if rank < 0 || rank >= len(models.Priorities) {
+		return models.PriorityNone
+	}
+	return models.Priorities[rank]
+}
+
 // Create creates a new todo in the database
 func (r *TodoRepository) Create(todo *models.Todo) error {
-	// Set the ID and timestamps
-	todo.ID = uuid.New()
+	// Set the ID, unless the client chose one, and the timestamps
+	if todo.ID == uuid.Nil {
+		todo.ID = uuid.New()
+	}
+	todo.Version = 1
+	todo.Tags = models.NormalizeTags(todo.Tags)
+	todo.Priority = models.NormalizePriority(todo.Priority)
 	todo.CreatedAt = time.Now()
 	todo.UpdatedAt = time.Now()
 
+	tx, err := r.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	// Insert the todo into the database
 	query := `
-	INSERT INTO todos (id, title, description, completed, user_id, created_at, updated_at)
-	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	INSERT INTO todos (id, title, description, completed, user_id, workspace_id, project_id, tags, priority, due_at, version, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
 	`
 
-	_, err := r.db.Exec(query, todo.ID, todo.Title, todo.Description, todo.Completed, todo.UserID, todo.CreatedAt, todo.UpdatedAt)
-	return err
+	_, err = tx.Exec(query, todo.ID, todo.Title, todo.Description, todo.Completed, todo.UserID, todo.WorkspaceID, todo.ProjectID, pq.Array(todo.Tags), models.PriorityRank(todo.Priority), todo.DueAt, todo.Version, todo.CreatedAt, todo.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	// Insert the assignees
+	if err := setAssignees(tx, todo.ID, todo.AssigneeIDs); err != nil {
+		return err
+	}
+
+	if todo.AssigneeIDs == nil {
+		todo.AssigneeIDs = []uuid.UUID{}
+	}
+
+	// Record the creation in the todo's history
+	if _, err := recordTodoEvent(tx, todo.ID, models.TodoEventCreated, todo.UserID, nil, todo.Snapshot()); err != nil {
+		return err
+	}
+
+	if err := recordTodoChange(tx, todo.ID); err != nil {
+		return err
+	}
+
+	if err := queueTodoWebhooks(tx, models.WebhookEventTodoCreated, todo.ID, false, todo.UserID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-// GetByID gets a todo by ID
-func (r *TodoRepository) GetByID(id uuid.UUID) (*models.Todo, error) {
+// lockSnapshot locks a todo row for the rest of the transaction and returns
+// the current state of its user-editable fields and its version. Only todos in
+// the trash are found when deleted is true, and only live todos otherwise.
+func lockSnapshot(tx querier, id uuid.UUID, deleted bool) (*models.TodoSnapshot, int, error) {
 	query := `
-	SELECT id, title, description, completed, user_id, created_at, updated_at
+	SELECT title, description, completed, project_id, tags, priority, due_at, version
 	FROM todos
-	WHERE id = $1
+	WHERE id = $1 AND (deleted_at IS NOT NULL) = $2
+	FOR UPDATE
 	`
 
-	row := r.db.QueryRow(query, id)
+	snapshot := &models.TodoSnapshot{AssigneeIDs: []uuid.UUID{}}
+	var projectID uuid.NullUUID
+	var priority, version int
+	var dueAt sql.NullTime
+	err := tx.QueryRow(query, id, deleted).Scan(&snapshot.Title, &snapshot.Description, &snapshot.Completed, &projectID, pq.Array(&snapshot.Tags), &priority, &dueAt, &version)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, 0, ErrTodoNotFound
+		}
+		return nil, 0, err
+	}
+	if projectID.Valid {
+		snapshot.ProjectID = &projectID.UUID
+	}
+	if dueAt.Valid {
+		snapshot.DueAt = &dueAt.Time
+	}
+	snapshot.Tags = models.NormalizeTags(snapshot.Tags)
+	snapshot.Priority = priorityName(priority)
 
-	todo := &models.Todo{}
-	err := row.Scan(&todo.ID, &todo.Title, &todo.Description, &todo.Completed, &todo.UserID, &todo.CreatedAt, &todo.UpdatedAt)
+	rows, err := tx.Query(`SELECT user_id FROM todo_assignees WHERE todo_id = $1 ORDER BY created_at`, id)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userID uuid.UUID
+		if err := rows.Scan(&userID); err != nil {
+			return nil, 0, err
+		}
+		snapshot.AssigneeIDs = append(snapshot.AssigneeIDs, userID)
+	}
+
+	return snapshot, version, rows.Err()
+}
+
+// lockVersion locks a live todo like lockSnapshot and checks that it is still
+// at the version the caller read
+func lockVersion(tx querier, todo *models.Todo) (*models.TodoSnapshot, error) {
+	before, version, err := lockSnapshot(tx, todo.ID, false)
+	if err != nil {
+		return nil, err
+	}
+	if version != todo.Version {
+		return nil, ErrVersionConflict
+	}
+	return before, nil
+}
+
+// GetByID gets a todo by ID, ignoring todos in the trash
+func (r *TodoRepository) GetByID(id uuid.UUID) (*models.Todo, error) {
+	return r.getByID(id, false)
+}
+
+// GetDeletedByID gets a todo in the trash by ID
+func (r *TodoRepository) GetDeletedByID(id uuid.UUID) (*models.Todo, error) {
+	return r.getByID(id, true)
+}
+
+// getByID gets a todo by ID from either the live todos or the trash
+func (r *TodoRepository) getByID(id uuid.UUID, deleted bool) (*models.Todo, error) {
+	query := `
+	SELECT ` + todoColumns + `
+	FROM todos
+	WHERE id = $1 AND (deleted_at IS NOT NULL) = $2
+	`
+
+	todo, err := scanTodo(r.conn().QueryRow(query, id, deleted))
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, errors.New("todo not found")
+			return nil, ErrTodoNotFound
 		}
 		return nil, err
 	}
 
+	if err := r.loadRelations([]*models.Todo{todo}); err != nil {
+		return nil, err
+	}
+
 	return todo, nil
 }
 
-// GetAllByUserID gets all todos for a user
-func (r *TodoRepository) GetAllByUserID(userID uuid.UUID) ([]*models.Todo, error) {
+// List gets the todos matching a filter, newest first.
+// Without a workspace only the user's personal todos are listed; with a
+// workspace every todo in it is visible, narrowed down by the filter's view.
+func (r *TodoRepository) List(filter models.TodoFilter) ([]*models.Todo, error) {
+	conditions := []string{"deleted_at IS NULL"}
+	var args []interface{}
+
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if filter.WorkspaceID != nil {
+		conditions = append(conditions, "workspace_id = "+arg(*filter.WorkspaceID))
+	} else if filter.Expr != nil {
+		// Filters can pick workspaces themselves
+		userID := arg(filter.UserID)
+		conditions = append(conditions, "((workspace_id IS NULL AND user_id = "+userID+") OR workspace_id IN (SELECT workspace_id FROM workspace_members WHERE user_id = "+userID+"))")
+	} else {
+		conditions = append(conditions, "workspace_id IS NULL", "user_id = "+arg(filter.UserID))
+	}
+
+	switch filter.View {
+	case models.TodoViewAssignedToMe:
+		conditions = append(conditions, "id IN (SELECT todo_id FROM todo_assignees WHERE user_id = "+arg(filter.UserID)+")")
+	case models.TodoViewCreatedByMe:
+		if filter.WorkspaceID != nil || filter.Expr != nil {
+			conditions = append(conditions, "user_id = "+arg(filter.UserID))
+		}
+	}
+
+	if filter.Expr != nil {
+		var condition string
+		condition, args = filterexpr.Compile(filter.Expr, filter.UserID, time.Now(), args)
+		conditions = append(conditions, condition)
+	}
+
+	// Creation times are compared as the database stores them, without a
+	// time zone
+	if filter.After != nil {
+		createdAt := arg(filter.After.CreatedAt.Format("2006-01-02 15:04:05.999999"))
+		conditions = append(conditions, "(created_at, id) < ("+createdAt+"::timestamp, "+arg(filter.After.ID)+")")
+	}
+
 	query := `
-	SELECT id, title, description, completed, user_id, created_at, updated_at
+	SELECT ` + todoColumns + `
+	FROM todos
+	WHERE ` + strings.Join(conditions, " AND ") + `
+	ORDER BY created_at DESC, id DESC
+	`
+	if filter.Limit > 0 {
+		query += "LIMIT " + arg(filter.Limit)
+	}
+
+	rows, err := r.conn().Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	todos := []*models.Todo{}
+	for rows.Next() {
+		todo, err := scanTodo(rows)
+		if err != nil {
+			return nil, err
+		}
+		todos = append(todos, todo)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := r.loadRelations(todos); err != nil {
+		return nil, err
+	}
+
+	return todos, nil
+}
+
+// ListCreatedBy gets every todo a user created, including those in the
+// trash, oldest first
+func (r *TodoRepository) ListCreatedBy(userID uuid.UUID) ([]*models.Todo, error) {
+	query := `
+	SELECT ` + todoColumns + `
 	FROM todos
 	WHERE user_id = $1
+	ORDER BY created_at, id
+	`
+
+	rows, err := r.conn().Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	todos := []*models.Todo{}
+	for rows.Next() {
+		todo, err := scanTodo(rows)
+		if err != nil {
+			return nil, err
+		}
+		todos = append(todos, todo)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := r.loadRelations(todos); err != nil {
+		return nil, err
+	}
+
+	return todos, nil
+}
+
+// ListByIDs gets the live todos with the given IDs, in no particular order.
+// IDs of todos that don't exist or are in the trash are skipped.
+func (r *TodoRepository) ListByIDs(ids []uuid.UUID) ([]*models.Todo, error) {
+	if len(ids) == 0 {
+		return []*models.Todo{}, nil
+	}
+
+	strIDs := make([]string, len(ids))
+	for i, id := range ids {
+		strIDs[i] = id.String()
+	}
+
+	query := `
+	SELECT ` + todoColumns + `
+	FROM todos
+	WHERE id = ANY($1) AND deleted_at IS NULL
+	`
+
+	rows, err := r.conn().Query(query, pq.Array(strIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	todos := []*models.Todo{}
+	for rows.Next() {
+		todo, err := scanTodo(rows)
+		if err != nil {
+			return nil, err
+		}
+		todos = append(todos, todo)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := r.loadRelations(todos); err != nil {
+		return nil, err
+	}
+
+	return todos, nil
+}
+
+// ListVisible gets every live todo a user can see: their own personal todos
+// and those of the workspaces they belong to
+func (r *TodoRepository) ListVisible(userID uuid.UUID) ([]*models.Todo, error) {
+	query := `
+	SELECT ` + todoColumns + `
+	FROM todos
+	WHERE deleted_at IS NULL
+		AND ((workspace_id IS NULL AND user_id = $1)
+			OR workspace_id IN (SELECT workspace_id FROM workspace_members WHERE user_id = $1))
 	ORDER BY created_at DESC
 	`
 
-	rows, err := r.db.Query(query, userID)
+	rows, err := r.conn().Query(query, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -78,8 +424,7 @@ func (r *TodoRepository) GetAllByUserID(userID uuid.UUID) ([]*models.Todo, error
 
 	todos := []*models.Todo{}
 	for rows.Next() {
-		todo := &models.Todo{}
-		err := rows.Scan(&todo.ID, &todo.Title, &todo.Description, &todo.Completed, &todo.UserID, &todo.CreatedAt, &todo.UpdatedAt)
+		todo, err := scanTodo(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -90,60 +435,554 @@ func (r *TodoRepository) GetAllByUserID(userID uuid.UUID) ([]*models.Todo, error
 		return nil, err
 	}
 
+	if err := r.loadRelations(todos); err != nil {
+		return nil, err
+	}
+
 	return todos, nil
 }
 
-// Update updates a todo in the database
-func (r *TodoRepository) Update(todo *models.Todo) error {
+// EachVisible calls fn with every live todo a user can see, oldest first, as
+// they're read from the database, stopping at the first error fn returns.
+// Assignees and comment counts aren't loaded.
+func (r *TodoRepository) EachVisible(userID uuid.UUID, fn func(*models.Todo) error) error {
+	query := `
+	SELECT ` + todoColumns + `
+	FROM todos
+	WHERE deleted_at IS NULL
+		AND ((workspace_id IS NULL AND user_id = $1)
+			OR workspace_id IN (SELECT workspace_id FROM workspace_members WHERE user_id = $1))
+	ORDER BY created_at, id
+	`
+
+	rows, err := r.conn().Query(query, userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		todo, err := scanTodo(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(todo); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// Headline options for search results: titles are highlighted in full, and
+// descriptions shortened to the fragments around the matches
+const (
+	titleHeadlineOptions       = `HighlightAll=true, StartSel=` + search.StartSel + `, StopSel=` + search.StopSel
+	descriptionHeadlineOptions = `MaxWords=30, MinWords=15, ShortWord=0, MaxFragments=2, FragmentDelimiter=" … ", StartSel=` + search.StartSel + `, StopSel=` + search.StopSel
+)
+
+// Search finds the live todos a user can see that match a query, using the
+// full-text index, best matches first
+func (r *TodoRepository) Search(userID uuid.UUID, query *search.Query, limit int) ([]*models.SearchResult, error) {
+	// Only the page of results is highlighted
+	sqlQuery := `
+	SELECT ` + todoColumns + `, rank, ts_headline('simple', title, query, $4), ts_headline('simple', COALESCE(description, ''), query, $5)
+	FROM (
+		SELECT ` + todoColumns + `, ts_rank(search_vector, query) AS rank, query
+		FROM todos, to_tsquery('simple', $2) query
+		WHERE search_vector @@ query
+			AND deleted_at IS NULL
+			AND ((workspace_id IS NULL AND user_id = $1)
+				OR workspace_id IN (SELECT workspace_id FROM workspace_members WHERE user_id = $1))
+		ORDER BY rank DESC, created_at DESC
+		LIMIT $3
+	) matches
+	ORDER BY rank DESC, created_at DESC
+	`
+
+	rows, err := r.conn().Query(sqlQuery, userID, query.TSQuery(), limit, titleHeadlineOptions, descriptionHeadlineOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	todos := []*models.Todo{}
+	results := []*models.SearchResult{}
+	for rows.Next() {
+		result := &models.SearchResult{}
+		todo, err := scanTodo(rows, &result.Rank, &result.TitleHighlight, &result.Snippet)
+		if err != nil {
+			return nil, err
+		}
+		result.TitleHighlight = search.Highlight(result.TitleHighlight)
+		result.Snippet = search.Highlight(result.Snippet)
+		todos = append(todos, todo)
+		results = append(results, result)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return r.searchResults(todos, results)
+}
+
+// SearchScan finds the live todos a user can see that match a query like
+// Search, but by reading each of them, for databases without full-text search
+func (r *TodoRepository) SearchScan(userID uuid.UUID, query *search.Query, limit int) ([]*models.SearchResult, error) {
+	todos := []*models.Todo{}
+	results := []*models.SearchResult{}
+	err := r.EachVisible(userID, func(todo *models.Todo) error {
+		if match := query.Match(todo.Title, todo.Description); match != nil {
+			todos = append(todos, todo)
+			results = append(results, &models.SearchResult{Rank: match.Rank, TitleHighlight: match.Title, Snippet: match.Snippet})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Best matches first, then the newest; todos are read oldest first
+	order := make([]int, len(todos))
+	for i := range order {
+		order[i] = len(todos) - 1 - i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return results[order[i]].Rank > results[order[j]].Rank })
+	if len(order) > limit {
+		order = order[:limit]
+	}
+
+	pageTodos := make([]*models.Todo, len(order))
+	pageResults := make([]*models.SearchResult, len(order))
+	for i, k := range order {
+		pageTodos[i], pageResults[i] = todos[k], results[k]
+	}
+
+	return r.searchResults(pageTodos, pageResults)
+}
+
+// searchResults loads the relations of the todos found by a search and adds
+// them to their results
+func (r *TodoRepository) searchResults(todos []*models.Todo, results []*models.SearchResult) ([]*models.SearchResult, error) {
+	if err := r.loadRelations(todos); err != nil {
+		return nil, err
+	}
+
+	for i, todo := range todos {
+		results[i].Todo = todo.ToResponse()
+	}
+
+	return results, nil
+}
+
+// Update updates a todo in the database on behalf of an actor. It fails with
+// ErrVersionConflict unless the todo is still at todo.Version, and bumps the version.
+func (r *TodoRepository) Update(todo *models.Todo, actorID uuid.UUID) error {
+	return r.update(todo, actorID, models.TodoEventUpdated)
+}
+
+// Restore writes a todo's fields and assignees back from an earlier revision
+// on behalf of an actor; the caller copies the revision's snapshot into todo first
+func (r *TodoRepository) Restore(todo *models.Todo, actorID uuid.UUID) error {
+	return r.update(todo, actorID, models.TodoEventRestored)
+}
+
+// update writes a todo's fields and records the change in its history.
+// Restores also replace the todo's assignees.
+func (r *TodoRepository) update(todo *models.Todo, actorID uuid.UUID, action string) error {
+	tx, err := r.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock the todo and capture its state before the change
+	before, err := lockVersion(tx, todo)
+	if err != nil {
+		return err
+	}
+
 	// Update the timestamp
 	todo.UpdatedAt = time.Now()
+	todo.Tags = models.NormalizeTags(todo.Tags)
+	todo.Priority = models.NormalizePriority(todo.Priority)
 
 	// Update the todo in the database
 	query := `
 	UPDATE todos
-	SET title = $1, description = $2, completed = $3, updated_at = $4
-	WHERE id = $5 AND user_id = $6
+	SET title = $1, description = $2, completed = $3, project_id = $4, tags = $5, priority = $6, due_at = $7, updated_at = $8, version = version + 1
+	WHERE id = $9
 	`
 
-	result, err := r.db.Exec(query, todo.Title, todo.Description, todo.Completed, todo.UpdatedAt, todo.ID, todo.UserID)
+	if _, err := tx.Exec(query, todo.Title, todo.Description, todo.Completed, todo.ProjectID, pq.Array(todo.Tags), models.PriorityRank(todo.Priority), todo.DueAt, todo.UpdatedAt, todo.ID); err != nil {
+		return err
+	}
+
+	after := *before
+	after.Title, after.Description, after.Completed, after.ProjectID = todo.Title, todo.Description, todo.Completed, todo.ProjectID
+	after.Tags, after.Priority, after.DueAt = todo.Tags, todo.Priority, todo.DueAt
+
+	if action == models.TodoEventRestored {
+		if _, err := tx.Exec(`DELETE FROM todo_assignees WHERE todo_id = $1`, todo.ID); err != nil {
+			return err
+		}
+		if err := setAssignees(tx, todo.ID, todo.AssigneeIDs); err != nil {
+			return err
+		}
+		after.AssigneeIDs = todo.Snapshot().AssigneeIDs
+	}
+
+	// Record the change, unless nothing actually changed
+	if len(models.DiffSnapshots(before, after)) > 0 {
+		if _, err := recordTodoEvent(tx, todo.ID, action, actorID, before, after); err != nil {
+			return err
+		}
+	}
+
+	// The version changes either way, so clients need the new one
+	if err := recordTodoChange(tx, todo.ID); err != nil {
+		return err
+	}
+
+	// Of the updates, only completing a todo triggers webhooks
+	if !before.Completed && after.Completed {
+		if err := queueTodoWebhooks(tx, models.WebhookEventTodoCompleted, todo.ID, false, actorID); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	todo.Version++
+	return nil
+}
+
+// SetAssignees replaces the users a todo is assigned to on behalf of an actor.
+// It fails with ErrVersionConflict unless the todo is still at todo.Version.
+func (r *TodoRepository) SetAssignees(todo *models.Todo, userIDs []uuid.UUID, actorID uuid.UUID) error {
+	tx, err := r.begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	// Check if the todo was found
-	rowsAffected, err := result.RowsAffected()
+	// Lock the todo and capture its state before the change
+	before, err := lockVersion(tx, todo)
 	if err != nil {
 		return err
 	}
 
-	if rowsAffected == 0 {
-		return errors.New("todo not found or not owned by user")
+	if _, err := tx.Exec(`DELETE FROM todo_assignees WHERE todo_id = $1`, todo.ID); err != nil {
+		return err
+	}
+
+	if err := setAssignees(tx, todo.ID, userIDs); err != nil {
+		return err
+	}
+
+	todo.UpdatedAt = time.Now()
+	if _, err := tx.Exec(`UPDATE todos SET updated_at = $1, version = version + 1 WHERE id = $2`, todo.UpdatedAt, todo.ID); err != nil {
+		return err
+	}
+
+	// Record the change, unless nothing actually changed
+	after := *before
+	after.AssigneeIDs = userIDs
+	if after.AssigneeIDs == nil {
+		after.AssigneeIDs = []uuid.UUID{}
+	}
+	if len(models.DiffSnapshots(before, after)) > 0 {
+		if _, err := recordTodoEvent(tx, todo.ID, models.TodoEventUpdated, actorID, before, after); err != nil {
+			return err
+		}
+	}
+
+	if err := recordTodoChange(tx, todo.ID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	todo.AssigneeIDs = after.AssigneeIDs
+	todo.Version++
+	return nil
+}
+
+// setAssignees inserts the assignees of a todo inside a transaction
+func setAssignees(tx querier, todoID uuid.UUID, userIDs []uuid.UUID) error {
+	query := `
+	INSERT INTO todo_assignees (todo_id, user_id, created_at)
+	VALUES ($1, $2, $3)
+	ON CONFLICT DO NOTHING
+	`
+
+	now := time.Now()
+	for _, userID := range userIDs {
+		if _, err := tx.Exec(query, todoID, userID, now); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-// Delete deletes a todo from the database
-func (r *TodoRepository) Delete(id, userID uuid.UUID) error {
+// loadRelations fills in the assignee IDs and comment counts of the given todos
+func (r *TodoRepository) loadRelations(todos []*models.Todo) error {
+	if len(todos) == 0 {
+		return nil
+	}
+
+	byID := make(map[uuid.UUID]*models.Todo, len(todos))
+	ids := make([]string, len(todos))
+	for i, todo := range todos {
+		byID[todo.ID] = todo
+		ids[i] = todo.ID.String()
+	}
+
+	if err := r.loadAssignees(byID, ids); err != nil {
+		return err
+	}
+
+	return r.loadCommentCounts(byID, ids)
+}
+
+// loadAssignees fills in the assignee IDs of the given todos
+func (r *TodoRepository) loadAssignees(byID map[uuid.UUID]*models.Todo, ids []string) error {
 	query := `
-	DELETE FROM todos
-	WHERE id = $1 AND user_id = $2
+	SELECT todo_id, user_id FROM todo_assignees
+	WHERE todo_id = ANY($1::uuid[])
+	ORDER BY created_at
 	`
 
-	result, err := r.db.Exec(query, id, userID)
+	rows, err := r.conn().Query(query, pq.Array(ids))
 	if err != nil {
 		return err
 	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var todoID, userID uuid.UUID
+		if err := rows.Scan(&todoID, &userID); err != nil {
+			return err
+		}
+		if todo, ok := byID[todoID]; ok {
+			todo.AssigneeIDs = append(todo.AssigneeIDs, userID)
+		}
+	}
 
-	// Check if the todo was found
-	rowsAffected, err := result.RowsAffected()
+	return rows.Err()
+}
+
+// loadCommentCounts fills in the comment counts of the given todos
+func (r *TodoRepository) loadCommentCounts(byID map[uuid.UUID]*models.Todo, ids []string) error {
+	query := `
+	SELECT todo_id, COUNT(*) FROM comments
+	WHERE todo_id = ANY($1::uuid[])
+	GROUP BY todo_id
+	`
+
+	rows, err := r.conn().Query(query, pq.Array(ids))
 	if err != nil {
 		return err
 	}
+	defer rows.Close()
 
-	if rowsAffected == 0 {
-		return errors.New("todo not found or not owned by user")
+	for rows.Next() {
+		var todoID uuid.UUID
+		var count int
+		if err := rows.Scan(&todoID, &count); err != nil {
+			return err
+		}
+		if todo, ok := byID[todoID]; ok {
+			todo.CommentCount = count
+		}
 	}
 
-	return nil
-}
\ No newline at end of file
+	return rows.Err()
+}
+
+// Delete moves a todo to the trash on behalf of an actor. It fails with
+// ErrVersionConflict unless the todo is still at todo.Version.
+func (r *TodoRepository) Delete(todo *models.Todo, actorID uuid.UUID) error {
+	tx, err := r.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock the todo and capture its final state
+	before, err := lockVersion(tx, todo)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`UPDATE todos SET deleted_at = $1, version = version + 1 WHERE id = $2`, time.Now(), todo.ID); err != nil {
+		return err
+	}
+
+	// Record the deletion with the final state as its snapshot
+	if _, err := recordTodoEvent(tx, todo.ID, models.TodoEventDeleted, actorID, before, *before); err != nil {
+		return err
+	}
+
+	if err := recordTodoChange(tx, todo.ID); err != nil {
+		return err
+	}
+
+	if err := queueTodoWebhooks(tx, models.WebhookEventTodoDeleted, todo.ID, true, actorID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Undelete moves a todo out of the trash on behalf of an actor
+func (r *TodoRepository) Undelete(id uuid.UUID, actorID uuid.UUID) error {
+	tx, err := r.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, _, err := lockSnapshot(tx, id, true)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`UPDATE todos SET deleted_at = NULL, updated_at = $1, version = version + 1 WHERE id = $2`, time.Now(), id); err != nil {
+		return err
+	}
+
+	if _, err := recordTodoEvent(tx, id, models.TodoEventUndeleted, actorID, before, *before); err != nil {
+		return err
+	}
+
+	if err := recordTodoChange(tx, id); err != nil {
+		return err
+	}
+
+	// The todo reappears in lists, so it's new to webhooks
+	if err := queueTodoWebhooks(tx, models.WebhookEventTodoCreated, id, false, actorID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ListTrash gets the todos in the trash that a user can access: their own
+// personal todos and those of the workspaces they belong to
+func (r *TodoRepository) ListTrash(userID uuid.UUID) ([]*models.Todo, error) {
+	query := `
+	SELECT ` + todoColumns + `
+	FROM todos
+	WHERE deleted_at IS NOT NULL
+		AND ((workspace_id IS NULL AND user_id = $1)
+			OR workspace_id IN (SELECT workspace_id FROM workspace_members WHERE user_id = $1))
+	ORDER BY deleted_at DESC
+	`
+
+	rows, err := r.conn().Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	todos := []*models.Todo{}
+	for rows.Next() {
+		todo, err := scanTodo(rows)
+		if err != nil {
+			return nil, err
+		}
+		todos = append(todos, todo)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := r.loadRelations(todos); err != nil {
+		return nil, err
+	}
+
+	return todos, nil
+}
+
+// GetDeletedBefore gets the IDs of todos that were moved to the trash before a cutoff
+func (r *TodoRepository) GetDeletedBefore(cutoff time.Time) ([]uuid.UUID, error) {
+	rows, err := r.conn().Query(`SELECT id FROM todos WHERE deleted_at < $1`, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// Purge permanently deletes a todo in the trash on behalf of an actor
+// (uuid.Nil for the system). It returns the blob storage keys of the todo's
+// attachments, whose rows are removed with it, so the caller can delete the blobs.
+func (r *TodoRepository) Purge(id uuid.UUID, actorID uuid.UUID) ([]string, error) {
+	tx, err := r.begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, _, err := lockSnapshot(tx, id, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// Collect the attachments' storage keys before they cascade away
+	rows, err := tx.Query(`SELECT storage_key FROM attachments WHERE todo_id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	keys := []string{}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Log the change while the todo's audience can still be worked out
+	if err := recordTodoChange(tx, id); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM todos WHERE id = $1`, id); err != nil {
+		return nil, err
+	}
+
+	// The history is kept, ending with the purge
+	if _, err := recordTodoEvent(tx, id, models.TodoEventPurged, actorID, before, *before); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
@@ -67,4 +67,35 @@ func (r *TokenRepository) CleanupExpiredTokens() error {
 
 	_, err := r.db.Exec(query, time.Now())
 	return err
+}
+
+// GetAllByUserID gets the unexpired tokens a user signed out of, oldest first
+func (r *TokenRepository) GetAllByUserID(userID uuid.UUID) ([]*models.BlacklistedToken, error) {
+	query := `
+	SELECT id, token, user_id, expires_at, created_at
+	FROM blacklisted_tokens
+	WHERE user_id = $1 AND expires_at > $2
+	ORDER BY created_at
+	`
+
+	rows, err := r.db.Query(query, userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []*models.BlacklistedToken{}
+	for rows.Next() {
+		token := &models.BlacklistedToken{}
+		if err := rows.Scan(&token.ID, &token.Token, &token.UserID, &token.ExpiresAt, &token.CreatedAt); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
 }
\ No newline at end of file
@@ -0,0 +1,420 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/noman/todo-application/database"
+	"github.com/noman/todo-application/events"
+	"github.com/noman/todo-application/models"
+)
+
+// webhookColumns is the list of columns selected for a webhook; the secret is
+// left out, since it's only shown once
+const webhookColumns = `id, user_id, url, description, events, active, failure_count, disabled_at, created_at, updated_at`
+
+// deliveryColumns is the list of columns selected for a webhook delivery
+const deliveryColumns = `d.id, d.webhook_id, d.event_id, d.event_type, d.payload, d.status, d.attempts, d.response_status, d.error, d.next_attempt_at, d.last_attempt_at, d.created_at`
+
+// WebhookRepository handles database operations for webhooks and their deliveries
+type WebhookRepository struct {
+	db *sql.DB
+}
+
+// NewWebhookRepository creates a new WebhookRepository
+func NewWebhookRepository() *WebhookRepository {
+	return &WebhookRepository{
+		db: database.DB,
+	}
+}
+
+// scanWebhook scans a webhook selected with webhookColumns
+func scanWebhook(row rowScanner) (*models.Webhook, error) {
+	webhook := &models.Webhook{}
+	var disabledAt sql.NullTime
+	err := row.Scan(&webhook.ID, &webhook.UserID, &webhook.URL, &webhook.Description, pq.Array(&webhook.Events), &webhook.Active, &webhook.FailureCount, &disabledAt, &webhook.CreatedAt, &webhook.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if disabledAt.Valid {
+		webhook.DisabledAt = &disabledAt.Time
+	}
+
+	return webhook, nil
+}
+
+// scanDelivery scans a webhook delivery selected with deliveryColumns, along
+// with any extra destinations
+func scanDelivery(row rowScanner, extra ...interface{}) (*models.WebhookDelivery, error) {
+	delivery := &models.WebhookDelivery{}
+	var responseStatus sql.NullInt64
+	var nextAttemptAt, lastAttemptAt sql.NullTime
+	dest := []interface{}{&delivery.ID, &delivery.WebhookID, &delivery.EventID, &delivery.EventType, &delivery.Payload, &delivery.Status, &delivery.Attempts, &responseStatus, &delivery.Error, &nextAttemptAt, &lastAttemptAt, &delivery.CreatedAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+
+	if responseStatus.Valid {
+		status := int(responseStatus.Int64)
+		delivery.ResponseStatus = &status
+	}
+	if nextAttemptAt.Valid && delivery.Status == models.WebhookDeliveryPending {
+		delivery.NextAttemptAt = &nextAttemptAt.Time
+	}
+	if lastAttemptAt.Valid {
+		delivery.LastAttemptAt = &lastAttemptAt.Time
+	}
+
+	return delivery, nil
+}
+
+// Create creates a new webhook
+func (r *WebhookRepository) Create(webhook *models.Webhook) error {
+	// Set the ID and timestamps
+	webhook.ID = uuid.New()
+	webhook.Active = true
+	webhook.CreatedAt = time.Now()
+	webhook.UpdatedAt = time.Now()
+
+	query := `
+	INSERT INTO webhooks (id, user_id, url, description, secret, events, active, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+
+	_, err := r.db.Exec(query, webhook.ID, webhook.UserID, webhook.URL, webhook.Description, webhook.Secret, pq.Array(webhook.Events), webhook.Active, webhook.CreatedAt, webhook.UpdatedAt)
+	return err
+}
+
+// GetByID gets a webhook by ID
+func (r *WebhookRepository) GetByID(id uuid.UUID) (*models.Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE id = $1`
+
+	webhook, err := scanWebhook(r.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrWebhookNotFound
+		}
+		return nil, err
+	}
+
+	return webhook, nil
+}
+
+// GetAllByUserID gets all of a user's webhooks
+func (r *WebhookRepository) GetAllByUserID(userID uuid.UUID) ([]*models.Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE user_id = $1 ORDER BY created_at`
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []*models.Webhook{}
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+// Update updates a webhook's endpoint, events and state. Activating a webhook
+// clears its failures.
+func (r *WebhookRepository) Update(webhook *models.Webhook) error {
+	// Update the timestamp
+	webhook.UpdatedAt = time.Now()
+
+	query := `
+	UPDATE webhooks
+	SET url = $1, description = $2, events = $3, active = $4, updated_at = $5,
+		failure_count = CASE WHEN $4 AND NOT active THEN 0 ELSE failure_count END,
+		disabled_at = CASE WHEN $4 THEN NULL ELSE disabled_at END
+	WHERE id = $6
+	RETURNING failure_count, disabled_at
+	`
+
+	var disabledAt sql.NullTime
+	err := r.db.QueryRow(query, webhook.URL, webhook.Description, pq.Array(webhook.Events), webhook.Active, webhook.UpdatedAt, webhook.ID).Scan(&webhook.FailureCount, &disabledAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrWebhookNotFound
+		}
+		return err
+	}
+
+	webhook.DisabledAt = nil
+	if disabledAt.Valid {
+		webhook.DisabledAt = &disabledAt.Time
+	}
+
+	return nil
+}
+
+// Delete deletes a webhook and its deliveries
+func (r *WebhookRepository) Delete(id uuid.UUID) error {
+	result, err := r.db.Exec(`DELETE FROM webhooks WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	// Check if the webhook was found
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrWebhookNotFound
+	}
+
+	return nil
+}
+
+// queueTodoWebhooks queues a todo event for the active webhooks of everyone
+// who can see the todo that subscribe to its type. It runs in the transaction
+// that changes the todo, so the deliveries commit with the change or not at
+// all. The payload has the same shape as the real-time event, but always
+// includes the todo as the transaction leaves it.
+func queueTodoWebhooks(tx *txn, eventType string, todoID uuid.UUID, deleted bool, actorID uuid.UUID) error {
+	todo, err := (&TodoRepository{tx: tx.Tx}).getByID(todoID, deleted)
+	if err != nil {
+		return err
+	}
+
+	response := todo.ToResponse()
+	event := events.Event{
+		ID:        uuid.New(),
+		Type:      eventType,
+		TodoID:    todo.ID,
+		Todo:      &response,
+		ActorID:   actorID,
+		CreatedAt: time.Now(),
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	// The audience is the todo's creator and the members of its workspace
+	query := `
+	SELECT id FROM webhooks
+	WHERE active AND $2 = ANY(events) AND user_id IN (
+		SELECT user_id FROM todos WHERE id = $1
+		UNION
+		SELECT m.user_id FROM todos t JOIN workspace_members m ON m.workspace_id = t.workspace_id WHERE t.id = $1
+	)
+	`
+
+	rows, err := tx.Query(query, todoID, eventType)
+	if err != nil {
+		return err
+	}
+	webhookIDs := []uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		webhookIDs = append(webhookIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	query = `
+	INSERT INTO webhook_deliveries (id, webhook_id, event_id, event_type, payload, status, next_attempt_at, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+	`
+
+	for _, webhookID := range webhookIDs {
+		if _, err := tx.Exec(query, uuid.New(), webhookID, event.ID, eventType, payload, models.WebhookDeliveryPending, event.CreatedAt); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetDeliveries gets a webhook's most recent deliveries, newest first
+func (r *WebhookRepository) GetDeliveries(webhookID uuid.UUID, limit int) ([]*models.WebhookDelivery, error) {
+	query := `
+	SELECT ` + deliveryColumns + `
+	FROM webhook_deliveries d
+	WHERE d.webhook_id = $1
+	ORDER BY d.created_at DESC
+	LIMIT $2
+	`
+
+	rows, err := r.db.Query(query, webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []*models.WebhookDelivery{}
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// ClaimDeliveries claims up to limit pending deliveries that are due, oldest
+// first, for an attempt. A claimed delivery isn't due again until the lease
+// runs out, so other server instances skip it, and it's retried if this one
+// dies before recording the attempt.
+func (r *WebhookRepository) ClaimDeliveries(limit int, lease time.Duration) ([]*models.WebhookDispatch, error) {
+	now := time.Now()
+	query := `
+	UPDATE webhook_deliveries d
+	SET next_attempt_at = $1
+	FROM webhooks w
+	WHERE w.id = d.webhook_id AND d.id IN (
+		SELECT due.id
+		FROM webhook_deliveries due
+		JOIN webhooks hook ON hook.id = due.webhook_id
+		WHERE due.status = $2 AND due.next_attempt_at <= $3 AND hook.active
+		ORDER BY due.next_attempt_at
+		LIMIT $4
+		FOR UPDATE OF due SKIP LOCKED
+	)
+	RETURNING ` + deliveryColumns + `, w.user_id, w.url, w.secret
+	`
+
+	rows, err := r.db.Query(query, now.Add(lease), models.WebhookDeliveryPending, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	dispatches := []*models.WebhookDispatch{}
+	for rows.Next() {
+		dispatch := &models.WebhookDispatch{}
+		delivery, err := scanDelivery(rows, &dispatch.UserID, &dispatch.URL, &dispatch.Secret)
+		if err != nil {
+			return nil, err
+		}
+		dispatch.Delivery = *delivery
+		dispatches = append(dispatches, dispatch)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return dispatches, nil
+}
+
+// RecordSuccess records a successful attempt, which also clears the webhook's failures
+func (r *WebhookRepository) RecordSuccess(delivery *models.WebhookDelivery, responseStatus int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+	UPDATE webhook_deliveries
+	SET status = $1, attempts = attempts + 1, response_status = $2, error = '', next_attempt_at = NULL, last_attempt_at = $3
+	WHERE id = $4
+	`
+
+	if _, err := tx.Exec(query, models.WebhookDeliverySucceeded, responseStatus, time.Now(), delivery.ID); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`UPDATE webhooks SET failure_count = 0 WHERE id = $1`, delivery.WebhookID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RecordFailure records a failed attempt; responseStatus is 0 if the endpoint
+// didn't respond. The delivery is retried at retryAt, or fails for good if
+// retryAt is nil. The webhook is disabled once it has failed disableAfter
+// times in a row, in which case disabled is true.
+func (r *WebhookRepository) RecordFailure(delivery *models.WebhookDelivery, responseStatus int, message string, retryAt *time.Time, disableAfter int) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	status := models.WebhookDeliveryPending
+	if retryAt == nil {
+		status = models.WebhookDeliveryFailed
+	}
+	var respStatus sql.NullInt64
+	if responseStatus != 0 {
+		respStatus = sql.NullInt64{Int64: int64(responseStatus), Valid: true}
+	}
+
+	query := `
+	UPDATE webhook_deliveries
+	SET status = $1, attempts = attempts + 1, response_status = $2, error = $3, next_attempt_at = $4, last_attempt_at = $5
+	WHERE id = $6
+	`
+
+	now := time.Now()
+	if _, err := tx.Exec(query, status, respStatus, message, retryAt, now, delivery.ID); err != nil {
+		return false, err
+	}
+
+	// Count the failure, disabling the webhook when there are too many
+	webhookQuery := `
+	UPDATE webhooks
+	SET failure_count = failure_count + 1,
+		active = active AND failure_count + 1 < $1,
+		disabled_at = CASE WHEN active AND failure_count + 1 >= $1 THEN $2 ELSE disabled_at END
+	WHERE id = $3
+	RETURNING active
+	`
+
+	var wasActive, active bool
+	if err := tx.QueryRow(`SELECT active FROM webhooks WHERE id = $1 FOR UPDATE`, delivery.WebhookID).Scan(&wasActive); err != nil {
+		return false, err
+	}
+	if err := tx.QueryRow(webhookQuery, disableAfter, now, delivery.WebhookID).Scan(&active); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+
+	return wasActive && !active, nil
+}
+
+// DeleteDeliveriesBefore deletes finished deliveries created before a cutoff.
+// It returns the number of deliveries deleted.
+func (r *WebhookRepository) DeleteDeliveriesBefore(cutoff time.Time) (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM webhook_deliveries WHERE status <> $1 AND created_at < $2`, models.WebhookDeliveryPending, cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,187 @@
+package repository
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/noman/todo-application/database/databasetest"
+	"github.com/noman/todo-application/models"
+)
+
+// newWebhook creates an active webhook for the user
+func newWebhook(t *testing.T, userID uuid.UUID, events ...string) *models.Webhook {
+	t.Helper()
+	webhook := &models.Webhook{UserID: userID, URL: "https://example.com/hook", Secret: "secret", Events: events}
+	if err := NewWebhookRepository().Create(webhook); err != nil {
+		t.Fatal(err)
+	}
+	return webhook
+}
+
+// deliveryTypes returns the event types of a webhook's deliveries, oldest first
+func deliveryTypes(t *testing.T, webhookID uuid.UUID) []string {
+	t.Helper()
+	deliveries, err := NewWebhookRepository().GetDeliveries(webhookID, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	types := []string{}
+	for i := len(deliveries) - 1; i >= 0; i-- {
+		types = append(types, deliveries[i].EventType)
+	}
+	return types
+}
+
+// claimed returns the deliveries of a webhook among the claimed ones
+func claimed(t *testing.T, webhookID uuid.UUID) []*models.WebhookDispatch {
+	t.Helper()
+	dispatches, err := NewWebhookRepository().ClaimDeliveries(1000, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ours := []*models.WebhookDispatch{}
+	for _, dispatch := range dispatches {
+		if dispatch.Delivery.WebhookID == webhookID {
+			ours = append(ours, dispatch)
+		}
+	}
+	return ours
+}
+
+func TestQueueTodoWebhooks(t *testing.T) {
+	databasetest.Open(t)
+	user, other := databasetest.NewUser(t), databasetest.NewUser(t)
+	webhooks, todos := NewWebhookRepository(), NewTodoRepository()
+
+	hook := newWebhook(t, user.ID, models.WebhookEventTodoCreated, models.WebhookEventTodoCompleted)
+	inactive := newWebhook(t, user.ID, models.WebhookEventTodoCreated)
+	inactive.Active = false
+	if err := webhooks.Update(inactive); err != nil {
+		t.Fatal(err)
+	}
+	othersHook := newWebhook(t, other.ID, models.WebhookEventTodoCreated)
+
+	// Only creating and completing the todo are subscribed to
+	todo := &models.Todo{Title: "Pay invoice", UserID: user.ID}
+	if err := todos.Create(todo); err != nil {
+		t.Fatal(err)
+	}
+	todo.Title = "Pay the invoice"
+	if err := todos.Update(todo, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	todo.Completed = true
+	if err := todos.Update(todo, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := todos.Delete(todo, user.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Deliveries are queued in the todo's transaction, so a rolled back
+	// change queues nothing
+	batch, err := BeginBatch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := batch.Todos().Create(&models.Todo{Title: "Rolled back", UserID: user.ID}); err != nil {
+		t.Fatal(err)
+	}
+	batch.Rollback()
+
+	want := []string{models.WebhookEventTodoCreated, models.WebhookEventTodoCompleted}
+	if got := deliveryTypes(t, hook.ID); len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("deliveries = %v, want %v", got, want)
+	}
+	if got := deliveryTypes(t, inactive.ID); len(got) != 0 {
+		t.Errorf("inactive webhook got %v", got)
+	}
+	if got := deliveryTypes(t, othersHook.ID); len(got) != 0 {
+		t.Errorf("another user's webhook got %v", got)
+	}
+
+	// The payload has the todo as the change left it
+	deliveries, err := webhooks.GetDeliveries(hook.ID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var event struct {
+		TodoID uuid.UUID           `json:"todo_id"`
+		Todo   models.TodoResponse `json:"todo"`
+	}
+	if err := json.Unmarshal(deliveries[0].Payload, &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.TodoID != todo.ID || event.Todo.Title != "Pay the invoice" || !event.Todo.Completed {
+		t.Errorf("payload = %s", deliveries[0].Payload)
+	}
+}
+
+func TestClaimDeliveries(t *testing.T) {
+	databasetest.Open(t)
+	user := databasetest.NewUser(t)
+	webhooks := NewWebhookRepository()
+	hook := newWebhook(t, user.ID, models.WebhookEventTodoCreated)
+	for _, title := range []string{"First", "Second"} {
+		if err := NewTodoRepository().Create(&models.Todo{Title: title, UserID: user.ID}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dispatches := claimed(t, hook.ID)
+	if len(dispatches) != 2 {
+		t.Fatalf("claimed %d deliveries, want 2", len(dispatches))
+	}
+	if d := dispatches[0]; d.UserID != user.ID || d.URL != hook.URL || d.Secret != "secret" {
+		t.Errorf("dispatch = %+v", d)
+	}
+
+	// Claimed deliveries aren't due again until their lease runs out
+	if again := claimed(t, hook.ID); len(again) != 0 {
+		t.Errorf("claimed %d leased deliveries", len(again))
+	}
+
+	// A failure with a retry time makes the delivery due again then
+	if err := webhooks.RecordSuccess(&dispatches[1].Delivery, 204); err != nil {
+		t.Fatal(err)
+	}
+	retryAt := time.Now().Add(-time.Second)
+	disabled, err := webhooks.RecordFailure(&dispatches[0].Delivery, 500, "Internal Server Error", &retryAt, 3)
+	if err != nil || disabled {
+		t.Fatalf("RecordFailure = %v, %v", disabled, err)
+	}
+	retried := claimed(t, hook.ID)
+	if len(retried) != 1 || retried[0].Delivery.ID != dispatches[0].Delivery.ID || retried[0].Delivery.Attempts != 1 {
+		t.Fatalf("retried = %+v", retried)
+	}
+
+	// Failing for good counts towards disabling the webhook, and deliveries
+	// of inactive webhooks aren't claimed
+	if _, err := webhooks.RecordFailure(&retried[0].Delivery, 500, "Internal Server Error", nil, 3); err != nil {
+		t.Fatal(err)
+	}
+	current, err := webhooks.GetByID(hook.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.FailureCount != 2 || !current.Active {
+		t.Errorf("webhook after two failures = %+v", current)
+	}
+	if err := NewTodoRepository().Create(&models.Todo{Title: "Third", UserID: user.ID}); err != nil {
+		t.Fatal(err)
+	}
+	retryAt = time.Now().Add(-time.Second)
+	third := claimed(t, hook.ID)
+	if len(third) != 1 {
+		t.Fatalf("claimed %d deliveries, want 1", len(third))
+	}
+	disabled, err = webhooks.RecordFailure(&third[0].Delivery, 0, "Connection refused", &retryAt, 3)
+	if err != nil || !disabled {
+		t.Fatalf("third failure: disabled %v, %v", disabled, err)
+	}
+	if again := claimed(t, hook.ID); len(again) != 0 {
+		t.Errorf("claimed %d deliveries of a disabled webhook", len(again))
+	}
+}
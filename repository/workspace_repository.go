@@ -0,0 +1,356 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/noman/todo-application/database"
+	"github.com/noman/todo-application/models"
+)
+
+// WorkspaceRepository handles database operations for workspaces, members and projects
+type WorkspaceRepository struct {
+	db *sql.DB
+}
+
+// NewWorkspaceRepository creates a new WorkspaceRepository
+func NewWorkspaceRepository() *WorkspaceRepository {
+	return &WorkspaceRepository{
+		db: database.DB,
+	}
+}
+
+// Create creates a new workspace and adds its owner as a member
+func (r *WorkspaceRepository) Create(workspace *models.Workspace) error {
+	// Set the ID and timestamps
+	workspace.ID = uuid.New()
+	workspace.CreatedAt = time.Now()
+	workspace.UpdatedAt = time.Now()
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Insert the workspace into the database
+	query := `
+	INSERT INTO workspaces (id, name, owner_id, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5)
+	`
+
+	if _, err := tx.Exec(query, workspace.ID, workspace.Name, workspace.OwnerID, workspace.CreatedAt, workspace.UpdatedAt); err != nil {
+		return err
+	}
+
+	// Add the owner as the first member
+	memberQuery := `
+	INSERT INTO workspace_members (workspace_id, user_id, role, created_at)
+	VALUES ($1, $2, $3, $4)
+	`
+
+	if _, err := tx.Exec(memberQuery, workspace.ID, workspace.OwnerID, models.WorkspaceRoleOwner, workspace.CreatedAt); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetByID gets a workspace by ID
+func (r *WorkspaceRepository) GetByID(id uuid.UUID) (*models.Workspace, error) {
+	query := `
+	SELECT id, name, owner_id, created_at, updated_at
+	FROM workspaces
+	WHERE id = $1
+	`
+
+	workspace := &models.Workspace{}
+	err := r.db.QueryRow(query, id).Scan(&workspace.ID, &workspace.Name, &workspace.OwnerID, &workspace.CreatedAt, &workspace.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrWorkspaceNotFound
+		}
+		return nil, err
+	}
+
+	return workspace, nil
+}
+
+// GetAllByUserID gets all workspaces a user is a member of
+func (r *WorkspaceRepository) GetAllByUserID(userID uuid.UUID) ([]*models.Workspace, error) {
+	query := `
+	SELECT w.id, w.name, w.owner_id, w.created_at, w.updated_at
+	FROM workspaces w
+	JOIN workspace_members m ON m.workspace_id = w.id
+	WHERE m.user_id = $1
+	ORDER BY w.created_at
+	`
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	workspaces := []*models.Workspace{}
+	for rows.Next() {
+		workspace := &models.Workspace{}
+		if err := rows.Scan(&workspace.ID, &workspace.Name, &workspace.OwnerID, &workspace.CreatedAt, &workspace.UpdatedAt); err != nil {
+			return nil, err
+		}
+		workspaces = append(workspaces, workspace)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return workspaces, nil
+}
+
+// GetMemberRole gets a user's role in a workspace, or an empty string if they are not a member
+func (r *WorkspaceRepository) GetMemberRole(workspaceID, userID uuid.UUID) (string, error) {
+	query := `
+	SELECT role FROM workspace_members
+	WHERE workspace_id = $1 AND user_id = $2
+	`
+
+	var role string
+	err := r.db.QueryRow(query, workspaceID, userID).Scan(&role)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return "", err
+	}
+
+	return role, nil
+}
+
+// IsMember checks if a user is a member of a workspace
+func (r *WorkspaceRepository) IsMember(workspaceID, userID uuid.UUID) (bool, error) {
+	role, err := r.GetMemberRole(workspaceID, userID)
+	if err != nil {
+		return false, err
+	}
+
+	return role != "", nil
+}
+
+// AddMember adds a user to a workspace
+func (r *WorkspaceRepository) AddMember(workspaceID, userID uuid.UUID, role string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+	INSERT INTO workspace_members (workspace_id, user_id, role, created_at)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (workspace_id, user_id) DO UPDATE SET role = EXCLUDED.role
+	RETURNING (xmax = 0)
+	`
+
+	var inserted bool
+	if err := tx.QueryRow(query, workspaceID, userID, role, time.Now()).Scan(&inserted); err != nil {
+		return err
+	}
+
+	// A new member can now see the workspace's todos
+	if inserted {
+		if err := recordAccessChange(tx, workspaceID, userID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// RemoveMember removes a user from a workspace and unassigns them from its todos
+func (r *WorkspaceRepository) RemoveMember(workspaceID, userID uuid.UUID) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+	DELETE FROM workspace_members
+	WHERE workspace_id = $1 AND user_id = $2
+	`
+
+	result, err := tx.Exec(query, workspaceID, userID)
+	if err != nil {
+		return err
+	}
+
+	// Check if the member was found
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrMemberNotFound
+	}
+
+	// Remove the user's assignments on the workspace's todos
+	unassignQuery := `
+	DELETE FROM todo_assignees
+	WHERE user_id = $1 AND todo_id IN (SELECT id FROM todos WHERE workspace_id = $2)
+	RETURNING todo_id
+	`
+
+	rows, err := tx.Query(unassignQuery, userID, workspaceID)
+	if err != nil {
+		return err
+	}
+	unassigned := []uuid.UUID{}
+	for rows.Next() {
+		var todoID uuid.UUID
+		if err := rows.Scan(&todoID); err != nil {
+			rows.Close()
+			return err
+		}
+		unassigned = append(unassigned, todoID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// The remaining members see the assignees change, and the removed user
+	// loses sight of the workspace's todos, except those they created
+	for _, todoID := range unassigned {
+		if err := recordTodoChange(tx, todoID); err != nil {
+			return err
+		}
+	}
+	if err := recordAccessChange(tx, workspaceID, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// TodoAudience returns the users who can see a todo: its creator and the
+// members of its workspace
+func (r *WorkspaceRepository) TodoAudience(todo *models.Todo) ([]uuid.UUID, error) {
+	audience := []uuid.UUID{todo.UserID}
+	if todo.WorkspaceID == nil {
+		return audience, nil
+	}
+
+	members, err := r.GetMembers(*todo.WorkspaceID)
+	if err != nil {
+		return nil, err
+	}
+	for _, member := range members {
+		if member.UserID != todo.UserID {
+			audience = append(audience, member.UserID)
+		}
+	}
+
+	return audience, nil
+}
+
+// GetMembers gets all members of a workspace
+func (r *WorkspaceRepository) GetMembers(workspaceID uuid.UUID) ([]*models.WorkspaceMember, error) {
+	query := `
+	SELECT m.workspace_id, m.user_id, u.username, u.email, m.role, m.created_at
+	FROM workspace_members m
+	JOIN users u ON u.id = m.user_id
+	WHERE m.workspace_id = $1
+	ORDER BY m.created_at
+	`
+
+	rows, err := r.db.Query(query, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []*models.WorkspaceMember{}
+	for rows.Next() {
+		member := &models.WorkspaceMember{}
+		if err := rows.Scan(&member.WorkspaceID, &member.UserID, &member.Username, &member.Email, &member.Role, &member.CreatedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+// CreateProject creates a new project in a workspace
+func (r *WorkspaceRepository) CreateProject(project *models.Project) error {
+	// Set the ID and timestamps
+	project.ID = uuid.New()
+	project.CreatedAt = time.Now()
+	project.UpdatedAt = time.Now()
+
+	query := `
+	INSERT INTO projects (id, workspace_id, name, description, created_by, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	_, err := r.db.Exec(query, project.ID, project.WorkspaceID, project.Name, project.Description, project.CreatedBy, project.CreatedAt, project.UpdatedAt)
+	return err
+}
+
+// GetProjectByID gets a project by ID
+func (r *WorkspaceRepository) GetProjectByID(id uuid.UUID) (*models.Project, error) {
+	query := `
+	SELECT id, workspace_id, name, description, created_by, created_at, updated_at
+	FROM projects
+	WHERE id = $1
+	`
+
+	project := &models.Project{}
+	err := r.db.QueryRow(query, id).Scan(&project.ID, &project.WorkspaceID, &project.Name, &project.Description, &project.CreatedBy, &project.CreatedAt, &project.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrProjectNotFound
+		}
+		return nil, err
+	}
+
+	return project, nil
+}
+
+// GetProjectsByWorkspaceID gets all projects in a workspace
+func (r *WorkspaceRepository) GetProjectsByWorkspaceID(workspaceID uuid.UUID) ([]*models.Project, error) {
+	query := `
+	SELECT id, workspace_id, name, description, created_by, created_at, updated_at
+	FROM projects
+	WHERE workspace_id = $1
+	ORDER BY created_at
+	`
+
+	rows, err := r.db.Query(query, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	projects := []*models.Project{}
+	for rows.Next() {
+		project := &models.Project{}
+		if err := rows.Scan(&project.ID, &project.WorkspaceID, &project.Name, &project.Description, &project.CreatedBy, &project.CreatedAt, &project.UpdatedAt); err != nil {
+			return nil, err
+		}
+		projects = append(projects, project)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return projects, nil
+}
@@ -0,0 +1,288 @@
+// Package search parses the queries used to find todos by the words in their
+// titles and descriptions, and matches and highlights them: as a PostgreSQL
+// tsquery, or in Go where full-text search isn't available
+package search
+
+import (
+	"errors"
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxQueryLength is the longest query that's accepted, in characters
+const MaxQueryLength = 256
+
+// Markers around the matches in text highlighted by PostgreSQL. They're
+// private use characters, so they don't turn up in titles by accident.
+const (
+	StartSel = "\uE000"
+	StopSel  = "\uE001"
+)
+
+// Snippet settings
+const (
+	snippetWords  = 30 // Words in a description snippet
+	snippetBefore = 5  // Words shown before the first match
+)
+
+// Query parsing errors
+var (
+	ErrEmptyQuery   = errors.New("query has no words to search for")
+	ErrQueryTooLong = errors.New("query is too long")
+)
+
+// Term is a word, or a phrase of words that must appear in order, that a
+// todo must contain
+type Term struct {
+	Words  []string // Lowercase
+	Prefix bool     // The last word only has to start a word
+}
+
+// Query is a parsed search query. A todo matches if it contains every term.
+type Query struct {
+	Terms []Term
+}
+
+// Parse parses a search query. Words are separated by spaces, "quoted words"
+// are a phrase, and a word ending in * matches every word it starts. Other
+// punctuation separates words, which are kept together as a phrase.
+func Parse(s string) (*Query, error) {
+	if utf8.RuneCountInString(s) > MaxQueryLength {
+		return nil, ErrQueryTooLong
+	}
+
+	query := &Query{}
+	for s != "" {
+		s = strings.TrimLeftFunc(s, unicode.IsSpace)
+		if s == "" {
+			break
+		}
+
+		// Take a quoted phrase, or a run of text up to the next space
+		var token string
+		if s[0] == '"' {
+			end := strings.IndexByte(s[1:], '"')
+			if end < 0 {
+				token, s = s[1:], ""
+			} else {
+				token, s = s[1:end+1], s[end+2:]
+			}
+		} else {
+			end := strings.IndexFunc(s, unicode.IsSpace)
+			if end < 0 {
+				end = len(s)
+			}
+			token, s = s[:end], s[end:]
+		}
+
+		term := Term{Prefix: strings.HasSuffix(strings.TrimSpace(token), "*")}
+		for _, w := range tokenize(token) {
+			term.Words = append(term.Words, w.text)
+		}
+		if len(term.Words) > 0 {
+			query.Terms = append(query.Terms, term)
+		}
+	}
+
+	if len(query.Terms) == 0 {
+		return nil, ErrEmptyQuery
+	}
+	return query, nil
+}
+
+// TSQuery returns the query as PostgreSQL tsquery text, to be parsed with
+// to_tsquery('simple', ...)
+func (q *Query) TSQuery() string {
+	terms := make([]string, len(q.Terms))
+	for i, term := range q.Terms {
+		lexemes := make([]string, len(term.Words))
+		for j, word := range term.Words {
+			// Words are only letters and digits, but quote them all the same
+			lexemes[j] = "'" + strings.ReplaceAll(strings.ReplaceAll(word, `\`, `\\`), "'", "''") + "'"
+		}
+		if term.Prefix {
+			lexemes[len(lexemes)-1] += ":*"
+		}
+		terms[i] = "(" + strings.Join(lexemes, " <-> ") + ")"
+	}
+	return strings.Join(terms, " & ")
+}
+
+// word is a word of a text, with where it is in the text
+type word struct {
+	text       string // Lowercase
+	start, end int    // Byte offsets in the original text
+}
+
+// tokenize splits text into words of letters and digits
+func tokenize(text string) []word {
+	words := []word{}
+	start := -1
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			words = append(words, word{strings.ToLower(text[start:i]), start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		words = append(words, word{strings.ToLower(text[start:]), start, len(text)})
+	}
+	return words
+}
+
+// matches marks the words that match a term, and returns how many times it
+// matched
+func (t Term) matches(words []word, marked []bool) int {
+	count := 0
+	for i := 0; i+len(t.Words) <= len(words); i++ {
+		matched := true
+		for j, w := range t.Words {
+			last := j == len(t.Words)-1
+			if words[i+j].text != w && !(last && t.Prefix && strings.HasPrefix(words[i+j].text, w)) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			count++
+			for j := range t.Words {
+				marked[i+j] = true
+			}
+		}
+	}
+	return count
+}
+
+// Match is a todo that matched a query, highlighted
+type Match struct {
+	Rank    float64
+	Title   string // The title as HTML, with matches in <mark>
+	Snippet string // An excerpt of the description as HTML, with matches in <mark>
+}
+
+// Match matches a todo's title and description against the query, the way
+// PostgreSQL would, and returns nil if it doesn't match. Each match in the
+// title counts for more than one in the description.
+func (q *Query) Match(title, description string) *Match {
+	titleWords, descriptionWords := tokenize(title), tokenize(description)
+	titleMarked, descriptionMarked := make([]bool, len(titleWords)), make([]bool, len(descriptionWords))
+
+	rank := 0.0
+	for _, term := range q.Terms {
+		inTitle := term.matches(titleWords, titleMarked)
+		inDescription := term.matches(descriptionWords, descriptionMarked)
+		if inTitle == 0 && inDescription == 0 {
+			return nil
+		}
+		rank += float64(inTitle) + 0.4*float64(inDescription)
+	}
+
+	return &Match{
+		Rank:    rank,
+		Title:   markup(title, titleWords, titleMarked, 0, len(titleWords)),
+		Snippet: snippet(description, descriptionWords, descriptionMarked),
+	}
+}
+
+// snippet returns an excerpt of a description around its first match, or its
+// beginning if nothing in it matched
+func snippet(text string, words []word, marked []bool) string {
+	if len(words) <= snippetWords {
+		return markup(text, words, marked, 0, len(words))
+	}
+
+	from := 0
+	for i, m := range marked {
+		if m {
+			from = i - snippetBefore
+			break
+		}
+	}
+	if from > len(words)-snippetWords {
+		from = len(words) - snippetWords
+	}
+	if from < 0 {
+		from = 0
+	}
+	to := from + snippetWords
+
+	excerpt := markup(text, words, marked, from, to)
+	if from > 0 {
+		excerpt = "… " + excerpt
+	}
+	if to < len(words) {
+		excerpt += " …"
+	}
+	return excerpt
+}
+
+// markup returns the text from words[from] to words[to-1] as HTML, with the
+// marked words in <mark>. The whole text is returned if it has no words in
+// the range.
+func markup(text string, words []word, marked []bool, from, to int) string {
+	if from >= to {
+		return html.EscapeString(text)
+	}
+
+	// Keep the text before the first word and after the last when the
+	// range covers all of them
+	start, end := words[from].start, words[to-1].end
+	if from == 0 {
+		start = 0
+	}
+	if to == len(words) {
+		end = len(text)
+	}
+
+	var b strings.Builder
+	pos := start
+	for i := from; i < to; i++ {
+		if !marked[i] {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[pos:words[i].start]))
+		b.WriteString("<mark>" + html.EscapeString(text[words[i].start:words[i].end]) + "</mark>")
+		pos = words[i].end
+	}
+	b.WriteString(html.EscapeString(text[pos:end]))
+	return b.String()
+}
+
+// Highlight turns text highlighted by PostgreSQL, with StartSel and StopSel
+// around the matches, into HTML with the matches in <mark>
+func Highlight(text string) string {
+	var b strings.Builder
+	open := false
+	for text != "" {
+		i := strings.IndexAny(text, StartSel+StopSel)
+		if i < 0 {
+			b.WriteString(html.EscapeString(text))
+			break
+		}
+		b.WriteString(html.EscapeString(text[:i]))
+
+		// Markers that are out of place, because the text contained them,
+		// are dropped so the tags stay balanced
+		marker := text[i : i+len(StartSel)]
+		if marker == StartSel && !open {
+			b.WriteString("<mark>")
+			open = true
+		} else if marker == StopSel && open {
+			b.WriteString("</mark>")
+			open = false
+		}
+		text = text[i+len(StartSel):]
+	}
+	if open {
+		b.WriteString("</mark>")
+	}
+	return b.String()
+}
@@ -0,0 +1,167 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []Term
+	}{
+		{"buy milk", []Term{{Words: []string{"buy"}}, {Words: []string{"milk"}}}},
+		{`  "Buy milk"   eggs* `, []Term{{Words: []string{"buy", "milk"}}, {Words: []string{"eggs"}, Prefix: true}}},
+		{"e-mail", []Term{{Words: []string{"e", "mail"}}}},
+		{"foo*bar", []Term{{Words: []string{"foo", "bar"}}}},
+		{`"new year*"`, []Term{{Words: []string{"new", "year"}, Prefix: true}}},
+		{`"unterminated phrase`, []Term{{Words: []string{"unterminated", "phrase"}}}},
+		{"Café 2024", []Term{{Words: []string{"café"}}, {Words: []string{"2024"}}}},
+		{`!! "" milk`, []Term{{Words: []string{"milk"}}}},
+	}
+	for _, tt := range tests {
+		query, err := Parse(tt.query)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(query.Terms, tt.want) {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.query, query.Terms, tt.want)
+		}
+	}
+
+	for _, query := range []string{"", "   ", `"" * !!`} {
+		if _, err := Parse(query); !errors.Is(err, ErrEmptyQuery) {
+			t.Errorf("Parse(%q): got %v, want ErrEmptyQuery", query, err)
+		}
+	}
+	if _, err := Parse(strings.Repeat("é", MaxQueryLength)); err != nil {
+		t.Errorf("Parse of the longest query: %v", err)
+	}
+	if _, err := Parse(strings.Repeat("é", MaxQueryLength+1)); !errors.Is(err, ErrQueryTooLong) {
+		t.Errorf("Parse of a query that's too long: got %v, want ErrQueryTooLong", err)
+	}
+}
+
+func TestTSQuery(t *testing.T) {
+	tests := []struct {
+		query, want string
+	}{
+		{"milk", "('milk')"},
+		{`"buy milk" eggs*`, "('buy' <-> 'milk') & ('eggs':*)"},
+		{`"new year*" o'brien`, "('new' <-> 'year':*) & ('o' <-> 'brien')"},
+	}
+	for _, tt := range tests {
+		query, err := Parse(tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := query.TSQuery(); got != tt.want {
+			t.Errorf("TSQuery of %q = %s, want %s", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		query, title, description string
+		want                      *Match
+	}{
+		{"milk", "Buy milk", "Milk, and more milk.", &Match{
+			Rank:    1.8,
+			Title:   "Buy <mark>milk</mark>",
+			Snippet: "<mark>Milk</mark>, and more <mark>milk</mark>.",
+		}},
+		{"buy mil*", "Buy milk", "Milky Way", &Match{
+			Rank:    2.4,
+			Title:   "<mark>Buy</mark> <mark>milk</mark>",
+			Snippet: "<mark>Milky</mark> Way",
+		}},
+		{`"buy milk"`, "Buy milk, buy bread", "", &Match{
+			Rank:  1,
+			Title: "<mark>Buy</mark> <mark>milk</mark>, buy bread",
+		}},
+		{"tom", "<b>Tom & Jerry</b>", "", &Match{
+			Rank:  1,
+			Title: "&lt;b&gt;<mark>Tom</mark> &amp; Jerry&lt;/b&gt;",
+		}},
+		{"jerry", "Call Tom", "Ask about <Jerry>", &Match{
+			Rank:    0.4,
+			Title:   "Call Tom",
+			Snippet: "Ask about &lt;<mark>Jerry</mark>&gt;",
+		}},
+		{`"milk buy"`, "Buy milk", "", nil},
+		{"milk eggs", "Buy milk", "", nil},
+		{"mil", "Buy milk", "", nil},
+	}
+	for _, tt := range tests {
+		query, err := Parse(tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := query.Match(tt.title, tt.description)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Match(%q) of %q, %q = %+v, want %+v", tt.query, tt.title, tt.description, got, tt.want)
+		}
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	// A description of 50 words, w0 to w49
+	words := make([]string, 50)
+	for i := range words {
+		words[i] = fmt.Sprintf("w%d", i)
+	}
+	description := strings.Join(words, " ") + "."
+
+	// excerpt returns words[from:to] with the match marked
+	excerpt := func(from, to, match int) string {
+		marked := append([]string{}, words[from:to]...)
+		if match >= from && match < to {
+			marked[match-from] = "<mark>" + marked[match-from] + "</mark>"
+		}
+		return strings.Join(marked, " ")
+	}
+
+	tests := []struct {
+		query, want string
+	}{
+		{"w20", "… " + excerpt(15, 45, 20) + " …"},
+		{"w2", excerpt(0, 30, 2) + " …"},
+		{"w48", "… " + excerpt(20, 50, 48) + "."},
+		{"title", excerpt(0, 30, -1) + " …"},
+	}
+	for _, tt := range tests {
+		query, err := Parse(tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		match := query.Match("Title", description)
+		if match == nil {
+			t.Fatalf("%q didn't match", tt.query)
+		}
+		if match.Snippet != tt.want {
+			t.Errorf("snippet for %q = %q, want %q", tt.query, match.Snippet, tt.want)
+		}
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		text, want string
+	}{
+		{"Buy milk", "Buy milk"},
+		{"Buy " + StartSel + "milk" + StopSel + " & eggs", "Buy <mark>milk</mark> &amp; eggs"},
+		{StartSel + "a" + StopSel + " " + StartSel + "b" + StopSel, "<mark>a</mark> <mark>b</mark>"},
+		{StopSel + "a" + StartSel + StartSel + "b" + StopSel + StopSel, "a<mark>b</mark>"},
+		{"<" + StartSel + "unclosed", "&lt;<mark>unclosed</mark>"},
+	}
+	for _, tt := range tests {
+		if got := Highlight(tt.text); got != tt.want {
+			t.Errorf("Highlight(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
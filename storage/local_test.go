@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalStore(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "blobs")
+	store, err := NewLocalStore(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	content := "hello, attachments"
+	key := "attachments/user/file"
+
+	if err := store.Put(ctx, key, strings.NewReader(content), int64(len(content)), "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+
+	blob, err := store.Open(ctx, key, int64(len(content)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readAll(t, blob); got != content {
+		t.Errorf("got %q, want %q", got, content)
+	}
+
+	checkRanges(t, func() Blob {
+		blob, err := store.Open(ctx, key, int64(len(content)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return blob
+	}, content)
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Open(ctx, key, int64(len(content))); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v after deleting, want ErrNotFound", err)
+	}
+
+	// Deleting a missing blob isn't an error
+	if err := store.Delete(ctx, key); err != nil {
+		t.Errorf("got %v deleting a missing blob", err)
+	}
+}
+
+func TestLocalStorePutWithWrongSizeLeavesNothing(t *testing.T) {
+	root := t.TempDir()
+	store, err := NewLocalStore(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if err := store.Put(ctx, "dir/short", strings.NewReader("abc"), 10, ""); err == nil {
+		t.Fatal("got no error for a short body")
+	}
+	if _, err := store.Open(ctx, "dir/short", 10); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v, want ErrNotFound", err)
+	}
+
+	// The temporary file is cleaned up too
+	entries, err := os.ReadDir(filepath.Join(root, "dir"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("left %d files behind", len(entries))
+	}
+}
+
+func TestLocalStoreKeys(t *testing.T) {
+	root := t.TempDir()
+	store, err := NewLocalStore(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"", "/", "..", `a\b`} {
+		if _, err := store.path(key); err == nil {
+			t.Errorf("key %q: got no error", key)
+		}
+	}
+
+	// Keys can't climb out of the root
+	for _, key := range []string{"../../etc/passwd", "/etc/passwd", "a/../../b"} {
+		path, err := store.path(key)
+		if err != nil {
+			t.Errorf("key %q: %v", key, err)
+			continue
+		}
+		if !strings.HasPrefix(path, root+string(filepath.Separator)) {
+			t.Errorf("key %q maps to %s, outside %s", key, path, root)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeS3 is an in-memory S3 bucket served over HTTP with path-style addressing
+type fakeS3 struct {
+	t      *testing.T
+	bucket string
+
+	mu       sync.Mutex
+	objects  map[string][]byte
+	types    map[string]string
+	requests []string // Method, key and Range of each request
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Every request must be signed with the store's credentials
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=test-key/") || !strings.Contains(auth, "/us-east-1/s3/aws4_request") {
+		f.t.Errorf("%s %s: unexpected Authorization %q", r.Method, r.URL.Path, auth)
+	}
+	if r.Header.Get("X-Amz-Date") == "" || r.Header.Get("X-Amz-Content-Sha256") != unsignedPayload {
+		f.t.Errorf("%s %s: missing signing headers", r.Method, r.URL.Path)
+	}
+
+	prefix := "/" + f.bucket + "/"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		http.Error(w, "NoSuchBucket", http.StatusNotFound)
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, prefix)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, strings.TrimSpace(r.Method+" "+key+" "+r.Header.Get("Range")))
+
+	object, ok := f.objects[key]
+	switch r.Method {
+	case http.MethodPut:
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if int64(len(data)) != r.ContentLength {
+			http.Error(w, "IncompleteBody", http.StatusBadRequest)
+			return
+		}
+		f.objects[key] = data
+		f.types[key] = r.Header.Get("Content-Type")
+
+	case http.MethodHead, http.MethodGet:
+		if !ok {
+			http.Error(w, "<Error><Code>NoSuchKey</Code></Error>", http.StatusNotFound)
+			return
+		}
+
+		// The store only asks for open-ended ranges
+		start := 0
+		if rangeHeader := r.Header.Get("Range"); rangeHeader != "" {
+			n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(rangeHeader, "bytes="), "-"))
+			if err != nil || n >= len(object) {
+				http.Error(w, "InvalidRange", http.StatusRequestedRangeNotSatisfiable)
+				return
+			}
+			start = n
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", n, len(object)-1, len(object)))
+			w.WriteHeader(http.StatusPartialContent)
+		}
+		if r.Method == http.MethodGet {
+			w.Write(object[start:])
+		}
+
+	case http.MethodDelete:
+		// S3 deletes missing objects without complaint
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "MethodNotAllowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// newFakeS3Store starts a fake bucket and creates a store for it
+func newFakeS3Store(t *testing.T) (*S3Store, *fakeS3) {
+	t.Helper()
+	fake := &fakeS3{t: t, bucket: "attachments", objects: map[string][]byte{}, types: map[string]string{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	store, err := NewS3Store(S3Config{
+		Endpoint:        server.URL,
+		Bucket:          "attachments",
+		AccessKeyID:     "test-key",
+		SecretAccessKey: "test-secret",
+		UsePathStyle:    true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store, fake
+}
+
+func TestS3Store(t *testing.T) {
+	store, fake := newFakeS3Store(t)
+	ctx := context.Background()
+	content := "hello, attachments"
+	key := "attachments/user/file name.txt"
+
+	if err := store.Put(ctx, key, strings.NewReader(content), int64(len(content)), "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(fake.objects[key]); got != content {
+		t.Fatalf("stored %q, want %q", got, content)
+	}
+	if got := fake.types[key]; got != "text/plain" {
+		t.Errorf("stored content type %q", got)
+	}
+
+	blob, err := store.Open(ctx, key, int64(len(content)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readAll(t, blob); got != content {
+		t.Errorf("got %q, want %q", got, content)
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fake.objects[key]; ok {
+		t.Error("object wasn't deleted")
+	}
+
+	// Deleting a missing blob isn't an error
+	if err := store.Delete(ctx, key); err != nil {
+		t.Errorf("got %v deleting a missing blob", err)
+	}
+}
+
+func TestS3StoreRanges(t *testing.T) {
+	store, fake := newFakeS3Store(t)
+	ctx := context.Background()
+	content := "0123456789abcdef"
+	key := "attachments/user/file"
+
+	if err := store.Put(ctx, key, strings.NewReader(content), int64(len(content)), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	checkRanges(t, func() Blob {
+		blob, err := store.Open(ctx, key, int64(len(content)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return blob
+	}, content)
+
+	// Seeking only moves the offset; the next read fetches from there
+	fake.requests = nil
+	blob, err := store.Open(ctx, key, int64(len(content)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer blob.Close()
+	if _, err := blob.Seek(10, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := blob.Seek(-4, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 2)
+	if _, err := io.ReadFull(blob, buf); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "cd" {
+		t.Errorf("read %q at offset 12, want %q", buf, "cd")
+	}
+
+	want := []string{"HEAD " + key, "GET " + key + " bytes=12-"}
+	if fmt.Sprint(fake.requests) != fmt.Sprint(want) {
+		t.Errorf("sent %q, want %q", fake.requests, want)
+	}
+}
+
+func TestS3StoreNotFound(t *testing.T) {
+	store, _ := newFakeS3Store(t)
+
+	if _, err := store.Open(context.Background(), "attachments/missing", 10); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v, want ErrNotFound", err)
+	}
+}
+
+func TestS3StoreErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "<Error><Code>AccessDenied</Code></Error>", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	store, err := NewS3Store(S3Config{Endpoint: server.URL, Bucket: "b", AccessKeyID: "k", SecretAccessKey: "s", UsePathStyle: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.Put(context.Background(), "key", strings.NewReader("x"), 1, "")
+	if err == nil || errors.Is(err, ErrNotFound) || !strings.Contains(err.Error(), "403") || !strings.Contains(err.Error(), "AccessDenied") {
+		t.Errorf("got %v, want the 403 and S3 error code", err)
+	}
+}
+
+func TestS3ObjectURL(t *testing.T) {
+	tests := []struct {
+		endpoint  string
+		pathStyle bool
+		want      string
+	}{
+		{"https://s3.us-east-1.amazonaws.com", false, "https://bucket.s3.us-east-1.amazonaws.com/attachments/a%20b%2Bc"},
+		{"http://localhost:9000", true, "http://localhost:9000/bucket/attachments/a%20b%2Bc"},
+		{"http://localhost:9000/minio/", true, "http://localhost:9000/minio/bucket/attachments/a%20b%2Bc"},
+	}
+	for _, tt := range tests {
+		store, err := NewS3Store(S3Config{Endpoint: tt.endpoint, Bucket: "bucket", AccessKeyID: "k", SecretAccessKey: "s", UsePathStyle: tt.pathStyle})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := store.objectURL("attachments/a b+c").String(); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.endpoint, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+// ErrNotFound is returned when a blob doesn't exist
+var ErrNotFound = errors.New("blob not found")
+
+// Blob is an open blob that can be read from any offset, which lets
+// http.ServeContent answer range requests
+type Blob interface {
+	io.ReadSeekCloser
+}
+
+// BlobStore stores opaque binary objects under string keys
+type BlobStore interface {
+	// Put stores size bytes read from r under key
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Open opens the blob stored under key; size is the blob's known length
+	Open(ctx context.Context, key string, size int64) (Blob, error)
+	// Delete removes the blob stored under key; deleting a missing blob is not an error
+	Delete(ctx context.Context, key string) error
+}
+
+// Store is the blob store used by the application
+var Store BlobStore
+
+// InitBlobStore initializes the blob store selected by the BLOB_STORE
+// environment variable ("local", the default, or "s3")
+func InitBlobStore() {
+	switch strings.ToLower(os.Getenv("BLOB_STORE")) {
+	case "", "local":
+		dir := os.Getenv("BLOB_LOCAL_DIR")
+		if dir == "" {
+			dir = "uploads" // Default directory if not specified
+		}
+
+		store, err := NewLocalStore(dir)
+		if err != nil {
+			log.Fatalf("Failed to initialize local blob store: %v", err)
+		}
+		Store = store
+		log.Printf("Storing attachments in %s", dir)
+
+	case "s3":
+		store, err := NewS3Store(S3Config{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Region:          os.Getenv("S3_REGION"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+			UsePathStyle:    os.Getenv("S3_USE_PATH_STYLE") == "true",
+		})
+		if err != nil {
+			log.Fatalf("Failed to initialize S3 blob store: %v", err)
+		}
+		Store = store
+		log.Printf("Storing attachments in S3 bucket %s", os.Getenv("S3_BUCKET"))
+
+	default:
+		log.Fatalf("Unknown BLOB_STORE %q", os.Getenv("BLOB_STORE"))
+	}
+}
+
+// DeleteAll deletes blobs that are no longer referenced, logging failures
+// since there is nothing left to roll back
+func DeleteAll(ctx context.Context, store BlobStore, keys []string) {
+	for _, key := range keys {
+		if err := store.Delete(ctx, key); err != nil {
+			log.Printf("Failed to delete blob %s: %v", key, err)
+		}
+	}
+}
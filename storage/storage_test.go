@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// checkRanges serves a blob with http.ServeContent, like attachment downloads,
+// and checks that range requests get the right parts of content
+func checkRanges(t *testing.T, open func() Blob, content string) {
+	t.Helper()
+
+	tests := []struct {
+		rangeHeader string
+		status      int
+		body        string
+	}{
+		{"", http.StatusOK, content},
+		{"bytes=0-3", http.StatusPartialContent, content[0:4]},
+		{"bytes=6-", http.StatusPartialContent, content[6:]},
+		{"bytes=-5", http.StatusPartialContent, content[len(content)-5:]},
+		{"bytes=4-4", http.StatusPartialContent, content[4:5]},
+		{"bytes=1000-", http.StatusRequestedRangeNotSatisfiable, ""},
+	}
+	for _, tt := range tests {
+		blob := open()
+		req := httptest.NewRequest(http.MethodGet, "/blob", nil)
+		if tt.rangeHeader != "" {
+			req.Header.Set("Range", tt.rangeHeader)
+		}
+		w := httptest.NewRecorder()
+		http.ServeContent(w, req, "blob.txt", time.Time{}, blob)
+		blob.Close()
+
+		if w.Code != tt.status {
+			t.Errorf("Range %q: got status %d, want %d", tt.rangeHeader, w.Code, tt.status)
+			continue
+		}
+		if tt.status == http.StatusRequestedRangeNotSatisfiable {
+			continue
+		}
+		if body := w.Body.String(); body != tt.body {
+			t.Errorf("Range %q: got %q, want %q", tt.rangeHeader, body, tt.body)
+		}
+	}
+}
+
+// readAll reads a whole blob and closes it
+func readAll(t *testing.T, blob Blob) string {
+	t.Helper()
+	defer blob.Close()
+	data, err := io.ReadAll(blob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
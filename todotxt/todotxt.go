@@ -0,0 +1,143 @@
+// Package todotxt reads and writes tasks in the todo.txt format
+// (https://github.com/todotxt/todo.txt), one task per line
+package todotxt
+
+import (
+	"strings"
+	"time"
+)
+
+// MediaType is the content type of todo.txt files
+const MediaType = "text/plain; charset=utf-8"
+
+// dateLayout is the format of completion and creation dates
+const dateLayout = "2006-01-02"
+
+// Task is a single line of a todo.txt file. Text keeps the task's +project
+// and @context tags and key:value pairs as they were written.
+type Task struct {
+	Completed      bool
+	Priority       string // "A" to "Z", or empty
+	CompletionDate time.Time
+	CreationDate   time.Time
+	Text           string
+}
+
+// Parse reads a task from a line of a todo.txt file. Every line is a valid
+// task; what isn't a marker or a date is the task's text.
+func Parse(line string) Task {
+	var task Task
+	rest := strings.TrimSpace(line)
+
+	// Completed tasks start with a lowercase x
+	if strings.HasPrefix(rest, "x ") {
+		task.Completed = true
+		rest = strings.TrimLeft(rest[2:], " ")
+	}
+
+	// Only open tasks have a priority, though some apps keep it when
+	// completing a task
+	if len(rest) >= 4 && rest[0] == '(' && rest[1] >= 'A' && rest[1] <= 'Z' && rest[2] == ')' && rest[3] == ' ' {
+		task.Priority = rest[1:2]
+		rest = strings.TrimLeft(rest[4:], " ")
+	}
+
+	// A completed task has its completion date, then its creation date; an
+	// open task only has its creation date
+	date, ok := parseDate(&rest)
+	if ok {
+		if task.Completed {
+			task.CompletionDate = date
+			task.CreationDate, _ = parseDate(&rest)
+		} else {
+			task.CreationDate = date
+		}
+	}
+
+	task.Text = rest
+	return task
+}
+
+// parseDate reads a date and the space after it from the start of s
+func parseDate(s *string) (time.Time, bool) {
+	if len(*s) < len(dateLayout)+1 || (*s)[len(dateLayout)] != ' ' {
+		return time.Time{}, false
+	}
+	date, err := time.Parse(dateLayout, (*s)[:len(dateLayout)])
+	if err != nil {
+		return time.Time{}, false
+	}
+	*s = strings.TrimLeft((*s)[len(dateLayout)+1:], " ")
+	return date, true
+}
+
+// isTag reports whether a word is a +project or @context tag
+func isTag(word string) bool {
+	return len(word) > 1 && (word[0] == '+' || word[0] == '@')
+}
+
+// Tags returns the +project and @context tags of the task, without their
+// + or @, in the order they're written
+func (t Task) Tags() []string {
+	var tags []string
+	for _, word := range strings.Fields(t.Text) {
+		if isTag(word) {
+			tags = append(tags, word[1:])
+		}
+	}
+	return tags
+}
+
+// Value returns the value of the task's first key:value pair with a key, or
+// "" if it has none
+func (t Task) Value(key string) string {
+	for _, word := range strings.Fields(t.Text) {
+		if value, ok := strings.CutPrefix(word, key+":"); ok && value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// PlainText returns the task's text without its tags and the key:value pairs
+// with the given keys
+func (t Task) PlainText(keys ...string) string {
+	var words []string
+	for _, word := range strings.Fields(t.Text) {
+		if isTag(word) {
+			continue
+		}
+		pair := false
+		for _, key := range keys {
+			if value, ok := strings.CutPrefix(word, key+":"); ok && value != "" {
+				pair = true
+			}
+		}
+		if !pair {
+			words = append(words, word)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// String formats the task as a line of a todo.txt file, without the line
+// break. Runs of whitespace in the text, line breaks included, become single
+// spaces.
+func (t Task) String() string {
+	var line strings.Builder
+	if t.Completed {
+		line.WriteString("x ")
+	}
+	if t.Priority != "" {
+		line.WriteString("(" + t.Priority + ") ")
+	}
+	// The creation date can only be written after a completion date
+	if t.Completed && !t.CompletionDate.IsZero() {
+		line.WriteString(t.CompletionDate.Format(dateLayout) + " ")
+	}
+	if !t.CreationDate.IsZero() && (!t.Completed || !t.CompletionDate.IsZero()) {
+		line.WriteString(t.CreationDate.Format(dateLayout) + " ")
+	}
+	line.WriteString(strings.Join(strings.Fields(t.Text), " "))
+	return line.String()
+}
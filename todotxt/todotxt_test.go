@@ -0,0 +1,88 @@
+package todotxt
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	t, err := time.Parse(dateLayout, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		line string
+		want Task
+	}{
+		{"Call mom", Task{Text: "Call mom"}},
+		{"  (A) Call mom  ", Task{Priority: "A", Text: "Call mom"}},
+		{"2024-05-01 Call mom", Task{CreationDate: date("2024-05-01"), Text: "Call mom"}},
+		{"(B) 2024-05-01 Call mom +family @phone", Task{Priority: "B", CreationDate: date("2024-05-01"), Text: "Call mom +family @phone"}},
+		{"x 2024-05-02 2024-05-01 Call mom", Task{Completed: true, CompletionDate: date("2024-05-02"), CreationDate: date("2024-05-01"), Text: "Call mom"}},
+		{"x 2024-05-02 Call mom", Task{Completed: true, CompletionDate: date("2024-05-02"), Text: "Call mom"}},
+		{"x (A) 2024-05-02 Call mom", Task{Completed: true, Priority: "A", CompletionDate: date("2024-05-02"), Text: "Call mom"}},
+
+		// Things that only look like markers are text
+		{"X 2024-05-02 Call mom", Task{Text: "X 2024-05-02 Call mom"}},
+		{"xylophone lesson", Task{Text: "xylophone lesson"}},
+		{"(a) lowercase", Task{Text: "(a) lowercase"}},
+		{"(A)no space", Task{Text: "(A)no space"}},
+		{"2024-13-01 not a date", Task{Text: "2024-13-01 not a date"}},
+		{"2024-05-01", Task{Text: "2024-05-01"}},
+	}
+	for _, tt := range tests {
+		if got := Parse(tt.line); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		task Task
+		want string
+	}{
+		{Task{Text: "Call mom"}, "Call mom"},
+		{Task{Priority: "A", CreationDate: date("2024-05-01"), Text: "Call mom"}, "(A) 2024-05-01 Call mom"},
+		{Task{Completed: true, CompletionDate: date("2024-05-02"), CreationDate: date("2024-05-01"), Text: "Call mom"}, "x 2024-05-02 2024-05-01 Call mom"},
+		// A creation date can't be written without a completion date
+		{Task{Completed: true, CreationDate: date("2024-05-01"), Text: "Call mom"}, "x Call mom"},
+		{Task{Text: "Line one\nline  two"}, "Line one line two"},
+	}
+	for _, tt := range tests {
+		got := tt.task.String()
+		if got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+
+		// What's written reads back the same
+		if parsed := Parse(got).String(); parsed != got {
+			t.Errorf("Parse(%q).String() = %q", got, parsed)
+		}
+	}
+}
+
+func TestTagsAndValues(t *testing.T) {
+	task := Parse("(A) Pay invoice +work @office due:2024-05-17 see http://example.com + @ due:")
+
+	if got, want := task.Tags(), []string{"work", "office"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Tags() = %q, want %q", got, want)
+	}
+	if got := task.Value("due"); got != "2024-05-17" {
+		t.Errorf(`Value("due") = %q, want 2024-05-17`, got)
+	}
+	if got := task.Value("rec"); got != "" {
+		t.Errorf(`Value("rec") = %q, want ""`, got)
+	}
+	if got, want := task.PlainText("due"), "Pay invoice see http://example.com + @ due:"; got != want {
+		t.Errorf(`PlainText("due") = %q, want %q`, got, want)
+	}
+	if got, want := task.PlainText(), "Pay invoice due:2024-05-17 see http://example.com + @ due:"; got != want {
+		t.Errorf("PlainText() = %q, want %q", got, want)
+	}
+}
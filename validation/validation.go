@@ -0,0 +1,334 @@
+// Package validation checks request payloads against rules declared in
+// `validate` struct tags, for example:
+//
+//	Title string `json:"title" validate:"required,max=100"`
+//
+// Supported rules are:
+//   - required: the value is set; strings must not be blank
+//   - omitempty: skip the other rules when the value is empty
+//   - min=N, max=N: the length of a string (in characters) or slice
+//   - itemmax=N: the length of every string in a slice, in characters
+//   - maxbytes=N: the length of a string in bytes
+//   - email: a plausible email address
+//   - url: an absolute http or https URL
+//   - oneof=a b c: one of a fixed set of strings; for a slice of strings,
+//     every element must be
+//
+// Nested structs and slices of structs are validated too. Failures are
+// reported per field, named by JSON path (e.g. "operations[2].op").
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/noman/todo-application/problem"
+)
+
+// Field error codes
+const (
+	CodeRequired    = "required"
+	CodeTooShort    = "too_short"
+	CodeTooLong     = "too_long"
+	CodeInvalidEnum = "invalid_enum"
+	CodeInvalidType = "invalid_type"
+	CodeEmail       = "invalid_email"
+	CodeURL         = "invalid_url"
+	CodeUnknown     = "unknown_field"
+
+	// CodeInvalidReference is for IDs that don't refer to a usable record; it
+	// is reported by handlers rather than by tag rules
+	CodeInvalidReference = "invalid_reference"
+)
+
+// Errors is the list of field-level failures of a payload
+type Errors []problem.FieldError
+
+// Error implements the error interface
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, fieldErr := range e {
+		messages[i] = fieldErr.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// ErrMalformed is returned by Decode when the payload isn't valid JSON
+var ErrMalformed = errors.New("malformed JSON")
+
+// Decode parses a JSON payload into v, which must be a pointer to a struct,
+// and validates it. Unknown fields and values of the wrong type are reported
+// as Errors, as are failed rules; a payload that isn't JSON at all returns
+// an error wrapping ErrMalformed.
+func Decode(r io.Reader, v interface{}) error {
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(v); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return Errors{{Field: typeErr.Field, Code: CodeInvalidType, Message: "must be " + describeType(typeErr.Type)}}
+		}
+		// encoding/json has no error type for unknown fields
+		if name, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			name, _ = strconv.Unquote(name)
+			return Errors{{Field: name, Code: CodeUnknown, Message: "is not allowed"}}
+		}
+		return fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+
+	if errs := Struct(v); errs != nil {
+		return errs
+	}
+	return nil
+}
+
+// Struct validates a struct, or a pointer to one, against its `validate` tags
+func Struct(v interface{}) Errors {
+	var errs Errors
+	validateValue(reflect.ValueOf(v), "", &errs)
+	return errs
+}
+
+// validateValue validates the fields of a struct and the elements of a slice
+func validateValue(value reflect.Value, path string, errs *Errors) {
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return
+		}
+		value = value.Elem()
+	}
+
+	switch value.Kind() {
+	case reflect.Struct:
+		structType := value.Type()
+		for i := 0; i < structType.NumField(); i++ {
+			field := structType.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+
+			fieldPath := joinPath(path, jsonName(field))
+			if tag := field.Tag.Get("validate"); tag != "" {
+				validateField(value.Field(i), fieldPath, tag, errs)
+			}
+			validateValue(value.Field(i), fieldPath, errs)
+		}
+
+	case reflect.Slice, reflect.Array:
+		// Only slices of structs have anything inside them to validate
+		elemType := value.Type().Elem()
+		for elemType.Kind() == reflect.Ptr {
+			elemType = elemType.Elem()
+		}
+		if elemType.Kind() != reflect.Struct {
+			return
+		}
+		for i := 0; i < value.Len(); i++ {
+			validateValue(value.Index(i), fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	}
+}
+
+// validateField checks one field against the rules in its tag, stopping at
+// the first rule that fails
+func validateField(value reflect.Value, path, tag string, errs *Errors) {
+	fail := func(code, message string) {
+		*errs = append(*errs, problem.FieldError{Field: path, Code: code, Message: message})
+	}
+
+	// Rules apply to what a pointer points to; a nil pointer is empty
+	empty := isEmpty(value)
+	for value.Kind() == reflect.Ptr && !value.IsNil() {
+		value = value.Elem()
+	}
+
+	for _, rule := range strings.Split(tag, ",") {
+		name, arg, _ := strings.Cut(rule, "=")
+
+		switch name {
+		case "omitempty":
+			if empty {
+				return
+			}
+
+		case "required":
+			if empty {
+				fail(CodeRequired, "is required")
+				return
+			}
+
+		case "min", "max":
+			limit, err := strconv.Atoi(arg)
+			if err != nil {
+				panic(fmt.Sprintf("validation: invalid %s rule %q on %s", name, rule, path))
+			}
+			length, unit, ok := lengthOf(value)
+			if !ok {
+				continue
+			}
+			if name == "min" && length < limit {
+				fail(CodeTooShort, fmt.Sprintf("must be at least %d %s", limit, unit))
+				return
+			}
+			if name == "max" && length > limit {
+				fail(CodeTooLong, fmt.Sprintf("must be at most %d %s", limit, unit))
+				return
+			}
+
+		case "itemmax":
+			limit, err := strconv.Atoi(arg)
+			if err != nil {
+				panic(fmt.Sprintf("validation: invalid %s rule %q on %s", name, rule, path))
+			}
+			if value.Kind() != reflect.Slice || value.Type().Elem().Kind() != reflect.String {
+				continue
+			}
+			for i := 0; i < value.Len(); i++ {
+				if utf8.RuneCountInString(value.Index(i).String()) > limit {
+					*errs = append(*errs, problem.FieldError{Field: fmt.Sprintf("%s[%d]", path, i), Code: CodeTooLong, Message: fmt.Sprintf("must be at most %d characters", limit)})
+					return
+				}
+			}
+
+		case "maxbytes":
+			limit, err := strconv.Atoi(arg)
+			if err != nil {
+				panic(fmt.Sprintf("validation: invalid %s rule %q on %s", name, rule, path))
+			}
+			if value.Kind() == reflect.String && len(value.String()) > limit {
+				fail(CodeTooLong, fmt.Sprintf("must be at most %d bytes", limit))
+				return
+			}
+
+		case "email":
+			if value.Kind() != reflect.String || empty {
+				continue
+			}
+			address, err := mail.ParseAddress(value.String())
+			if err != nil || address.Address != value.String() {
+				fail(CodeEmail, "must be a valid email address")
+				return
+			}
+
+		case "url":
+			if value.Kind() != reflect.String || empty {
+				continue
+			}
+			parsed, err := url.Parse(value.String())
+			if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+				fail(CodeURL, "must be an http or https URL")
+				return
+			}
+
+		case "oneof":
+			options := strings.Fields(arg)
+			if value.Kind() == reflect.Slice && value.Type().Elem().Kind() == reflect.String {
+				for i := 0; i < value.Len(); i++ {
+					if !contains(options, value.Index(i).String()) {
+						*errs = append(*errs, problem.FieldError{Field: fmt.Sprintf("%s[%d]", path, i), Code: CodeInvalidEnum, Message: "must be one of " + strings.Join(options, ", ")})
+						return
+					}
+				}
+				continue
+			}
+			if value.Kind() != reflect.String {
+				continue
+			}
+			if !contains(options, value.String()) {
+				fail(CodeInvalidEnum, "must be one of "+strings.Join(options, ", "))
+				return
+			}
+
+		default:
+			panic(fmt.Sprintf("validation: unknown rule %q on %s", rule, path))
+		}
+	}
+}
+
+// isEmpty checks if a value is unset: a nil pointer or slice, a blank string
+// or another zero value
+func isEmpty(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if value.IsNil() {
+			return true
+		}
+		return isEmpty(value.Elem())
+	case reflect.String:
+		return strings.TrimSpace(value.String()) == ""
+	case reflect.Slice, reflect.Map:
+		return value.Len() == 0
+	default:
+		return value.IsZero()
+	}
+}
+
+// lengthOf returns the length of a string in characters or of a slice in
+// items, and false for values that have no length
+func lengthOf(value reflect.Value) (int, string, bool) {
+	switch value.Kind() {
+	case reflect.String:
+		return utf8.RuneCountInString(value.String()), "characters", true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return value.Len(), "items", true
+	default:
+		return 0, "", false
+	}
+}
+
+// describeType describes the JSON type a Go type is decoded from
+func describeType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Struct, reflect.Map:
+		return "an object"
+	default:
+		return "a " + t.String()
+	}
+}
+
+// jsonName returns the name a struct field has in JSON
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
+
+// joinPath appends a field name to a JSON path
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// contains checks if a string is in a list
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package validation
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type item struct {
+	Op string `json:"op" validate:"required,oneof=create delete"`
+}
+
+type payload struct {
+	Title    *string  `json:"title" validate:"required,max=5"`
+	Note     string   `json:"note" validate:"omitempty,min=2"`
+	Password string   `json:"password" validate:"maxbytes=4"`
+	Email    string   `json:"email" validate:"omitempty,email"`
+	URL      string   `json:"url" validate:"omitempty,url"`
+	Tags     []string `json:"tags" validate:"max=2,itemmax=3"`
+	Events   []string `json:"events" validate:"oneof=a b"`
+	Items    []item   `json:"items" validate:"max=3"`
+	Count    int      `json:"count"`
+}
+
+// codes returns the code of each field's error
+func codes(errs Errors) map[string]string {
+	codes := map[string]string{}
+	for _, fieldErr := range errs {
+		codes[fieldErr.Field] = fieldErr.Code
+	}
+	return codes
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want map[string]string // Field errors by field; nil for none
+	}{
+		{"valid", `{"title":"abc","note":"ok","tags":["a","abc"],"events":["a","b"],"items":[{"op":"create"}],"email":"me@example.com","url":"https://example.com/hook"}`, nil},
+		{"missing required", `{}`, map[string]string{"title": CodeRequired}},
+		{"blank required", `{"title":"   "}`, map[string]string{"title": CodeRequired}},
+		{"too long in characters", `{"title":"ééééé"}`, nil},
+		{"too long", `{"title":"abcdef"}`, map[string]string{"title": CodeTooLong}},
+		{"omitempty skips empty", `{"title":"a","note":""}`, nil},
+		{"too short", `{"title":"a","note":"x"}`, map[string]string{"note": CodeTooShort}},
+		{"too many bytes", `{"title":"a","password":"éé€"}`, map[string]string{"password": CodeTooLong}},
+		{"invalid email", `{"title":"a","email":"Me <me@example.com>"}`, map[string]string{"email": CodeEmail}},
+		{"invalid url", `{"title":"a","url":"ftp://example.com"}`, map[string]string{"url": CodeURL}},
+		{"too many items", `{"title":"a","tags":["a","b","c"]}`, map[string]string{"tags": CodeTooLong}},
+		{"item too long", `{"title":"a","tags":["a","abcd"]}`, map[string]string{"tags[1]": CodeTooLong}},
+		{"item not one of", `{"title":"a","events":["a","c"]}`, map[string]string{"events[1]": CodeInvalidEnum}},
+		{"nested struct", `{"title":"a","items":[{"op":"create"},{"op":"frob"},{}]}`, map[string]string{"items[1].op": CodeInvalidEnum, "items[2].op": CodeRequired}},
+		{"wrong type", `{"title":"a","count":"three"}`, map[string]string{"count": CodeInvalidType}},
+		{"unknown field", `{"title":"a","colour":"red"}`, map[string]string{"colour": CodeUnknown}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p payload
+			err := Decode(strings.NewReader(tt.body), &p)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("got %v", err)
+				}
+				return
+			}
+			var errs Errors
+			if !errors.As(err, &errs) {
+				t.Fatalf("got %v, want field errors", err)
+			}
+			if got := codes(errs); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	for _, body := range []string{``, `{`, `not json`} {
+		var p payload
+		err := Decode(strings.NewReader(body), &p)
+		if !errors.Is(err, ErrMalformed) {
+			t.Errorf("Decode(%q): got %v, want ErrMalformed", body, err)
+		}
+	}
+}
+
+func TestMessages(t *testing.T) {
+	title := "abcdef"
+	errs := Struct(&payload{Title: &title, Tags: []string{"abcd"}})
+	want := "title: must be at most 5 characters; tags[0]: must be at most 3 characters"
+	if errs.Error() != want {
+		t.Errorf("Error() = %q, want %q", errs.Error(), want)
+	}
+}
+
+func TestUnknownRulePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("an unknown rule didn't panic")
+		}
+	}()
+	Struct(struct {
+		Name string `json:"name" validate:"frobnicate"`
+	}{})
+}
@@ -0,0 +1,190 @@
+// Package webhooks signs and sends webhook deliveries, and decides when failed
+// deliveries are retried
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Delivery headers
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+)
+
+// Retry policy
+const (
+	// MaxAttempts is how many times a delivery is tried before it fails for good
+	MaxAttempts = 10
+	// DisableAfter is how many failed attempts in a row disable a webhook
+	DisableAfter = 20
+
+	baseRetryDelay = 30 * time.Second
+	maxRetryDelay  = 6 * time.Hour
+)
+
+// SendTimeout is how long an endpoint has to respond, which bounds how long
+// Send takes
+const SendTimeout = 10 * time.Second
+
+// RetryDelay returns how long to wait before retrying a delivery that has
+// failed the given number of times: 30 seconds, doubling after every failure,
+// up to 6 hours
+func RetryDelay(attempts int) time.Duration {
+	delay := baseRetryDelay
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay
+}
+
+// NewSecret generates a signing secret for a new webhook
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// Sign returns the signature header value for a payload sent at a time:
+// "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<payload>">". Including the
+// timestamp lets receivers reject replayed deliveries.
+func Sign(secret string, timestamp time.Time, payload []byte) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(t + "."))
+	mac.Write(payload)
+	return "t=" + t + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// ErrPrivateAddress is returned when an endpoint resolves to an address on
+// a private network and those aren't allowed
+var ErrPrivateAddress = errors.New("endpoint resolves to a private address")
+
+// Sender posts deliveries to webhook endpoints
+type Sender struct {
+	client *http.Client
+}
+
+// NewSender creates a Sender. Unless allowPrivate is set, endpoints that
+// resolve to loopback, private, link-local or carrier-grade NAT addresses, or
+// other addresses that aren't on the public internet, are refused, so
+// webhooks can't be used to reach services behind the server's firewall.
+func NewSender(allowPrivate bool) *Sender {
+	dialer := &net.Dialer{Timeout: SendTimeout}
+	if !allowPrivate {
+		// The check runs on the resolved address of every connection, so
+		// DNS can't be used to get around it
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || isPrivate(ip) {
+				return ErrPrivateAddress
+			}
+			return nil
+		}
+	}
+
+	return &Sender{
+		client: &http.Client{
+			Timeout: SendTimeout,
+			Transport: &http.Transport{
+				DialContext:         dialer.DialContext,
+				TLSHandshakeTimeout: SendTimeout,
+				MaxIdleConnsPerHost: 2,
+			},
+			// Redirects count as failures rather than being followed somewhere else
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// reservedNetworks are the IPv4 ranges that aren't on the public internet
+// besides the ones net.IP has methods for
+var reservedNetworks = []*net.IPNet{
+	mustParseCIDR("0.0.0.0/8"),     // "This network"
+	mustParseCIDR("100.64.0.0/10"), // Carrier-grade NAT
+	mustParseCIDR("192.0.0.0/24"),  // IETF protocol assignments
+	mustParseCIDR("198.18.0.0/15"), // Benchmarking
+	mustParseCIDR("240.0.0.0/4"),   // Reserved, and the broadcast address
+}
+
+func mustParseCIDR(cidr string) *net.IPNet {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
+
+// isPrivate checks if an address is loopback, private, link-local or
+// otherwise not on the public internet
+func isPrivate(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() {
+		return true
+	}
+	for _, network := range reservedNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Send posts a signed payload to an endpoint. It returns the response's
+// status code, or 0 if there was no response, and an error unless the
+// endpoint responded with a 2xx status.
+func (s *Sender) Send(ctx context.Context, endpoint, secret, deliveryID, eventType string, payload []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "todo-application-webhooks/1.0")
+	req.Header.Set(EventHeader, eventType)
+	req.Header.Set(DeliveryHeader, deliveryID)
+	req.Header.Set(SignatureHeader, Sign(secret, time.Now(), payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		// The delivery log already shows the method and URL net/http adds
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return 0, urlErr.Err
+		}
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// Drain a little of the body so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded with %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,133 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsPrivate(t *testing.T) {
+	tests := []struct {
+		ip      string
+		private bool
+	}{
+		{"127.0.0.1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"0.0.0.0", true},
+		{"0.1.2.3", true},
+		{"100.64.0.1", true},
+		{"100.127.255.255", true},
+		{"198.18.0.1", true},
+		{"255.255.255.255", true},
+		{"::1", true},
+		{"::", true},
+		{"fd00::1", true},
+		{"fe80::1", true},
+		{"::ffff:127.0.0.1", true},
+		{"::ffff:100.64.0.1", true},
+		{"100.63.255.255", false},
+		{"100.128.0.0", false},
+		{"93.184.216.34", false},
+		{"2606:4700::1111", false},
+	}
+	for _, tt := range tests {
+		if got := isPrivate(net.ParseIP(tt.ip)); got != tt.private {
+			t.Errorf("%s: got %v, want %v", tt.ip, got, tt.private)
+		}
+	}
+}
+
+func TestSign(t *testing.T) {
+	// Receivers check the signature by computing the same HMAC
+	timestamp := time.Unix(1700000000, 0)
+	payload := []byte(`{"type":"todo.created"}`)
+
+	mac := hmac.New(sha256.New, []byte("whsec_test"))
+	mac.Write([]byte("1700000000."))
+	mac.Write(payload)
+	want := "t=1700000000,v1=" + hex.EncodeToString(mac.Sum(nil))
+
+	if got := Sign("whsec_test", timestamp, payload); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if Sign("whsec_other", timestamp, payload) == want {
+		t.Error("signature doesn't depend on the secret")
+	}
+	if Sign("whsec_test", timestamp.Add(time.Second), payload) == want {
+		t.Error("signature doesn't depend on the timestamp")
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		delay    time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{10, 256 * time.Minute},
+		{11, 6 * time.Hour}, // 30s * 2^10 is over the cap
+		{100, 6 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := RetryDelay(tt.attempts); got != tt.delay {
+			t.Errorf("after %d attempts: got %s, want %s", tt.attempts, got, tt.delay)
+		}
+	}
+}
+
+func TestSend(t *testing.T) {
+	var got *http.Request
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+		if r.Header.Get(EventHeader) == "todo.deleted" {
+			w.WriteHeader(http.StatusGone)
+		}
+	}))
+	defer server.Close()
+
+	// The test server is on loopback, so it's refused unless allowed
+	status, err := NewSender(false).Send(context.Background(), server.URL, "whsec_test", "d1", "todo.created", []byte("{}"))
+	if status != 0 || !errors.Is(err, ErrPrivateAddress) {
+		t.Fatalf("got %d %v, want ErrPrivateAddress", status, err)
+	}
+	if got != nil {
+		t.Fatal("refused delivery reached the endpoint")
+	}
+
+	sender := NewSender(true)
+	status, err = sender.Send(context.Background(), server.URL, "whsec_test", "d1", "todo.created", []byte(`{"id":1}`))
+	if err != nil || status != http.StatusOK {
+		t.Fatalf("got %d %v", status, err)
+	}
+	if string(body) != `{"id":1}` || got.Header.Get(DeliveryHeader) != "d1" || got.Header.Get(EventHeader) != "todo.created" {
+		t.Errorf("sent %q with headers %v", body, got.Header)
+	}
+	signature := got.Header.Get(SignatureHeader)
+	timestamp, err := strconv.ParseInt(strings.TrimPrefix(strings.Split(signature, ",")[0], "t="), 10, 64)
+	if err != nil || signature != Sign("whsec_test", time.Unix(timestamp, 0), body) {
+		t.Errorf("bad signature %q", signature)
+	}
+
+	// Non-2xx responses are failures
+	status, err = sender.Send(context.Background(), server.URL, "whsec_test", "d2", "todo.deleted", []byte("{}"))
+	if status != http.StatusGone || err == nil {
+		t.Errorf("got %d %v, want 410 and an error", status, err)
+	}
+}